@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/milad-ahmd/go-clean-arch/internal/delivery/http"
+	"github.com/milad-ahmd/go-clean-arch/internal/payment"
 	"github.com/milad-ahmd/go-clean-arch/internal/repository/postgres"
 	"github.com/milad-ahmd/go-clean-arch/internal/usecase"
 	"github.com/milad-ahmd/go-clean-arch/pkg/auth"
@@ -23,6 +24,15 @@ func main() {
 
 	// Initialize logger
 	log := logger.NewLogger(cfg.Logger.Level)
+
+	// Run the migrate subcommand instead of the server when requested
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := postgres.RunMigrateCommand(context.Background(), cfg, os.Args[2:], os.Stdout, log); err != nil {
+			log.Fatal("Migration command failed", zap.Error(err))
+		}
+		return
+	}
+
 	log.Info("Starting application")
 
 	// Connect to database
@@ -32,9 +42,11 @@ func main() {
 	}
 	defer db.Close()
 
-	// Create tables
-	if err := postgres.InitTables(db, log); err != nil {
-		log.Fatal("Failed to create tables", zap.Error(err))
+	// Apply pending migrations
+	if cfg.Database.AutoMigrate {
+		if err := postgres.Migrate(context.Background(), db, log); err != nil {
+			log.Fatal("Failed to run database migrations", zap.Error(err))
+		}
 	}
 
 	// Initialize repositories
@@ -42,26 +54,57 @@ func main() {
 	categoryRepo := postgres.NewCategoryRepository(db, log)
 	productRepo := postgres.NewProductRepository(db, log)
 	orderRepo := postgres.NewOrderRepository(db, log)
+	refreshTokenRepo := postgres.NewRefreshTokenRepository(db, log)
+	revokedTokenRepo := postgres.NewRevokedTokenRepository(db, log)
+	roleRepo := postgres.NewRoleRepository(db, log)
+	cartRepo := postgres.NewCartRepository(db, log)
+	couponRepo := postgres.NewCouponRepository(db, log)
+	paymentRepo := postgres.NewPaymentRepository(db, log)
+	refundRepo := postgres.NewRefundRepository(db, log)
+	returnRepo := postgres.NewReturnRepository(db, log)
+	idempotencyRepo := postgres.NewIdempotencyRepository(db, log)
 
 	// Initialize services
-	jwtService := auth.NewJWTService(cfg.Auth.JWTSecret, log)
+	keySet, err := auth.LoadKeySet(cfg.Auth)
+	if err != nil {
+		log.Fatal("Failed to load JWT signing keys", zap.Error(err))
+	}
+	jwtService := auth.NewJWTService(keySet, cfg.Auth.AccessTokenTTL, cfg.Auth.RefreshTokenTTL, revokedTokenRepo, log)
+	paymentGateway, err := payment.NewGateway(cfg.Payment)
+	if err != nil {
+		log.Fatal("Failed to create payment gateway", zap.Error(err))
+	}
 
 	// Initialize use cases
-	userUseCase := usecase.NewUserUseCase(userRepo, jwtService, log)
+	userUseCase := usecase.NewUserUseCase(userRepo, refreshTokenRepo, roleRepo, jwtService, log)
+	roleUseCase := usecase.NewRoleUseCase(roleRepo, userRepo, log)
 	categoryUseCase := usecase.NewCategoryUseCase(categoryRepo, log)
 	productUseCase := usecase.NewProductUseCase(productRepo, categoryRepo, log)
-	orderUseCase := usecase.NewOrderUseCase(orderRepo, productRepo, userRepo, log)
+	paymentUseCase := usecase.NewPaymentUseCase(paymentRepo, orderRepo, paymentGateway, cfg.Payment.Timeout, log)
+	orderUseCase := usecase.NewOrderUseCase(orderRepo, productRepo, userRepo, categoryRepo, couponRepo, paymentUseCase, log)
+	cartUseCase := usecase.NewCartUseCase(cartRepo, productRepo, orderUseCase, log)
+	couponUseCase := usecase.NewCouponUseCase(couponRepo, log)
+	refundUseCase := usecase.NewRefundUseCase(refundRepo, paymentRepo, orderRepo, paymentGateway, cfg.Payment.Timeout, log)
+	returnUseCase := usecase.NewReturnUseCase(returnRepo, orderRepo, log)
 
 	// Initialize HTTP server
 	server := http.NewServer(cfg, log)
 	server.SetupMiddleware()
+	server.UseIdempotency(idempotencyRepo, userUseCase, cfg.Idempotency.TTL)
 
 	// Register HTTP handlers
 	http.NewUserHandler(server.Router(), userUseCase, log)
-	http.NewAuthHandler(server.Router(), userUseCase, log)
-	http.NewCategoryHandler(server.Router(), categoryUseCase, log)
-	http.NewProductHandler(server.Router(), productUseCase, log)
-	http.NewOrderHandler(server.Router(), orderUseCase, log)
+	http.NewRoleHandler(server.Router(), roleUseCase, userUseCase, log)
+	http.NewAuthHandler(server.Router(), userUseCase, cartUseCase, log)
+	http.NewJWKSHandler(server.Router(), keySet, log)
+	http.NewCategoryHandler(server.Router(), categoryUseCase, userUseCase, log)
+	http.NewProductHandler(server.Router(), productUseCase, userUseCase, log)
+	http.NewOrderHandler(server.Router(), orderUseCase, userUseCase, log)
+	http.NewCartHandler(server.Router(), cartUseCase, userUseCase, log)
+	http.NewCouponHandler(server.Router(), couponUseCase, userUseCase, log)
+	http.NewPaymentHandler(server.Router(), paymentUseCase, orderUseCase, userUseCase, log)
+	http.NewRefundHandler(server.Router(), refundUseCase, orderUseCase, userUseCase, log)
+	http.NewReturnHandler(server.Router(), returnUseCase, orderUseCase, userUseCase, log)
 
 	// Setup Swagger
 	swagger.SetupSwagger(server.Router())
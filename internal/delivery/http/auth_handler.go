@@ -7,68 +7,92 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
 	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"github.com/milad-ahmd/go-clean-arch/pkg/response"
 	"go.uber.org/zap"
 )
 
 // AuthHandler handles HTTP requests for authentication
 type AuthHandler struct {
 	userUseCase domain.UserUseCase
+	cartUseCase domain.CartUseCase
 	logger      logger.Logger
 }
 
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(r *mux.Router, userUseCase domain.UserUseCase, logger logger.Logger) {
+func NewAuthHandler(r *mux.Router, userUseCase domain.UserUseCase, cartUseCase domain.CartUseCase, logger logger.Logger) {
 	handler := &AuthHandler{
 		userUseCase: userUseCase,
+		cartUseCase: cartUseCase,
 		logger:      logger,
 	}
 
 	r.HandleFunc("/auth/login", handler.Login).Methods("POST")
 	r.HandleFunc("/auth/register", handler.Register).Methods("POST")
 	r.HandleFunc("/auth/me", handler.Me).Methods("GET")
+	r.HandleFunc("/auth/refresh", handler.Refresh).Methods("POST")
+	r.HandleFunc("/auth/logout", handler.Logout).Methods("POST")
 }
 
 // Login handles user login
 // @Summary Login user
-// @Description Login user and get JWT token
+// @Description Login user and get a JWT access token and a refresh token. An anonymous cart named by X-Cart-Token is merged into the user's cart.
 // @Tags auth
 // @Accept json
 // @Produce json
+// @Param X-Cart-Token header string false "Anonymous cart token"
 // @Param request body domain.LoginRequest true "Login Request"
 // @Success 200 {object} domain.TokenResponse
-// @Failure 400 {object} map[string]string
-// @Failure 401 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var loginReq domain.LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&loginReq); err != nil {
 		h.logger.Error("Failed to decode login request", zap.Error(err))
-		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
 		return
 	}
 	defer r.Body.Close()
 
-	// Validate login request
-	if loginReq.Email == "" || loginReq.Password == "" {
-		respondWithError(w, http.StatusBadRequest, "Email and password are required")
+	if !validateRequest(w, r, &loginReq) {
 		return
 	}
 
 	// Login user
-	token, err := h.userUseCase.Login(r.Context(), loginReq.Email, loginReq.Password)
+	tokens, err := h.userUseCase.Login(r.Context(), loginReq.Email, loginReq.Password)
 	if err != nil {
 		h.logger.Error("Failed to login user", zap.String("email", loginReq.Email), zap.Error(err))
 		if err == domain.ErrUnauthorized {
-			respondWithError(w, http.StatusUnauthorized, "Invalid email or password")
+			response.Problem(w, r, errors.NewUnauthorizedError("Invalid email or password"))
 			return
 		}
-		respondWithError(w, http.StatusInternalServerError, "Failed to login")
+		response.Problem(w, r, err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, domain.TokenResponse{Token: token})
+	if cartToken := r.Header.Get(domain.CartTokenHeader); cartToken != "" {
+		h.mergeCart(r, tokens.Token, cartToken)
+	}
+
+	respondWithJSON(w, http.StatusOK, tokens)
+}
+
+// mergeCart moves the anonymous cart into the cart of the user who just
+// logged in. A failed merge leaves the anonymous cart as it was and does
+// not fail the login.
+func (h *AuthHandler) mergeCart(r *http.Request, accessToken, cartToken string) {
+	user, err := h.userUseCase.ValidateToken(r.Context(), accessToken)
+	if err != nil {
+		h.logger.Error("Failed to validate token for cart merge", zap.Error(err))
+		return
+	}
+
+	if err := h.cartUseCase.Merge(r.Context(), cartToken, user.ID); err != nil {
+		h.logger.Error("Failed to merge cart at login", zap.Int64("userID", user.ID), zap.Error(err))
+	}
 }
 
 // Register handles user registration
@@ -79,22 +103,20 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param request body domain.RegisterRequest true "Register Request"
 // @Success 201 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 409 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /auth/register [post]
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var registerReq domain.RegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&registerReq); err != nil {
 		h.logger.Error("Failed to decode register request", zap.Error(err))
-		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
 		return
 	}
 	defer r.Body.Close()
 
-	// Validate register request
-	if registerReq.Username == "" || registerReq.Email == "" || registerReq.Password == "" {
-		respondWithError(w, http.StatusBadRequest, "Username, email, and password are required")
+	if !validateRequest(w, r, &registerReq) {
 		return
 	}
 
@@ -108,11 +130,7 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 
 	if err := h.userUseCase.Register(r.Context(), user); err != nil {
 		h.logger.Error("Failed to register user", zap.String("email", registerReq.Email), zap.Error(err))
-		if _, ok := err.(*domain.ConflictError); ok {
-			respondWithError(w, http.StatusConflict, err.Error())
-			return
-		}
-		respondWithError(w, http.StatusInternalServerError, "Failed to register user")
+		response.Problem(w, r, err)
 		return
 	}
 
@@ -132,33 +150,129 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Security BearerAuth
 // @Success 200 {object} domain.User
-// @Failure 401 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /auth/me [get]
 func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
-	// Get token from Authorization header
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		respondWithError(w, http.StatusUnauthorized, "Authorization header is required")
-		return
-	}
-
-	// Check if the header has the Bearer prefix
-	if !strings.HasPrefix(authHeader, "Bearer ") {
-		respondWithError(w, http.StatusUnauthorized, "Invalid authorization header format")
+	token, ok := bearerToken(w, r)
+	if !ok {
 		return
 	}
 
-	// Extract the token
-	token := strings.TrimPrefix(authHeader, "Bearer ")
-
 	// Validate token and get user
 	user, err := h.userUseCase.ValidateToken(r.Context(), token)
 	if err != nil {
 		h.logger.Error("Failed to validate token", zap.Error(err))
-		respondWithError(w, http.StatusUnauthorized, "Invalid or expired token")
+		response.Problem(w, r, errors.NewUnauthorizedError("Invalid or expired token"))
 		return
 	}
 
 	respondWithJSON(w, http.StatusOK, user)
 }
+
+// Refresh handles refresh token rotation
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access and refresh token pair
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body domain.RefreshRequest true "Refresh Request"
+// @Success 200 {object} domain.TokenResponse
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /auth/refresh [post]
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var refreshReq domain.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&refreshReq); err != nil {
+		h.logger.Error("Failed to decode refresh request", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+		return
+	}
+	defer r.Body.Close()
+
+	if !validateRequest(w, r, &refreshReq) {
+		return
+	}
+
+	tokens, err := h.userUseCase.Refresh(r.Context(), refreshReq.RefreshToken)
+	if err != nil {
+		h.logger.Error("Failed to refresh token", zap.Error(err))
+		if err == domain.ErrUnauthorized {
+			response.Problem(w, r, errors.NewUnauthorizedError("Invalid or expired refresh token"))
+			return
+		}
+		response.Problem(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, tokens)
+}
+
+// Logout handles user logout
+// @Summary Logout user
+// @Description Revoke the current access token and its refresh token, or every session of the user
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body domain.LogoutRequest false "Logout Request"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+
+	// The body is optional; an empty body only revokes the access token
+	var logoutReq domain.LogoutRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&logoutReq); err != nil {
+			h.logger.Error("Failed to decode logout request", zap.Error(err))
+			response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+			return
+		}
+	}
+	defer r.Body.Close()
+
+	if err := h.userUseCase.Logout(r.Context(), token, &logoutReq); err != nil {
+		h.logger.Error("Failed to logout user", zap.Error(err))
+		switch err {
+		case domain.ErrUnauthorized:
+			response.Problem(w, r, errors.NewUnauthorizedError("Invalid or expired token"))
+		case domain.ErrForbidden:
+			response.Problem(w, r, errors.NewForbiddenError("Refresh token does not belong to the current user"))
+		default:
+			response.Problem(w, r, err)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"message": "Logged out successfully",
+	})
+}
+
+// bearerToken extracts the bearer token from the Authorization header and
+// writes a 401 response when it is missing or malformed
+func bearerToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	// Get token from Authorization header
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		response.Problem(w, r, errors.NewUnauthorizedError("Authorization header is required"))
+		return "", false
+	}
+
+	// Check if the header has the Bearer prefix
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		response.Problem(w, r, errors.NewUnauthorizedError("Invalid authorization header format"))
+		return "", false
+	}
+
+	// Extract the token
+	return strings.TrimPrefix(authHeader, "Bearer "), true
+}
@@ -0,0 +1,241 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"github.com/milad-ahmd/go-clean-arch/pkg/middleware"
+	"github.com/milad-ahmd/go-clean-arch/pkg/response"
+	"go.uber.org/zap"
+)
+
+// CartHandler handles HTTP requests for shopping carts
+type CartHandler struct {
+	cartUseCase domain.CartUseCase
+	logger      logger.Logger
+}
+
+// NewCartHandler creates a new cart handler
+func NewCartHandler(r *mux.Router, cartUseCase domain.CartUseCase, userUseCase domain.UserUseCase, logger logger.Logger) {
+	handler := &CartHandler{
+		cartUseCase: cartUseCase,
+		logger:      logger,
+	}
+
+	// Signed-in users get their own cart, anonymous visitors the cart of
+	// their X-Cart-Token
+	cart := r.PathPrefix("/cart").Subrouter()
+	cart.Use(mux.MiddlewareFunc(middleware.OptionalAuth(userUseCase, logger)))
+	cart.HandleFunc("", handler.Get).Methods("GET")
+	cart.HandleFunc("/items", handler.AddItem).Methods("POST")
+	cart.HandleFunc("/items/{itemID:[0-9]+}", handler.UpdateItem).Methods("PUT")
+	cart.HandleFunc("/items/{itemID:[0-9]+}", handler.RemoveItem).Methods("DELETE")
+
+	// Protected routes (require authentication)
+	r.Handle("/cart/checkout", middleware.Chain(
+		http.HandlerFunc(handler.Checkout),
+		middleware.Auth(userUseCase, logger),
+	)).Methods("POST")
+}
+
+// Get handles getting the current cart
+// @Summary Get cart
+// @Description Get the current cart with prices and stock revalidated; changes are listed as issues
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Param X-Cart-Token header string false "Anonymous cart token"
+// @Success 200 {object} response.Response{data=domain.Cart}
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /cart [get]
+func (h *CartHandler) Get(w http.ResponseWriter, r *http.Request) {
+	cart, err := h.cartUseCase.Get(r.Context(), cartOwner(r))
+	if err != nil {
+		h.logger.Error("Failed to get cart", zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Cart retrieved successfully", cart, http.StatusOK)
+}
+
+// AddItem handles adding a product to the cart
+// @Summary Add cart item
+// @Description Add a product or variant to the cart. Without a cart token a new anonymous cart is created and its token returned in the X-Cart-Token header.
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Param X-Cart-Token header string false "Anonymous cart token"
+// @Param request body domain.CartItemCreateDTO true "Cart Item Create Request"
+// @Success 200 {object} response.Response{data=domain.Cart}
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /cart/items [post]
+func (h *CartHandler) AddItem(w http.ResponseWriter, r *http.Request) {
+	var createDTO domain.CartItemCreateDTO
+	if err := json.NewDecoder(r.Body).Decode(&createDTO); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+		return
+	}
+	defer r.Body.Close()
+
+	if !validateRequest(w, r, &createDTO) {
+		return
+	}
+
+	cart, err := h.cartUseCase.AddItem(r.Context(), cartOwner(r), &createDTO)
+	if err != nil {
+		h.logger.Error("Failed to add cart item", zap.Int64("productID", createDTO.ProductID), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	if cart.Token != "" {
+		w.Header().Set(domain.CartTokenHeader, cart.Token)
+	}
+
+	response.Success(w, "Cart item added successfully", cart, http.StatusOK)
+}
+
+// UpdateItem handles changing the quantity of a cart item
+// @Summary Update cart item
+// @Description Change the quantity of a cart item
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Param X-Cart-Token header string false "Anonymous cart token"
+// @Param itemID path int true "Cart Item ID"
+// @Param request body domain.CartItemUpdateDTO true "Cart Item Update Request"
+// @Success 200 {object} response.Response{data=domain.Cart}
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /cart/items/{itemID} [put]
+func (h *CartHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
+	itemID, ok := cartItemID(w, r)
+	if !ok {
+		return
+	}
+
+	var updateDTO domain.CartItemUpdateDTO
+	if err := json.NewDecoder(r.Body).Decode(&updateDTO); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+		return
+	}
+	defer r.Body.Close()
+
+	if !validateRequest(w, r, &updateDTO) {
+		return
+	}
+
+	cart, err := h.cartUseCase.UpdateItem(r.Context(), cartOwner(r), itemID, &updateDTO)
+	if err != nil {
+		h.logger.Error("Failed to update cart item", zap.Int64("itemID", itemID), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Cart item updated successfully", cart, http.StatusOK)
+}
+
+// RemoveItem handles removing an item from the cart
+// @Summary Remove cart item
+// @Description Remove an item from the cart
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Param X-Cart-Token header string false "Anonymous cart token"
+// @Param itemID path int true "Cart Item ID"
+// @Success 200 {object} response.Response{data=domain.Cart}
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /cart/items/{itemID} [delete]
+func (h *CartHandler) RemoveItem(w http.ResponseWriter, r *http.Request) {
+	itemID, ok := cartItemID(w, r)
+	if !ok {
+		return
+	}
+
+	cart, err := h.cartUseCase.RemoveItem(r.Context(), cartOwner(r), itemID)
+	if err != nil {
+		h.logger.Error("Failed to remove cart item", zap.Int64("itemID", itemID), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Cart item removed successfully", cart, http.StatusOK)
+}
+
+// Checkout handles turning the cart into an order
+// @Summary Check out cart
+// @Description Create an order from the signed-in user's cart and empty the cart. A cart whose prices or stock changed is refused with its issues so the customer can review them.
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body domain.CartCheckoutDTO true "Cart Checkout Request"
+// @Success 201 {object} response.Response{data=domain.Order}
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /cart/checkout [post]
+func (h *CartHandler) Checkout(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Problem(w, r, errors.NewUnauthorizedError(""))
+		return
+	}
+
+	var checkoutDTO domain.CartCheckoutDTO
+	if err := json.NewDecoder(r.Body).Decode(&checkoutDTO); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+		return
+	}
+	defer r.Body.Close()
+
+	if !validateRequest(w, r, &checkoutDTO) {
+		return
+	}
+
+	order, err := h.cartUseCase.Checkout(r.Context(), user.ID, &checkoutDTO)
+	if err != nil {
+		h.logger.Error("Failed to check out cart", zap.Int64("userID", user.ID), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Order created successfully", order, http.StatusCreated)
+}
+
+// cartOwner identifies the cart of a request by its user, or by its cart
+// token when the request is anonymous
+func cartOwner(r *http.Request) domain.CartOwner {
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		return domain.CartOwner{UserID: user.ID}
+	}
+	return domain.CartOwner{Token: r.Header.Get(domain.CartTokenHeader)}
+}
+
+// cartItemID parses the cart item ID of the request path, writing a problem
+// response when it is invalid
+func cartItemID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	itemID, err := strconv.ParseInt(mux.Vars(r)["itemID"], 10, 64)
+	if err != nil {
+		response.Problem(w, r, errors.NewBadRequestError("Invalid cart item ID"))
+		return 0, false
+	}
+	return itemID, true
+}
@@ -20,19 +20,25 @@ type CategoryHandler struct {
 }
 
 // NewCategoryHandler creates a new category handler
-func NewCategoryHandler(r *mux.Router, categoryUseCase domain.CategoryUseCase, logger logger.Logger) {
+func NewCategoryHandler(r *mux.Router, categoryUseCase domain.CategoryUseCase, userUseCase domain.UserUseCase, logger logger.Logger) {
 	handler := &CategoryHandler{
 		categoryUseCase: categoryUseCase,
 		logger:          logger,
 	}
 
-	// Register routes
-	r.HandleFunc("/categories", handler.Create).Methods("POST")
+	// Public routes
 	r.HandleFunc("/categories", handler.List).Methods("GET")
 	r.HandleFunc("/categories/{id:[0-9]+}", handler.GetByID).Methods("GET")
-	r.HandleFunc("/categories/{id:[0-9]+}", handler.Update).Methods("PUT")
-	r.HandleFunc("/categories/{id:[0-9]+}", handler.Delete).Methods("DELETE")
 	r.HandleFunc("/categories/slug/{slug}", handler.GetBySlug).Methods("GET")
+	r.HandleFunc("/categories/tree", handler.GetTree).Methods("GET")
+	r.HandleFunc("/categories/{id:[0-9]+}/tree", handler.GetSubtree).Methods("GET")
+	r.HandleFunc("/categories/{id:[0-9]+}/breadcrumbs", handler.GetBreadcrumbs).Methods("GET")
+
+	// Protected routes
+	r.Handle("/categories", requirePermission(handler.Create, userUseCase, logger, domain.PermissionCategoryWrite)).Methods("POST")
+	r.Handle("/categories/{id:[0-9]+}", requirePermission(handler.Update, userUseCase, logger, domain.PermissionCategoryWrite)).Methods("PUT")
+	r.Handle("/categories/{id:[0-9]+}", requirePermission(handler.Delete, userUseCase, logger, domain.PermissionCategoryWrite)).Methods("DELETE")
+	r.Handle("/categories/{id:[0-9]+}/parent", requirePermission(handler.Move, userUseCase, logger, domain.PermissionCategoryWrite)).Methods("PUT")
 }
 
 // Create handles the creation of a new category
@@ -43,27 +49,32 @@ func NewCategoryHandler(r *mux.Router, categoryUseCase domain.CategoryUseCase, l
 // @Produce json
 // @Param request body domain.CategoryCreateDTO true "Category Create Request"
 // @Success 201 {object} response.Response{data=domain.Category}
-// @Failure 400 {object} response.Response
-// @Failure 409 {object} response.Response
-// @Failure 500 {object} response.Response
+// @Header 201 {string} ETag "Entity tag of the category version"
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /categories [post]
 func (h *CategoryHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var createDTO domain.CategoryCreateDTO
 	if err := json.NewDecoder(r.Body).Decode(&createDTO); err != nil {
 		h.logger.Error("Failed to decode request body", zap.Error(err))
-		response.Error(w, "Invalid request payload", errors.NewBadRequestError("Invalid request payload"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
 		return
 	}
 	defer r.Body.Close()
 
+	if !validateRequest(w, r, &createDTO) {
+		return
+	}
+
 	category, err := h.categoryUseCase.Create(r.Context(), &createDTO)
 	if err != nil {
 		h.logger.Error("Failed to create category", zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to create category", err, statusCode)
+		response.Problem(w, r, err)
 		return
 	}
 
+	setETag(w, category.Version)
 	response.Success(w, "Category created successfully", category, http.StatusCreated)
 }
 
@@ -75,68 +86,83 @@ func (h *CategoryHandler) Create(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param id path int true "Category ID"
 // @Success 200 {object} response.Response{data=domain.Category}
-// @Failure 404 {object} response.Response
-// @Failure 500 {object} response.Response
+// @Header 200 {string} ETag "Entity tag of the category version"
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /categories/{id} [get]
 func (h *CategoryHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
 		h.logger.Error("Failed to parse category ID", zap.Error(err))
-		response.Error(w, "Invalid category ID", errors.NewBadRequestError("Invalid category ID"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid category ID"))
 		return
 	}
 
 	category, err := h.categoryUseCase.GetByID(r.Context(), id)
 	if err != nil {
 		h.logger.Error("Failed to get category", zap.Int64("id", id), zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to get category", err, statusCode)
+		response.Problem(w, r, err)
 		return
 	}
 
+	setETag(w, category.Version)
 	response.Success(w, "Category retrieved successfully", category, http.StatusOK)
 }
 
 // Update handles updating a category
 // @Summary Update category
-// @Description Update a category by its ID
+// @Description Update a category by its ID. With an If-Match header, the update only applies while the category is still at that entity tag.
 // @Tags categories
 // @Accept json
 // @Produce json
 // @Param id path int true "Category ID"
+// @Param If-Match header string false "Entity tag of the category version being updated"
 // @Param request body domain.CategoryUpdateDTO true "Category Update Request"
 // @Success 200 {object} response.Response{data=domain.Category}
-// @Failure 400 {object} response.Response
-// @Failure 404 {object} response.Response
-// @Failure 409 {object} response.Response
-// @Failure 500 {object} response.Response
+// @Header 200 {string} ETag "Entity tag of the updated category version"
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 412 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /categories/{id} [put]
 func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
 		h.logger.Error("Failed to parse category ID", zap.Error(err))
-		response.Error(w, "Invalid category ID", errors.NewBadRequestError("Invalid category ID"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid category ID"))
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		response.Problem(w, r, err)
 		return
 	}
 
 	var updateDTO domain.CategoryUpdateDTO
 	if err := json.NewDecoder(r.Body).Decode(&updateDTO); err != nil {
 		h.logger.Error("Failed to decode request body", zap.Error(err))
-		response.Error(w, "Invalid request payload", errors.NewBadRequestError("Invalid request payload"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
 		return
 	}
 	defer r.Body.Close()
 
+	if !validateRequest(w, r, &updateDTO) {
+		return
+	}
+	updateDTO.Version = version
+
 	category, err := h.categoryUseCase.Update(r.Context(), id, &updateDTO)
 	if err != nil {
 		h.logger.Error("Failed to update category", zap.Int64("id", id), zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to update category", err, statusCode)
+		response.Problem(w, r, preconditionFailed(err, version))
 		return
 	}
 
+	setETag(w, category.Version)
 	response.Success(w, "Category updated successfully", category, http.StatusOK)
 }
 
@@ -148,22 +174,22 @@ func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param id path int true "Category ID"
 // @Success 200 {object} response.Response
-// @Failure 404 {object} response.Response
-// @Failure 500 {object} response.Response
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /categories/{id} [delete]
 func (h *CategoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
 		h.logger.Error("Failed to parse category ID", zap.Error(err))
-		response.Error(w, "Invalid category ID", errors.NewBadRequestError("Invalid category ID"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid category ID"))
 		return
 	}
 
 	if err := h.categoryUseCase.Delete(r.Context(), id); err != nil {
 		h.logger.Error("Failed to delete category", zap.Int64("id", id), zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to delete category", err, statusCode)
+		response.Problem(w, r, err)
 		return
 	}
 
@@ -176,34 +202,29 @@ func (h *CategoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
 // @Tags categories
 // @Accept json
 // @Produce json
-// @Param page query int false "Page number"
+// @Param page query int false "Page number (offset pagination)"
 // @Param per_page query int false "Items per page"
+// @Param after query string false "Cursor of the page to read after (cursor pagination)"
+// @Param before query string false "Cursor of the page to read before (cursor pagination)"
+// @Param total query bool false "Set to false to skip counting the total"
 // @Success 200 {object} response.PaginatedResponse{data=[]domain.Category}
-// @Failure 500 {object} response.Response
+// @Failure 500 {object} response.ProblemDetails
 // @Router /categories [get]
 func (h *CategoryHandler) List(w http.ResponseWriter, r *http.Request) {
-	// Parse pagination parameters
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
-
-	if page < 1 {
-		page = 1
-	}
-	if perPage < 1 {
-		perPage = 10
+	pageReq, err := parsePageRequest(r)
+	if err != nil {
+		response.Problem(w, r, err)
+		return
 	}
 
-	offset := (page - 1) * perPage
-
-	categories, total, err := h.categoryUseCase.List(r.Context(), perPage, offset)
+	categories, err := h.categoryUseCase.List(r.Context(), pageReq)
 	if err != nil {
 		h.logger.Error("Failed to list categories", zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to list categories", err, statusCode)
+		response.Problem(w, r, err)
 		return
 	}
 
-	response.Paginated(w, "Categories retrieved successfully", categories, page, perPage, total, http.StatusOK)
+	response.Paginated(w, "Categories retrieved successfully", categories.Items, pageMeta(r, pageReq, categories), http.StatusOK)
 }
 
 // GetBySlug handles getting a category by slug
@@ -214,8 +235,9 @@ func (h *CategoryHandler) List(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param slug path string true "Category Slug"
 // @Success 200 {object} response.Response{data=domain.Category}
-// @Failure 404 {object} response.Response
-// @Failure 500 {object} response.Response
+// @Header 200 {string} ETag "Entity tag of the category version"
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /categories/slug/{slug} [get]
 func (h *CategoryHandler) GetBySlug(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -224,10 +246,137 @@ func (h *CategoryHandler) GetBySlug(w http.ResponseWriter, r *http.Request) {
 	category, err := h.categoryUseCase.GetBySlug(r.Context(), slug)
 	if err != nil {
 		h.logger.Error("Failed to get category by slug", zap.String("slug", slug), zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to get category", err, statusCode)
+		response.Problem(w, r, err)
 		return
 	}
 
+	setETag(w, category.Version)
 	response.Success(w, "Category retrieved successfully", category, http.StatusOK)
 }
+
+// GetTree handles getting the whole category tree
+// @Summary Get category tree
+// @Description Get every category arranged as a tree, siblings ordered by name
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=[]domain.CategoryNode}
+// @Failure 500 {object} response.ProblemDetails
+// @Router /categories/tree [get]
+func (h *CategoryHandler) GetTree(w http.ResponseWriter, r *http.Request) {
+	tree, err := h.categoryUseCase.GetTree(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to get category tree", zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Category tree retrieved successfully", tree, http.StatusOK)
+}
+
+// GetSubtree handles getting a category with its descendants
+// @Summary Get category subtree
+// @Description Get a category with its descendants arranged as a tree
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param id path int true "Category ID"
+// @Success 200 {object} response.Response{data=domain.CategoryNode}
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /categories/{id}/tree [get]
+func (h *CategoryHandler) GetSubtree(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.Error("Failed to parse category ID", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid category ID"))
+		return
+	}
+
+	subtree, err := h.categoryUseCase.GetSubtree(r.Context(), id)
+	if err != nil {
+		h.logger.Error("Failed to get category subtree", zap.Int64("id", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Category tree retrieved successfully", subtree, http.StatusOK)
+}
+
+// GetBreadcrumbs handles getting the path from the top level to a category
+// @Summary Get category breadcrumbs
+// @Description Get the categories from the top level down to and including a category
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param id path int true "Category ID"
+// @Success 200 {object} response.Response{data=[]domain.Category}
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /categories/{id}/breadcrumbs [get]
+func (h *CategoryHandler) GetBreadcrumbs(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.Error("Failed to parse category ID", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid category ID"))
+		return
+	}
+
+	breadcrumbs, err := h.categoryUseCase.GetBreadcrumbs(r.Context(), id)
+	if err != nil {
+		h.logger.Error("Failed to get category breadcrumbs", zap.Int64("id", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Category breadcrumbs retrieved successfully", breadcrumbs, http.StatusOK)
+}
+
+// Move handles moving a category to a new parent
+// @Summary Move category
+// @Description Move a category and its descendants under a new parent, or to the top level with a null parent_id. A category cannot be moved under itself or its descendants.
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param id path int true "Category ID"
+// @Param request body domain.CategoryMoveDTO true "Category Move Request"
+// @Success 200 {object} response.Response{data=domain.Category}
+// @Header 200 {string} ETag "Entity tag of the moved category version"
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /categories/{id}/parent [put]
+func (h *CategoryHandler) Move(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.Error("Failed to parse category ID", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid category ID"))
+		return
+	}
+
+	var moveDTO domain.CategoryMoveDTO
+	if err := json.NewDecoder(r.Body).Decode(&moveDTO); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+		return
+	}
+	defer r.Body.Close()
+
+	if !validateRequest(w, r, &moveDTO) {
+		return
+	}
+
+	category, err := h.categoryUseCase.Move(r.Context(), id, &moveDTO)
+	if err != nil {
+		h.logger.Error("Failed to move category", zap.Int64("id", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	setETag(w, category.Version)
+	response.Success(w, "Category moved successfully", category, http.StatusOK)
+}
@@ -0,0 +1,209 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"github.com/milad-ahmd/go-clean-arch/pkg/response"
+	"go.uber.org/zap"
+)
+
+// CouponHandler handles HTTP requests for coupons
+type CouponHandler struct {
+	couponUseCase domain.CouponUseCase
+	logger        logger.Logger
+}
+
+// NewCouponHandler creates a new coupon handler
+func NewCouponHandler(r *mux.Router, couponUseCase domain.CouponUseCase, userUseCase domain.UserUseCase, logger logger.Logger) {
+	handler := &CouponHandler{
+		couponUseCase: couponUseCase,
+		logger:        logger,
+	}
+
+	// Protected routes
+	r.Handle("/coupons", requirePermission(handler.List, userUseCase, logger, domain.PermissionCouponManage)).Methods("GET")
+	r.Handle("/coupons", requirePermission(handler.Create, userUseCase, logger, domain.PermissionCouponManage)).Methods("POST")
+	r.Handle("/coupons/{id:[0-9]+}", requirePermission(handler.GetByID, userUseCase, logger, domain.PermissionCouponManage)).Methods("GET")
+	r.Handle("/coupons/{id:[0-9]+}", requirePermission(handler.Update, userUseCase, logger, domain.PermissionCouponManage)).Methods("PUT")
+	r.Handle("/coupons/{id:[0-9]+}", requirePermission(handler.Delete, userUseCase, logger, domain.PermissionCouponManage)).Methods("DELETE")
+}
+
+// Create handles the creation of a new coupon
+// @Summary Create coupon
+// @Description Create a new percentage or fixed-amount coupon
+// @Tags coupons
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body domain.CouponCreateDTO true "Coupon Create Request"
+// @Success 201 {object} response.Response{data=domain.Coupon}
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /coupons [post]
+func (h *CouponHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var createDTO domain.CouponCreateDTO
+	if err := json.NewDecoder(r.Body).Decode(&createDTO); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+		return
+	}
+	defer r.Body.Close()
+
+	if !validateRequest(w, r, &createDTO) {
+		return
+	}
+
+	coupon, err := h.couponUseCase.Create(r.Context(), &createDTO)
+	if err != nil {
+		h.logger.Error("Failed to create coupon", zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Coupon created successfully", coupon, http.StatusCreated)
+}
+
+// GetByID handles getting a coupon by ID
+// @Summary Get coupon by ID
+// @Description Get a coupon by its ID, with the number of times it has been redeemed
+// @Tags coupons
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Coupon ID"
+// @Success 200 {object} response.Response{data=domain.Coupon}
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /coupons/{id} [get]
+func (h *CouponHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.Error("Failed to parse coupon ID", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid coupon ID"))
+		return
+	}
+
+	coupon, err := h.couponUseCase.GetByID(r.Context(), id)
+	if err != nil {
+		h.logger.Error("Failed to get coupon", zap.Int64("id", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Coupon retrieved successfully", coupon, http.StatusOK)
+}
+
+// Update handles updating a coupon
+// @Summary Update coupon
+// @Description Update a coupon by its ID; its code and type cannot change
+// @Tags coupons
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Coupon ID"
+// @Param request body domain.CouponUpdateDTO true "Coupon Update Request"
+// @Success 200 {object} response.Response{data=domain.Coupon}
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /coupons/{id} [put]
+func (h *CouponHandler) Update(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.Error("Failed to parse coupon ID", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid coupon ID"))
+		return
+	}
+
+	var updateDTO domain.CouponUpdateDTO
+	if err := json.NewDecoder(r.Body).Decode(&updateDTO); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+		return
+	}
+	defer r.Body.Close()
+
+	if !validateRequest(w, r, &updateDTO) {
+		return
+	}
+
+	coupon, err := h.couponUseCase.Update(r.Context(), id, &updateDTO)
+	if err != nil {
+		h.logger.Error("Failed to update coupon", zap.Int64("id", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Coupon updated successfully", coupon, http.StatusOK)
+}
+
+// Delete handles deleting a coupon
+// @Summary Delete coupon
+// @Description Delete a coupon by its ID; orders keep the code and discounts they were placed with
+// @Tags coupons
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Coupon ID"
+// @Success 200 {object} response.Response
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /coupons/{id} [delete]
+func (h *CouponHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.Error("Failed to parse coupon ID", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid coupon ID"))
+		return
+	}
+
+	if err := h.couponUseCase.Delete(r.Context(), id); err != nil {
+		h.logger.Error("Failed to delete coupon", zap.Int64("id", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Coupon deleted successfully", nil, http.StatusOK)
+}
+
+// List handles listing coupons with pagination
+// @Summary List coupons
+// @Description List coupons with pagination
+// @Tags coupons
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (offset pagination)"
+// @Param per_page query int false "Items per page"
+// @Param after query string false "Cursor of the page to read after (cursor pagination)"
+// @Param before query string false "Cursor of the page to read before (cursor pagination)"
+// @Param total query bool false "Set to false to skip counting the total"
+// @Success 200 {object} response.PaginatedResponse{data=[]domain.Coupon}
+// @Failure 500 {object} response.ProblemDetails
+// @Router /coupons [get]
+func (h *CouponHandler) List(w http.ResponseWriter, r *http.Request) {
+	pageReq, err := parsePageRequest(r)
+	if err != nil {
+		response.Problem(w, r, err)
+		return
+	}
+
+	coupons, err := h.couponUseCase.List(r.Context(), pageReq)
+	if err != nil {
+		h.logger.Error("Failed to list coupons", zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Paginated(w, "Coupons retrieved successfully", coupons.Items, pageMeta(r, pageReq, coupons), http.StatusOK)
+}
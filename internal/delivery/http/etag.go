@@ -0,0 +1,54 @@
+package http
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
+)
+
+// setETag sets the ETag header of a response to the entity tag of version.
+// Versioned entities are tagged with their version, so an update sent with
+// the tag in If-Match only applies while the entity is unchanged.
+func setETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, version))
+}
+
+// ifMatchVersion returns the version named by the If-Match header of an
+// update, or zero when the header is absent or "*". If-Match uses strong
+// comparison, so weak tags and tags that name no version can never match
+// and fail the precondition.
+func ifMatchVersion(r *http.Request) (int64, error) {
+	tag := strings.TrimSpace(r.Header.Get("If-Match"))
+	if tag == "" || tag == "*" {
+		return 0, nil
+	}
+	if strings.Contains(tag, ",") {
+		return 0, errors.NewBadRequestError("If-Match must be a single entity tag")
+	}
+
+	unquoted := strings.TrimPrefix(tag, "W/")
+	if len(unquoted) < 2 || unquoted[0] != '"' || unquoted[len(unquoted)-1] != '"' {
+		return 0, errors.NewBadRequestError("If-Match must be a quoted entity tag")
+	}
+
+	version, err := strconv.ParseInt(unquoted[1:len(unquoted)-1], 10, 64)
+	if err != nil || version < 1 || unquoted != tag {
+		return 0, errors.NewPreconditionFailedError("If-Match does not match the current entity tag")
+	}
+	return version, nil
+}
+
+// preconditionFailed turns the version conflict of an update sent with an
+// If-Match version into 412 Precondition Failed. Conflicts of updates sent
+// without one stay 409 Conflict.
+func preconditionFailed(err error, version int64) error {
+	if version != 0 && stderrors.Is(err, domain.ErrVersionConflict) {
+		return errors.NewPreconditionFailedError(err.Error())
+	}
+	return err
+}
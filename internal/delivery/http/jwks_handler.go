@@ -0,0 +1,38 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/milad-ahmd/go-clean-arch/pkg/auth"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+)
+
+// JWKSHandler serves the public keys used to verify access tokens
+type JWKSHandler struct {
+	keys   *auth.KeySet
+	logger logger.Logger
+}
+
+// NewJWKSHandler creates a new JWKS handler
+func NewJWKSHandler(r *mux.Router, keys *auth.KeySet, logger logger.Logger) {
+	handler := &JWKSHandler{
+		keys:   keys,
+		logger: logger,
+	}
+
+	r.HandleFunc("/.well-known/jwks.json", handler.JWKS).Methods("GET")
+}
+
+// JWKS handles serving the JSON Web Key Set
+// @Summary JSON Web Key Set
+// @Description Public keys other services use to verify access tokens
+// @Tags auth
+// @Produce json
+// @Success 200 {object} auth.JWKSet
+// @Router /.well-known/jwks.json [get]
+func (h *JWKSHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	// Verifiers may cache the set briefly; rotations pre-publish keys
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, h.keys.JWKS())
+}
@@ -21,27 +21,31 @@ type OrderHandler struct {
 }
 
 // NewOrderHandler creates a new order handler
-func NewOrderHandler(r *mux.Router, orderUseCase domain.OrderUseCase, logger logger.Logger) {
+func NewOrderHandler(r *mux.Router, orderUseCase domain.OrderUseCase, userUseCase domain.UserUseCase, logger logger.Logger) {
 	handler := &OrderHandler{
 		orderUseCase: orderUseCase,
 		logger:       logger,
 	}
 
-	// Public routes
-	r.HandleFunc("/orders/{id:[0-9]+}", handler.GetByID).Methods("GET")
-
 	// Protected routes (require authentication)
 	protected := r.PathPrefix("/orders").Subrouter()
-	// Note: In a real application, you would need to pass the userUseCase to the Auth middleware
-	// This is a placeholder that will need to be updated when integrating with the actual application
-	// protected.Use(middleware.Auth(userUseCase, logger))
+	protected.Use(mux.MiddlewareFunc(middleware.Auth(userUseCase, logger)))
 	protected.HandleFunc("", handler.Create).Methods("POST")
 	protected.HandleFunc("", handler.List).Methods("GET")
+	protected.HandleFunc("/{id:[0-9]+}", handler.GetByID).Methods("GET")
 	protected.HandleFunc("/{id:[0-9]+}", handler.Update).Methods("PUT")
 	protected.HandleFunc("/{id:[0-9]+}", handler.Delete).Methods("DELETE")
-	protected.HandleFunc("/{id:[0-9]+}/status", handler.UpdateStatus).Methods("PATCH")
+	protected.Handle("/{id:[0-9]+}/status", middleware.Chain(
+		http.HandlerFunc(handler.UpdateStatus),
+		middleware.RequirePermission(domain.PermissionOrderStatus),
+	)).Methods("PATCH")
+	protected.HandleFunc("/{id:[0-9]+}/history", handler.GetStatusHistory).Methods("GET")
+	protected.HandleFunc("/{id:[0-9]+}/items/{itemID:[0-9]+}/cancel", handler.CancelItem).Methods("POST")
 	protected.HandleFunc("/user/{userID:[0-9]+}", handler.GetByUserID).Methods("GET")
-	protected.HandleFunc("/status/{status}", handler.GetByStatus).Methods("GET")
+	protected.Handle("/status/{status}", middleware.Chain(
+		http.HandlerFunc(handler.GetByStatus),
+		middleware.RequirePermission(domain.PermissionOrderRead),
+	)).Methods("GET")
 }
 
 // Create handles the creation of a new order
@@ -53,22 +57,23 @@ func NewOrderHandler(r *mux.Router, orderUseCase domain.OrderUseCase, logger log
 // @Security BearerAuth
 // @Param request body domain.OrderCreateDTO true "Order Create Request"
 // @Success 201 {object} response.Response{data=domain.Order}
-// @Failure 400 {object} response.Response
-// @Failure 401 {object} response.Response
-// @Failure 500 {object} response.Response
+// @Header 201 {string} ETag "Entity tag of the order version"
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /orders [post]
 func (h *OrderHandler) Create(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
 	user, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		response.Error(w, "Unauthorized", errors.NewUnauthorizedError(""), http.StatusUnauthorized)
+		response.Problem(w, r, errors.NewUnauthorizedError(""))
 		return
 	}
 
 	var createDTO domain.OrderCreateDTO
 	if err := json.NewDecoder(r.Body).Decode(&createDTO); err != nil {
 		h.logger.Error("Failed to decode request body", zap.Error(err))
-		response.Error(w, "Invalid request payload", errors.NewBadRequestError("Invalid request payload"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
 		return
 	}
 	defer r.Body.Close()
@@ -76,14 +81,18 @@ func (h *OrderHandler) Create(w http.ResponseWriter, r *http.Request) {
 	// Set the user ID from the authenticated user
 	createDTO.UserID = user.ID
 
+	if !validateRequest(w, r, &createDTO) {
+		return
+	}
+
 	order, err := h.orderUseCase.Create(r.Context(), &createDTO)
 	if err != nil {
 		h.logger.Error("Failed to create order", zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to create order", err, statusCode)
+		response.Problem(w, r, err)
 		return
 	}
 
+	setETag(w, order.Version)
 	response.Success(w, "Order created successfully", order, http.StatusCreated)
 }
 
@@ -93,159 +102,147 @@ func (h *OrderHandler) Create(w http.ResponseWriter, r *http.Request) {
 // @Tags orders
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param id path int true "Order ID"
 // @Success 200 {object} response.Response{data=domain.Order}
-// @Failure 404 {object} response.Response
-// @Failure 500 {object} response.Response
+// @Header 200 {string} ETag "Entity tag of the order version"
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /orders/{id} [get]
 func (h *OrderHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
 		h.logger.Error("Failed to parse order ID", zap.Error(err))
-		response.Error(w, "Invalid order ID", errors.NewBadRequestError("Invalid order ID"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid order ID"))
 		return
 	}
 
-	order, err := h.orderUseCase.GetOrderWithDetails(r.Context(), id)
-	if err != nil {
-		h.logger.Error("Failed to get order", zap.Int64("id", id), zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to get order", err, statusCode)
-		return
-	}
-
-	// Check if the user is authorized to view this order
-	user, ok := middleware.GetUserFromContext(r.Context())
-	if ok && user.ID != order.UserID && user.Role != domain.RoleAdmin {
-		response.Error(w, "Forbidden", errors.NewForbiddenError(""), http.StatusForbidden)
+	order, _, ok := h.authorizeOrder(w, r, id, domain.PermissionOrderRead)
+	if !ok {
 		return
 	}
 
+	setETag(w, order.Version)
 	response.Success(w, "Order retrieved successfully", order, http.StatusOK)
 }
 
 // Update handles updating an order
 // @Summary Update order
-// @Description Update an order by its ID
+// @Description Update an order by its ID. With an If-Match header, the update only applies while the order is still at that entity tag.
 // @Tags orders
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Order ID"
+// @Param If-Match header string false "Entity tag of the order version being updated"
 // @Param request body domain.OrderUpdateDTO true "Order Update Request"
 // @Success 200 {object} response.Response{data=domain.Order}
-// @Failure 400 {object} response.Response
-// @Failure 401 {object} response.Response
-// @Failure 403 {object} response.Response
-// @Failure 404 {object} response.Response
-// @Failure 500 {object} response.Response
+// @Header 200 {string} ETag "Entity tag of the updated order version"
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 412 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /orders/{id} [put]
 func (h *OrderHandler) Update(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
 		h.logger.Error("Failed to parse order ID", zap.Error(err))
-		response.Error(w, "Invalid order ID", errors.NewBadRequestError("Invalid order ID"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid order ID"))
 		return
 	}
 
-	// Get user from context
-	user, ok := middleware.GetUserFromContext(r.Context())
+	// Only the order owner or staff with order:write can update the order
+	_, user, ok := h.authorizeOrder(w, r, id, domain.PermissionOrderWrite)
 	if !ok {
-		response.Error(w, "Unauthorized", errors.NewUnauthorizedError(""), http.StatusUnauthorized)
 		return
 	}
 
-	// Check if the order exists and belongs to the user
-	order, err := h.orderUseCase.GetByID(r.Context(), id)
+	version, err := ifMatchVersion(r)
 	if err != nil {
-		h.logger.Error("Failed to get order for update", zap.Int64("id", id), zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to get order", err, statusCode)
-		return
-	}
-
-	// Only the order owner or an admin can update the order
-	if user.ID != order.UserID && user.Role != domain.RoleAdmin {
-		response.Error(w, "Forbidden", errors.NewForbiddenError(""), http.StatusForbidden)
+		response.Problem(w, r, err)
 		return
 	}
 
 	var updateDTO domain.OrderUpdateDTO
 	if err := json.NewDecoder(r.Body).Decode(&updateDTO); err != nil {
 		h.logger.Error("Failed to decode request body", zap.Error(err))
-		response.Error(w, "Invalid request payload", errors.NewBadRequestError("Invalid request payload"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
 		return
 	}
 	defer r.Body.Close()
 
-	// Only admins can change the order status
-	if updateDTO.Status != "" && user.Role != domain.RoleAdmin {
-		response.Error(w, "Forbidden", errors.NewForbiddenError("Only admins can change order status"), http.StatusForbidden)
+	if !validateRequest(w, r, &updateDTO) {
+		return
+	}
+
+	// Changing the order status requires order:status
+	if updateDTO.Status != "" && !user.HasPermission(domain.PermissionOrderStatus) {
+		response.Problem(w, r, errors.NewForbiddenError("Missing permission to change order status"))
 		return
 	}
 
+	updateDTO.ActorID = user.ID
+	updateDTO.Version = version
+
 	updatedOrder, err := h.orderUseCase.Update(r.Context(), id, &updateDTO)
 	if err != nil {
 		h.logger.Error("Failed to update order", zap.Int64("id", id), zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to update order", err, statusCode)
+		response.Problem(w, r, preconditionFailed(err, version))
 		return
 	}
 
+	setETag(w, updatedOrder.Version)
 	response.Success(w, "Order updated successfully", updatedOrder, http.StatusOK)
 }
 
 // Delete handles deleting an order
 // @Summary Delete order
-// @Description Delete an order by its ID
+// @Description Delete an order by its ID. With an If-Match header, the order is only deleted while it is still at that entity tag.
 // @Tags orders
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Order ID"
+// @Param If-Match header string false "Entity tag of the order version being deleted"
 // @Success 200 {object} response.Response
-// @Failure 401 {object} response.Response
-// @Failure 403 {object} response.Response
-// @Failure 404 {object} response.Response
-// @Failure 500 {object} response.Response
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 412 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /orders/{id} [delete]
 func (h *OrderHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
 		h.logger.Error("Failed to parse order ID", zap.Error(err))
-		response.Error(w, "Invalid order ID", errors.NewBadRequestError("Invalid order ID"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid order ID"))
 		return
 	}
 
-	// Get user from context
-	user, ok := middleware.GetUserFromContext(r.Context())
-	if !ok {
-		response.Error(w, "Unauthorized", errors.NewUnauthorizedError(""), http.StatusUnauthorized)
+	// Only the order owner or staff with order:write can delete the order
+	if _, _, ok := h.authorizeOrder(w, r, id, domain.PermissionOrderWrite); !ok {
 		return
 	}
 
-	// Check if the order exists and belongs to the user
-	order, err := h.orderUseCase.GetByID(r.Context(), id)
+	version, err := ifMatchVersion(r)
 	if err != nil {
-		h.logger.Error("Failed to get order for deletion", zap.Int64("id", id), zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to get order", err, statusCode)
-		return
-	}
-
-	// Only the order owner or an admin can delete the order
-	if user.ID != order.UserID && user.Role != domain.RoleAdmin {
-		response.Error(w, "Forbidden", errors.NewForbiddenError(""), http.StatusForbidden)
+		response.Problem(w, r, err)
 		return
 	}
 
-	if err := h.orderUseCase.Delete(r.Context(), id); err != nil {
+	if err := h.orderUseCase.DeleteVersion(r.Context(), id, version); err != nil {
 		h.logger.Error("Failed to delete order", zap.Int64("id", id), zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to delete order", err, statusCode)
+		response.Problem(w, r, preconditionFailed(err, version))
 		return
 	}
 
@@ -259,121 +256,222 @@ func (h *OrderHandler) Delete(w http.ResponseWriter, r *http.Request) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param page query int false "Page number"
+// @Param page query int false "Page number (offset pagination)"
 // @Param per_page query int false "Items per page"
+// @Param after query string false "Cursor of the page to read after (cursor pagination)"
+// @Param before query string false "Cursor of the page to read before (cursor pagination)"
+// @Param total query bool false "Set to false to skip counting the total"
 // @Success 200 {object} response.PaginatedResponse{data=[]domain.Order}
-// @Failure 401 {object} response.Response
-// @Failure 500 {object} response.Response
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /orders [get]
 func (h *OrderHandler) List(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
 	user, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		response.Error(w, "Unauthorized", errors.NewUnauthorizedError(""), http.StatusUnauthorized)
+		response.Problem(w, r, errors.NewUnauthorizedError(""))
 		return
 	}
 
-	// Parse pagination parameters
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
-
-	if page < 1 {
-		page = 1
-	}
-	if perPage < 1 {
-		perPage = 10
+	pageReq, err := parsePageRequest(r)
+	if err != nil {
+		response.Problem(w, r, err)
+		return
 	}
 
-	offset := (page - 1) * perPage
-
-	var orders []domain.Order
-	var total int
-	var err error
+	var orders *domain.Page[domain.Order]
 
-	// If user is admin, show all orders, otherwise show only user's orders
-	if user.Role == domain.RoleAdmin {
-		orders, total, err = h.orderUseCase.List(r.Context(), perPage, offset)
+	// Staff with order:read see all orders, otherwise show only user's orders
+	if user.HasPermission(domain.PermissionOrderRead) {
+		orders, err = h.orderUseCase.List(r.Context(), pageReq)
 	} else {
-		orders, total, err = h.orderUseCase.GetByUserID(r.Context(), user.ID, page, perPage)
+		orders, err = h.orderUseCase.GetByUserID(r.Context(), user.ID, pageReq)
 	}
 
 	if err != nil {
 		h.logger.Error("Failed to list orders", zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to list orders", err, statusCode)
+		response.Problem(w, r, err)
 		return
 	}
 
-	response.Paginated(w, "Orders retrieved successfully", orders, page, perPage, total, http.StatusOK)
+	response.Paginated(w, "Orders retrieved successfully", orders.Items, pageMeta(r, pageReq, orders), http.StatusOK)
 }
 
 // UpdateStatus handles updating an order's status
 // @Summary Update order status
-// @Description Update an order's status
+// @Description Move an order to a new status. Pending orders can move to processing or cancelled, processing orders to completed or cancelled; completed and cancelled orders are final.
 // @Tags orders
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Order ID"
-// @Param request body map[string]string true "Status Update Request"
+// @Param If-Match header string false "Entity tag of the order version being changed"
+// @Param request body domain.OrderStatusUpdateDTO true "Status Update Request"
 // @Success 200 {object} response.Response
-// @Failure 400 {object} response.Response
-// @Failure 401 {object} response.Response
-// @Failure 403 {object} response.Response
-// @Failure 404 {object} response.Response
-// @Failure 500 {object} response.Response
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 412 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /orders/{id}/status [patch]
 func (h *OrderHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
 		h.logger.Error("Failed to parse order ID", zap.Error(err))
-		response.Error(w, "Invalid order ID", errors.NewBadRequestError("Invalid order ID"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid order ID"))
 		return
 	}
 
 	// Get user from context
 	user, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		response.Error(w, "Unauthorized", errors.NewUnauthorizedError(""), http.StatusUnauthorized)
+		response.Problem(w, r, errors.NewUnauthorizedError(""))
 		return
 	}
 
-	// Only admins can update order status
-	if user.Role != domain.RoleAdmin {
-		response.Error(w, "Forbidden", errors.NewForbiddenError("Only admins can update order status"), http.StatusForbidden)
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		response.Problem(w, r, err)
 		return
 	}
 
-	var statusUpdate struct {
-		Status string `json:"status"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&statusUpdate); err != nil {
+	var statusDTO domain.OrderStatusUpdateDTO
+	if err := json.NewDecoder(r.Body).Decode(&statusDTO); err != nil {
 		h.logger.Error("Failed to decode request body", zap.Error(err))
-		response.Error(w, "Invalid request payload", errors.NewBadRequestError("Invalid request payload"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
 		return
 	}
 	defer r.Body.Close()
 
-	// Validate status
-	if statusUpdate.Status != string(domain.OrderStatusPending) &&
-		statusUpdate.Status != string(domain.OrderStatusProcessing) &&
-		statusUpdate.Status != string(domain.OrderStatusCompleted) &&
-		statusUpdate.Status != string(domain.OrderStatusCancelled) {
-		response.Error(w, "Invalid status", errors.NewBadRequestError("Invalid status"), http.StatusBadRequest)
+	if !validateRequest(w, r, &statusDTO) {
 		return
 	}
 
-	if err := h.orderUseCase.UpdateStatus(r.Context(), id, domain.OrderStatus(statusUpdate.Status)); err != nil {
-		h.logger.Error("Failed to update order status", zap.Int64("id", id), zap.String("status", statusUpdate.Status), zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to update order status", err, statusCode)
+	statusDTO.ActorID = user.ID
+	statusDTO.Version = version
+
+	if err := h.orderUseCase.UpdateStatus(r.Context(), id, &statusDTO); err != nil {
+		h.logger.Error("Failed to update order status", zap.Int64("id", id), zap.String("status", string(statusDTO.Status)), zap.Error(err))
+		response.Problem(w, r, preconditionFailed(err, version))
 		return
 	}
 
 	response.Success(w, "Order status updated successfully", nil, http.StatusOK)
 }
 
+// GetStatusHistory handles getting an order's status history
+// @Summary Get order status history
+// @Description Get every status change of an order, oldest first
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {object} response.Response{data=[]domain.OrderStatusHistory}
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /orders/{id}/history [get]
+func (h *OrderHandler) GetStatusHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.Error("Failed to parse order ID", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid order ID"))
+		return
+	}
+
+	if _, _, ok := h.authorizeOrder(w, r, id, domain.PermissionOrderRead); !ok {
+		return
+	}
+
+	history, err := h.orderUseCase.GetStatusHistory(r.Context(), id)
+	if err != nil {
+		h.logger.Error("Failed to get order status history", zap.Int64("id", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Order status history retrieved successfully", history, http.StatusOK)
+}
+
+// CancelItem handles cancelling part or all of an order item
+// @Summary Cancel order item
+// @Description Cancel units of an order item and return them to stock. The quantity is the item's total cancelled quantity, so retrying a request is safe.
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param itemID path int true "Order item ID"
+// @Param If-Match header string false "Entity tag of the order version being changed"
+// @Param request body domain.OrderItemCancelDTO true "Item Cancel Request"
+// @Success 200 {object} response.Response{data=domain.Order}
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 412 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /orders/{id}/items/{itemID}/cancel [post]
+func (h *OrderHandler) CancelItem(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.Error("Failed to parse order ID", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid order ID"))
+		return
+	}
+
+	itemID, err := strconv.ParseInt(vars["itemID"], 10, 64)
+	if err != nil {
+		h.logger.Error("Failed to parse order item ID", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid order item ID"))
+		return
+	}
+
+	// Only the order owner or staff with order:write can cancel items
+	if _, _, ok := h.authorizeOrder(w, r, id, domain.PermissionOrderWrite); !ok {
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		response.Problem(w, r, err)
+		return
+	}
+
+	var cancelDTO domain.OrderItemCancelDTO
+	if err := json.NewDecoder(r.Body).Decode(&cancelDTO); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+		return
+	}
+	defer r.Body.Close()
+
+	if !validateRequest(w, r, &cancelDTO) {
+		return
+	}
+
+	cancelDTO.Version = version
+
+	order, err := h.orderUseCase.CancelItem(r.Context(), id, itemID, &cancelDTO)
+	if err != nil {
+		h.logger.Error("Failed to cancel order item", zap.Int64("id", id), zap.Int64("itemID", itemID), zap.Error(err))
+		response.Problem(w, r, preconditionFailed(err, version))
+		return
+	}
+
+	setETag(w, order.Version)
+	response.Success(w, "Order item cancelled successfully", order, http.StatusOK)
+}
+
 // GetByUserID handles getting orders by user ID
 // @Summary Get orders by user ID
 // @Description Get orders by user ID with pagination
@@ -382,56 +480,53 @@ func (h *OrderHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Security BearerAuth
 // @Param userID path int true "User ID"
-// @Param page query int false "Page number"
+// @Param page query int false "Page number (offset pagination)"
 // @Param per_page query int false "Items per page"
+// @Param after query string false "Cursor of the page to read after (cursor pagination)"
+// @Param before query string false "Cursor of the page to read before (cursor pagination)"
+// @Param total query bool false "Set to false to skip counting the total"
 // @Success 200 {object} response.PaginatedResponse{data=[]domain.Order}
-// @Failure 401 {object} response.Response
-// @Failure 403 {object} response.Response
-// @Failure 404 {object} response.Response
-// @Failure 500 {object} response.Response
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /orders/user/{userID} [get]
 func (h *OrderHandler) GetByUserID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID, err := strconv.ParseInt(vars["userID"], 10, 64)
 	if err != nil {
 		h.logger.Error("Failed to parse user ID", zap.Error(err))
-		response.Error(w, "Invalid user ID", errors.NewBadRequestError("Invalid user ID"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid user ID"))
 		return
 	}
 
 	// Get user from context
 	user, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		response.Error(w, "Unauthorized", errors.NewUnauthorizedError(""), http.StatusUnauthorized)
+		response.Problem(w, r, errors.NewUnauthorizedError(""))
 		return
 	}
 
-	// Users can only see their own orders, admins can see any user's orders
-	if user.ID != userID && user.Role != domain.RoleAdmin {
-		response.Error(w, "Forbidden", errors.NewForbiddenError(""), http.StatusForbidden)
+	// Users can only see their own orders, staff with order:read can see any user's orders
+	if user.ID != userID && !user.HasPermission(domain.PermissionOrderRead) {
+		response.Problem(w, r, errors.NewForbiddenError(""))
 		return
 	}
 
-	// Parse pagination parameters
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
-
-	if page < 1 {
-		page = 1
-	}
-	if perPage < 1 {
-		perPage = 10
+	pageReq, err := parsePageRequest(r)
+	if err != nil {
+		response.Problem(w, r, err)
+		return
 	}
 
-	orders, total, err := h.orderUseCase.GetByUserID(r.Context(), userID, page, perPage)
+	orders, err := h.orderUseCase.GetByUserID(r.Context(), userID, pageReq)
 	if err != nil {
 		h.logger.Error("Failed to get orders by user ID", zap.Int64("userID", userID), zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to get orders", err, statusCode)
+		response.Problem(w, r, err)
 		return
 	}
 
-	response.Paginated(w, "Orders retrieved successfully", orders, page, perPage, total, http.StatusOK)
+	response.Paginated(w, "Orders retrieved successfully", orders.Items, pageMeta(r, pageReq, orders), http.StatusOK)
 }
 
 // GetByStatus handles getting orders by status
@@ -442,13 +537,16 @@ func (h *OrderHandler) GetByUserID(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Security BearerAuth
 // @Param status path string true "Order Status"
-// @Param page query int false "Page number"
+// @Param page query int false "Page number (offset pagination)"
 // @Param per_page query int false "Items per page"
+// @Param after query string false "Cursor of the page to read after (cursor pagination)"
+// @Param before query string false "Cursor of the page to read before (cursor pagination)"
+// @Param total query bool false "Set to false to skip counting the total"
 // @Success 200 {object} response.PaginatedResponse{data=[]domain.Order}
-// @Failure 400 {object} response.Response
-// @Failure 401 {object} response.Response
-// @Failure 403 {object} response.Response
-// @Failure 500 {object} response.Response
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /orders/status/{status} [get]
 func (h *OrderHandler) GetByStatus(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -459,41 +557,53 @@ func (h *OrderHandler) GetByStatus(w http.ResponseWriter, r *http.Request) {
 		status != string(domain.OrderStatusProcessing) &&
 		status != string(domain.OrderStatusCompleted) &&
 		status != string(domain.OrderStatusCancelled) {
-		response.Error(w, "Invalid status", errors.NewBadRequestError("Invalid status"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid status"))
 		return
 	}
 
-	// Get user from context
-	user, ok := middleware.GetUserFromContext(r.Context())
-	if !ok {
-		response.Error(w, "Unauthorized", errors.NewUnauthorizedError(""), http.StatusUnauthorized)
+	pageReq, err := parsePageRequest(r)
+	if err != nil {
+		response.Problem(w, r, err)
 		return
 	}
 
-	// Only admins can see all orders by status
-	if user.Role != domain.RoleAdmin {
-		response.Error(w, "Forbidden", errors.NewForbiddenError(""), http.StatusForbidden)
+	orders, err := h.orderUseCase.GetByStatus(r.Context(), domain.OrderStatus(status), pageReq)
+	if err != nil {
+		h.logger.Error("Failed to get orders by status", zap.String("status", status), zap.Error(err))
+		response.Problem(w, r, err)
 		return
 	}
 
-	// Parse pagination parameters
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	response.Paginated(w, "Orders retrieved successfully", orders.Items, pageMeta(r, pageReq, orders), http.StatusOK)
+}
 
-	if page < 1 {
-		page = 1
-	}
-	if perPage < 1 {
-		perPage = 10
+// authorizeOrder checks access to an order with the handler's use case
+func (h *OrderHandler) authorizeOrder(w http.ResponseWriter, r *http.Request, id int64, permission domain.Permission) (*domain.Order, *domain.User, bool) {
+	return authorizeOrder(w, r, h.orderUseCase, h.logger, id, permission)
+}
+
+// authorizeOrder loads an order and checks that the authenticated user owns
+// it or holds the permission. It writes the error response and returns false otherwise.
+func authorizeOrder(w http.ResponseWriter, r *http.Request, orderUseCase domain.OrderUseCase, logger logger.Logger, id int64, permission domain.Permission) (*domain.Order, *domain.User, bool) {
+	// Get user from context
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Problem(w, r, errors.NewUnauthorizedError(""))
+		return nil, nil, false
 	}
 
-	orders, total, err := h.orderUseCase.GetByStatus(r.Context(), domain.OrderStatus(status), page, perPage)
+	order, err := orderUseCase.GetOrderWithDetails(r.Context(), id)
 	if err != nil {
-		h.logger.Error("Failed to get orders by status", zap.String("status", status), zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to get orders", err, statusCode)
-		return
+		logger.Error("Failed to get order", zap.Int64("id", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return nil, nil, false
+	}
+
+	if !order.IsAccessibleBy(user, permission) {
+		logger.Warn("Order access denied", zap.Int64("id", id), zap.Int64("userID", user.ID))
+		response.Problem(w, r, errors.NewForbiddenError(""))
+		return nil, nil, false
 	}
 
-	response.Paginated(w, "Orders retrieved successfully", orders, page, perPage, total, http.StatusOK)
+	return order, user, true
 }
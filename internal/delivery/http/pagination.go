@@ -0,0 +1,101 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"github.com/milad-ahmd/go-clean-arch/pkg/response"
+)
+
+// defaultPerPage is the page size used when per_page is missing or invalid
+const defaultPerPage = 10
+
+// parsePageRequest reads the pagination query parameters of a list request.
+// Lists page by offset with page and per_page, or by cursor with an after or
+// before token taken from a previous response. total=false skips counting
+// the matching rows.
+func parsePageRequest(r *http.Request) (domain.PageRequest, error) {
+	query := r.URL.Query()
+
+	perPage, _ := strconv.Atoi(query.Get("per_page"))
+	if perPage < 1 {
+		perPage = defaultPerPage
+	}
+	pageReq := domain.PageRequest{
+		Limit:     perPage,
+		SkipTotal: query.Get("total") == "false",
+	}
+
+	violations := make(map[string]interface{})
+	for _, param := range []string{"after", "before"} {
+		token := query.Get(param)
+		if token == "" {
+			continue
+		}
+		cursor, err := domain.DecodeCursor(token)
+		if err != nil {
+			violations[param] = "is not a valid cursor"
+			continue
+		}
+		if param == "after" {
+			pageReq.After = cursor
+		} else {
+			pageReq.Before = cursor
+		}
+	}
+	if query.Get("after") != "" && query.Get("before") != "" {
+		violations["before"] = "cannot be combined with after"
+	}
+	if len(violations) > 0 {
+		return pageReq, errors.NewValidationError("Invalid pagination parameters", violations)
+	}
+
+	if !pageReq.IsKeyset() {
+		page, _ := strconv.Atoi(query.Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		pageReq.Offset = (page - 1) * perPage
+	}
+
+	return pageReq, nil
+}
+
+// pageMeta builds the pagination metadata of a list response. Links keep
+// the request's other query parameters and stay in the request's mode:
+// page numbers for offset pagination, cursors for cursor pagination.
+func pageMeta[T any](r *http.Request, pageReq domain.PageRequest, page *domain.Page[T]) response.Meta {
+	if pageReq.IsKeyset() {
+		var next, prev string
+		if page.Next != nil {
+			next = pageLink(r, "after", page.Next.Encode())
+		}
+		if page.Prev != nil {
+			prev = pageLink(r, "before", page.Prev.Encode())
+		}
+		return response.NewMeta(0, pageReq.Limit, page.Total, next, prev)
+	}
+
+	pageNumber := pageReq.Offset/pageReq.Limit + 1
+	var next, prev string
+	if page.Next != nil {
+		next = pageLink(r, "page", strconv.Itoa(pageNumber+1))
+	}
+	if pageNumber > 1 {
+		prev = pageLink(r, "page", strconv.Itoa(pageNumber-1))
+	}
+	return response.NewMeta(pageNumber, pageReq.Limit, page.Total, next, prev)
+}
+
+// pageLink returns the request URL with its pagination position replaced
+func pageLink(r *http.Request, param, value string) string {
+	query := r.URL.Query()
+	query.Del("page")
+	query.Del("after")
+	query.Del("before")
+	query.Set(param, value)
+
+	return r.URL.Path + "?" + query.Encode()
+}
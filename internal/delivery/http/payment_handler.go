@@ -0,0 +1,235 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"github.com/milad-ahmd/go-clean-arch/pkg/middleware"
+	"github.com/milad-ahmd/go-clean-arch/pkg/response"
+	"go.uber.org/zap"
+)
+
+// PaymentHandler handles HTTP requests for order payments
+type PaymentHandler struct {
+	paymentUseCase domain.PaymentUseCase
+	orderUseCase   domain.OrderUseCase
+	logger         logger.Logger
+}
+
+// NewPaymentHandler creates a new payment handler
+func NewPaymentHandler(r *mux.Router, paymentUseCase domain.PaymentUseCase, orderUseCase domain.OrderUseCase, userUseCase domain.UserUseCase, logger logger.Logger) {
+	handler := &PaymentHandler{
+		paymentUseCase: paymentUseCase,
+		orderUseCase:   orderUseCase,
+		logger:         logger,
+	}
+
+	// Protected routes (require authentication)
+	protected := r.PathPrefix("/orders/{id:[0-9]+}/payments").Subrouter()
+	protected.Use(mux.MiddlewareFunc(middleware.Auth(userUseCase, logger)))
+	protected.HandleFunc("", handler.List).Methods("GET")
+	protected.HandleFunc("", handler.Authorize).Methods("POST")
+	protected.Handle("/{paymentID:[0-9]+}/capture", middleware.Chain(
+		http.HandlerFunc(handler.Capture),
+		middleware.RequirePermission(domain.PermissionOrderStatus),
+	)).Methods("POST")
+	protected.Handle("/{paymentID:[0-9]+}/void", middleware.Chain(
+		http.HandlerFunc(handler.Void),
+		middleware.RequirePermission(domain.PermissionOrderStatus),
+	)).Methods("POST")
+}
+
+// List handles listing the payments of an order
+// @Summary List order payments
+// @Description List every payment attempt of an order, oldest first, including declined and timed out attempts
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {object} response.Response{data=[]domain.Payment}
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /orders/{id}/payments [get]
+func (h *PaymentHandler) List(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.orderID(w, r)
+	if !ok {
+		return
+	}
+
+	// Only the order owner or staff with order:read can see payments
+	if _, _, ok := authorizeOrder(w, r, h.orderUseCase, h.logger, id, domain.PermissionOrderRead); !ok {
+		return
+	}
+
+	payments, err := h.paymentUseCase.GetByOrderID(r.Context(), id)
+	if err != nil {
+		h.logger.Error("Failed to get payments", zap.Int64("orderID", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Payments retrieved successfully", payments, http.StatusOK)
+}
+
+// Authorize handles paying an order
+// @Summary Pay order
+// @Description Authorize the total of a pending order with the payment provider, and capture it right away with "capture": true. Declines return 402 with a payment_declined or payment_insufficient_funds code, and provider timeouts 504 with payment_timeout.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param request body domain.PaymentAuthorizeDTO true "Payment Request"
+// @Success 201 {object} response.Response{data=domain.Payment}
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 402 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 502 {object} response.ProblemDetails
+// @Failure 504 {object} response.ProblemDetails
+// @Router /orders/{id}/payments [post]
+func (h *PaymentHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.orderID(w, r)
+	if !ok {
+		return
+	}
+
+	// Only the order owner or staff with order:write can pay an order
+	_, user, ok := authorizeOrder(w, r, h.orderUseCase, h.logger, id, domain.PermissionOrderWrite)
+	if !ok {
+		return
+	}
+
+	var authorizeDTO domain.PaymentAuthorizeDTO
+	if err := json.NewDecoder(r.Body).Decode(&authorizeDTO); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+		return
+	}
+	defer r.Body.Close()
+
+	if !validateRequest(w, r, &authorizeDTO) {
+		return
+	}
+
+	authorizeDTO.ActorID = user.ID
+
+	payment, err := h.paymentUseCase.Authorize(r.Context(), id, &authorizeDTO)
+	if err != nil {
+		h.logger.Error("Failed to pay order", zap.Int64("orderID", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Payment authorized successfully", payment, http.StatusCreated)
+}
+
+// Capture handles capturing an authorized payment
+// @Summary Capture payment
+// @Description Collect an authorized payment; a pending order moves to processing
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param paymentID path int true "Payment ID"
+// @Success 200 {object} response.Response{data=domain.Payment}
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 502 {object} response.ProblemDetails
+// @Failure 504 {object} response.ProblemDetails
+// @Router /orders/{id}/payments/{paymentID}/capture [post]
+func (h *PaymentHandler) Capture(w http.ResponseWriter, r *http.Request) {
+	id, paymentID, ok := h.paymentIDs(w, r)
+	if !ok {
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Problem(w, r, errors.NewUnauthorizedError(""))
+		return
+	}
+
+	payment, err := h.paymentUseCase.Capture(r.Context(), id, paymentID, user.ID)
+	if err != nil {
+		h.logger.Error("Failed to capture payment", zap.Int64("orderID", id), zap.Int64("paymentID", paymentID), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Payment captured successfully", payment, http.StatusOK)
+}
+
+// Void handles voiding an authorized payment
+// @Summary Void payment
+// @Description Release an authorized payment without collecting it
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param paymentID path int true "Payment ID"
+// @Success 200 {object} response.Response{data=domain.Payment}
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 502 {object} response.ProblemDetails
+// @Failure 504 {object} response.ProblemDetails
+// @Router /orders/{id}/payments/{paymentID}/void [post]
+func (h *PaymentHandler) Void(w http.ResponseWriter, r *http.Request) {
+	id, paymentID, ok := h.paymentIDs(w, r)
+	if !ok {
+		return
+	}
+
+	payment, err := h.paymentUseCase.Void(r.Context(), id, paymentID)
+	if err != nil {
+		h.logger.Error("Failed to void payment", zap.Int64("orderID", id), zap.Int64("paymentID", paymentID), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Payment voided successfully", payment, http.StatusOK)
+}
+
+// orderID parses the order ID from the path, writing the error response on failure
+func (h *PaymentHandler) orderID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.Error("Failed to parse order ID", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid order ID"))
+		return 0, false
+	}
+	return id, true
+}
+
+// paymentIDs parses the order and payment IDs from the path, writing the
+// error response on failure
+func (h *PaymentHandler) paymentIDs(w http.ResponseWriter, r *http.Request) (int64, int64, bool) {
+	id, ok := h.orderID(w, r)
+	if !ok {
+		return 0, 0, false
+	}
+
+	paymentID, err := strconv.ParseInt(mux.Vars(r)["paymentID"], 10, 64)
+	if err != nil {
+		h.logger.Error("Failed to parse payment ID", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid payment ID"))
+		return 0, 0, false
+	}
+	return id, paymentID, true
+}
@@ -20,22 +20,29 @@ type ProductHandler struct {
 }
 
 // NewProductHandler creates a new product handler
-func NewProductHandler(r *mux.Router, productUseCase domain.ProductUseCase, logger logger.Logger) {
+func NewProductHandler(r *mux.Router, productUseCase domain.ProductUseCase, userUseCase domain.UserUseCase, logger logger.Logger) {
 	handler := &ProductHandler{
 		productUseCase: productUseCase,
 		logger:         logger,
 	}
 
-	// Register routes
-	r.HandleFunc("/products", handler.Create).Methods("POST")
+	// Public routes
 	r.HandleFunc("/products", handler.List).Methods("GET")
 	r.HandleFunc("/products/{id:[0-9]+}", handler.GetByID).Methods("GET")
-	r.HandleFunc("/products/{id:[0-9]+}", handler.Update).Methods("PUT")
-	r.HandleFunc("/products/{id:[0-9]+}", handler.Delete).Methods("DELETE")
 	r.HandleFunc("/products/sku/{sku}", handler.GetBySKU).Methods("GET")
 	r.HandleFunc("/products/category/{categoryID:[0-9]+}", handler.GetByCategory).Methods("GET")
 	r.HandleFunc("/products/search", handler.Search).Methods("GET")
-	r.HandleFunc("/products/{id:[0-9]+}/stock", handler.UpdateStock).Methods("PATCH")
+	r.HandleFunc("/products/{id:[0-9]+}/variants", handler.GetVariants).Methods("GET")
+
+	// Protected routes
+	r.Handle("/products", requirePermission(handler.Create, userUseCase, logger, domain.PermissionProductWrite)).Methods("POST")
+	r.Handle("/products/{id:[0-9]+}", requirePermission(handler.Update, userUseCase, logger, domain.PermissionProductWrite)).Methods("PUT")
+	r.Handle("/products/{id:[0-9]+}", requirePermission(handler.Delete, userUseCase, logger, domain.PermissionProductWrite)).Methods("DELETE")
+	r.Handle("/products/{id:[0-9]+}/stock", requirePermission(handler.UpdateStock, userUseCase, logger, domain.PermissionInventoryWrite)).Methods("PATCH")
+	r.Handle("/products/{id:[0-9]+}/variants", requirePermission(handler.CreateVariant, userUseCase, logger, domain.PermissionProductWrite)).Methods("POST")
+	r.Handle("/products/{id:[0-9]+}/variants/{variantID:[0-9]+}", requirePermission(handler.UpdateVariant, userUseCase, logger, domain.PermissionProductWrite)).Methods("PUT")
+	r.Handle("/products/{id:[0-9]+}/variants/{variantID:[0-9]+}", requirePermission(handler.DeleteVariant, userUseCase, logger, domain.PermissionProductWrite)).Methods("DELETE")
+	r.Handle("/products/{id:[0-9]+}/variants/{variantID:[0-9]+}/stock", requirePermission(handler.UpdateVariantStock, userUseCase, logger, domain.PermissionInventoryWrite)).Methods("PATCH")
 }
 
 // Create handles the creation of a new product
@@ -46,27 +53,32 @@ func NewProductHandler(r *mux.Router, productUseCase domain.ProductUseCase, logg
 // @Produce json
 // @Param request body domain.ProductCreateDTO true "Product Create Request"
 // @Success 201 {object} response.Response{data=domain.Product}
-// @Failure 400 {object} response.Response
-// @Failure 409 {object} response.Response
-// @Failure 500 {object} response.Response
+// @Header 201 {string} ETag "Entity tag of the product version"
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /products [post]
 func (h *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var createDTO domain.ProductCreateDTO
 	if err := json.NewDecoder(r.Body).Decode(&createDTO); err != nil {
 		h.logger.Error("Failed to decode request body", zap.Error(err))
-		response.Error(w, "Invalid request payload", errors.NewBadRequestError("Invalid request payload"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
 		return
 	}
 	defer r.Body.Close()
 
+	if !validateRequest(w, r, &createDTO) {
+		return
+	}
+
 	product, err := h.productUseCase.Create(r.Context(), &createDTO)
 	if err != nil {
 		h.logger.Error("Failed to create product", zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to create product", err, statusCode)
+		response.Problem(w, r, err)
 		return
 	}
 
+	setETag(w, product.Version)
 	response.Success(w, "Product created successfully", product, http.StatusCreated)
 }
 
@@ -78,68 +90,83 @@ func (h *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param id path int true "Product ID"
 // @Success 200 {object} response.Response{data=domain.Product}
-// @Failure 404 {object} response.Response
-// @Failure 500 {object} response.Response
+// @Header 200 {string} ETag "Entity tag of the product version"
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /products/{id} [get]
 func (h *ProductHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
 		h.logger.Error("Failed to parse product ID", zap.Error(err))
-		response.Error(w, "Invalid product ID", errors.NewBadRequestError("Invalid product ID"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid product ID"))
 		return
 	}
 
 	product, err := h.productUseCase.GetByID(r.Context(), id)
 	if err != nil {
 		h.logger.Error("Failed to get product", zap.Int64("id", id), zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to get product", err, statusCode)
+		response.Problem(w, r, err)
 		return
 	}
 
+	setETag(w, product.Version)
 	response.Success(w, "Product retrieved successfully", product, http.StatusOK)
 }
 
 // Update handles updating a product
 // @Summary Update product
-// @Description Update a product by its ID
+// @Description Update a product by its ID. With an If-Match header, the update only applies while the product is still at that entity tag.
 // @Tags products
 // @Accept json
 // @Produce json
 // @Param id path int true "Product ID"
+// @Param If-Match header string false "Entity tag of the product version being updated"
 // @Param request body domain.ProductUpdateDTO true "Product Update Request"
 // @Success 200 {object} response.Response{data=domain.Product}
-// @Failure 400 {object} response.Response
-// @Failure 404 {object} response.Response
-// @Failure 409 {object} response.Response
-// @Failure 500 {object} response.Response
+// @Header 200 {string} ETag "Entity tag of the updated product version"
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 412 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /products/{id} [put]
 func (h *ProductHandler) Update(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
 		h.logger.Error("Failed to parse product ID", zap.Error(err))
-		response.Error(w, "Invalid product ID", errors.NewBadRequestError("Invalid product ID"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid product ID"))
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		response.Problem(w, r, err)
 		return
 	}
 
 	var updateDTO domain.ProductUpdateDTO
 	if err := json.NewDecoder(r.Body).Decode(&updateDTO); err != nil {
 		h.logger.Error("Failed to decode request body", zap.Error(err))
-		response.Error(w, "Invalid request payload", errors.NewBadRequestError("Invalid request payload"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
 		return
 	}
 	defer r.Body.Close()
 
+	if !validateRequest(w, r, &updateDTO) {
+		return
+	}
+	updateDTO.Version = version
+
 	product, err := h.productUseCase.Update(r.Context(), id, &updateDTO)
 	if err != nil {
 		h.logger.Error("Failed to update product", zap.Int64("id", id), zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to update product", err, statusCode)
+		response.Problem(w, r, preconditionFailed(err, version))
 		return
 	}
 
+	setETag(w, product.Version)
 	response.Success(w, "Product updated successfully", product, http.StatusOK)
 }
 
@@ -151,62 +178,70 @@ func (h *ProductHandler) Update(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param id path int true "Product ID"
 // @Success 200 {object} response.Response
-// @Failure 404 {object} response.Response
-// @Failure 500 {object} response.Response
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /products/{id} [delete]
 func (h *ProductHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
 		h.logger.Error("Failed to parse product ID", zap.Error(err))
-		response.Error(w, "Invalid product ID", errors.NewBadRequestError("Invalid product ID"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid product ID"))
 		return
 	}
 
 	if err := h.productUseCase.Delete(r.Context(), id); err != nil {
 		h.logger.Error("Failed to delete product", zap.Int64("id", id), zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to delete product", err, statusCode)
+		response.Problem(w, r, err)
 		return
 	}
 
 	response.Success(w, "Product deleted successfully", nil, http.StatusOK)
 }
 
-// List handles listing products with pagination
+// List handles listing products with sorting, filtering and pagination
 // @Summary List products
-// @Description List products with pagination
+// @Description List products, optionally sorted and filtered, with pagination
 // @Tags products
 // @Accept json
 // @Produce json
-// @Param page query int false "Page number"
+// @Param sort query string false "Comma separated sort fields (id, name, price, stock, created_at); prefix with - for descending"
+// @Param price[gte] query int false "Minimum price in minor units"
+// @Param price[lte] query int false "Maximum price in minor units"
+// @Param category_id[in] query string false "Comma separated category IDs"
+// @Param in_stock query bool false "Only products in stock (true) or out of stock (false)"
+// @Param created_at[gte] query string false "Created at or after (RFC 3339)"
+// @Param created_at[lte] query string false "Created at or before (RFC 3339)"
+// @Param page query int false "Page number (offset pagination)"
 // @Param per_page query int false "Items per page"
+// @Param after query string false "Cursor of the page to read after (cursor pagination)"
+// @Param before query string false "Cursor of the page to read before (cursor pagination)"
+// @Param total query bool false "Set to false to skip counting the total"
 // @Success 200 {object} response.PaginatedResponse{data=[]domain.Product}
-// @Failure 500 {object} response.Response
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /products [get]
 func (h *ProductHandler) List(w http.ResponseWriter, r *http.Request) {
-	// Parse pagination parameters
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
-
-	if page < 1 {
-		page = 1
-	}
-	if perPage < 1 {
-		perPage = 10
+	pageReq, err := parsePageRequest(r)
+	if err != nil {
+		response.Problem(w, r, err)
+		return
 	}
 
-	offset := (page - 1) * perPage
+	filter, err := parseProductFilter(r.URL.Query())
+	if err != nil {
+		response.Problem(w, r, err)
+		return
+	}
 
-	products, total, err := h.productUseCase.List(r.Context(), perPage, offset)
+	products, err := h.productUseCase.ListByFilter(r.Context(), filter, pageReq)
 	if err != nil {
 		h.logger.Error("Failed to list products", zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to list products", err, statusCode)
+		response.Problem(w, r, err)
 		return
 	}
 
-	response.Paginated(w, "Products retrieved successfully", products, page, perPage, total, http.StatusOK)
+	response.Paginated(w, "Products retrieved successfully", products.Items, pageMeta(r, pageReq, products), http.StatusOK)
 }
 
 // GetBySKU handles getting a product by SKU
@@ -217,8 +252,9 @@ func (h *ProductHandler) List(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param sku path string true "Product SKU"
 // @Success 200 {object} response.Response{data=domain.Product}
-// @Failure 404 {object} response.Response
-// @Failure 500 {object} response.Response
+// @Header 200 {string} ETag "Entity tag of the product version"
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /products/sku/{sku} [get]
 func (h *ProductHandler) GetBySKU(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -227,97 +263,118 @@ func (h *ProductHandler) GetBySKU(w http.ResponseWriter, r *http.Request) {
 	product, err := h.productUseCase.GetBySKU(r.Context(), sku)
 	if err != nil {
 		h.logger.Error("Failed to get product by SKU", zap.String("sku", sku), zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to get product", err, statusCode)
+		response.Problem(w, r, err)
 		return
 	}
 
+	setETag(w, product.Version)
 	response.Success(w, "Product retrieved successfully", product, http.StatusOK)
 }
 
 // GetByCategory handles getting products by category ID
 // @Summary Get products by category
-// @Description Get products by category ID with pagination
+// @Description Get products by category ID with pagination, optionally including the products of every subcategory
 // @Tags products
 // @Accept json
 // @Produce json
 // @Param categoryID path int true "Category ID"
-// @Param page query int false "Page number"
+// @Param include_descendants query bool false "Set to true to include the products of subcategories"
+// @Param page query int false "Page number (offset pagination)"
 // @Param per_page query int false "Items per page"
+// @Param after query string false "Cursor of the page to read after (cursor pagination)"
+// @Param before query string false "Cursor of the page to read before (cursor pagination)"
+// @Param total query bool false "Set to false to skip counting the total"
 // @Success 200 {object} response.PaginatedResponse{data=[]domain.Product}
-// @Failure 404 {object} response.Response
-// @Failure 500 {object} response.Response
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /products/category/{categoryID} [get]
 func (h *ProductHandler) GetByCategory(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	categoryID, err := strconv.ParseInt(vars["categoryID"], 10, 64)
 	if err != nil {
 		h.logger.Error("Failed to parse category ID", zap.Error(err))
-		response.Error(w, "Invalid category ID", errors.NewBadRequestError("Invalid category ID"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid category ID"))
 		return
 	}
 
-	// Parse pagination parameters
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
-
-	if page < 1 {
-		page = 1
-	}
-	if perPage < 1 {
-		perPage = 10
+	pageReq, err := parsePageRequest(r)
+	if err != nil {
+		response.Problem(w, r, err)
+		return
 	}
 
-	products, total, err := h.productUseCase.GetByCategory(r.Context(), categoryID, page, perPage)
+	includeDescendants := r.URL.Query().Get("include_descendants") == "true"
+	products, err := h.productUseCase.GetByCategory(r.Context(), categoryID, includeDescendants, pageReq)
 	if err != nil {
 		h.logger.Error("Failed to get products by category", zap.Int64("categoryID", categoryID), zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to get products", err, statusCode)
+		response.Problem(w, r, err)
 		return
 	}
 
-	response.Paginated(w, "Products retrieved successfully", products, page, perPage, total, http.StatusOK)
+	response.Paginated(w, "Products retrieved successfully", products.Items, pageMeta(r, pageReq, products), http.StatusOK)
 }
 
 // Search handles searching for products
 // @Summary Search products
-// @Description Search for products by name or description
+// @Description Full-text search of product names and descriptions, most relevant first, with highlighted snippets. Names and SKUs also match approximately. Accepts the filters of the product list.
 // @Tags products
 // @Accept json
 // @Produce json
-// @Param q query string true "Search query"
-// @Param page query int false "Page number"
+// @Param q query string true "Search query in web search syntax: quoted phrases, or, -word"
+// @Param facets query bool false "Set to true to include category, price range and stock counts"
+// @Param price[gte] query int false "Minimum price in minor units"
+// @Param price[lte] query int false "Maximum price in minor units"
+// @Param category_id[in] query string false "Comma separated category IDs"
+// @Param in_stock query bool false "Only products in stock (true) or out of stock (false)"
+// @Param created_at[gte] query string false "Created at or after (RFC 3339)"
+// @Param created_at[lte] query string false "Created at or before (RFC 3339)"
+// @Param page query int false "Page number (offset pagination)"
 // @Param per_page query int false "Items per page"
-// @Success 200 {object} response.PaginatedResponse{data=[]domain.Product}
-// @Failure 500 {object} response.Response
+// @Param after query string false "Cursor of the page to read after (cursor pagination)"
+// @Param before query string false "Cursor of the page to read before (cursor pagination)"
+// @Param total query bool false "Set to false to skip counting the total"
+// @Success 200 {object} response.PaginatedResponse{data=[]domain.ProductSearchHit,facets=domain.ProductFacets}
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /products/search [get]
 func (h *ProductHandler) Search(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("q")
+	params := r.URL.Query()
+	query := params.Get("q")
 	if query == "" {
-		response.Error(w, "Search query is required", errors.NewBadRequestError("Search query is required"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Search query is required"))
+		return
+	}
+	if params.Get("sort") != "" {
+		response.Problem(w, r, errors.NewValidationError("Invalid filter parameters", map[string]interface{}{
+			"sort": "is not supported, search results are ordered by relevance",
+		}))
 		return
 	}
 
-	// Parse pagination parameters
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
-
-	if page < 1 {
-		page = 1
+	pageReq, err := parsePageRequest(r)
+	if err != nil {
+		response.Problem(w, r, err)
+		return
 	}
-	if perPage < 1 {
-		perPage = 10
+
+	filter, err := parseProductFilter(params)
+	if err != nil {
+		response.Problem(w, r, err)
+		return
 	}
 
-	products, total, err := h.productUseCase.Search(r.Context(), query, page, perPage)
+	result, err := h.productUseCase.Search(r.Context(), query, filter, pageReq, params.Get("facets") == "true")
 	if err != nil {
 		h.logger.Error("Failed to search products", zap.String("query", query), zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to search products", err, statusCode)
+		response.Problem(w, r, err)
 		return
 	}
 
-	response.Paginated(w, "Products retrieved successfully", products, page, perPage, total, http.StatusOK)
+	resp := response.NewPaginatedResponse("Products retrieved successfully", result.Page.Items, pageMeta(r, pageReq, result.Page), http.StatusOK)
+	if result.Facets != nil {
+		resp.Facets = result.Facets
+	}
+	response.JSON(w, http.StatusOK, resp)
 }
 
 // UpdateStock handles updating a product's stock
@@ -329,35 +386,248 @@ func (h *ProductHandler) Search(w http.ResponseWriter, r *http.Request) {
 // @Param id path int true "Product ID"
 // @Param request body map[string]int true "Stock Update Request"
 // @Success 200 {object} response.Response
-// @Failure 400 {object} response.Response
-// @Failure 404 {object} response.Response
-// @Failure 500 {object} response.Response
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
 // @Router /products/{id}/stock [patch]
 func (h *ProductHandler) UpdateStock(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
 		h.logger.Error("Failed to parse product ID", zap.Error(err))
-		response.Error(w, "Invalid product ID", errors.NewBadRequestError("Invalid product ID"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid product ID"))
 		return
 	}
 
 	var stockUpdate struct {
-		Quantity int `json:"quantity"`
+		Quantity int `json:"quantity" validate:"required"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&stockUpdate); err != nil {
 		h.logger.Error("Failed to decode request body", zap.Error(err))
-		response.Error(w, "Invalid request payload", errors.NewBadRequestError("Invalid request payload"), http.StatusBadRequest)
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
 		return
 	}
 	defer r.Body.Close()
 
+	if !validateRequest(w, r, &stockUpdate) {
+		return
+	}
+
 	if err := h.productUseCase.UpdateStock(r.Context(), id, stockUpdate.Quantity); err != nil {
 		h.logger.Error("Failed to update product stock", zap.Int64("id", id), zap.Int("quantity", stockUpdate.Quantity), zap.Error(err))
-		statusCode := errors.GetStatusCode(err)
-		response.Error(w, "Failed to update product stock", err, statusCode)
+		response.Problem(w, r, err)
 		return
 	}
 
 	response.Success(w, "Product stock updated successfully", nil, http.StatusOK)
 }
+
+// GetVariants handles getting the variants of a product
+// @Summary Get product variants
+// @Description Get the variants of a product
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Success 200 {object} response.Response{data=[]domain.ProductVariant}
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /products/{id}/variants [get]
+func (h *ProductHandler) GetVariants(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.Error("Failed to parse product ID", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid product ID"))
+		return
+	}
+
+	variants, err := h.productUseCase.GetVariants(r.Context(), id)
+	if err != nil {
+		h.logger.Error("Failed to get product variants", zap.Int64("id", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Product variants retrieved successfully", variants, http.StatusOK)
+}
+
+// CreateVariant handles the creation of a product variant
+// @Summary Create product variant
+// @Description Create a variant of a product, picking one value of each product option
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param request body domain.ProductVariantCreateDTO true "Product Variant Create Request"
+// @Success 201 {object} response.Response{data=domain.ProductVariant}
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /products/{id}/variants [post]
+func (h *ProductHandler) CreateVariant(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.Error("Failed to parse product ID", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid product ID"))
+		return
+	}
+
+	var createDTO domain.ProductVariantCreateDTO
+	if err := json.NewDecoder(r.Body).Decode(&createDTO); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+		return
+	}
+	defer r.Body.Close()
+
+	if !validateRequest(w, r, &createDTO) {
+		return
+	}
+
+	variant, err := h.productUseCase.CreateVariant(r.Context(), id, &createDTO)
+	if err != nil {
+		h.logger.Error("Failed to create product variant", zap.Int64("id", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Product variant created successfully", variant, http.StatusCreated)
+}
+
+// UpdateVariant handles updating a product variant
+// @Summary Update product variant
+// @Description Update a variant of a product; clear_price removes its price override
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param variantID path int true "Variant ID"
+// @Param request body domain.ProductVariantUpdateDTO true "Product Variant Update Request"
+// @Success 200 {object} response.Response{data=domain.ProductVariant}
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /products/{id}/variants/{variantID} [put]
+func (h *ProductHandler) UpdateVariant(w http.ResponseWriter, r *http.Request) {
+	id, variantID, ok := h.variantPath(w, r)
+	if !ok {
+		return
+	}
+
+	var updateDTO domain.ProductVariantUpdateDTO
+	if err := json.NewDecoder(r.Body).Decode(&updateDTO); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+		return
+	}
+	defer r.Body.Close()
+
+	if !validateRequest(w, r, &updateDTO) {
+		return
+	}
+
+	variant, err := h.productUseCase.UpdateVariant(r.Context(), id, variantID, &updateDTO)
+	if err != nil {
+		h.logger.Error("Failed to update product variant", zap.Int64("id", id), zap.Int64("variantID", variantID), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Product variant updated successfully", variant, http.StatusOK)
+}
+
+// DeleteVariant handles deleting a product variant
+// @Summary Delete product variant
+// @Description Delete a variant of a product that has never been ordered
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param variantID path int true "Variant ID"
+// @Success 200 {object} response.Response
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /products/{id}/variants/{variantID} [delete]
+func (h *ProductHandler) DeleteVariant(w http.ResponseWriter, r *http.Request) {
+	id, variantID, ok := h.variantPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.productUseCase.DeleteVariant(r.Context(), id, variantID); err != nil {
+		h.logger.Error("Failed to delete product variant", zap.Int64("id", id), zap.Int64("variantID", variantID), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Product variant deleted successfully", nil, http.StatusOK)
+}
+
+// UpdateVariantStock handles updating a product variant's stock
+// @Summary Update product variant stock
+// @Description Adjust a product variant's stock quantity
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param variantID path int true "Variant ID"
+// @Param request body map[string]int true "Stock Update Request"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /products/{id}/variants/{variantID}/stock [patch]
+func (h *ProductHandler) UpdateVariantStock(w http.ResponseWriter, r *http.Request) {
+	id, variantID, ok := h.variantPath(w, r)
+	if !ok {
+		return
+	}
+
+	var stockUpdate struct {
+		Quantity int `json:"quantity" validate:"required"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&stockUpdate); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+		return
+	}
+	defer r.Body.Close()
+
+	if !validateRequest(w, r, &stockUpdate) {
+		return
+	}
+
+	if err := h.productUseCase.UpdateVariantStock(r.Context(), id, variantID, stockUpdate.Quantity); err != nil {
+		h.logger.Error("Failed to update variant stock", zap.Int64("id", id), zap.Int64("variantID", variantID), zap.Int("quantity", stockUpdate.Quantity), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Product variant stock updated successfully", nil, http.StatusOK)
+}
+
+// variantPath parses the product and variant IDs of a variant route. It
+// writes a 400 problem response and returns false if either is invalid.
+func (h *ProductHandler) variantPath(w http.ResponseWriter, r *http.Request) (int64, int64, bool) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.Error("Failed to parse product ID", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid product ID"))
+		return 0, 0, false
+	}
+
+	variantID, err := strconv.ParseInt(vars["variantID"], 10, 64)
+	if err != nil {
+		h.logger.Error("Failed to parse variant ID", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid variant ID"))
+		return 0, 0, false
+	}
+
+	return id, variantID, true
+}
@@ -0,0 +1,111 @@
+package http
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
+)
+
+// productFilterParams are the operator query parameters product lists accept
+var productFilterParams = map[string]bool{
+	"price[gte]":      true,
+	"price[lte]":      true,
+	"category_id[in]": true,
+	"created_at[gte]": true,
+	"created_at[lte]": true,
+}
+
+// parseProductFilter reads the sort and filter query parameters of a product
+// list. Prices are in minor units, category_id[in] takes a comma separated
+// list of IDs and times are RFC 3339. Operators other than the supported
+// ones are rejected rather than ignored, so a typo never widens a listing.
+func parseProductFilter(query url.Values) (domain.ProductFilter, error) {
+	var filter domain.ProductFilter
+	violations := make(map[string]interface{})
+
+	for param := range query {
+		if strings.Contains(param, "[") && !productFilterParams[param] {
+			violations[param] = "is not a supported filter"
+		}
+	}
+
+	sort, err := domain.ParseProductSort(query.Get("sort"))
+	if verr, ok := err.(*domain.ValidationError); ok {
+		violations[verr.Field] = verr.Message
+	}
+	filter.Sort = sort
+
+	filter.MinPrice = parseInt64Param(query, "price[gte]", violations)
+	filter.MaxPrice = parseInt64Param(query, "price[lte]", violations)
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		violations["price[lte]"] = "must not be less than price[gte]"
+	}
+
+	for _, param := range []string{"category_id", "category_id[in]"} {
+		if query.Get(param) == "" {
+			continue
+		}
+		for _, value := range strings.Split(query.Get(param), ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil || id < 1 {
+				violations[param] = "must be a comma separated list of category IDs"
+				break
+			}
+			filter.CategoryIDs = append(filter.CategoryIDs, id)
+		}
+	}
+
+	if value := query.Get("in_stock"); value != "" {
+		inStock, err := strconv.ParseBool(value)
+		if err != nil {
+			violations["in_stock"] = "must be true or false"
+		} else {
+			filter.InStock = &inStock
+		}
+	}
+
+	filter.CreatedFrom = parseTimeParam(query, "created_at[gte]", violations)
+	filter.CreatedTo = parseTimeParam(query, "created_at[lte]", violations)
+	if filter.CreatedFrom != nil && filter.CreatedTo != nil && filter.CreatedFrom.After(*filter.CreatedTo) {
+		violations["created_at[lte]"] = "must not be before created_at[gte]"
+	}
+
+	if len(violations) > 0 {
+		return filter, errors.NewValidationError("Invalid filter parameters", violations)
+	}
+	return filter, nil
+}
+
+// parseInt64Param reads an integer query parameter, or returns nil if it is
+// missing. An invalid value is recorded in violations.
+func parseInt64Param(query url.Values, param string, violations map[string]interface{}) *int64 {
+	value := query.Get(param)
+	if value == "" {
+		return nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		violations[param] = "must be an integer"
+		return nil
+	}
+	return &n
+}
+
+// parseTimeParam reads an RFC 3339 time query parameter, or returns nil if it
+// is missing. An invalid value is recorded in violations.
+func parseTimeParam(query url.Values, param string, violations map[string]interface{}) *time.Time {
+	value := query.Get(param)
+	if value == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		violations[param] = "must be an RFC 3339 time"
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,140 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"github.com/milad-ahmd/go-clean-arch/pkg/middleware"
+	"github.com/milad-ahmd/go-clean-arch/pkg/response"
+	"go.uber.org/zap"
+)
+
+// RefundHandler handles HTTP requests for order refunds
+type RefundHandler struct {
+	refundUseCase domain.RefundUseCase
+	orderUseCase  domain.OrderUseCase
+	logger        logger.Logger
+}
+
+// NewRefundHandler creates a new refund handler
+func NewRefundHandler(r *mux.Router, refundUseCase domain.RefundUseCase, orderUseCase domain.OrderUseCase, userUseCase domain.UserUseCase, logger logger.Logger) {
+	handler := &RefundHandler{
+		refundUseCase: refundUseCase,
+		orderUseCase:  orderUseCase,
+		logger:        logger,
+	}
+
+	// Protected routes (require authentication)
+	protected := r.PathPrefix("/orders/{id:[0-9]+}/refunds").Subrouter()
+	protected.Use(mux.MiddlewareFunc(middleware.Auth(userUseCase, logger)))
+	protected.HandleFunc("", handler.List).Methods("GET")
+	protected.Handle("", middleware.Chain(
+		http.HandlerFunc(handler.Create),
+		middleware.RequirePermission(domain.PermissionOrderRefund),
+	)).Methods("POST")
+}
+
+// List handles listing the refunds of an order
+// @Summary List order refunds
+// @Description List every refund of an order, oldest first, including failed refunds
+// @Tags refunds
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {object} response.Response{data=[]domain.Refund}
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /orders/{id}/refunds [get]
+func (h *RefundHandler) List(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.orderID(w, r)
+	if !ok {
+		return
+	}
+
+	// Only the order owner or staff with order:read can see refunds
+	if _, _, ok := authorizeOrder(w, r, h.orderUseCase, h.logger, id, domain.PermissionOrderRead); !ok {
+		return
+	}
+
+	refunds, err := h.refundUseCase.GetByOrderID(r.Context(), id)
+	if err != nil {
+		h.logger.Error("Failed to get refunds", zap.Int64("orderID", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Refunds retrieved successfully", refunds, http.StatusOK)
+}
+
+// Create handles refunding an order
+// @Summary Refund order
+// @Description Refund a completed or cancelled order's captured payment. Without items, "amount" is refunded off the whole order, or everything not refunded yet when omitted. With items, each line refunds "quantity" units at what was paid for them, or "amount" when given; "restock": true returns the units to stock. Refunds never exceed the captured amount, nor a line's units and paid total.
+// @Tags refunds
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param request body domain.RefundCreateDTO true "Refund Request"
+// @Success 201 {object} response.Response{data=domain.Refund}
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 502 {object} response.ProblemDetails
+// @Failure 504 {object} response.ProblemDetails
+// @Router /orders/{id}/refunds [post]
+func (h *RefundHandler) Create(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.orderID(w, r)
+	if !ok {
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Problem(w, r, errors.NewUnauthorizedError(""))
+		return
+	}
+
+	var createDTO domain.RefundCreateDTO
+	if err := json.NewDecoder(r.Body).Decode(&createDTO); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+		return
+	}
+	defer r.Body.Close()
+
+	if !validateRequest(w, r, &createDTO) {
+		return
+	}
+
+	createDTO.ActorID = user.ID
+
+	refund, err := h.refundUseCase.Create(r.Context(), id, &createDTO)
+	if err != nil {
+		h.logger.Error("Failed to refund order", zap.Int64("orderID", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Refund created successfully", refund, http.StatusCreated)
+}
+
+// orderID parses the order ID from the path, writing the error response on failure
+func (h *RefundHandler) orderID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.Error("Failed to parse order ID", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid order ID"))
+		return 0, false
+	}
+	return id, true
+}
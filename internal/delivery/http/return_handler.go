@@ -0,0 +1,280 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"github.com/milad-ahmd/go-clean-arch/pkg/middleware"
+	"github.com/milad-ahmd/go-clean-arch/pkg/response"
+	"go.uber.org/zap"
+)
+
+// ReturnHandler handles HTTP requests for order returns
+type ReturnHandler struct {
+	returnUseCase domain.ReturnUseCase
+	orderUseCase  domain.OrderUseCase
+	logger        logger.Logger
+}
+
+// NewReturnHandler creates a new return handler
+func NewReturnHandler(r *mux.Router, returnUseCase domain.ReturnUseCase, orderUseCase domain.OrderUseCase, userUseCase domain.UserUseCase, logger logger.Logger) {
+	handler := &ReturnHandler{
+		returnUseCase: returnUseCase,
+		orderUseCase:  orderUseCase,
+		logger:        logger,
+	}
+
+	// Protected routes (require authentication)
+	orders := r.PathPrefix("/orders/{id:[0-9]+}/returns").Subrouter()
+	orders.Use(mux.MiddlewareFunc(middleware.Auth(userUseCase, logger)))
+	orders.HandleFunc("", handler.ListByOrder).Methods("GET")
+	orders.HandleFunc("", handler.Create).Methods("POST")
+
+	returns := r.PathPrefix("/returns").Subrouter()
+	returns.Use(mux.MiddlewareFunc(middleware.Auth(userUseCase, logger)))
+	returns.HandleFunc("/{id:[0-9]+}", handler.GetByID).Methods("GET")
+	returns.Handle("/{id:[0-9]+}/status", middleware.Chain(
+		http.HandlerFunc(handler.UpdateStatus),
+		middleware.RequirePermission(domain.PermissionReturnManage),
+	)).Methods("PATCH")
+	returns.Handle("/{id:[0-9]+}/inspect", middleware.Chain(
+		http.HandlerFunc(handler.Inspect),
+		middleware.RequirePermission(domain.PermissionReturnManage),
+	)).Methods("POST")
+}
+
+// ListByOrder handles listing the returns of an order
+// @Summary List order returns
+// @Description List every return of an order, oldest first
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {object} response.Response{data=[]domain.Return}
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /orders/{id}/returns [get]
+func (h *ReturnHandler) ListByOrder(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.parseID(w, r, "order")
+	if !ok {
+		return
+	}
+
+	// Only the order owner or staff with order:read can see returns
+	if _, _, ok := authorizeOrder(w, r, h.orderUseCase, h.logger, id, domain.PermissionOrderRead); !ok {
+		return
+	}
+
+	returns, err := h.returnUseCase.GetByOrderID(r.Context(), id)
+	if err != nil {
+		h.logger.Error("Failed to get returns", zap.Int64("orderID", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Returns retrieved successfully", returns, http.StatusOK)
+}
+
+// Create handles requesting a return
+// @Summary Request return
+// @Description Request the return of units of a completed order. The units of an item across returns that were not rejected cannot exceed the units ordered and not cancelled.
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param request body domain.ReturnCreateDTO true "Return Request"
+// @Success 201 {object} response.Response{data=domain.Return}
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /orders/{id}/returns [post]
+func (h *ReturnHandler) Create(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.parseID(w, r, "order")
+	if !ok {
+		return
+	}
+
+	// Only the order owner or staff with order:write can request a return
+	if _, _, ok := authorizeOrder(w, r, h.orderUseCase, h.logger, id, domain.PermissionOrderWrite); !ok {
+		return
+	}
+
+	var createDTO domain.ReturnCreateDTO
+	if err := json.NewDecoder(r.Body).Decode(&createDTO); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+		return
+	}
+	defer r.Body.Close()
+
+	if !validateRequest(w, r, &createDTO) {
+		return
+	}
+
+	rma, err := h.returnUseCase.Create(r.Context(), id, &createDTO)
+	if err != nil {
+		h.logger.Error("Failed to create return", zap.Int64("orderID", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Return requested successfully", rma, http.StatusCreated)
+}
+
+// GetByID handles getting a return by ID
+// @Summary Get return by ID
+// @Description Get a return with its items
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Success 200 {object} response.Response{data=domain.Return}
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /returns/{id} [get]
+func (h *ReturnHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.parseID(w, r, "return")
+	if !ok {
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Problem(w, r, errors.NewUnauthorizedError(""))
+		return
+	}
+
+	rma, err := h.returnUseCase.GetByID(r.Context(), id)
+	if err != nil {
+		h.logger.Error("Failed to get return", zap.Int64("id", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	// Only the customer or staff with return:manage can see a return
+	if !rma.IsAccessibleBy(user, domain.PermissionReturnManage) {
+		h.logger.Warn("Return access denied", zap.Int64("id", id), zap.Int64("userID", user.ID))
+		response.Problem(w, r, errors.NewForbiddenError(""))
+		return
+	}
+
+	response.Success(w, "Return retrieved successfully", rma, http.StatusOK)
+}
+
+// UpdateStatus handles approving, rejecting and receiving a return
+// @Summary Update return status
+// @Description Approve or reject a requested return, or mark an approved return as received. Returns move from requested to approved or rejected, then to received and inspected; other changes return 409.
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Param request body domain.ReturnStatusUpdateDTO true "Return Status Update Request"
+// @Success 200 {object} response.Response{data=domain.Return}
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /returns/{id}/status [patch]
+func (h *ReturnHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.parseID(w, r, "return")
+	if !ok {
+		return
+	}
+
+	var statusDTO domain.ReturnStatusUpdateDTO
+	if err := json.NewDecoder(r.Body).Decode(&statusDTO); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+		return
+	}
+	defer r.Body.Close()
+
+	if !validateRequest(w, r, &statusDTO) {
+		return
+	}
+
+	rma, err := h.returnUseCase.UpdateStatus(r.Context(), id, &statusDTO)
+	if err != nil {
+		h.logger.Error("Failed to update return status", zap.Int64("id", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Return status updated successfully", rma, http.StatusOK)
+}
+
+// Inspect handles inspecting a received return
+// @Summary Inspect return
+// @Description Record the disposition of every item of a received return, restock or write_off, and apply it to stock
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Param request body domain.ReturnInspectDTO true "Return Inspection Request"
+// @Success 200 {object} response.Response{data=domain.Return}
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /returns/{id}/inspect [post]
+func (h *ReturnHandler) Inspect(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.parseID(w, r, "return")
+	if !ok {
+		return
+	}
+
+	var inspectDTO domain.ReturnInspectDTO
+	if err := json.NewDecoder(r.Body).Decode(&inspectDTO); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+		return
+	}
+	defer r.Body.Close()
+
+	if !validateRequest(w, r, &inspectDTO) {
+		return
+	}
+
+	rma, err := h.returnUseCase.Inspect(r.Context(), id, &inspectDTO)
+	if err != nil {
+		h.logger.Error("Failed to inspect return", zap.Int64("id", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	response.Success(w, "Return inspected successfully", rma, http.StatusOK)
+}
+
+// parseID parses the ID in the path, writing the error response on failure.
+// entity names it in the error, e.g. "Invalid order ID".
+func (h *ReturnHandler) parseID(w http.ResponseWriter, r *http.Request, entity string) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.Error("Failed to parse "+entity+" ID", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid "+entity+" ID"))
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,255 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"github.com/milad-ahmd/go-clean-arch/pkg/response"
+	"go.uber.org/zap"
+)
+
+// RoleHandler handles HTTP requests for roles and permissions
+type RoleHandler struct {
+	roleUseCase domain.RoleUseCase
+	logger      logger.Logger
+}
+
+// NewRoleHandler creates a new role handler
+func NewRoleHandler(r *mux.Router, roleUseCase domain.RoleUseCase, userUseCase domain.UserUseCase, logger logger.Logger) {
+	handler := &RoleHandler{
+		roleUseCase: roleUseCase,
+		logger:      logger,
+	}
+
+	// Every route requires role:manage
+	r.Handle("/roles", requirePermission(handler.List, userUseCase, logger, domain.PermissionRoleManage)).Methods("GET")
+	r.Handle("/roles", requirePermission(handler.Create, userUseCase, logger, domain.PermissionRoleManage)).Methods("POST")
+	r.Handle("/roles/{name}", requirePermission(handler.GetByName, userUseCase, logger, domain.PermissionRoleManage)).Methods("GET")
+	r.Handle("/roles/{name}", requirePermission(handler.Update, userUseCase, logger, domain.PermissionRoleManage)).Methods("PUT")
+	r.Handle("/roles/{name}", requirePermission(handler.Delete, userUseCase, logger, domain.PermissionRoleManage)).Methods("DELETE")
+	r.Handle("/permissions", requirePermission(handler.ListPermissions, userUseCase, logger, domain.PermissionRoleManage)).Methods("GET")
+	r.Handle("/users/{id:[0-9]+}/role", requirePermission(handler.AssignRole, userUseCase, logger, domain.PermissionRoleManage)).Methods("PUT")
+}
+
+// List handles listing roles
+// @Summary List roles
+// @Description List every role with its permissions
+// @Tags roles
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} domain.RoleDefinition
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /roles [get]
+func (h *RoleHandler) List(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.roleUseCase.List(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to list roles", zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, roles)
+}
+
+// GetByName handles getting a role by name
+// @Summary Get role
+// @Description Get a role and its permissions by name
+// @Tags roles
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Role name"
+// @Success 200 {object} domain.RoleDefinition
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /roles/{name} [get]
+func (h *RoleHandler) GetByName(w http.ResponseWriter, r *http.Request) {
+	name := domain.Role(mux.Vars(r)["name"])
+
+	role, err := h.roleUseCase.GetByName(r.Context(), name)
+	if err != nil {
+		h.logger.Error("Failed to get role", zap.String("name", string(name)), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, role)
+}
+
+// Create handles the creation of a new role
+// @Summary Create role
+// @Description Create a new role with a set of permissions
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body domain.RoleCreateDTO true "Role Create Request"
+// @Success 201 {object} domain.RoleDefinition
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /roles [post]
+func (h *RoleHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var createDTO domain.RoleCreateDTO
+	if err := json.NewDecoder(r.Body).Decode(&createDTO); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+		return
+	}
+	defer r.Body.Close()
+
+	if !validateRequest(w, r, &createDTO) {
+		return
+	}
+
+	role, err := h.roleUseCase.Create(r.Context(), &createDTO)
+	if err != nil {
+		h.logger.Error("Failed to create role", zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, role)
+}
+
+// Update handles updating a role
+// @Summary Update role
+// @Description Update a role's description and replace its permissions
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Role name"
+// @Param request body domain.RoleUpdateDTO true "Role Update Request"
+// @Success 200 {object} domain.RoleDefinition
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /roles/{name} [put]
+func (h *RoleHandler) Update(w http.ResponseWriter, r *http.Request) {
+	name := domain.Role(mux.Vars(r)["name"])
+
+	var updateDTO domain.RoleUpdateDTO
+	if err := json.NewDecoder(r.Body).Decode(&updateDTO); err != nil {
+		h.logger.Error("Failed to decode request body for update", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+		return
+	}
+	defer r.Body.Close()
+
+	if !validateRequest(w, r, &updateDTO) {
+		return
+	}
+
+	role, err := h.roleUseCase.Update(r.Context(), name, &updateDTO)
+	if err != nil {
+		h.logger.Error("Failed to update role", zap.String("name", string(name)), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, role)
+}
+
+// Delete handles deleting a role
+// @Summary Delete role
+// @Description Delete a role that is not built in and not assigned to any user
+// @Tags roles
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Role name"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 409 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /roles/{name} [delete]
+func (h *RoleHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	name := domain.Role(mux.Vars(r)["name"])
+
+	if err := h.roleUseCase.Delete(r.Context(), name); err != nil {
+		h.logger.Error("Failed to delete role", zap.String("name", string(name)), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Role deleted successfully",
+	})
+}
+
+// ListPermissions handles listing the permissions that can be granted
+// @Summary List permissions
+// @Description List every permission that can be granted to a role
+// @Tags roles
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} string
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Router /permissions [get]
+func (h *RoleHandler) ListPermissions(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, domain.AllPermissions)
+}
+
+// AssignRole handles assigning a role to a user
+// @Summary Assign role
+// @Description Assign a role to a user
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body domain.RoleAssignDTO true "Role Assign Request"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} response.ProblemDetails
+// @Failure 401 {object} response.ProblemDetails
+// @Failure 403 {object} response.ProblemDetails
+// @Failure 404 {object} response.ProblemDetails
+// @Failure 500 {object} response.ProblemDetails
+// @Router /users/{id}/role [put]
+func (h *RoleHandler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.Error("Failed to parse user ID for role assignment", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid user ID"))
+		return
+	}
+
+	var assignDTO domain.RoleAssignDTO
+	if err := json.NewDecoder(r.Body).Decode(&assignDTO); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+		return
+	}
+	defer r.Body.Close()
+
+	if !validateRequest(w, r, &assignDTO) {
+		return
+	}
+
+	if err := h.roleUseCase.AssignRole(r.Context(), id, assignDTO.Role); err != nil {
+		h.logger.Error("Failed to assign role", zap.Int64("id", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Role assigned successfully",
+	})
+}
@@ -3,11 +3,15 @@ package http
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
 	"github.com/milad-ahmd/go-clean-arch/pkg/config"
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
 	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
 	"github.com/milad-ahmd/go-clean-arch/pkg/middleware"
+	"github.com/milad-ahmd/go-clean-arch/pkg/response"
 )
 
 // Server represents the HTTP server
@@ -45,6 +49,9 @@ func (s *Server) Router() *mux.Router {
 // SetupMiddleware sets up the middleware
 func (s *Server) SetupMiddleware() {
 	// Apply middleware to all routes
+	s.router.Use(func(next http.Handler) http.Handler {
+		return middleware.RequestID()(next)
+	})
 	s.router.Use(func(next http.Handler) http.Handler {
 		return middleware.Logger(s.logger)(next)
 	})
@@ -54,6 +61,32 @@ func (s *Server) SetupMiddleware() {
 	s.router.Use(func(next http.Handler) http.Handler {
 		return middleware.Recover(s.logger)(next)
 	})
+
+	// Unmatched routes bypass the middleware above, so give them a request ID explicitly
+	s.router.NotFoundHandler = middleware.RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response.Problem(w, r, errors.NewAppError(errors.ErrNotFound, "No route matches "+r.URL.Path, http.StatusNotFound))
+	}))
+	s.router.MethodNotAllowedHandler = middleware.RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response.Problem(w, r, errors.NewAppError(errors.ErrInvalidInput, "Method "+r.Method+" is not allowed", http.StatusMethodNotAllowed))
+	}))
+}
+
+// UseIdempotency replays the stored responses of mutating requests repeated
+// with the same Idempotency-Key, keeping each key for ttl
+func (s *Server) UseIdempotency(store domain.IdempotencyRepository, userUseCase domain.UserUseCase, ttl time.Duration) {
+	s.router.Use(func(next http.Handler) http.Handler {
+		return middleware.Idempotency(store, userUseCase, ttl, s.logger)(next)
+	})
+}
+
+// requirePermission wraps a handler so that it is only reachable by
+// authenticated users holding every listed permission
+func requirePermission(handler http.HandlerFunc, userUseCase domain.UserUseCase, logger logger.Logger, permissions ...domain.Permission) http.Handler {
+	return middleware.Chain(
+		handler,
+		middleware.RequirePermission(permissions...),
+		middleware.Auth(userUseCase, logger),
+	)
 }
 
 // Start starts the server
@@ -2,14 +2,15 @@ package http
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
 	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"github.com/milad-ahmd/go-clean-arch/pkg/response"
 	"go.uber.org/zap"
 )
 
@@ -26,11 +27,12 @@ func NewUserHandler(r *mux.Router, userUseCase domain.UserUseCase, logger logger
 		logger:      logger,
 	}
 
-	r.HandleFunc("/users", handler.Create).Methods("POST")
-	r.HandleFunc("/users", handler.List).Methods("GET")
-	r.HandleFunc("/users/{id:[0-9]+}", handler.GetByID).Methods("GET")
-	r.HandleFunc("/users/{id:[0-9]+}", handler.Update).Methods("PUT")
-	r.HandleFunc("/users/{id:[0-9]+}", handler.Delete).Methods("DELETE")
+	r.Handle("/users", requirePermission(handler.Create, userUseCase, logger, domain.PermissionUserWrite)).Methods("POST")
+	r.Handle("/users", requirePermission(handler.List, userUseCase, logger, domain.PermissionUserRead)).Methods("GET")
+	r.Handle("/users/{id:[0-9]+}", requirePermission(handler.GetByID, userUseCase, logger, domain.PermissionUserRead)).Methods("GET")
+	r.Handle("/users/{id:[0-9]+}", requirePermission(handler.Update, userUseCase, logger, domain.PermissionUserWrite)).Methods("PUT")
+	r.Handle("/users/{id:[0-9]+}", requirePermission(handler.Delete, userUseCase, logger, domain.PermissionUserWrite)).Methods("DELETE")
+	r.Handle("/users/{id:[0-9]+}/sessions", requirePermission(handler.RevokeSessions, userUseCase, logger, domain.PermissionSessionRevoke)).Methods("DELETE")
 }
 
 // Create handles the creation of a new user
@@ -38,25 +40,20 @@ func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var user domain.User
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
 		h.logger.Error("Failed to decode request body", zap.Error(err))
-		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
 		return
 	}
 	defer r.Body.Close()
 
 	// Validate user input
 	if user.Username == "" || user.Email == "" || user.Password == "" {
-		respondWithError(w, http.StatusBadRequest, "Username, email, and password are required")
+		response.Problem(w, r, errors.NewBadRequestError("Username, email, and password are required"))
 		return
 	}
 
 	if err := h.userUseCase.Create(r.Context(), &user); err != nil {
 		h.logger.Error("Failed to create user", zap.Error(err))
-		var conflictErr *domain.ConflictError
-		if errors.As(err, &conflictErr) {
-			respondWithError(w, http.StatusConflict, conflictErr.Error())
-			return
-		}
-		respondWithError(w, http.StatusInternalServerError, "Failed to create user")
+		response.Problem(w, r, err)
 		return
 	}
 
@@ -74,19 +71,14 @@ func (h *UserHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
 		h.logger.Error("Failed to parse user ID", zap.Error(err))
-		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		response.Problem(w, r, errors.NewBadRequestError("Invalid user ID"))
 		return
 	}
 
 	user, err := h.userUseCase.GetByID(r.Context(), id)
 	if err != nil {
 		h.logger.Error("Failed to get user", zap.Int64("id", id), zap.Error(err))
-		var notFoundErr *domain.NotFoundError
-		if errors.As(err, &notFoundErr) {
-			respondWithError(w, http.StatusNotFound, notFoundErr.Error())
-			return
-		}
-		respondWithError(w, http.StatusInternalServerError, "Failed to get user")
+		response.Problem(w, r, err)
 		return
 	}
 
@@ -99,14 +91,14 @@ func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
 		h.logger.Error("Failed to parse user ID for update", zap.Error(err))
-		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		response.Problem(w, r, errors.NewBadRequestError("Invalid user ID"))
 		return
 	}
 
 	var user domain.User
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
 		h.logger.Error("Failed to decode request body for update", zap.Error(err))
-		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
 		return
 	}
 	defer r.Body.Close()
@@ -116,17 +108,7 @@ func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
 
 	if err := h.userUseCase.Update(r.Context(), &user); err != nil {
 		h.logger.Error("Failed to update user", zap.Int64("id", id), zap.Error(err))
-		var notFoundErr *domain.NotFoundError
-		if errors.As(err, &notFoundErr) {
-			respondWithError(w, http.StatusNotFound, notFoundErr.Error())
-			return
-		}
-		var conflictErr *domain.ConflictError
-		if errors.As(err, &conflictErr) {
-			respondWithError(w, http.StatusConflict, conflictErr.Error())
-			return
-		}
-		respondWithError(w, http.StatusInternalServerError, "Failed to update user")
+		response.Problem(w, r, err)
 		return
 	}
 
@@ -141,18 +123,13 @@ func (h *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
 		h.logger.Error("Failed to parse user ID for deletion", zap.Error(err))
-		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		response.Problem(w, r, errors.NewBadRequestError("Invalid user ID"))
 		return
 	}
 
 	if err := h.userUseCase.Delete(r.Context(), id); err != nil {
 		h.logger.Error("Failed to delete user", zap.Int64("id", id), zap.Error(err))
-		var notFoundErr *domain.NotFoundError
-		if errors.As(err, &notFoundErr) {
-			respondWithError(w, http.StatusNotFound, notFoundErr.Error())
-			return
-		}
-		respondWithError(w, http.StatusInternalServerError, "Failed to delete user")
+		response.Problem(w, r, err)
 		return
 	}
 
@@ -185,21 +162,42 @@ func (h *UserHandler) List(w http.ResponseWriter, r *http.Request) {
 	users, err := h.userUseCase.List(r.Context(), limit, offset)
 	if err != nil {
 		h.logger.Error("Failed to list users", zap.Int("limit", limit), zap.Int("offset", offset), zap.Error(err))
-		respondWithError(w, http.StatusInternalServerError, "Failed to list users")
+		response.Problem(w, r, err)
 		return
 	}
 
 	respondWithJSON(w, http.StatusOK, users)
 }
 
-// respondWithError responds with an error message
-func respondWithError(w http.ResponseWriter, code int, message string) {
-	respondWithJSON(w, code, map[string]string{"error": message})
+// RevokeSessions handles revoking every refresh token of a user
+func (h *UserHandler) RevokeSessions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.Error("Failed to parse user ID for session revocation", zap.Error(err))
+		response.Problem(w, r, errors.NewBadRequestError("Invalid user ID"))
+		return
+	}
+
+	if _, err := h.userUseCase.GetByID(r.Context(), id); err != nil {
+		response.Problem(w, r, err)
+		return
+	}
+
+	if err := h.userUseCase.RevokeSessions(r.Context(), id); err != nil {
+		h.logger.Error("Failed to revoke sessions", zap.Int64("id", id), zap.Error(err))
+		response.Problem(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Sessions revoked successfully",
+	})
 }
 
 // respondWithJSON responds with JSON
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
-	response, err := json.Marshal(payload)
+	body, err := json.Marshal(payload)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		_, writeErr := w.Write([]byte("Internal Server Error"))
@@ -212,7 +210,7 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
-	_, writeErr := w.Write(response)
+	_, writeErr := w.Write(body)
 	if writeErr != nil {
 		// Can't do much if writing fails, just log it
 		fmt.Println("Error writing response:", writeErr)
@@ -0,0 +1,19 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/milad-ahmd/go-clean-arch/pkg/response"
+	"github.com/milad-ahmd/go-clean-arch/pkg/validator"
+)
+
+// validateRequest checks a decoded request body against its validate tags.
+// It writes a 400 problem response listing the invalid fields and returns
+// false if any rule fails.
+func validateRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := validator.Validate(v); err != nil {
+		response.Problem(w, r, err)
+		return false
+	}
+	return true
+}
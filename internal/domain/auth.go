@@ -1,5 +1,10 @@
 package domain
 
+import (
+	"context"
+	"time"
+)
+
 // LoginRequest represents the login request
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email"`
@@ -13,20 +18,75 @@ type RegisterRequest struct {
 	Password string `json:"password" validate:"required,min=6"`
 }
 
+// RefreshRequest represents the refresh token request
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// LogoutRequest represents the logout request
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	All          bool   `json:"all"`
+}
+
 // TokenResponse represents the token response
 type TokenResponse struct {
-	Token string `json:"token"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
 }
 
 // JWTService represents the JWT service contract
 type JWTService interface {
-	GenerateToken(userID int64, username string, role Role) (string, error)
-	ValidateToken(token string) (*JWTClaims, error)
+	GenerateToken(userID int64, username string, role Role) (string, *JWTClaims, error)
+	GenerateRefreshToken() (string, time.Time, error)
+	ValidateToken(ctx context.Context, token string) (*JWTClaims, error)
+	RevokeToken(ctx context.Context, claims *JWTClaims) error
 }
 
 // JWTClaims represents the JWT claims
 type JWTClaims struct {
-	UserID   int64  `json:"user_id"`
-	Username string `json:"username"`
-	Role     Role   `json:"role"`
+	ID        string    `json:"jti"`
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	Role      Role      `json:"role"`
+	ExpiresAt time.Time `json:"exp"`
+}
+
+// RefreshToken represents a persisted refresh token. Only the hash of the
+// token is stored; tokens issued by rotating each other share a FamilyID so
+// that reuse of a rotated token can revoke the whole chain.
+type RefreshToken struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	TokenHash string     `json:"-"`
+	FamilyID  string     `json:"family_id"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// IsExpired reports whether the refresh token is past its expiry
+func (t *RefreshToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// IsRevoked reports whether the refresh token has been revoked or rotated
+func (t *RefreshToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
+
+// RefreshTokenRepository represents the refresh token repository contract
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *RefreshToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*RefreshToken, error)
+	Revoke(ctx context.Context, id int64) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeAllForUser(ctx context.Context, userID int64) error
+}
+
+// RevokedTokenRepository represents the store of revoked access token IDs
+type RevokedTokenRepository interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
 }
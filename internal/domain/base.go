@@ -2,12 +2,13 @@ package domain
 
 import (
 	"context"
+	"time"
 )
 
 // BaseRepository defines the base repository interface
 type BaseRepository[T any, ID any] interface {
 	FindByID(ctx context.Context, id ID) (*T, error)
-	FindAll(ctx context.Context, limit, offset int) ([]T, int, error)
+	FindAll(ctx context.Context, page PageRequest) (*Page[T], error)
 	Create(ctx context.Context, entity *T) error
 	Update(ctx context.Context, entity *T) error
 	Delete(ctx context.Context, id ID) error
@@ -16,7 +17,7 @@ type BaseRepository[T any, ID any] interface {
 // BaseUseCase defines the base use case interface
 type BaseUseCase[T any, ID any, C any, U any] interface {
 	GetByID(ctx context.Context, id ID) (*T, error)
-	List(ctx context.Context, limit, offset int) ([]T, int, error)
+	List(ctx context.Context, page PageRequest) (*Page[T], error)
 	Create(ctx context.Context, createDTO *C) (*T, error)
 	Update(ctx context.Context, id ID, updateDTO *U) (*T, error)
 	Delete(ctx context.Context, id ID) error
@@ -47,8 +48,9 @@ func (p *Pagination) GetLimit() int {
 	return p.PerPage
 }
 
-// BaseEntity defines common fields for all entities
+// BaseEntity defines common fields for all entities. Timestamps are stored
+// as TIMESTAMPTZ, kept in UTC and encoded as RFC 3339 in JSON.
 type BaseEntity struct {
-	CreatedAt int64 `json:"created_at"`
-	UpdatedAt int64 `json:"updated_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
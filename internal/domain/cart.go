@@ -0,0 +1,151 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// CartTokenHeader is the request header carrying the token of an anonymous cart
+const CartTokenHeader = "X-Cart-Token"
+
+// CartIssueCode identifies a problem found when a cart is revalidated
+type CartIssueCode string
+
+const (
+	// CartIssuePriceChanged means the item's price changed since it was
+	// added or last viewed; the cart now shows the current price
+	CartIssuePriceChanged CartIssueCode = "price_changed"
+	// CartIssueInsufficientStock means fewer units are in stock than the
+	// item's quantity
+	CartIssueInsufficientStock CartIssueCode = "insufficient_stock"
+	// CartIssueUnavailable means the item can no longer be ordered as it
+	// is, such as a product that is now sold by variant
+	CartIssueUnavailable CartIssueCode = "unavailable"
+	// CartIssueCurrencyMismatch means the item is priced in a different
+	// currency than the rest of the cart
+	CartIssueCurrencyMismatch CartIssueCode = "currency_mismatch"
+)
+
+// CartIssue is a problem with a cart item that the customer should review
+// before checking out
+type CartIssue struct {
+	ItemID  int64         `json:"item_id"`
+	Code    CartIssueCode `json:"code"`
+	Message string        `json:"message"`
+}
+
+// CartChangedError is returned when a cart cannot be checked out until the
+// customer has reviewed its issues
+type CartChangedError struct {
+	Issues []CartIssue
+}
+
+// Error returns the error message
+func (e *CartChangedError) Error() string {
+	return "cart has changed and must be reviewed before checkout"
+}
+
+// Is checks if the error is of the given type
+func (e *CartChangedError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// ErrorCode returns the stable error code
+func (e *CartChangedError) ErrorCode() string {
+	return "cart_changed"
+}
+
+// Violations returns the issues of each cart item
+func (e *CartChangedError) Violations() map[string]interface{} {
+	messages := make(map[string][]string)
+	for _, issue := range e.Issues {
+		key := fmt.Sprintf("items[%d]", issue.ItemID)
+		messages[key] = append(messages[key], issue.Message)
+	}
+
+	violations := make(map[string]interface{}, len(messages))
+	for key, itemMessages := range messages {
+		violations[key] = strings.Join(itemMessages, "; ")
+	}
+	return violations
+}
+
+// Cart is a customer's basket of products waiting to be ordered. A cart
+// belongs to a user, or is anonymous and addressed by a random token that
+// is only returned when the cart is created.
+type Cart struct {
+	ID       int64       `json:"id"`
+	UserID   *int64      `json:"user_id,omitempty"`
+	Token    string      `json:"token,omitempty"`
+	Items    []CartItem  `json:"items"`
+	Subtotal *Money      `json:"subtotal,omitempty"`
+	Issues   []CartIssue `json:"issues"`
+	BaseEntity
+}
+
+// CartItem is a quantity of a product, or of one of its variants, in a cart.
+// Price is the unit price the customer last saw.
+type CartItem struct {
+	ID        int64           `json:"id"`
+	CartID    int64           `json:"cart_id"`
+	ProductID int64           `json:"product_id"`
+	Product   *Product        `json:"product,omitempty"`
+	VariantID *int64          `json:"variant_id,omitempty"`
+	Variant   *ProductVariant `json:"variant,omitempty"`
+	Quantity  int             `json:"quantity"`
+	Price     Money           `json:"price"`
+	BaseEntity
+}
+
+// CartOwner identifies a cart: the cart of a signed-in user when UserID is
+// set, otherwise the anonymous cart with the given token
+type CartOwner struct {
+	UserID int64
+	Token  string
+}
+
+// IsAnonymous reports whether the owner is not signed in
+func (o CartOwner) IsAnonymous() bool {
+	return o.UserID == 0
+}
+
+// CartItemCreateDTO represents the data for adding a product to a cart
+type CartItemCreateDTO struct {
+	ProductID int64  `json:"product_id" validate:"required,gt=0"`
+	VariantID *int64 `json:"variant_id,omitempty" validate:"omitempty,gt=0"`
+	Quantity  int    `json:"quantity" validate:"required,gt=0"`
+}
+
+// CartItemUpdateDTO represents the data for changing the quantity of a cart item
+type CartItemUpdateDTO struct {
+	Quantity int `json:"quantity" validate:"required,gt=0"`
+}
+
+// CartCheckoutDTO represents the data for turning a cart into an order
+type CartCheckoutDTO struct {
+	PaymentMethod PaymentMethod   `json:"payment_method" validate:"required,oneof=credit_card paypal bank_transfer"`
+	ShippingInfo  ShippingInfoDTO `json:"shipping_info" validate:"required"`
+	CouponCode    string          `json:"coupon_code,omitempty" validate:"max=50"`
+}
+
+// CartRepository defines the cart repository interface
+type CartRepository interface {
+	FindOrCreateByUserID(ctx context.Context, userID int64) (*Cart, error)
+	FindByTokenHash(ctx context.Context, tokenHash string) (*Cart, error)
+	CreateAnonymous(ctx context.Context, tokenHash string) (*Cart, error)
+	AddItem(ctx context.Context, item *CartItem) error
+	UpdateItem(ctx context.Context, item *CartItem) error
+	RemoveItem(ctx context.Context, cartID, itemID int64) error
+	Merge(ctx context.Context, fromCartID, toCartID int64) error
+}
+
+// CartUseCase defines the cart use case interface
+type CartUseCase interface {
+	Get(ctx context.Context, owner CartOwner) (*Cart, error)
+	AddItem(ctx context.Context, owner CartOwner, createDTO *CartItemCreateDTO) (*Cart, error)
+	UpdateItem(ctx context.Context, owner CartOwner, itemID int64, updateDTO *CartItemUpdateDTO) (*Cart, error)
+	RemoveItem(ctx context.Context, owner CartOwner, itemID int64) (*Cart, error)
+	Merge(ctx context.Context, token string, userID int64) error
+	Checkout(ctx context.Context, userID int64, checkoutDTO *CartCheckoutDTO) (*Order, error)
+}
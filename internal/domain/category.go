@@ -2,22 +2,103 @@ package domain
 
 import (
 	"context"
+	"fmt"
+	"strings"
 )
 
-// Category represents a product category
+// Category represents a product category. Categories form a tree: ParentID
+// is nil for top-level categories. Version is incremented by every update and
+// move of the category.
 type Category struct {
 	ID          int64  `json:"id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	Slug        string `json:"slug"`
+	ParentID    *int64 `json:"parent_id"`
+	Version     int64  `json:"version"`
+	// Path is the materialized path of IDs from the top-level category down
+	// to this one, such as "/1/4/9/"
+	Path string `json:"-"`
 	BaseEntity
 }
 
+// Contains reports whether other is c or one of its descendants
+func (c Category) Contains(other Category) bool {
+	return strings.HasPrefix(other.Path, c.Path)
+}
+
+// ChildPath returns the path of a child of c with the given ID
+func (c Category) ChildPath(id int64) string {
+	return c.Path + fmt.Sprintf("%d/", id)
+}
+
+// CategoryRootPath returns the path of a top-level category
+func CategoryRootPath(id int64) string {
+	return fmt.Sprintf("/%d/", id)
+}
+
+// CategoryCycleError reports a move that would place a category under itself
+// or one of its descendants
+type CategoryCycleError struct {
+	ID       int64
+	ParentID int64
+}
+
+// Error returns the error message
+func (e *CategoryCycleError) Error() string {
+	if e.ID == e.ParentID {
+		return fmt.Sprintf("category %d cannot be its own parent", e.ID)
+	}
+	return fmt.Sprintf("category %d cannot be moved under its descendant %d", e.ID, e.ParentID)
+}
+
+// Is checks if the error is of the given type
+func (e *CategoryCycleError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// ErrorCode returns the stable error code
+func (e *CategoryCycleError) ErrorCode() string {
+	return "category_cycle"
+}
+
+// CategoryNode is a category with its subcategories
+type CategoryNode struct {
+	Category
+	Children []*CategoryNode `json:"children"`
+}
+
+// BuildCategoryTree arranges categories into trees. Categories whose parent
+// is not among them become roots. Siblings keep their order in categories.
+func BuildCategoryTree(categories []Category) []*CategoryNode {
+	nodes := make(map[int64]*CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &CategoryNode{Category: category, Children: []*CategoryNode{}}
+	}
+
+	roots := []*CategoryNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if category.ParentID != nil {
+			if parent, ok := nodes[*category.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	return roots
+}
+
 // CategoryRepository defines the category repository interface
 type CategoryRepository interface {
 	BaseRepository[Category, int64]
 	FindBySlug(ctx context.Context, slug string) (*Category, error)
 	FindByName(ctx context.Context, name string) (*Category, error)
+	FindTree(ctx context.Context) ([]Category, error)
+	FindSubtree(ctx context.Context, id int64) ([]Category, error)
+	FindAncestors(ctx context.Context, id int64) ([]Category, error)
+	Move(ctx context.Context, id int64, parentID *int64) error
 }
 
 // CategoryCreateDTO represents the data for creating a category
@@ -25,6 +106,7 @@ type CategoryCreateDTO struct {
 	Name        string `json:"name" validate:"required,min=3,max=100"`
 	Description string `json:"description" validate:"max=500"`
 	Slug        string `json:"slug" validate:"required,min=3,max=100,alphanum"`
+	ParentID    *int64 `json:"parent_id,omitempty" validate:"omitempty,gt=0"`
 }
 
 // CategoryUpdateDTO represents the data for updating a category
@@ -32,10 +114,21 @@ type CategoryUpdateDTO struct {
 	Name        string `json:"name" validate:"omitempty,min=3,max=100"`
 	Description string `json:"description" validate:"max=500"`
 	Slug        string `json:"slug" validate:"omitempty,min=3,max=100,alphanum"`
+	Version     int64  `json:"-"` // Taken from the If-Match header; zero updates any version
+}
+
+// CategoryMoveDTO represents the data for moving a category. A nil ParentID
+// moves the category to the top level.
+type CategoryMoveDTO struct {
+	ParentID *int64 `json:"parent_id" validate:"omitempty,gt=0"`
 }
 
 // CategoryUseCase defines the category use case interface
 type CategoryUseCase interface {
 	BaseUseCase[Category, int64, CategoryCreateDTO, CategoryUpdateDTO]
 	GetBySlug(ctx context.Context, slug string) (*Category, error)
+	GetTree(ctx context.Context) ([]*CategoryNode, error)
+	GetSubtree(ctx context.Context, id int64) (*CategoryNode, error)
+	GetBreadcrumbs(ctx context.Context, id int64) ([]Category, error)
+	Move(ctx context.Context, id int64, moveDTO *CategoryMoveDTO) (*Category, error)
 }
@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestBuildCategoryTree tests arranging categories into trees
+func TestBuildCategoryTree(t *testing.T) {
+	parent := func(id int64) *int64 { return &id }
+	categories := []Category{
+		{ID: 1, Name: "Clothing", Path: "/1/"},
+		{ID: 4, Name: "Hats", ParentID: parent(1), Path: "/1/4/"},
+		{ID: 2, Name: "Shoes", ParentID: parent(1), Path: "/1/2/"},
+		{ID: 3, Name: "Boots", ParentID: parent(2), Path: "/1/2/3/"},
+		{ID: 5, Name: "Toys", Path: "/5/"},
+	}
+
+	roots := BuildCategoryTree(categories)
+	if len(roots) != 2 || roots[0].ID != 1 || roots[1].ID != 5 {
+		t.Fatalf("Expected roots 1 and 5, got %+v", roots)
+	}
+	clothing := roots[0]
+	if len(clothing.Children) != 2 || clothing.Children[0].ID != 4 || clothing.Children[1].ID != 2 {
+		t.Fatalf("Expected children 4 and 2 in input order, got %+v", clothing.Children)
+	}
+	if boots := clothing.Children[1].Children; len(boots) != 1 || boots[0].ID != 3 {
+		t.Errorf("Expected Boots under Shoes, got %+v", boots)
+	}
+	if roots[1].Children == nil {
+		t.Error("Expected an empty, non-nil children slice for leaves")
+	}
+
+	// A subtree's root has its parent outside the set
+	subtree := BuildCategoryTree(categories[2:4])
+	if len(subtree) != 1 || subtree[0].ID != 2 || len(subtree[0].Children) != 1 {
+		t.Errorf("Expected Shoes as the only root, got %+v", subtree)
+	}
+}
+
+// TestCategory_Contains tests the descendant check that prevents cycles
+func TestCategory_Contains(t *testing.T) {
+	shoes := Category{ID: 2, Path: "/1/2/"}
+	boots := Category{ID: 3, Path: shoes.ChildPath(3)}
+	if boots.Path != "/1/2/3/" {
+		t.Fatalf("Expected path /1/2/3/, got %s", boots.Path)
+	}
+
+	tests := []struct {
+		name     string
+		category Category
+		other    Category
+		want     bool
+	}{
+		{name: "itself", category: shoes, other: shoes, want: true},
+		{name: "child", category: shoes, other: boots, want: true},
+		{name: "parent", category: boots, other: shoes, want: false},
+		{name: "ID prefix", category: Category{ID: 1, Path: "/1/"}, other: Category{ID: 12, Path: "/12/"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.category.Contains(tt.other); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestCategoryCycleError tests that cycles are reported as conflicts
+func TestCategoryCycleError(t *testing.T) {
+	err := error(&CategoryCycleError{ID: 2, ParentID: 3})
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+	if code := err.(*CategoryCycleError).ErrorCode(); code != "category_cycle" {
+		t.Errorf("Expected code category_cycle, got %s", code)
+	}
+}
@@ -0,0 +1,330 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CouponType represents how a coupon discounts an order
+type CouponType string
+
+const (
+	// CouponTypePercentage takes a percentage off each eligible line
+	CouponTypePercentage CouponType = "percentage"
+
+	// CouponTypeFixed takes a fixed amount off the eligible lines together
+	CouponTypeFixed CouponType = "fixed"
+)
+
+// Coupon is a discount code customers can redeem when placing an order.
+// A coupon without product or category rules applies to every line;
+// otherwise it applies to lines of the listed products and of products in
+// the listed categories or their subcategories. AmountOff and MinOrderValue
+// share one currency.
+type Coupon struct {
+	ID             int64      `json:"id"`
+	Code           string     `json:"code"`
+	Description    string     `json:"description"`
+	Type           CouponType `json:"type"`
+	PercentOff     int        `json:"percent_off,omitempty"`
+	AmountOff      *Money     `json:"amount_off,omitempty"`
+	MinOrderValue  *Money     `json:"min_order_value,omitempty"`
+	ProductIDs     []int64    `json:"product_ids"`
+	CategoryIDs    []int64    `json:"category_ids"`
+	MaxUses        *int       `json:"max_uses,omitempty"`
+	MaxUsesPerUser *int       `json:"max_uses_per_user,omitempty"`
+	Uses           int        `json:"uses"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	EndsAt         *time.Time `json:"ends_at,omitempty"`
+	Active         bool       `json:"active"`
+	BaseEntity
+}
+
+// NormalizeCouponCode returns the canonical form of a coupon code; codes are
+// matched case-insensitively
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Validate checks that the coupon's discount, limits and validity window
+// are consistent
+func (c *Coupon) Validate() error {
+	switch c.Type {
+	case CouponTypePercentage:
+		if c.PercentOff < 1 || c.PercentOff > 100 {
+			return &ValidationError{Field: "percent_off", Message: "must be between 1 and 100 for percentage coupons"}
+		}
+		if c.AmountOff != nil {
+			return &ValidationError{Field: "amount_off", Message: "is only allowed for fixed coupons"}
+		}
+	case CouponTypeFixed:
+		if c.AmountOff == nil || !c.AmountOff.IsPositive() {
+			return &ValidationError{Field: "amount_off", Message: "must be positive for fixed coupons"}
+		}
+		if c.PercentOff != 0 {
+			return &ValidationError{Field: "percent_off", Message: "is only allowed for percentage coupons"}
+		}
+	default:
+		return &ValidationError{Field: "type", Message: "must be percentage or fixed"}
+	}
+
+	if c.MinOrderValue != nil {
+		if !c.MinOrderValue.IsPositive() {
+			return &ValidationError{Field: "min_order_value", Message: "must be positive"}
+		}
+		if c.AmountOff != nil && c.AmountOff.Currency != c.MinOrderValue.Currency {
+			return &ValidationError{Field: "min_order_value", Message: "must be in the currency of amount_off"}
+		}
+	}
+
+	if c.StartsAt != nil && c.EndsAt != nil && !c.EndsAt.After(*c.StartsAt) {
+		return &ValidationError{Field: "ends_at", Message: "must be after starts_at"}
+	}
+
+	return nil
+}
+
+// CouponRejection identifies why a coupon cannot be redeemed
+type CouponRejection string
+
+const (
+	// CouponRejectionUnknown means no coupon has the code
+	CouponRejectionUnknown CouponRejection = "unknown"
+	// CouponRejectionInactive means the coupon has been switched off
+	CouponRejectionInactive CouponRejection = "inactive"
+	// CouponRejectionNotStarted means the coupon's validity window has not opened
+	CouponRejectionNotStarted CouponRejection = "not_started"
+	// CouponRejectionExpired means the coupon's validity window has closed
+	CouponRejectionExpired CouponRejection = "expired"
+	// CouponRejectionUsageLimit means the coupon has been redeemed as often as allowed
+	CouponRejectionUsageLimit CouponRejection = "usage_limit_reached"
+	// CouponRejectionUserLimit means the user has redeemed the coupon as often as allowed
+	CouponRejectionUserLimit CouponRejection = "user_limit_reached"
+	// CouponRejectionMinimum means the order is below the coupon's minimum value
+	CouponRejectionMinimum CouponRejection = "minimum_not_met"
+	// CouponRejectionNotApplicable means no line of the order is eligible
+	CouponRejectionNotApplicable CouponRejection = "not_applicable"
+	// CouponRejectionCurrency means the order is in another currency than the coupon
+	CouponRejectionCurrency CouponRejection = "currency_mismatch"
+)
+
+// CouponError is returned when a coupon cannot be applied to an order
+type CouponError struct {
+	Code    string
+	Reason  CouponRejection
+	Message string
+}
+
+// Error returns the error message
+func (e *CouponError) Error() string {
+	return fmt.Sprintf("coupon %s: %s", e.Code, e.Message)
+}
+
+// Is checks if the error is of the given type
+func (e *CouponError) Is(target error) bool {
+	return target == ErrInvalidInput
+}
+
+// ErrorCode returns the stable error code
+func (e *CouponError) ErrorCode() string {
+	return "coupon_" + string(e.Reason)
+}
+
+// Violations returns the rejection keyed by the coupon code field
+func (e *CouponError) Violations() map[string]interface{} {
+	return map[string]interface{}{"coupon_code": e.Message}
+}
+
+// NewCouponError creates a CouponError with the standard message for reason
+func NewCouponError(code string, reason CouponRejection) *CouponError {
+	messages := map[CouponRejection]string{
+		CouponRejectionUnknown:       "does not exist",
+		CouponRejectionInactive:      "is not active",
+		CouponRejectionNotStarted:    "is not valid yet",
+		CouponRejectionExpired:       "has expired",
+		CouponRejectionUsageLimit:    "has been fully redeemed",
+		CouponRejectionUserLimit:     "has already been used the maximum number of times",
+		CouponRejectionMinimum:       "requires a higher order value",
+		CouponRejectionNotApplicable: "does not apply to any item in the order",
+		CouponRejectionCurrency:      "is not valid for the order's currency",
+	}
+	return &CouponError{Code: code, Reason: reason, Message: messages[reason]}
+}
+
+// CouponUsage counts the redemptions of a coupon, overall and by one user
+type CouponUsage struct {
+	Total  int
+	ByUser int
+}
+
+// CheckRedeemable returns a *CouponError unless the coupon is active at now
+// and below its usage limits
+func (c *Coupon) CheckRedeemable(now time.Time, usage CouponUsage) error {
+	switch {
+	case !c.Active:
+		return NewCouponError(c.Code, CouponRejectionInactive)
+	case c.StartsAt != nil && now.Before(*c.StartsAt):
+		return NewCouponError(c.Code, CouponRejectionNotStarted)
+	case c.EndsAt != nil && !now.Before(*c.EndsAt):
+		return NewCouponError(c.Code, CouponRejectionExpired)
+	case c.MaxUses != nil && usage.Total >= *c.MaxUses:
+		return NewCouponError(c.Code, CouponRejectionUsageLimit)
+	case c.MaxUsesPerUser != nil && usage.ByUser >= *c.MaxUsesPerUser:
+		return NewCouponError(c.Code, CouponRejectionUserLimit)
+	}
+	return nil
+}
+
+// DiscountLine is an order line a coupon may discount
+type DiscountLine struct {
+	ProductID int64
+	// CategoryPath is the path of the product's category, so rules for a
+	// category also cover its subcategories
+	CategoryPath string
+	Total        Money
+}
+
+// Applies reports whether the coupon's rules cover the line
+func (c *Coupon) Applies(line DiscountLine) bool {
+	if len(c.ProductIDs) == 0 && len(c.CategoryIDs) == 0 {
+		return true
+	}
+	for _, id := range c.ProductIDs {
+		if id == line.ProductID {
+			return true
+		}
+	}
+	for _, id := range c.CategoryIDs {
+		if strings.Contains(line.CategoryPath, CategoryRootPath(id)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Discounts returns the discount of each line of an order whose lines sum
+// to subtotal. Percentage coupons discount every eligible line by the
+// percentage. Fixed coupons take their amount, at most the eligible total,
+// off the eligible lines in proportion to their totals.
+func (c *Coupon) Discounts(lines []DiscountLine, subtotal Money) ([]Money, error) {
+	if c.MinOrderValue != nil {
+		if c.MinOrderValue.Currency != subtotal.Currency {
+			return nil, NewCouponError(c.Code, CouponRejectionCurrency)
+		}
+		if subtotal.Amount < c.MinOrderValue.Amount {
+			err := NewCouponError(c.Code, CouponRejectionMinimum)
+			err.Message = fmt.Sprintf("requires an order value of at least %s", c.MinOrderValue)
+			return nil, err
+		}
+	}
+	if c.AmountOff != nil && c.AmountOff.Currency != subtotal.Currency {
+		return nil, NewCouponError(c.Code, CouponRejectionCurrency)
+	}
+
+	discounts := make([]Money, len(lines))
+	eligible := NewMoney(0, subtotal.Currency)
+	last := -1
+	for i, line := range lines {
+		discounts[i] = NewMoney(0, subtotal.Currency)
+		if !c.Applies(line) || !line.Total.IsPositive() {
+			continue
+		}
+		var err error
+		if eligible, err = eligible.Add(line.Total); err != nil {
+			return nil, err
+		}
+		last = i
+	}
+	if last < 0 {
+		return nil, NewCouponError(c.Code, CouponRejectionNotApplicable)
+	}
+
+	if c.Type == CouponTypePercentage {
+		for i, line := range lines {
+			if !c.Applies(line) || !line.Total.IsPositive() {
+				continue
+			}
+			discount, err := line.Total.MulRatio(int64(c.PercentOff), 100)
+			if err != nil {
+				return nil, err
+			}
+			discounts[i] = discount
+		}
+		return discounts, nil
+	}
+
+	amount := *c.AmountOff
+	if amount.Amount > eligible.Amount {
+		amount = eligible
+	}
+
+	// The last eligible line takes the rounding remainder, so the line
+	// discounts add up to the amount exactly
+	remaining := amount
+	for i, line := range lines {
+		if !c.Applies(line) || !line.Total.IsPositive() {
+			continue
+		}
+		share := remaining
+		if i != last {
+			var err error
+			if share, err = amount.MulRatio(line.Total.Amount, eligible.Amount); err != nil {
+				return nil, err
+			}
+		}
+		if share.Amount > line.Total.Amount {
+			share = line.Total
+		}
+		discounts[i] = share
+		remaining.Amount -= share.Amount
+	}
+
+	return discounts, nil
+}
+
+// CouponRepository defines the coupon repository interface
+type CouponRepository interface {
+	BaseRepository[Coupon, int64]
+	FindByCode(ctx context.Context, code string) (*Coupon, error)
+	CountUses(ctx context.Context, couponID, userID int64) (CouponUsage, error)
+}
+
+// CouponCreateDTO represents the data for creating a coupon
+type CouponCreateDTO struct {
+	Code           string     `json:"code" validate:"required,min=3,max=50"`
+	Description    string     `json:"description" validate:"max=500"`
+	Type           CouponType `json:"type" validate:"required,oneof=percentage fixed"`
+	PercentOff     int        `json:"percent_off" validate:"omitempty,min=1,max=100"`
+	AmountOff      *Money     `json:"amount_off,omitempty"`
+	MinOrderValue  *Money     `json:"min_order_value,omitempty"`
+	ProductIDs     []int64    `json:"product_ids" validate:"omitempty,dive,gt=0"`
+	CategoryIDs    []int64    `json:"category_ids" validate:"omitempty,dive,gt=0"`
+	MaxUses        *int       `json:"max_uses,omitempty" validate:"omitempty,gt=0"`
+	MaxUsesPerUser *int       `json:"max_uses_per_user,omitempty" validate:"omitempty,gt=0"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	EndsAt         *time.Time `json:"ends_at,omitempty"`
+	Active         *bool      `json:"active,omitempty"` // Defaults to true
+}
+
+// CouponUpdateDTO represents the data for updating a coupon. The code and
+// type of a coupon cannot change; omitted fields are left as they are.
+type CouponUpdateDTO struct {
+	Description    *string    `json:"description,omitempty" validate:"omitempty,max=500"`
+	PercentOff     *int       `json:"percent_off,omitempty" validate:"omitempty,min=1,max=100"`
+	AmountOff      *Money     `json:"amount_off,omitempty"`
+	MinOrderValue  *Money     `json:"min_order_value,omitempty"`
+	ProductIDs     *[]int64   `json:"product_ids,omitempty" validate:"omitempty,dive,gt=0"`
+	CategoryIDs    *[]int64   `json:"category_ids,omitempty" validate:"omitempty,dive,gt=0"`
+	MaxUses        *int       `json:"max_uses,omitempty" validate:"omitempty,gt=0"`
+	MaxUsesPerUser *int       `json:"max_uses_per_user,omitempty" validate:"omitempty,gt=0"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	EndsAt         *time.Time `json:"ends_at,omitempty"`
+	Active         *bool      `json:"active,omitempty"`
+}
+
+// CouponUseCase defines the coupon use case interface
+type CouponUseCase interface {
+	BaseUseCase[Coupon, int64, CouponCreateDTO, CouponUpdateDTO]
+}
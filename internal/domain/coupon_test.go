@@ -0,0 +1,167 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestCoupon_Validate tests the consistency checks on a coupon
+func TestCoupon_Validate(t *testing.T) {
+	usd := NewMoney(500, CurrencyUSD)
+	eur := NewMoney(5000, CurrencyEUR)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		coupon Coupon
+		field  string
+	}{
+		{name: "valid percentage", coupon: Coupon{Type: CouponTypePercentage, PercentOff: 10}},
+		{name: "valid fixed", coupon: Coupon{Type: CouponTypeFixed, AmountOff: &usd}},
+		{name: "percentage without percent", coupon: Coupon{Type: CouponTypePercentage}, field: "percent_off"},
+		{name: "percentage with amount", coupon: Coupon{Type: CouponTypePercentage, PercentOff: 10, AmountOff: &usd}, field: "amount_off"},
+		{name: "fixed without amount", coupon: Coupon{Type: CouponTypeFixed}, field: "amount_off"},
+		{name: "minimum in another currency", coupon: Coupon{Type: CouponTypeFixed, AmountOff: &usd, MinOrderValue: &eur}, field: "min_order_value"},
+		{name: "window ends before start", coupon: Coupon{Type: CouponTypePercentage, PercentOff: 10, StartsAt: &start, EndsAt: &end}, field: "ends_at"},
+		{name: "unknown type", coupon: Coupon{Type: "bogo"}, field: "type"},
+	}
+
+	for _, tt := range tests {
+		err := tt.coupon.Validate()
+		if tt.field == "" {
+			if err != nil {
+				t.Errorf("%s: expected no error, got %v", tt.name, err)
+			}
+			continue
+		}
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("%s: expected ValidationError, got %v", tt.name, err)
+			continue
+		}
+		if validationErr.Field != tt.field {
+			t.Errorf("%s: expected field %s, got %s", tt.name, tt.field, validationErr.Field)
+		}
+	}
+}
+
+// TestCoupon_CheckRedeemable tests the validity window and usage limits
+func TestCoupon_CheckRedeemable(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	before := now.Add(-time.Hour)
+	after := now.Add(time.Hour)
+	one, two := 1, 2
+
+	tests := []struct {
+		name   string
+		coupon Coupon
+		usage  CouponUsage
+		reason CouponRejection
+	}{
+		{name: "redeemable", coupon: Coupon{Active: true, StartsAt: &before, EndsAt: &after, MaxUses: &two, MaxUsesPerUser: &one}, usage: CouponUsage{Total: 1}},
+		{name: "inactive", coupon: Coupon{}, reason: CouponRejectionInactive},
+		{name: "not started", coupon: Coupon{Active: true, StartsAt: &after}, reason: CouponRejectionNotStarted},
+		{name: "expired", coupon: Coupon{Active: true, EndsAt: &now}, reason: CouponRejectionExpired},
+		{name: "fully redeemed", coupon: Coupon{Active: true, MaxUses: &two}, usage: CouponUsage{Total: 2}, reason: CouponRejectionUsageLimit},
+		{name: "used by user", coupon: Coupon{Active: true, MaxUsesPerUser: &one}, usage: CouponUsage{Total: 1, ByUser: 1}, reason: CouponRejectionUserLimit},
+	}
+
+	for _, tt := range tests {
+		err := tt.coupon.CheckRedeemable(now, tt.usage)
+		if tt.reason == "" {
+			if err != nil {
+				t.Errorf("%s: expected no error, got %v", tt.name, err)
+			}
+			continue
+		}
+		var couponErr *CouponError
+		if !errors.As(err, &couponErr) {
+			t.Errorf("%s: expected CouponError, got %v", tt.name, err)
+			continue
+		}
+		if couponErr.Reason != tt.reason {
+			t.Errorf("%s: expected reason %s, got %s", tt.name, tt.reason, couponErr.Reason)
+		}
+		if !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: expected error to match ErrInvalidInput", tt.name)
+		}
+	}
+}
+
+// TestCoupon_Discounts tests how percentage and fixed coupons discount order lines
+func TestCoupon_Discounts(t *testing.T) {
+	lines := []DiscountLine{
+		{ProductID: 1, CategoryPath: "/1/2/", Total: NewMoney(1000, CurrencyUSD)},
+		{ProductID: 2, CategoryPath: "/3/", Total: NewMoney(2000, CurrencyUSD)},
+		{ProductID: 3, CategoryPath: "/1/", Total: NewMoney(333, CurrencyUSD)},
+	}
+	subtotal := NewMoney(3333, CurrencyUSD)
+	amount := func(amount int64) *Money {
+		money := NewMoney(amount, CurrencyUSD)
+		return &money
+	}
+
+	tests := []struct {
+		name   string
+		coupon Coupon
+		want   []int64
+		reason CouponRejection
+	}{
+		{name: "percentage on every line", coupon: Coupon{Type: CouponTypePercentage, PercentOff: 10}, want: []int64{100, 200, 33}},
+		{name: "percentage on a product", coupon: Coupon{Type: CouponTypePercentage, PercentOff: 50, ProductIDs: []int64{2}}, want: []int64{0, 1000, 0}},
+		{name: "percentage on a category and its subcategories", coupon: Coupon{Type: CouponTypePercentage, PercentOff: 10, CategoryIDs: []int64{1}}, want: []int64{100, 0, 33}},
+		{name: "fixed split by line total", coupon: Coupon{Type: CouponTypeFixed, AmountOff: amount(1000), CategoryIDs: []int64{1}}, want: []int64{750, 0, 250}},
+		{name: "fixed capped at eligible total", coupon: Coupon{Type: CouponTypeFixed, AmountOff: amount(5000), ProductIDs: []int64{1}}, want: []int64{1000, 0, 0}},
+		{name: "fixed remainder on last line", coupon: Coupon{Type: CouponTypeFixed, AmountOff: amount(100)}, want: []int64{30, 60, 10}},
+		{name: "minimum not met", coupon: Coupon{Type: CouponTypePercentage, PercentOff: 10, MinOrderValue: amount(5000)}, reason: CouponRejectionMinimum},
+		{name: "no eligible line", coupon: Coupon{Type: CouponTypePercentage, PercentOff: 10, ProductIDs: []int64{9}}, reason: CouponRejectionNotApplicable},
+		{name: "other currency", coupon: Coupon{Type: CouponTypeFixed, AmountOff: &Money{Amount: 100, Currency: CurrencyEUR}}, reason: CouponRejectionCurrency},
+	}
+
+	for _, tt := range tests {
+		discounts, err := tt.coupon.Discounts(lines, subtotal)
+		if tt.reason != "" {
+			var couponErr *CouponError
+			if !errors.As(err, &couponErr) || couponErr.Reason != tt.reason {
+				t.Errorf("%s: expected %s rejection, got %v", tt.name, tt.reason, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", tt.name, err)
+			continue
+		}
+		for i, want := range tt.want {
+			if discounts[i].Amount != want || discounts[i].Currency != CurrencyUSD {
+				t.Errorf("%s: expected line %d discount %d USD, got %s", tt.name, i, want, discounts[i])
+			}
+		}
+	}
+}
+
+// TestOrderItem_UnitsTotal tests spreading a line discount over its units
+func TestOrderItem_UnitsTotal(t *testing.T) {
+	item := OrderItem{Quantity: 3, Price: NewMoney(1000, CurrencyUSD), Discount: NewMoney(100, CurrencyUSD)}
+
+	tests := []struct {
+		quantity int
+		want     int64
+	}{
+		{quantity: 0, want: 0},
+		{quantity: 1, want: 967},
+		{quantity: 2, want: 1933},
+		{quantity: 3, want: 2900},
+	}
+
+	for _, tt := range tests {
+		total, err := item.UnitsTotal(tt.quantity)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if total.Amount != tt.want {
+			t.Errorf("Expected %d units to cost %d, got %d", tt.quantity, tt.want, total.Amount)
+		}
+	}
+}
@@ -1,18 +1,20 @@
 package domain
 
 import (
-	"errors"
 	"fmt"
+
+	apperrors "github.com/milad-ahmd/go-clean-arch/pkg/errors"
 )
 
-// Common errors
+// Common errors. They share the application sentinels so that domain and
+// application errors map to the same HTTP status and error code.
 var (
-	ErrNotFound       = errors.New("not found")
-	ErrInvalidInput   = errors.New("invalid input")
-	ErrInternalServer = errors.New("internal server error")
-	ErrConflict       = errors.New("conflict")
-	ErrUnauthorized   = errors.New("unauthorized")
-	ErrForbidden      = errors.New("forbidden")
+	ErrNotFound       = apperrors.ErrNotFound
+	ErrInvalidInput   = apperrors.ErrInvalidInput
+	ErrInternalServer = apperrors.ErrInternal
+	ErrConflict       = apperrors.ErrConflict
+	ErrUnauthorized   = apperrors.ErrUnauthorized
+	ErrForbidden      = apperrors.ErrForbidden
 )
 
 // NotFoundError represents a not found error
@@ -63,3 +65,13 @@ func (e *ValidationError) Error() string {
 func (e *ValidationError) Is(target error) bool {
 	return target == ErrInvalidInput
 }
+
+// ErrorCode returns the stable error code
+func (e *ValidationError) ErrorCode() string {
+	return apperrors.CodeValidationFailed
+}
+
+// Violations returns the invalid field and its message
+func (e *ValidationError) Violations() map[string]interface{} {
+	return map[string]interface{}{e.Field: e.Message}
+}
@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotencyKeyHeader is the request header carrying a client-chosen key
+// that makes retries of a mutating request safe
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set on responses replayed for a repeated key
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// IdempotencyRecord stores the outcome of a request sent with an
+// idempotency key. Keys are scoped to a user, and Fingerprint identifies the
+// request they were first used for. StatusCode is zero while the first
+// request is still in progress. Headers holds the response headers replayed
+// with the body, keyed by canonical header name.
+type IdempotencyRecord struct {
+	UserID      int64
+	Key         string
+	Fingerprint string
+	StatusCode  int
+	Headers     map[string]string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// IsComplete reports whether the response of the request has been stored
+func (r *IdempotencyRecord) IsComplete() bool {
+	return r.StatusCode != 0
+}
+
+// IdempotencyRepository represents the store of idempotency keys
+type IdempotencyRepository interface {
+	// Reserve claims record.Key for record.UserID. When the key is already
+	// in use and has not expired, the existing record is returned and
+	// nothing is stored; otherwise the result is nil.
+	Reserve(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error)
+	// Complete stores the response of a reserved key
+	Complete(ctx context.Context, record *IdempotencyRecord) error
+	// Release frees a reserved key whose request failed, so it can be retried
+	Release(ctx context.Context, userID int64, key string) error
+}
@@ -0,0 +1,212 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Money errors
+var (
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrMoneyOverflow    = errors.New("money amount overflow")
+)
+
+// Currency represents an ISO 4217 currency code
+type Currency string
+
+// Common currencies
+const (
+	CurrencyUSD Currency = "USD"
+	CurrencyEUR Currency = "EUR"
+	CurrencyGBP Currency = "GBP"
+	CurrencyJPY Currency = "JPY"
+)
+
+// DefaultCurrency is used when a price is given without a currency
+const DefaultCurrency = CurrencyUSD
+
+// currencyExponents maps supported currencies to their number of minor unit digits
+var currencyExponents = map[Currency]int{
+	"AUD": 2, "BRL": 2, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2, "DKK": 2,
+	"EUR": 2, "GBP": 2, "HKD": 2, "INR": 2, "MXN": 2, "NOK": 2, "NZD": 2,
+	"PLN": 2, "SEK": 2, "SGD": 2, "TRY": 2, "USD": 2, "ZAR": 2, "AED": 2,
+	"JPY": 0, "KRW": 0, "ISK": 0, "CLP": 0, "VND": 0,
+	"BHD": 3, "JOD": 3, "KWD": 3, "OMR": 3, "TND": 3,
+}
+
+// IsValid reports whether the currency is a supported ISO 4217 code
+func (c Currency) IsValid() bool {
+	_, ok := currencyExponents[c]
+	return ok
+}
+
+// Exponent returns the number of minor unit digits of the currency
+func (c Currency) Exponent() int {
+	return currencyExponents[c]
+}
+
+// Money is an exact amount of a currency, stored in minor units (e.g. cents).
+//
+// Rounding policy: addition, subtraction and multiplication by a whole
+// quantity are exact and fail on overflow. Operations that can produce a
+// fraction of a minor unit (MulRatio) round half to even, so repeated
+// rounding does not drift in either direction.
+type Money struct {
+	Amount   int64    `json:"amount"`
+	Currency Currency `json:"currency"`
+}
+
+// NewMoney creates an amount from minor units
+func NewMoney(amount int64, currency Currency) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney parses a decimal string such as "19.99" in the given currency.
+// More fractional digits than the currency allows are rejected rather than rounded.
+func ParseMoney(value string, currency Currency) (Money, error) {
+	if !currency.IsValid() {
+		return Money{}, fmt.Errorf("unsupported currency %q", currency)
+	}
+
+	rat, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return Money{}, fmt.Errorf("invalid amount %q", value)
+	}
+
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(currency.Exponent())), nil)
+	rat.Mul(rat, new(big.Rat).SetInt(scale))
+	if !rat.IsInt() {
+		return Money{}, fmt.Errorf("amount %q has more than %d decimal places for %s", value, currency.Exponent(), currency)
+	}
+	if !rat.Num().IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	return Money{Amount: rat.Num().Int64(), Currency: currency}, nil
+}
+
+// Add returns m + other
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	if (other.Amount > 0 && m.Amount > math.MaxInt64-other.Amount) ||
+		(other.Amount < 0 && m.Amount < math.MinInt64-other.Amount) {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+// Sub returns m - other
+func (m Money) Sub(other Money) (Money, error) {
+	if other.Amount == math.MinInt64 {
+		return Money{}, ErrMoneyOverflow
+	}
+	return m.Add(Money{Amount: -other.Amount, Currency: other.Currency})
+}
+
+// Mul returns m multiplied by a whole quantity
+func (m Money) Mul(quantity int64) (Money, error) {
+	result := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(quantity))
+	if !result.IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: result.Int64(), Currency: m.Currency}, nil
+}
+
+// MulRatio returns m * numerator / denominator rounded half to even, e.g.
+// MulRatio(15, 100) for 15%
+func (m Money) MulRatio(numerator, denominator int64) (Money, error) {
+	if denominator == 0 {
+		return Money{}, errors.New("division by zero")
+	}
+
+	num := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(numerator))
+	den := big.NewInt(denominator)
+	if den.Sign() < 0 {
+		num.Neg(num)
+		den.Neg(den)
+	}
+
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+
+	// Compare twice the remainder with the denominator to decide rounding
+	twiceRem := new(big.Int).Abs(rem)
+	twiceRem.Lsh(twiceRem, 1)
+	switch cmp := twiceRem.Cmp(den); {
+	case cmp > 0, cmp == 0 && quo.Bit(0) == 1:
+		if rem.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+
+	if !quo.IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: quo.Int64(), Currency: m.Currency}, nil
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsPositive reports whether the amount is greater than zero
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+// Decimal formats the amount in major units, e.g. "19.99"
+func (m Money) Decimal() string {
+	exp := m.Currency.Exponent()
+	if exp == 0 {
+		return fmt.Sprintf("%d", m.Amount)
+	}
+
+	sign := ""
+	amount := new(big.Int).SetInt64(m.Amount)
+	if amount.Sign() < 0 {
+		sign = "-"
+		amount.Abs(amount)
+	}
+
+	digits := amount.String()
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp+1-len(digits)) + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+// String formats the amount with its currency, e.g. "19.99 USD"
+func (m Money) String() string {
+	return m.Decimal() + " " + string(m.Currency)
+}
+
+// UnmarshalJSON decodes {"amount": 1999, "currency": "USD"} and validates the currency
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Amount   int64    `json:"amount"`
+		Currency Currency `json:"currency"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	raw.Currency = Currency(strings.ToUpper(string(raw.Currency)))
+	if raw.Currency == "" {
+		raw.Currency = DefaultCurrency
+	}
+	if !raw.Currency.IsValid() {
+		return fmt.Errorf("unsupported currency %q", raw.Currency)
+	}
+
+	m.Amount = raw.Amount
+	m.Currency = raw.Currency
+	return nil
+}
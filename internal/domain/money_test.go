@@ -0,0 +1,140 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+// TestParseMoney tests parsing decimal amounts into minor units
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value    string
+		currency Currency
+		want     int64
+		wantErr  bool
+	}{
+		{value: "19.99", currency: CurrencyUSD, want: 1999},
+		{value: "0.1", currency: CurrencyUSD, want: 10},
+		{value: "-5", currency: CurrencyEUR, want: -500},
+		{value: "1500", currency: CurrencyJPY, want: 1500},
+		{value: "1.005", currency: CurrencyUSD, wantErr: true},
+		{value: "1.5", currency: CurrencyJPY, wantErr: true},
+		{value: "abc", currency: CurrencyUSD, wantErr: true},
+		{value: "1.00", currency: "XXX", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.value, tt.currency)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseMoney(%q, %s): expected error, got nil", tt.value, tt.currency)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMoney(%q, %s): expected no error, got %v", tt.value, tt.currency, err)
+			continue
+		}
+		if got.Amount != tt.want || got.Currency != tt.currency {
+			t.Errorf("ParseMoney(%q, %s): expected %d, got %v", tt.value, tt.currency, tt.want, got)
+		}
+	}
+}
+
+// TestMoney_Add tests exact addition and currency checks
+func TestMoney_Add(t *testing.T) {
+	// 0.1 + 0.2 drifts as float64 but must be exact here
+	sum, err := NewMoney(10, CurrencyUSD).Add(NewMoney(20, CurrencyUSD))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if sum.Amount != 30 {
+		t.Errorf("Expected 30, got %d", sum.Amount)
+	}
+
+	if _, err := NewMoney(10, CurrencyUSD).Add(NewMoney(10, CurrencyEUR)); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Expected ErrCurrencyMismatch, got %v", err)
+	}
+
+	if _, err := NewMoney(math.MaxInt64, CurrencyUSD).Add(NewMoney(1, CurrencyUSD)); !errors.Is(err, ErrMoneyOverflow) {
+		t.Errorf("Expected ErrMoneyOverflow, got %v", err)
+	}
+}
+
+// TestMoney_MulRatio tests that fractional results round half to even
+func TestMoney_MulRatio(t *testing.T) {
+	tests := []struct {
+		amount      int64
+		numerator   int64
+		denominator int64
+		want        int64
+	}{
+		{amount: 1000, numerator: 15, denominator: 100, want: 150},
+		{amount: 5, numerator: 1, denominator: 2, want: 2},
+		{amount: 15, numerator: 1, denominator: 10, want: 2},
+		{amount: 25, numerator: 1, denominator: 10, want: 2},
+		{amount: 26, numerator: 1, denominator: 10, want: 3},
+		{amount: -5, numerator: 1, denominator: 2, want: -2},
+		{amount: -15, numerator: 1, denominator: 10, want: -2},
+		{amount: 999, numerator: 1, denominator: 3, want: 333},
+	}
+
+	for _, tt := range tests {
+		got, err := NewMoney(tt.amount, CurrencyUSD).MulRatio(tt.numerator, tt.denominator)
+		if err != nil {
+			t.Errorf("MulRatio(%d, %d/%d): expected no error, got %v", tt.amount, tt.numerator, tt.denominator, err)
+			continue
+		}
+		if got.Amount != tt.want {
+			t.Errorf("MulRatio(%d, %d/%d): expected %d, got %d", tt.amount, tt.numerator, tt.denominator, tt.want, got.Amount)
+		}
+	}
+
+	if _, err := NewMoney(1, CurrencyUSD).MulRatio(1, 0); err == nil {
+		t.Error("Expected error for zero denominator, got nil")
+	}
+}
+
+// TestMoney_Decimal tests formatting in major units
+func TestMoney_Decimal(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{money: NewMoney(1999, CurrencyUSD), want: "19.99"},
+		{money: NewMoney(5, CurrencyUSD), want: "0.05"},
+		{money: NewMoney(-5, CurrencyUSD), want: "-0.05"},
+		{money: NewMoney(1500, CurrencyJPY), want: "1500"},
+		{money: NewMoney(1, "KWD"), want: "0.001"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.money.Decimal(); got != tt.want {
+			t.Errorf("Expected %s, got %s", tt.want, got)
+		}
+	}
+}
+
+// TestMoney_UnmarshalJSON tests currency normalization and validation
+func TestMoney_UnmarshalJSON(t *testing.T) {
+	var m Money
+	if err := json.Unmarshal([]byte(`{"amount": 1999, "currency": "eur"}`), &m); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if m.Amount != 1999 || m.Currency != CurrencyEUR {
+		t.Errorf("Expected 1999 EUR, got %v", m)
+	}
+
+	if err := json.Unmarshal([]byte(`{"amount": 100}`), &m); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if m.Currency != DefaultCurrency {
+		t.Errorf("Expected default currency %s, got %s", DefaultCurrency, m.Currency)
+	}
+
+	if err := json.Unmarshal([]byte(`{"amount": 100, "currency": "ZZZ"}`), &m); err == nil {
+		t.Error("Expected error for unsupported currency, got nil")
+	}
+}
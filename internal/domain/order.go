@@ -2,6 +2,9 @@ package domain
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 )
 
 // OrderStatus represents the status of an order
@@ -21,6 +24,63 @@ const (
 	OrderStatusCancelled OrderStatus = "cancelled"
 )
 
+// orderStatusTransitions lists the statuses each status may move to.
+// Completed and cancelled orders are final.
+var orderStatusTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPending:    {OrderStatusProcessing, OrderStatusCancelled},
+	OrderStatusProcessing: {OrderStatusCompleted, OrderStatusCancelled},
+	OrderStatusCompleted:  {},
+	OrderStatusCancelled:  {},
+}
+
+// ErrInvalidStatusTransition is returned when an order cannot move to the requested status
+var ErrInvalidStatusTransition = errors.New("invalid order status transition")
+
+// StatusTransitionError describes a rejected order status transition
+type StatusTransitionError struct {
+	From OrderStatus
+	To   OrderStatus
+}
+
+// Error returns the error message
+func (e *StatusTransitionError) Error() string {
+	return fmt.Sprintf("order status cannot change from %s to %s", e.From, e.To)
+}
+
+// Is checks if the error is of the given type
+func (e *StatusTransitionError) Is(target error) bool {
+	return target == ErrInvalidStatusTransition || target == ErrConflict
+}
+
+// ErrorCode returns the stable error code
+func (e *StatusTransitionError) ErrorCode() string {
+	return "invalid_status_transition"
+}
+
+// IsValid reports whether the status is a known order status
+func (s OrderStatus) IsValid() bool {
+	_, ok := orderStatusTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether an order in status s may move to next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTransition returns a *StatusTransitionError unless s may move to next
+func (s OrderStatus) ValidateTransition(next OrderStatus) error {
+	if !s.CanTransitionTo(next) {
+		return &StatusTransitionError{From: s, To: next}
+	}
+	return nil
+}
+
 // PaymentMethod represents the payment method
 type PaymentMethod string
 
@@ -35,30 +95,87 @@ const (
 	PaymentMethodBankTransfer PaymentMethod = "bank_transfer"
 )
 
-// OrderItem represents an item in an order
+// OrderItem represents an item in an order. Quantity is the quantity
+// originally ordered; CancelledQuantity of it has since been cancelled.
+// Items of products sold by variant reference the variant ordered. Discount
+// is the coupon discount on the whole line, across every unit ordered.
 type OrderItem struct {
-	ID        int64   `json:"id"`
-	OrderID   int64   `json:"order_id"`
-	ProductID int64   `json:"product_id"`
-	Product   Product `json:"product,omitempty"`
-	Quantity  int     `json:"quantity"`
-	Price     float64 `json:"price"`
+	ID                int64           `json:"id"`
+	OrderID           int64           `json:"order_id"`
+	ProductID         int64           `json:"product_id"`
+	Product           Product         `json:"product,omitempty"`
+	VariantID         *int64          `json:"variant_id,omitempty"`
+	Variant           *ProductVariant `json:"variant,omitempty"`
+	Quantity          int             `json:"quantity"`
+	CancelledQuantity int             `json:"cancelled_quantity"`
+	Price             Money           `json:"price"`
+	Discount          Money           `json:"discount"`
 	BaseEntity
 }
 
-// Order represents an order entity
+// ActiveQuantity returns the quantity that has not been cancelled
+func (i *OrderItem) ActiveQuantity() int {
+	return i.Quantity - i.CancelledQuantity
+}
+
+// UnitsTotal returns what the first quantity units of the line cost: their
+// price less their share of the line discount. The shares are cumulative,
+// so the units of the whole line always add up to its discounted total.
+func (i *OrderItem) UnitsTotal(quantity int) (Money, error) {
+	gross, err := i.Price.Mul(int64(quantity))
+	if err != nil {
+		return Money{}, err
+	}
+	if i.Discount.IsZero() || i.Quantity == 0 {
+		return gross, nil
+	}
+	discount, err := i.Discount.MulRatio(int64(quantity), int64(i.Quantity))
+	if err != nil {
+		return Money{}, err
+	}
+	return gross.Sub(discount)
+}
+
+// Order represents an order entity. TotalAmount is Subtotal, the sum of the
+// line prices, less DiscountAmount, the sum of the line discounts. Version is
+// incremented by every change to the order row, such as status changes.
 type Order struct {
-	ID            int64         `json:"id"`
-	UserID        int64         `json:"user_id"`
-	User          User          `json:"user,omitempty"`
-	Status        OrderStatus   `json:"status"`
-	TotalAmount   float64       `json:"total_amount"`
-	Items         []OrderItem   `json:"items,omitempty"`
-	PaymentMethod PaymentMethod `json:"payment_method"`
-	ShippingInfo  ShippingInfo  `json:"shipping_info,omitempty"`
+	ID             int64         `json:"id"`
+	UserID         int64         `json:"user_id"`
+	User           User          `json:"user,omitempty"`
+	Status         OrderStatus   `json:"status"`
+	Subtotal       Money         `json:"subtotal"`
+	DiscountAmount Money         `json:"discount_amount"`
+	TotalAmount    Money         `json:"total_amount"`
+	CouponID       *int64        `json:"coupon_id,omitempty"`
+	CouponCode     string        `json:"coupon_code,omitempty"`
+	Items          []OrderItem   `json:"items,omitempty"`
+	PaymentMethod  PaymentMethod `json:"payment_method"`
+	ShippingInfo   ShippingInfo  `json:"shipping_info,omitempty"`
+	Version        int64         `json:"version"`
+	CartItems      []CartItem    `json:"-"` // Checked out items, removed from the cart with the order
 	BaseEntity
 }
 
+// IsAccessibleBy reports whether the user may access the order. Users may
+// always access their own orders; other orders require the given permission.
+func (o *Order) IsAccessibleBy(user *User, permission Permission) bool {
+	return o.UserID == user.ID || user.HasPermission(permission)
+}
+
+// OrderStatusHistory records a single order status change. FromStatus is
+// empty for the entry written when the order is created, and ActorID is nil
+// when the acting user no longer exists.
+type OrderStatusHistory struct {
+	ID         int64       `json:"id"`
+	OrderID    int64       `json:"order_id"`
+	FromStatus OrderStatus `json:"from_status,omitempty"`
+	ToStatus   OrderStatus `json:"to_status"`
+	ActorID    *int64      `json:"actor_id,omitempty"`
+	Reason     string      `json:"reason,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
 // ShippingInfo represents shipping information
 type ShippingInfo struct {
 	ID          int64  `json:"id"`
@@ -75,20 +192,42 @@ type ShippingInfo struct {
 // OrderRepository defines the order repository interface
 type OrderRepository interface {
 	BaseRepository[Order, int64]
-	FindByUserID(ctx context.Context, userID int64, limit, offset int) ([]Order, int, error)
-	FindByStatus(ctx context.Context, status OrderStatus, limit, offset int) ([]Order, int, error)
-	UpdateStatus(ctx context.Context, id int64, status OrderStatus) error
+	FindByUserID(ctx context.Context, userID int64, page PageRequest) (*Page[Order], error)
+	FindByStatus(ctx context.Context, status OrderStatus, page PageRequest) (*Page[Order], error)
+	// UpdateWithStatus updates an order at order.Version and, unless change
+	// is nil, moves it to change.ToStatus in the same transaction
+	UpdateWithStatus(ctx context.Context, order *Order, change *OrderStatusHistory) error
+	// UpdateStatus moves an order to change.ToStatus; unless version is
+	// zero, the order must still be at version
+	UpdateStatus(ctx context.Context, change *OrderStatusHistory, version int64) error
+	// DeleteVersion deletes an order; unless version is zero, the order must
+	// still be at version
+	DeleteVersion(ctx context.Context, id, version int64) error
+	GetStatusHistory(ctx context.Context, orderID int64) ([]OrderStatusHistory, error)
 	AddOrderItem(ctx context.Context, item *OrderItem) error
 	GetOrderItems(ctx context.Context, orderID int64) ([]OrderItem, error)
+	// CancelOrderItem cancels quantity units of an order item in total; unless
+	// version is zero, the order must still be at version
+	CancelOrderItem(ctx context.Context, orderID, itemID int64, quantity int, version int64) error
 	SaveShippingInfo(ctx context.Context, info *ShippingInfo) error
 	GetShippingInfo(ctx context.Context, orderID int64) (*ShippingInfo, error)
 }
 
-// OrderItemCreateDTO represents the data for creating an order item
+// OrderItemCreateDTO represents the data for creating an order item.
+// The price is always taken from the current product or variant price.
+// VariantID is required for products with variants.
 type OrderItemCreateDTO struct {
-	ProductID int64   `json:"product_id" validate:"required,gt=0"`
-	Quantity  int     `json:"quantity" validate:"required,gt=0"`
-	Price     float64 `json:"price" validate:"required,gt=0"`
+	ProductID int64  `json:"product_id" validate:"required,gt=0"`
+	VariantID *int64 `json:"variant_id,omitempty" validate:"omitempty,gt=0"`
+	Quantity  int    `json:"quantity" validate:"required,gt=0"`
+}
+
+// OrderItemCancelDTO represents the data for cancelling part of an order item.
+// Quantity is the total quantity of the item to be cancelled, so repeating a
+// request does not cancel more.
+type OrderItemCancelDTO struct {
+	Quantity int   `json:"quantity" validate:"required,gt=0"`
+	Version  int64 `json:"-"` // Taken from the If-Match header; zero cancels at any version
 }
 
 // ShippingInfoDTO represents the data for shipping information
@@ -103,24 +242,40 @@ type ShippingInfoDTO struct {
 
 // OrderCreateDTO represents the data for creating an order
 type OrderCreateDTO struct {
-	UserID        int64                `json:"user_id" validate:"required,gt=0"`
+	UserID        int64                `json:"-" validate:"required,gt=0"` // Always taken from the authenticated user
 	Items         []OrderItemCreateDTO `json:"items" validate:"required,dive"`
 	PaymentMethod PaymentMethod        `json:"payment_method" validate:"required,oneof=credit_card paypal bank_transfer"`
 	ShippingInfo  ShippingInfoDTO      `json:"shipping_info" validate:"required"`
+	CouponCode    string               `json:"coupon_code,omitempty" validate:"max=50"`
+	CartItems     []CartItem           `json:"-"` // Set by checkout to the cart items being ordered
 }
 
 // OrderUpdateDTO represents the data for updating an order
 type OrderUpdateDTO struct {
 	Status        OrderStatus     `json:"status" validate:"omitempty,oneof=pending processing completed cancelled"`
+	Reason        string          `json:"reason" validate:"max=500"`
 	PaymentMethod PaymentMethod   `json:"payment_method" validate:"omitempty,oneof=credit_card paypal bank_transfer"`
 	ShippingInfo  ShippingInfoDTO `json:"shipping_info" validate:"omitempty"`
+	ActorID       int64           `json:"-"` // Always taken from the authenticated user
+	Version       int64           `json:"-"` // Taken from the If-Match header; zero updates any version
+}
+
+// OrderStatusUpdateDTO represents the data for changing an order's status
+type OrderStatusUpdateDTO struct {
+	Status  OrderStatus `json:"status" validate:"required,oneof=pending processing completed cancelled"`
+	Reason  string      `json:"reason" validate:"max=500"`
+	ActorID int64       `json:"-"` // Always taken from the authenticated user
+	Version int64       `json:"-"` // Taken from the If-Match header; zero changes any version
 }
 
 // OrderUseCase defines the order use case interface
 type OrderUseCase interface {
 	BaseUseCase[Order, int64, OrderCreateDTO, OrderUpdateDTO]
-	GetByUserID(ctx context.Context, userID int64, page, perPage int) ([]Order, int, error)
-	GetByStatus(ctx context.Context, status OrderStatus, page, perPage int) ([]Order, int, error)
-	UpdateStatus(ctx context.Context, id int64, status OrderStatus) error
+	GetByUserID(ctx context.Context, userID int64, page PageRequest) (*Page[Order], error)
+	GetByStatus(ctx context.Context, status OrderStatus, page PageRequest) (*Page[Order], error)
+	UpdateStatus(ctx context.Context, id int64, statusDTO *OrderStatusUpdateDTO) error
+	DeleteVersion(ctx context.Context, id, version int64) error
+	GetStatusHistory(ctx context.Context, id int64) ([]OrderStatusHistory, error)
+	CancelItem(ctx context.Context, orderID, itemID int64, cancelDTO *OrderItemCancelDTO) (*Order, error)
 	GetOrderWithDetails(ctx context.Context, id int64) (*Order, error)
 }
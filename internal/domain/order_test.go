@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestOrderStatus_ValidateTransition tests the order status state machine
+func TestOrderStatus_ValidateTransition(t *testing.T) {
+	tests := []struct {
+		from    OrderStatus
+		to      OrderStatus
+		allowed bool
+	}{
+		{from: OrderStatusPending, to: OrderStatusProcessing, allowed: true},
+		{from: OrderStatusPending, to: OrderStatusCancelled, allowed: true},
+		{from: OrderStatusPending, to: OrderStatusCompleted, allowed: false},
+		{from: OrderStatusPending, to: OrderStatusPending, allowed: false},
+		{from: OrderStatusProcessing, to: OrderStatusCompleted, allowed: true},
+		{from: OrderStatusProcessing, to: OrderStatusCancelled, allowed: true},
+		{from: OrderStatusProcessing, to: OrderStatusPending, allowed: false},
+		{from: OrderStatusCompleted, to: OrderStatusCancelled, allowed: false},
+		{from: OrderStatusCancelled, to: OrderStatusPending, allowed: false},
+		{from: OrderStatusPending, to: "shipped", allowed: false},
+	}
+
+	for _, tt := range tests {
+		err := tt.from.ValidateTransition(tt.to)
+		if tt.allowed && err != nil {
+			t.Errorf("%s -> %s: expected no error, got %v", tt.from, tt.to, err)
+		}
+		if !tt.allowed {
+			var transitionErr *StatusTransitionError
+			if !errors.As(err, &transitionErr) {
+				t.Errorf("%s -> %s: expected StatusTransitionError, got %v", tt.from, tt.to, err)
+				continue
+			}
+			if transitionErr.From != tt.from || transitionErr.To != tt.to {
+				t.Errorf("Expected %s -> %s in error, got %s -> %s", tt.from, tt.to, transitionErr.From, transitionErr.To)
+			}
+			if !errors.Is(err, ErrInvalidStatusTransition) {
+				t.Errorf("Expected error to match ErrInvalidStatusTransition")
+			}
+		}
+	}
+}
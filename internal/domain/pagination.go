@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded or
+// does not belong to the requested list order
+var ErrInvalidCursor = fmt.Errorf("%w: invalid cursor", ErrInvalidInput)
+
+// Cursor is a position in a list. Lists are ordered by their sort keys and
+// then by ID; Values holds the row's values of the sort keys before ID and
+// Sort names the order the cursor was issued for. Clients receive cursors as
+// opaque tokens and must not depend on their contents.
+type Cursor struct {
+	ID     int64    `json:"id"`
+	Values []string `json:"v,omitempty"`
+	Sort   string   `json:"s,omitempty"`
+}
+
+// Encode returns the cursor as an opaque URL-safe token
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a token returned by Cursor.Encode
+func DecodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID < 1 {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// PageRequest selects a page of a list. It pages by Offset unless After or
+// Before is set, in which case it reads the rows directly after or before
+// the cursor, so pages stay stable while rows are inserted or deleted.
+// SkipTotal avoids counting every matching row.
+type PageRequest struct {
+	Limit     int
+	Offset    int
+	After     *Cursor
+	Before    *Cursor
+	SkipTotal bool
+}
+
+// Cursor returns the After or Before cursor, or nil in offset mode
+func (p PageRequest) Cursor() *Cursor {
+	if p.After != nil {
+		return p.After
+	}
+	return p.Before
+}
+
+// IsKeyset reports whether the request pages by cursor rather than offset
+func (p PageRequest) IsKeyset() bool {
+	return p.After != nil || p.Before != nil
+}
+
+// Page is one page of a list. Next and Prev are the cursors of the
+// neighbouring pages, nil when there is no such page. Total is nil when
+// the count was skipped.
+type Page[T any] struct {
+	Items []T
+	Next  *Cursor
+	Prev  *Cursor
+	Total *int
+}
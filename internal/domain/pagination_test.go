@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestCursor_Encode tests that cursors survive a round trip as opaque tokens
+func TestCursor_Encode(t *testing.T) {
+	want := Cursor{ID: 42, Values: []string{"1999", "Lamp"}, Sort: "-price,name"}
+	token := want.Encode()
+
+	cursor, err := DecodeCursor(token)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(*cursor, want) {
+		t.Errorf("Expected %+v, got %+v", want, *cursor)
+	}
+}
+
+// TestDecodeCursor_Invalid tests that malformed tokens are rejected
+func TestDecodeCursor_Invalid(t *testing.T) {
+	tokens := []string{
+		"",
+		"not base64!",
+		"bm90IGpzb24",     // "not json"
+		"eyJpZCI6MH0",     // {"id":0}
+		"eyJpZCI6Ii0xIn0", // {"id":"-1"}
+	}
+
+	for _, token := range tokens {
+		if _, err := DecodeCursor(token); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeCursor(%q): expected ErrInvalidCursor, got %v", token, err)
+		}
+	}
+}
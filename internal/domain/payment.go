@@ -0,0 +1,153 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+)
+
+// PaymentStatus represents the status of a payment attempt
+type PaymentStatus string
+
+const (
+	// PaymentStatusPending means the gateway has not answered yet
+	PaymentStatusPending PaymentStatus = "pending"
+
+	// PaymentStatusAuthorized means the amount is reserved on the customer's account
+	PaymentStatusAuthorized PaymentStatus = "authorized"
+
+	// PaymentStatusCaptured means the amount has been collected
+	PaymentStatusCaptured PaymentStatus = "captured"
+
+	// PaymentStatusVoided means the authorization was released without collecting
+	PaymentStatusVoided PaymentStatus = "voided"
+
+	// PaymentStatusFailed means the gateway declined or did not answer in time
+	PaymentStatusFailed PaymentStatus = "failed"
+)
+
+// paymentStatusTransitions lists the statuses each payment status can move to
+var paymentStatusTransitions = map[PaymentStatus][]PaymentStatus{
+	PaymentStatusPending:    {PaymentStatusAuthorized, PaymentStatusFailed},
+	PaymentStatusAuthorized: {PaymentStatusCaptured, PaymentStatusVoided},
+	PaymentStatusCaptured:   {},
+	PaymentStatusVoided:     {},
+	PaymentStatusFailed:     {},
+}
+
+// CanTransitionTo reports whether a payment can move from s to next
+func (s PaymentStatus) CanTransitionTo(next PaymentStatus) bool {
+	for _, allowed := range paymentStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsActive reports whether the payment holds or has collected money, so the
+// order cannot be paid again
+func (s PaymentStatus) IsActive() bool {
+	return s == PaymentStatusPending || s == PaymentStatusAuthorized || s == PaymentStatusCaptured
+}
+
+// Payment is one attempt to pay an order through a payment gateway. Failed
+// attempts are kept with the gateway's reason, so an order can have several
+// payments but at most one active one.
+type Payment struct {
+	ID                int64         `json:"id"`
+	OrderID           int64         `json:"order_id"`
+	Provider          string        `json:"provider"`
+	Method            PaymentMethod `json:"method"`
+	Status            PaymentStatus `json:"status"`
+	Amount            Money         `json:"amount"`
+	CapturedAmount    Money         `json:"captured_amount"`
+	ProviderReference string        `json:"provider_reference,omitempty"`
+	FailureCode       string        `json:"failure_code,omitempty"`
+	FailureMessage    string        `json:"failure_message,omitempty"`
+	BaseEntity
+}
+
+// GatewayFailure identifies why a payment gateway refused a request
+type GatewayFailure string
+
+const (
+	// GatewayFailureDeclined means the issuer declined the payment
+	GatewayFailureDeclined GatewayFailure = "declined"
+	// GatewayFailureInsufficientFunds means the account cannot cover the amount
+	GatewayFailureInsufficientFunds GatewayFailure = "insufficient_funds"
+	// GatewayFailureTimeout means the gateway did not answer in time; the
+	// request may be retried
+	GatewayFailureTimeout GatewayFailure = "timeout"
+	// GatewayFailureProcessing means the gateway could not process the request
+	GatewayFailureProcessing GatewayFailure = "processing_error"
+)
+
+// GatewayError is returned by a payment gateway that refused a request
+type GatewayError struct {
+	Reason  GatewayFailure
+	Message string
+}
+
+// Error returns the error message
+func (e *GatewayError) Error() string {
+	return fmt.Sprintf("payment gateway: %s: %s", e.Reason, e.Message)
+}
+
+// PaymentAuthorization is a request to reserve an amount for an order.
+// Source identifies the customer's payment instrument, such as a card token
+// issued by the provider's client library.
+type PaymentAuthorization struct {
+	OrderID   int64
+	PaymentID int64
+	Amount    Money
+	Method    PaymentMethod
+	Source    string
+}
+
+// PaymentGateway is a payment provider. Implementations return a
+// *GatewayError when the provider refuses a request, and must honour the
+// context's deadline.
+type PaymentGateway interface {
+	// Name returns the provider name stored with each payment
+	Name() string
+	// Authorize reserves an amount and returns the provider's reference for it
+	Authorize(ctx context.Context, authorization *PaymentAuthorization) (string, error)
+	// Capture collects an authorized amount
+	Capture(ctx context.Context, reference string, amount Money) error
+	// Void releases an authorization that has not been captured
+	Void(ctx context.Context, reference string) error
+	// Refund returns part or all of a captured amount and returns the
+	// provider's reference for the refund
+	Refund(ctx context.Context, reference string, amount Money) (string, error)
+}
+
+// PaymentRepository defines the payment repository interface
+type PaymentRepository interface {
+	FindByID(ctx context.Context, id int64) (*Payment, error)
+	FindByOrderID(ctx context.Context, orderID int64) ([]Payment, error)
+	Create(ctx context.Context, payment *Payment) error
+	// Update stores a payment that has moved on from status from. It is a
+	// conflict when the stored payment is no longer in that status, so
+	// concurrent changes cannot overwrite each other.
+	Update(ctx context.Context, payment *Payment, from PaymentStatus) error
+	// Capture stores a payment captured from authorized and moves a pending
+	// order to processing in the same transaction
+	Capture(ctx context.Context, payment *Payment, actorID int64) error
+}
+
+// PaymentAuthorizeDTO represents the data for paying an order
+type PaymentAuthorizeDTO struct {
+	PaymentToken string `json:"payment_token" validate:"max=255"`
+	Capture      bool   `json:"capture"` // Captures the payment right after it is authorized
+	ActorID      int64  `json:"-"`       // Always taken from the authenticated user
+}
+
+// PaymentUseCase defines the payment use case interface
+type PaymentUseCase interface {
+	GetByOrderID(ctx context.Context, orderID int64) ([]Payment, error)
+	Authorize(ctx context.Context, orderID int64, authorizeDTO *PaymentAuthorizeDTO) (*Payment, error)
+	Capture(ctx context.Context, orderID, paymentID, actorID int64) (*Payment, error)
+	Void(ctx context.Context, orderID, paymentID int64) (*Payment, error)
+	// VoidOrder voids the authorized payments of an order
+	VoidOrder(ctx context.Context, orderID int64) error
+}
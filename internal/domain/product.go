@@ -4,17 +4,26 @@ import (
 	"context"
 )
 
-// Product represents a product entity
+// Product represents a product entity. A product with variants is sold by
+// variant, and its PriceRange and AvailableStock summarise its variants;
+// otherwise they are its own price and stock. Variants are only loaded for
+// single products, not lists. Version is incremented by every change to the
+// product, including stock changes.
 type Product struct {
-	ID          int64    `json:"id"`
-	Name        string   `json:"name"`
-	Description string   `json:"description"`
-	Price       float64  `json:"price"`
-	SKU         string   `json:"sku"`
-	Stock       int      `json:"stock"`
-	CategoryID  int64    `json:"category_id"`
-	Category    Category `json:"category,omitempty"`
-	Images      []string `json:"images,omitempty"`
+	ID             int64            `json:"id"`
+	Name           string           `json:"name"`
+	Description    string           `json:"description"`
+	Price          Money            `json:"price"`
+	SKU            string           `json:"sku"`
+	Stock          int              `json:"stock"`
+	CategoryID     int64            `json:"category_id"`
+	Category       Category         `json:"category,omitempty"`
+	Images         []string         `json:"images,omitempty"`
+	Options        []ProductOption  `json:"options,omitempty"`
+	Variants       []ProductVariant `json:"variants,omitempty"`
+	PriceRange     PriceRange       `json:"price_range"`
+	AvailableStock int              `json:"available_stock"`
+	Version        int64            `json:"version"`
 	BaseEntity
 }
 
@@ -22,38 +31,55 @@ type Product struct {
 type ProductRepository interface {
 	BaseRepository[Product, int64]
 	FindBySKU(ctx context.Context, sku string) (*Product, error)
-	FindByCategory(ctx context.Context, categoryID int64, limit, offset int) ([]Product, int, error)
+	FindByCategory(ctx context.Context, categoryID int64, includeDescendants bool, page PageRequest) (*Page[Product], error)
 	UpdateStock(ctx context.Context, id int64, quantity int) error
-	SearchProducts(ctx context.Context, query string, limit, offset int) ([]Product, int, error)
+	SearchProducts(ctx context.Context, query string, filter ProductFilter, page PageRequest) (*Page[ProductSearchHit], error)
+	SearchFacets(ctx context.Context, query string, filter ProductFilter, priceBounds []int64) (*ProductFacets, error)
+	FindByFilter(ctx context.Context, filter ProductFilter, page PageRequest) (*Page[Product], error)
+	FindVariants(ctx context.Context, productID int64) ([]ProductVariant, error)
+	FindVariantByID(ctx context.Context, id int64) (*ProductVariant, error)
+	CreateVariant(ctx context.Context, variant *ProductVariant) error
+	UpdateVariant(ctx context.Context, variant *ProductVariant) error
+	DeleteVariant(ctx context.Context, id int64) error
+	UpdateVariantStock(ctx context.Context, id int64, quantity int) error
 }
 
 // ProductCreateDTO represents the data for creating a product
 type ProductCreateDTO struct {
-	Name        string   `json:"name" validate:"required,min=3,max=100"`
-	Description string   `json:"description" validate:"max=1000"`
-	Price       float64  `json:"price" validate:"required,gt=0"`
-	SKU         string   `json:"sku" validate:"required,min=3,max=50"`
-	Stock       int      `json:"stock" validate:"required,gte=0"`
-	CategoryID  int64    `json:"category_id" validate:"required,gt=0"`
-	Images      []string `json:"images" validate:"dive,url"`
+	Name        string          `json:"name" validate:"required,min=3,max=100"`
+	Description string          `json:"description" validate:"max=1000"`
+	Price       Money           `json:"price" validate:"required"`
+	SKU         string          `json:"sku" validate:"required,min=3,max=50"`
+	Stock       int             `json:"stock" validate:"gte=0"`
+	CategoryID  int64           `json:"category_id" validate:"required,gt=0"`
+	Images      []string        `json:"images" validate:"dive,url"`
+	Options     []ProductOption `json:"options" validate:"dive"`
 }
 
 // ProductUpdateDTO represents the data for updating a product
 type ProductUpdateDTO struct {
-	Name        string   `json:"name" validate:"omitempty,min=3,max=100"`
-	Description string   `json:"description" validate:"max=1000"`
-	Price       float64  `json:"price" validate:"omitempty,gt=0"`
-	SKU         string   `json:"sku" validate:"omitempty,min=3,max=50"`
-	Stock       int      `json:"stock" validate:"omitempty,gte=0"`
-	CategoryID  int64    `json:"category_id" validate:"omitempty,gt=0"`
-	Images      []string `json:"images" validate:"omitempty,dive,url"`
+	Name        string          `json:"name" validate:"omitempty,min=3,max=100"`
+	Description string          `json:"description" validate:"max=1000"`
+	Price       *Money          `json:"price,omitempty" validate:"omitempty"`
+	SKU         string          `json:"sku" validate:"omitempty,min=3,max=50"`
+	Stock       int             `json:"stock" validate:"omitempty,gte=0"`
+	CategoryID  int64           `json:"category_id" validate:"omitempty,gt=0"`
+	Images      []string        `json:"images" validate:"omitempty,dive,url"`
+	Options     []ProductOption `json:"options" validate:"omitempty,dive"`
+	Version     int64           `json:"-"` // Taken from the If-Match header; zero updates any version
 }
 
 // ProductUseCase defines the product use case interface
 type ProductUseCase interface {
 	BaseUseCase[Product, int64, ProductCreateDTO, ProductUpdateDTO]
 	GetBySKU(ctx context.Context, sku string) (*Product, error)
-	GetByCategory(ctx context.Context, categoryID int64, page, perPage int) ([]Product, int, error)
+	GetByCategory(ctx context.Context, categoryID int64, includeDescendants bool, page PageRequest) (*Page[Product], error)
 	UpdateStock(ctx context.Context, id int64, quantity int) error
-	Search(ctx context.Context, query string, page, perPage int) ([]Product, int, error)
+	Search(ctx context.Context, query string, filter ProductFilter, page PageRequest, withFacets bool) (*ProductSearchResult, error)
+	ListByFilter(ctx context.Context, filter ProductFilter, page PageRequest) (*Page[Product], error)
+	GetVariants(ctx context.Context, productID int64) ([]ProductVariant, error)
+	CreateVariant(ctx context.Context, productID int64, createDTO *ProductVariantCreateDTO) (*ProductVariant, error)
+	UpdateVariant(ctx context.Context, productID, variantID int64, updateDTO *ProductVariantUpdateDTO) (*ProductVariant, error)
+	DeleteVariant(ctx context.Context, productID, variantID int64) error
+	UpdateVariantStock(ctx context.Context, productID, variantID int64, quantity int) error
 }
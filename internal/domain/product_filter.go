@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// ProductSortField is a field product lists can be sorted by
+type ProductSortField string
+
+const (
+	// ProductSortID sorts by product ID
+	ProductSortID ProductSortField = "id"
+	// ProductSortName sorts by product name
+	ProductSortName ProductSortField = "name"
+	// ProductSortPrice sorts by lowest price amount in minor units
+	ProductSortPrice ProductSortField = "price"
+	// ProductSortStock sorts by available stock
+	ProductSortStock ProductSortField = "stock"
+	// ProductSortCreatedAt sorts by creation time
+	ProductSortCreatedAt ProductSortField = "created_at"
+)
+
+// IsValid reports whether products can be sorted by the field
+func (f ProductSortField) IsValid() bool {
+	switch f {
+	case ProductSortID, ProductSortName, ProductSortPrice, ProductSortStock, ProductSortCreatedAt:
+		return true
+	}
+	return false
+}
+
+// ProductSort is one key of a product sort order
+type ProductSort struct {
+	Field      ProductSortField
+	Descending bool
+}
+
+// ProductFilter selects and orders the products of a list. Nil and empty
+// fields do not filter. Prices are compared in minor units.
+type ProductFilter struct {
+	MinPrice    *int64
+	MaxPrice    *int64
+	CategoryIDs []int64
+	InStock     *bool
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Sort        []ProductSort
+}
+
+// ParseProductSort parses a comma separated sort expression such as
+// "-price,name", where a leading "-" sorts that field in descending order
+func ParseProductSort(expr string) ([]ProductSort, error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	var sort []ProductSort
+	seen := make(map[ProductSortField]bool)
+	for _, part := range strings.Split(expr, ",") {
+		key := ProductSort{Field: ProductSortField(strings.TrimPrefix(part, "-")), Descending: strings.HasPrefix(part, "-")}
+		if !key.Field.IsValid() {
+			return nil, &ValidationError{Field: "sort", Message: "cannot sort by " + string(key.Field)}
+		}
+		if seen[key.Field] {
+			return nil, &ValidationError{Field: "sort", Message: "sorts by " + string(key.Field) + " more than once"}
+		}
+		seen[key.Field] = true
+		sort = append(sort, key)
+	}
+	return sort, nil
+}
+
+// SortKeys returns the sort order made total by ending it with the unique
+// product ID, so that every product has a single position in the list
+func (f ProductFilter) SortKeys() []ProductSort {
+	keys := make([]ProductSort, 0, len(f.Sort)+1)
+	for _, key := range f.Sort {
+		keys = append(keys, key)
+		if key.Field == ProductSortID {
+			// Keys after a unique field never decide the order
+			return keys
+		}
+	}
+	return append(keys, ProductSort{Field: ProductSortID})
+}
+
+// SortString returns the sort order in the syntax of ParseProductSort
+func (f ProductFilter) SortString() string {
+	parts := make([]string, len(f.Sort))
+	for i, key := range f.Sort {
+		parts[i] = string(key.Field)
+		if key.Descending {
+			parts[i] = "-" + parts[i]
+		}
+	}
+	return strings.Join(parts, ",")
+}
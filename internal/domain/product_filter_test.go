@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestParseProductSort tests parsing sort expressions
+func TestParseProductSort(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    []ProductSort
+		wantErr bool
+	}{
+		{expr: "", want: nil},
+		{expr: "name", want: []ProductSort{{Field: ProductSortName}}},
+		{expr: "-price,name", want: []ProductSort{{Field: ProductSortPrice, Descending: true}, {Field: ProductSortName}}},
+		{expr: "-created_at,-id", want: []ProductSort{{Field: ProductSortCreatedAt, Descending: true}, {Field: ProductSortID, Descending: true}}},
+		{expr: "sku", wantErr: true},
+		{expr: "price,-price", wantErr: true},
+		{expr: "name,", wantErr: true},
+		{expr: "p.price; DROP TABLE products", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			sort, err := ParseProductSort(tt.expr)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Errorf("Expected ErrInvalidInput, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(sort, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, sort)
+			}
+			if got := (ProductFilter{Sort: sort}).SortString(); got != tt.expr {
+				t.Errorf("Expected sort string %q, got %q", tt.expr, got)
+			}
+		})
+	}
+}
+
+// TestProductFilter_SortKeys tests that sort orders end with the product ID
+func TestProductFilter_SortKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		sort []ProductSort
+		want []ProductSort
+	}{
+		{name: "default", want: []ProductSort{{Field: ProductSortID}}},
+		{
+			name: "by price",
+			sort: []ProductSort{{Field: ProductSortPrice, Descending: true}},
+			want: []ProductSort{{Field: ProductSortPrice, Descending: true}, {Field: ProductSortID}},
+		},
+		{
+			name: "by id then name",
+			sort: []ProductSort{{Field: ProductSortID, Descending: true}, {Field: ProductSortName}},
+			want: []ProductSort{{Field: ProductSortID, Descending: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (ProductFilter{Sort: tt.sort}).SortKeys(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
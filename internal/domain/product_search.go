@@ -0,0 +1,52 @@
+package domain
+
+// ProductPriceBounds are the lower bounds, in minor units, of the price
+// ranges product search facets count. The first range starts at zero and
+// the last one is open ended.
+var ProductPriceBounds = []int64{1000, 2500, 5000, 10000, 25000}
+
+// ProductSearchHit is a product matching a search, with the relevance of the
+// match and a snippet of its description with the matching words in <b> tags
+type ProductSearchHit struct {
+	Product
+	Rank     float64 `json:"rank"`
+	Headline string  `json:"headline"`
+}
+
+// CategoryFacet is the number of matching products in a category
+type CategoryFacet struct {
+	CategoryID int64  `json:"category_id"`
+	Name       string `json:"name"`
+	Count      int    `json:"count"`
+}
+
+// PriceRangeFacet is the number of matching products priced from Min up to
+// but excluding Max, in minor units. Max is nil for the highest range.
+type PriceRangeFacet struct {
+	Min   int64  `json:"min"`
+	Max   *int64 `json:"max,omitempty"`
+	Count int    `json:"count"`
+}
+
+// StockFacet is the number of matching products in and out of stock
+type StockFacet struct {
+	InStock    int `json:"in_stock"`
+	OutOfStock int `json:"out_of_stock"`
+}
+
+// ProductFacets are the counts of the products matching a search, broken
+// down by category, price range and stock. Each facet applies every active
+// filter except its own, so that it counts the products each alternative
+// value would match.
+type ProductFacets struct {
+	Categories  []CategoryFacet   `json:"categories"`
+	PriceRanges []PriceRangeFacet `json:"price_ranges"`
+	Stock       StockFacet        `json:"stock"`
+}
+
+// ProductSearchResult is a page of search hits and, when requested, the
+// facets of every matching product
+type ProductSearchResult struct {
+	Page   *Page[ProductSearchHit]
+	Facets *ProductFacets
+}
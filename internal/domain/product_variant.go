@@ -0,0 +1,165 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ProductOption is an axis a product varies along, such as size or color,
+// with the values its variants may take
+type ProductOption struct {
+	Name   string   `json:"name" validate:"required,max=50"`
+	Values []string `json:"values" validate:"required,dive,required,max=50"`
+}
+
+// ProductVariant is a purchasable version of a product, picking one value of
+// each of the product's options. Price overrides the product price when set
+// and is always in the product's currency.
+type ProductVariant struct {
+	ID        int64             `json:"id"`
+	ProductID int64             `json:"product_id"`
+	SKU       string            `json:"sku"`
+	Options   map[string]string `json:"options"`
+	Price     *Money            `json:"price,omitempty"`
+	Stock     int               `json:"stock"`
+	BaseEntity
+}
+
+// PriceRange is the lowest and highest price a product sells for
+type PriceRange struct {
+	Min Money `json:"min"`
+	Max Money `json:"max"`
+}
+
+// ProductVariantCreateDTO represents the data for creating a product variant
+type ProductVariantCreateDTO struct {
+	SKU     string            `json:"sku" validate:"required,min=3,max=50"`
+	Options map[string]string `json:"options" validate:"required,dive,required"`
+	Price   *Money            `json:"price,omitempty"`
+	Stock   int               `json:"stock" validate:"gte=0"`
+}
+
+// ProductVariantUpdateDTO represents the data for updating a product
+// variant. ClearPrice removes a price override so the variant sells at the
+// product price.
+type ProductVariantUpdateDTO struct {
+	SKU        string            `json:"sku" validate:"omitempty,min=3,max=50"`
+	Options    map[string]string `json:"options,omitempty" validate:"omitempty,dive,required"`
+	Price      *Money            `json:"price,omitempty"`
+	ClearPrice bool              `json:"clear_price"`
+	Stock      *int              `json:"stock,omitempty" validate:"omitempty,gte=0"`
+}
+
+// ValidateProductOptions checks that option names and the values of each
+// option are unique
+func ValidateProductOptions(options []ProductOption) error {
+	names := make(map[string]bool, len(options))
+	for _, option := range options {
+		if names[option.Name] {
+			return &ValidationError{Field: "options", Message: fmt.Sprintf("lists %s more than once", option.Name)}
+		}
+		names[option.Name] = true
+
+		values := make(map[string]bool, len(option.Values))
+		for _, value := range option.Values {
+			if values[value] {
+				return &ValidationError{Field: "options", Message: fmt.Sprintf("lists %s %s more than once", option.Name, value)}
+			}
+			values[value] = true
+		}
+	}
+	return nil
+}
+
+// ValidateVariantOptions checks that options picks a listed value of every
+// option of the product and nothing else
+func (p *Product) ValidateVariantOptions(options map[string]string) error {
+	if len(p.Options) == 0 {
+		return &ValidationError{Field: "options", Message: "cannot be set, the product has no options"}
+	}
+
+	for _, option := range p.Options {
+		value, ok := options[option.Name]
+		if !ok {
+			return &ValidationError{Field: "options", Message: "must set " + option.Name}
+		}
+		if !containsString(option.Values, value) {
+			return &ValidationError{Field: "options", Message: fmt.Sprintf("%s must be one of %s", option.Name, strings.Join(option.Values, ", "))}
+		}
+	}
+
+	if len(options) > len(p.Options) {
+		var unknown []string
+		for name := range options {
+			if !p.hasOption(name) {
+				unknown = append(unknown, name)
+			}
+		}
+		sort.Strings(unknown)
+		return &ValidationError{Field: "options", Message: "has unknown options " + strings.Join(unknown, ", ")}
+	}
+
+	return nil
+}
+
+// Variant returns the product variant with the given ID, or nil if the
+// product has no such variant
+func (p *Product) Variant(id int64) *ProductVariant {
+	for i := range p.Variants {
+		if p.Variants[i].ID == id {
+			return &p.Variants[i]
+		}
+	}
+	return nil
+}
+
+// ResolveVariant returns the variant an order item of the product must
+// reference. Products with variants can only be ordered by variant, and
+// products without can only be ordered as a whole, so variantID must be set
+// exactly when the product has variants.
+func (p *Product) ResolveVariant(variantID *int64) (*ProductVariant, error) {
+	if len(p.Variants) == 0 {
+		if variantID != nil {
+			return nil, &ValidationError{Field: "variant_id", Message: fmt.Sprintf("must not be set, product %d has no variants", p.ID)}
+		}
+		return nil, nil
+	}
+
+	if variantID == nil {
+		return nil, &ValidationError{Field: "variant_id", Message: fmt.Sprintf("is required, product %d is sold by variant", p.ID)}
+	}
+	variant := p.Variant(*variantID)
+	if variant == nil {
+		return nil, &ValidationError{Field: "variant_id", Message: fmt.Sprintf("is not a variant of product %d", p.ID)}
+	}
+	return variant, nil
+}
+
+// PriceOf returns the price of a variant of the product
+func (p *Product) PriceOf(variant *ProductVariant) Money {
+	if variant != nil && variant.Price != nil {
+		return *variant.Price
+	}
+	return p.Price
+}
+
+// hasOption reports whether the product has an option with the given name
+func (p *Product) hasOption(name string) bool {
+	for _, option := range p.Options {
+		if option.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
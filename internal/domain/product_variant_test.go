@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestValidateProductOptions tests rejecting duplicate option names and values
+func TestValidateProductOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []ProductOption
+		valid   bool
+	}{
+		{"none", nil, true},
+		{"distinct", []ProductOption{{Name: "size", Values: []string{"S", "M"}}, {Name: "color", Values: []string{"red"}}}, true},
+		{"duplicate name", []ProductOption{{Name: "size", Values: []string{"S"}}, {Name: "size", Values: []string{"M"}}}, false},
+		{"duplicate value", []ProductOption{{Name: "size", Values: []string{"S", "S"}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateProductOptions(tt.options)
+			if tt.valid && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("Expected invalid input, got %v", err)
+			}
+		})
+	}
+}
+
+// TestProduct_ValidateVariantOptions tests that variants pick one listed value of every option
+func TestProduct_ValidateVariantOptions(t *testing.T) {
+	product := &Product{Options: []ProductOption{
+		{Name: "size", Values: []string{"S", "M"}},
+		{Name: "color", Values: []string{"red", "blue"}},
+	}}
+
+	tests := []struct {
+		name    string
+		options map[string]string
+		valid   bool
+	}{
+		{"every option", map[string]string{"size": "M", "color": "red"}, true},
+		{"missing option", map[string]string{"size": "M"}, false},
+		{"unlisted value", map[string]string{"size": "XL", "color": "red"}, false},
+		{"unknown option", map[string]string{"size": "M", "color": "red", "fit": "slim"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := product.ValidateVariantOptions(tt.options)
+			if tt.valid && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("Expected invalid input, got %v", err)
+			}
+		})
+	}
+
+	if err := (&Product{}).ValidateVariantOptions(map[string]string{}); err == nil {
+		t.Error("Expected error for a product without options, got nil")
+	}
+}
+
+// TestProduct_ResolveVariant tests which order items must reference a variant
+func TestProduct_ResolveVariant(t *testing.T) {
+	id := func(id int64) *int64 { return &id }
+	override := NewMoney(2500, CurrencyUSD)
+
+	plain := &Product{ID: 1, Price: NewMoney(2000, CurrencyUSD)}
+	if variant, err := plain.ResolveVariant(nil); err != nil || variant != nil {
+		t.Errorf("Expected no variant and no error, got %v, %v", variant, err)
+	}
+	if _, err := plain.ResolveVariant(id(7)); err == nil {
+		t.Error("Expected error for a variant of a product without variants, got nil")
+	}
+
+	shirt := &Product{ID: 2, Price: NewMoney(2000, CurrencyUSD), Variants: []ProductVariant{
+		{ID: 7, ProductID: 2},
+		{ID: 8, ProductID: 2, Price: &override},
+	}}
+	if _, err := shirt.ResolveVariant(nil); err == nil {
+		t.Error("Expected error for a product sold by variant, got nil")
+	}
+	if _, err := shirt.ResolveVariant(id(9)); err == nil {
+		t.Error("Expected error for a variant of another product, got nil")
+	}
+
+	variant, err := shirt.ResolveVariant(id(7))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if price := shirt.PriceOf(variant); price != shirt.Price {
+		t.Errorf("Expected the product price, got %v", price)
+	}
+
+	variant, _ = shirt.ResolveVariant(id(8))
+	if price := shirt.PriceOf(variant); price != override {
+		t.Errorf("Expected the variant price, got %v", price)
+	}
+}
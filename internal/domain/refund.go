@@ -0,0 +1,180 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+)
+
+// RefundStatus represents the status of a refund
+type RefundStatus string
+
+const (
+	// RefundStatusPending means the gateway has not answered yet
+	RefundStatusPending RefundStatus = "pending"
+
+	// RefundStatusSucceeded means the money has been returned
+	RefundStatusSucceeded RefundStatus = "succeeded"
+
+	// RefundStatusFailed means the gateway refused the refund or did not
+	// answer in time
+	RefundStatusFailed RefundStatus = "failed"
+)
+
+// Refund returns money from an order's captured payment, either as an
+// amount off the whole order or line by line. Pending and succeeded refunds
+// count against the captured amount; failed ones are kept for the record.
+type Refund struct {
+	ID                int64        `json:"id"`
+	OrderID           int64        `json:"order_id"`
+	PaymentID         int64        `json:"payment_id"`
+	Status            RefundStatus `json:"status"`
+	Amount            Money        `json:"amount"`
+	Reason            string       `json:"reason,omitempty"`
+	Restock           bool         `json:"restock"`
+	Items             []RefundItem `json:"items"`
+	ProviderReference string       `json:"provider_reference,omitempty"`
+	FailureMessage    string       `json:"failure_message,omitempty"`
+	ActorID           *int64       `json:"actor_id,omitempty"`
+	BaseEntity
+}
+
+// RefundItem is the part of a refund for one order item. Quantity is the
+// number of units returned, and may be zero for a goodwill amount.
+type RefundItem struct {
+	ID          int64 `json:"id"`
+	OrderItemID int64 `json:"order_item_id"`
+	Quantity    int   `json:"quantity"`
+	Amount      Money `json:"amount"`
+}
+
+// RefundedItem totals what has been refunded of one order item
+type RefundedItem struct {
+	Quantity int
+	Amount   int64
+}
+
+// RefundedTotals totals what has been refunded of a payment, overall and by
+// order item
+type RefundedTotals struct {
+	Amount int64
+	Items  map[int64]RefundedItem
+}
+
+// PaidTotal returns what the customer paid for the units of the line that
+// were not cancelled
+func (i *OrderItem) PaidTotal() (Money, error) {
+	total, err := i.UnitsTotal(i.Quantity)
+	if err != nil {
+		return Money{}, err
+	}
+	cancelled, err := i.UnitsTotal(i.CancelledQuantity)
+	if err != nil {
+		return Money{}, err
+	}
+	return total.Sub(cancelled)
+}
+
+// RefundTotal returns what quantity more units of the line cost, after the
+// refunded units. Cancelled units come first, so the units of the line add
+// up to its paid total exactly.
+func (i *OrderItem) RefundTotal(refunded, quantity int) (Money, error) {
+	from := i.CancelledQuantity + refunded
+	to, err := i.UnitsTotal(from + quantity)
+	if err != nil {
+		return Money{}, err
+	}
+	before, err := i.UnitsTotal(from)
+	if err != nil {
+		return Money{}, err
+	}
+	return to.Sub(before)
+}
+
+// CheckRefund returns a *ValidationError unless the refund, added to what
+// has been refunded already, stays within the captured amount, and each of
+// its lines within the units and paid total of its order item
+func CheckRefund(refund *Refund, captured Money, items []OrderItem, refunded RefundedTotals) error {
+	if refund.Amount.Currency != captured.Currency {
+		return &ValidationError{Field: "amount", Message: fmt.Sprintf("must be in %s", captured.Currency)}
+	}
+	if !refund.Amount.IsPositive() {
+		return &ValidationError{Field: "amount", Message: "must be positive"}
+	}
+	if remaining := captured.Amount - refunded.Amount; refund.Amount.Amount > remaining {
+		return &ValidationError{Field: "amount", Message: fmt.Sprintf("exceeds the refundable amount of %s", NewMoney(remaining, captured.Currency))}
+	}
+
+	byID := make(map[int64]*OrderItem, len(items))
+	for i := range items {
+		byID[items[i].ID] = &items[i]
+	}
+
+	totals := make(map[int64]RefundedItem, len(refund.Items))
+	for _, line := range refund.Items {
+		item, ok := byID[line.OrderItemID]
+		if !ok {
+			return &ValidationError{Field: "items", Message: fmt.Sprintf("order item %d is not part of the order", line.OrderItemID)}
+		}
+		if line.Amount.Currency != captured.Currency || line.Amount.Amount < 0 {
+			return &ValidationError{Field: "items", Message: fmt.Sprintf("amount of order item %d is invalid", line.OrderItemID)}
+		}
+
+		total, ok := totals[line.OrderItemID]
+		if !ok {
+			total = refunded.Items[line.OrderItemID]
+		}
+		total.Quantity += line.Quantity
+		total.Amount += line.Amount.Amount
+		totals[line.OrderItemID] = total
+
+		if total.Quantity > item.ActiveQuantity() {
+			return &ValidationError{Field: "items", Message: fmt.Sprintf("quantity of order item %d exceeds the %d units that can be refunded", line.OrderItemID, item.ActiveQuantity()-refunded.Items[line.OrderItemID].Quantity)}
+		}
+		paid, err := item.PaidTotal()
+		if err != nil {
+			return &ValidationError{Field: "items", Message: fmt.Sprintf("total of order item %d is out of range", line.OrderItemID)}
+		}
+		if total.Amount > paid.Amount {
+			return &ValidationError{Field: "items", Message: fmt.Sprintf("amount of order item %d exceeds what was paid for it", line.OrderItemID)}
+		}
+	}
+
+	return nil
+}
+
+// RefundRepository defines the refund repository interface
+type RefundRepository interface {
+	FindByOrderID(ctx context.Context, orderID int64) ([]Refund, error)
+	RefundedTotals(ctx context.Context, paymentID int64) (RefundedTotals, error)
+	// Create records a pending refund after checking it again with the
+	// payment locked, so concurrent refunds cannot exceed the captured amount
+	Create(ctx context.Context, refund *Refund) error
+	// Complete stores the final status of a pending refund and restocks its items
+	// when it succeeded and asked for it
+	Complete(ctx context.Context, refund *Refund) error
+}
+
+// RefundItemCreateDTO represents the refund of one order item. Without an
+// amount, the units are refunded at what was paid for them.
+type RefundItemCreateDTO struct {
+	OrderItemID int64  `json:"order_item_id" validate:"required,gt=0"`
+	Quantity    int    `json:"quantity" validate:"omitempty,gt=0"`
+	Amount      *Money `json:"amount,omitempty"`
+}
+
+// RefundCreateDTO represents the data for refunding an order. With items,
+// the refund is the sum of their amounts; otherwise it is Amount off the
+// whole order, or everything not refunded yet when Amount is omitted.
+type RefundCreateDTO struct {
+	Amount  *Money                `json:"amount,omitempty"`
+	Items   []RefundItemCreateDTO `json:"items" validate:"omitempty,dive"`
+	Reason  string                `json:"reason" validate:"max=500"`
+	Restock bool                  `json:"restock"` // Returns the refunded units to stock
+	ActorID int64                 `json:"-"`       // Always taken from the authenticated user
+}
+
+// RefundUseCase defines the refund use case interface
+type RefundUseCase interface {
+	GetByOrderID(ctx context.Context, orderID int64) ([]Refund, error)
+	Create(ctx context.Context, orderID int64, createDTO *RefundCreateDTO) (*Refund, error)
+}
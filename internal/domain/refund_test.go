@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestOrderItem_RefundTotal tests that refunded units add up to what was paid for the line
+func TestOrderItem_RefundTotal(t *testing.T) {
+	// 3 units at 10.00 with 1.00 off the line, one unit cancelled
+	item := OrderItem{Quantity: 3, CancelledQuantity: 1, Price: NewMoney(1000, CurrencyUSD), Discount: NewMoney(100, CurrencyUSD)}
+
+	paid, err := item.PaidTotal()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if paid.Amount != 1933 {
+		t.Errorf("Expected paid total 1933, got %d", paid.Amount)
+	}
+
+	first, err := item.RefundTotal(0, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	second, err := item.RefundTotal(1, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if first.Amount != 966 || second.Amount != 967 {
+		t.Errorf("Expected 966 and 967, got %d and %d", first.Amount, second.Amount)
+	}
+	if first.Amount+second.Amount != paid.Amount {
+		t.Errorf("Expected refunds to add up to %d, got %d", paid.Amount, first.Amount+second.Amount)
+	}
+}
+
+// TestCheckRefund tests the limits on a refund
+func TestCheckRefund(t *testing.T) {
+	captured := NewMoney(3000, CurrencyUSD)
+	items := []OrderItem{
+		{ID: 1, Quantity: 2, Price: NewMoney(1000, CurrencyUSD), Discount: NewMoney(0, CurrencyUSD)},
+		{ID: 2, Quantity: 1, Price: NewMoney(1000, CurrencyUSD), Discount: NewMoney(0, CurrencyUSD)},
+	}
+	refunded := RefundedTotals{Amount: 1000, Items: map[int64]RefundedItem{1: {Quantity: 1, Amount: 1000}}}
+
+	usd := func(amount int64) Money { return NewMoney(amount, CurrencyUSD) }
+
+	tests := []struct {
+		name   string
+		refund Refund
+		valid  bool
+	}{
+		{name: "rest of the order", refund: Refund{Amount: usd(2000)}, valid: true},
+		{name: "more than remains", refund: Refund{Amount: usd(2001)}},
+		{name: "zero", refund: Refund{Amount: usd(0)}},
+		{name: "other currency", refund: Refund{Amount: NewMoney(500, CurrencyEUR)}},
+		{name: "last unit of a line", refund: Refund{Amount: usd(1000), Items: []RefundItem{{OrderItemID: 1, Quantity: 1, Amount: usd(1000)}}}, valid: true},
+		{name: "units already refunded", refund: Refund{Amount: usd(2000), Items: []RefundItem{{OrderItemID: 1, Quantity: 2, Amount: usd(1000)}}}},
+		{name: "units over two lines", refund: Refund{Amount: usd(2), Items: []RefundItem{{OrderItemID: 2, Quantity: 1, Amount: usd(1)}, {OrderItemID: 2, Quantity: 1, Amount: usd(1)}}}},
+		{name: "more than paid for the line", refund: Refund{Amount: usd(1500), Items: []RefundItem{{OrderItemID: 2, Amount: usd(1500)}}}},
+		{name: "goodwill amount", refund: Refund{Amount: usd(300), Items: []RefundItem{{OrderItemID: 2, Amount: usd(300)}}}, valid: true},
+		{name: "unknown item", refund: Refund{Amount: usd(100), Items: []RefundItem{{OrderItemID: 3, Quantity: 1, Amount: usd(100)}}}},
+		{name: "negative line", refund: Refund{Amount: usd(100), Items: []RefundItem{{OrderItemID: 2, Amount: usd(-100)}}}},
+	}
+
+	for _, tt := range tests {
+		err := CheckRefund(&tt.refund, captured, items, refunded)
+		if tt.valid {
+			if err != nil {
+				t.Errorf("%s: expected no error, got %v", tt.name, err)
+			}
+			continue
+		}
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("%s: expected validation error, got %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+)
+
+// ReturnStatus represents the status of a return
+type ReturnStatus string
+
+const (
+	// ReturnStatusRequested means the customer has asked to return items
+	ReturnStatusRequested ReturnStatus = "requested"
+
+	// ReturnStatusApproved means the customer may send the items back
+	ReturnStatusApproved ReturnStatus = "approved"
+
+	// ReturnStatusRejected means the return was refused
+	ReturnStatusRejected ReturnStatus = "rejected"
+
+	// ReturnStatusReceived means the warehouse has received the items
+	ReturnStatusReceived ReturnStatus = "received"
+
+	// ReturnStatusInspected means the items have been inspected and their
+	// dispositions applied to stock
+	ReturnStatusInspected ReturnStatus = "inspected"
+)
+
+// returnStatusTransitions lists the statuses each status may move to.
+// Rejected and inspected returns are final.
+var returnStatusTransitions = map[ReturnStatus][]ReturnStatus{
+	ReturnStatusRequested: {ReturnStatusApproved, ReturnStatusRejected},
+	ReturnStatusApproved:  {ReturnStatusReceived},
+	ReturnStatusReceived:  {ReturnStatusInspected},
+	ReturnStatusRejected:  {},
+	ReturnStatusInspected: {},
+}
+
+// ReturnTransitionError describes a rejected return status transition
+type ReturnTransitionError struct {
+	From ReturnStatus
+	To   ReturnStatus
+}
+
+// Error returns the error message
+func (e *ReturnTransitionError) Error() string {
+	return fmt.Sprintf("return status cannot change from %s to %s", e.From, e.To)
+}
+
+// Is checks if the error is of the given type
+func (e *ReturnTransitionError) Is(target error) bool {
+	return target == ErrInvalidStatusTransition || target == ErrConflict
+}
+
+// ErrorCode returns the stable error code
+func (e *ReturnTransitionError) ErrorCode() string {
+	return "invalid_status_transition"
+}
+
+// IsValid reports whether the status is a known return status
+func (s ReturnStatus) IsValid() bool {
+	_, ok := returnStatusTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether a return in status s may move to next
+func (s ReturnStatus) CanTransitionTo(next ReturnStatus) bool {
+	for _, allowed := range returnStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTransition returns a *ReturnTransitionError unless s may move to next
+func (s ReturnStatus) ValidateTransition(next ReturnStatus) error {
+	if !s.CanTransitionTo(next) {
+		return &ReturnTransitionError{From: s, To: next}
+	}
+	return nil
+}
+
+// ReturnDisposition is what happens to a returned item after inspection
+type ReturnDisposition string
+
+const (
+	// ReturnDispositionRestock returns the units to stock
+	ReturnDispositionRestock ReturnDisposition = "restock"
+
+	// ReturnDispositionWriteOff discards the units
+	ReturnDispositionWriteOff ReturnDisposition = "write_off"
+)
+
+// Return is a customer's request to send back units of a completed order,
+// also known as a return merchandise authorization. Note holds the staff's
+// note on the latest status change.
+type Return struct {
+	ID      int64        `json:"id"`
+	OrderID int64        `json:"order_id"`
+	UserID  int64        `json:"user_id"`
+	Status  ReturnStatus `json:"status"`
+	Reason  string       `json:"reason"`
+	Note    string       `json:"note,omitempty"`
+	Items   []ReturnItem `json:"items"`
+	BaseEntity
+}
+
+// ReturnItem is the part of a return for one order item. Disposition is set
+// when the return is inspected.
+type ReturnItem struct {
+	ID          int64             `json:"id"`
+	ReturnID    int64             `json:"return_id"`
+	OrderItemID int64             `json:"order_item_id"`
+	Quantity    int               `json:"quantity"`
+	Reason      string            `json:"reason,omitempty"`
+	Disposition ReturnDisposition `json:"disposition,omitempty"`
+}
+
+// IsAccessibleBy reports whether the user may access the return. Users may
+// always access their own returns; other returns require the given permission.
+func (r *Return) IsAccessibleBy(user *User, permission Permission) bool {
+	return r.UserID == user.ID || user.HasPermission(permission)
+}
+
+// CheckReturn returns a *ValidationError unless every line of the return is
+// an item of the order, and the units returned of each item, added to those
+// in other returns, stay within the units not cancelled
+func CheckReturn(rma *Return, items []OrderItem, returned map[int64]int) error {
+	if len(rma.Items) == 0 {
+		return &ValidationError{Field: "items", Message: "at least one item is required"}
+	}
+
+	byID := make(map[int64]*OrderItem, len(items))
+	for i := range items {
+		byID[items[i].ID] = &items[i]
+	}
+
+	totals := make(map[int64]int, len(rma.Items))
+	for _, line := range rma.Items {
+		item, ok := byID[line.OrderItemID]
+		if !ok {
+			return &ValidationError{Field: "items", Message: fmt.Sprintf("order item %d is not part of the order", line.OrderItemID)}
+		}
+		if line.Quantity <= 0 {
+			return &ValidationError{Field: "items", Message: fmt.Sprintf("quantity of order item %d must be positive", line.OrderItemID)}
+		}
+
+		total, ok := totals[line.OrderItemID]
+		if !ok {
+			total = returned[line.OrderItemID]
+		}
+		total += line.Quantity
+		totals[line.OrderItemID] = total
+
+		if total > item.ActiveQuantity() {
+			return &ValidationError{Field: "items", Message: fmt.Sprintf("quantity of order item %d exceeds the %d units that can be returned", line.OrderItemID, item.ActiveQuantity()-returned[line.OrderItemID])}
+		}
+	}
+
+	return nil
+}
+
+// ReturnRepository defines the return repository interface
+type ReturnRepository interface {
+	FindByID(ctx context.Context, id int64) (*Return, error)
+	FindByOrderID(ctx context.Context, orderID int64) ([]Return, error)
+	// Create records a requested return after checking it with the order
+	// locked, so concurrent returns cannot exceed the units ordered
+	Create(ctx context.Context, rma *Return) error
+	// UpdateStatus moves a return from status from to its current status,
+	// storing the item dispositions and restocking the items to restock when
+	// it is inspected
+	UpdateStatus(ctx context.Context, rma *Return, from ReturnStatus) error
+}
+
+// ReturnItemCreateDTO represents the return of units of one order item
+type ReturnItemCreateDTO struct {
+	OrderItemID int64  `json:"order_item_id" validate:"required,gt=0"`
+	Quantity    int    `json:"quantity" validate:"required,gt=0"`
+	Reason      string `json:"reason" validate:"max=500"`
+}
+
+// ReturnCreateDTO represents the data for requesting a return
+type ReturnCreateDTO struct {
+	Reason string                `json:"reason" validate:"required,max=500"`
+	Items  []ReturnItemCreateDTO `json:"items" validate:"required,min=1,dive"`
+}
+
+// ReturnStatusUpdateDTO represents the data for approving, rejecting or
+// receiving a return
+type ReturnStatusUpdateDTO struct {
+	Status ReturnStatus `json:"status" validate:"required,oneof=approved rejected received"`
+	Note   string       `json:"note" validate:"max=500"`
+}
+
+// ReturnItemInspectDTO represents the disposition of one returned item
+type ReturnItemInspectDTO struct {
+	ReturnItemID int64             `json:"return_item_id" validate:"required,gt=0"`
+	Disposition  ReturnDisposition `json:"disposition" validate:"required,oneof=restock write_off"`
+}
+
+// ReturnInspectDTO represents the outcome of inspecting a received return.
+// Every item of the return needs a disposition.
+type ReturnInspectDTO struct {
+	Items []ReturnItemInspectDTO `json:"items" validate:"required,min=1,dive"`
+	Note  string                 `json:"note" validate:"max=500"`
+}
+
+// ReturnUseCase defines the return use case interface
+type ReturnUseCase interface {
+	GetByID(ctx context.Context, id int64) (*Return, error)
+	GetByOrderID(ctx context.Context, orderID int64) ([]Return, error)
+	Create(ctx context.Context, orderID int64, createDTO *ReturnCreateDTO) (*Return, error)
+	UpdateStatus(ctx context.Context, id int64, statusDTO *ReturnStatusUpdateDTO) (*Return, error)
+	Inspect(ctx context.Context, id int64, inspectDTO *ReturnInspectDTO) (*Return, error)
+}
@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestReturnStatus_ValidateTransition tests the return status state machine
+func TestReturnStatus_ValidateTransition(t *testing.T) {
+	tests := []struct {
+		from    ReturnStatus
+		to      ReturnStatus
+		allowed bool
+	}{
+		{from: ReturnStatusRequested, to: ReturnStatusApproved, allowed: true},
+		{from: ReturnStatusRequested, to: ReturnStatusRejected, allowed: true},
+		{from: ReturnStatusRequested, to: ReturnStatusReceived, allowed: false},
+		{from: ReturnStatusApproved, to: ReturnStatusReceived, allowed: true},
+		{from: ReturnStatusApproved, to: ReturnStatusRejected, allowed: false},
+		{from: ReturnStatusReceived, to: ReturnStatusInspected, allowed: true},
+		{from: ReturnStatusRejected, to: ReturnStatusApproved, allowed: false},
+		{from: ReturnStatusInspected, to: ReturnStatusReceived, allowed: false},
+	}
+
+	for _, tt := range tests {
+		err := tt.from.ValidateTransition(tt.to)
+		if tt.allowed && err != nil {
+			t.Errorf("%s -> %s: expected no error, got %v", tt.from, tt.to, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrConflict) {
+			t.Errorf("%s -> %s: expected conflict, got %v", tt.from, tt.to, err)
+		}
+	}
+}
+
+// TestCheckReturn tests the limits on the units returned
+func TestCheckReturn(t *testing.T) {
+	items := []OrderItem{
+		{ID: 1, Quantity: 3, CancelledQuantity: 1},
+		{ID: 2, Quantity: 1},
+	}
+	returned := map[int64]int{1: 1}
+
+	tests := []struct {
+		name  string
+		lines []ReturnItem
+		valid bool
+	}{
+		{name: "last unit of a line", lines: []ReturnItem{{OrderItemID: 1, Quantity: 1}}, valid: true},
+		{name: "two lines", lines: []ReturnItem{{OrderItemID: 1, Quantity: 1}, {OrderItemID: 2, Quantity: 1}}, valid: true},
+		{name: "cancelled units", lines: []ReturnItem{{OrderItemID: 1, Quantity: 2}}},
+		{name: "units over two lines", lines: []ReturnItem{{OrderItemID: 2, Quantity: 1}, {OrderItemID: 2, Quantity: 1}}},
+		{name: "unknown item", lines: []ReturnItem{{OrderItemID: 3, Quantity: 1}}},
+		{name: "zero quantity", lines: []ReturnItem{{OrderItemID: 2}}},
+		{name: "no items"},
+	}
+
+	for _, tt := range tests {
+		err := CheckReturn(&Return{Items: tt.lines}, items, returned)
+		if tt.valid {
+			if err != nil {
+				t.Errorf("%s: expected no error, got %v", tt.name, err)
+			}
+			continue
+		}
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("%s: expected validation error, got %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Permission represents a fine-grained right granted to a role
+type Permission string
+
+// Available permissions
+const (
+	PermissionProductWrite   Permission = "product:write"
+	PermissionInventoryWrite Permission = "inventory:write"
+	PermissionCategoryWrite  Permission = "category:write"
+	PermissionOrderRead      Permission = "order:read"
+	PermissionOrderWrite     Permission = "order:write"
+	PermissionOrderStatus    Permission = "order:status"
+	PermissionOrderRefund    Permission = "order:refund"
+	PermissionUserRead       Permission = "user:read"
+	PermissionUserWrite      Permission = "user:write"
+	PermissionSessionRevoke  Permission = "session:revoke"
+	PermissionRoleManage     Permission = "role:manage"
+	PermissionCouponManage   Permission = "coupon:manage"
+	PermissionReturnManage   Permission = "return:manage"
+)
+
+// AllPermissions lists every permission known to the application
+var AllPermissions = []Permission{
+	PermissionProductWrite,
+	PermissionInventoryWrite,
+	PermissionCategoryWrite,
+	PermissionOrderRead,
+	PermissionOrderWrite,
+	PermissionOrderStatus,
+	PermissionOrderRefund,
+	PermissionUserRead,
+	PermissionUserWrite,
+	PermissionSessionRevoke,
+	PermissionRoleManage,
+	PermissionCouponManage,
+	PermissionReturnManage,
+}
+
+// IsValid reports whether the permission is known to the application
+func (p Permission) IsValid() bool {
+	for _, known := range AllPermissions {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBuiltIn reports whether the role is required by the application and
+// therefore cannot be deleted
+func (r Role) IsBuiltIn() bool {
+	return r == RoleUser || r == RoleAdmin
+}
+
+// RoleDefinition represents a role and the permissions it grants
+type RoleDefinition struct {
+	Name        Role         `json:"name"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// RoleRepository represents the role repository contract
+type RoleRepository interface {
+	FindAll(ctx context.Context) ([]RoleDefinition, error)
+	FindByName(ctx context.Context, name Role) (*RoleDefinition, error)
+	Create(ctx context.Context, role *RoleDefinition) error
+	Update(ctx context.Context, role *RoleDefinition) error
+	Delete(ctx context.Context, name Role) error
+	GetPermissions(ctx context.Context, name Role) ([]Permission, error)
+	CountUsers(ctx context.Context, name Role) (int, error)
+}
+
+// RoleCreateDTO represents the data for creating a role
+type RoleCreateDTO struct {
+	Name        Role         `json:"name" validate:"required,min=3,max=50"`
+	Description string       `json:"description" validate:"max=500"`
+	Permissions []Permission `json:"permissions" validate:"dive,required"`
+}
+
+// RoleUpdateDTO represents the data for updating a role
+type RoleUpdateDTO struct {
+	Description string       `json:"description" validate:"max=500"`
+	Permissions []Permission `json:"permissions" validate:"omitempty,dive,required"`
+}
+
+// RoleAssignDTO represents the data for assigning a role to a user
+type RoleAssignDTO struct {
+	Role Role `json:"role" validate:"required"`
+}
+
+// RoleUseCase represents the role use case contract
+type RoleUseCase interface {
+	List(ctx context.Context) ([]RoleDefinition, error)
+	GetByName(ctx context.Context, name Role) (*RoleDefinition, error)
+	Create(ctx context.Context, createDTO *RoleCreateDTO) (*RoleDefinition, error)
+	Update(ctx context.Context, name Role, updateDTO *RoleUpdateDTO) (*RoleDefinition, error)
+	Delete(ctx context.Context, name Role) error
+	AssignRole(ctx context.Context, userID int64, role Role) error
+}
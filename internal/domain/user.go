@@ -23,6 +23,23 @@ type User struct {
 	Role      Role      `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// Permissions granted by Role, loaded when the user is authenticated
+	Permissions []Permission `json:"permissions,omitempty"`
+}
+
+// HasPermission reports whether the user has been granted the permission.
+// Admins implicitly hold every permission so they can never be locked out.
+func (u *User) HasPermission(permission Permission) bool {
+	if u.Role == RoleAdmin {
+		return true
+	}
+	for _, granted := range u.Permissions {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
 }
 
 // UserRepository represents the user repository contract
@@ -43,7 +60,10 @@ type UserUseCase interface {
 	Update(ctx context.Context, user *User) error
 	Delete(ctx context.Context, id int64) error
 	List(ctx context.Context, limit, offset int) ([]*User, error)
-	Login(ctx context.Context, email, password string) (string, error)
+	Login(ctx context.Context, email, password string) (*TokenResponse, error)
 	Register(ctx context.Context, user *User) error
 	ValidateToken(ctx context.Context, token string) (*User, error)
+	Refresh(ctx context.Context, refreshToken string) (*TokenResponse, error)
+	Logout(ctx context.Context, accessToken string, req *LogoutRequest) error
+	RevokeSessions(ctx context.Context, userID int64) error
 }
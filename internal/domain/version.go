@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Products, categories and orders carry a version that starts at 1 and is
+// incremented by every change to them. Updates name the version they were
+// based on and only apply while it is still current, so concurrent updates
+// cannot silently overwrite each other.
+
+// ErrVersionConflict is returned when an entity has changed since the
+// version an update was based on
+var ErrVersionConflict = errors.New("version conflict")
+
+// VersionConflictError describes an update based on a stale version of an
+// entity
+type VersionConflictError struct {
+	Entity  string
+	ID      int64
+	Version int64
+}
+
+// Error returns the error message
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s %d has changed since version %d", e.Entity, e.ID, e.Version)
+}
+
+// Is checks if the error is of the given type
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict || target == ErrConflict
+}
+
+// ErrorCode returns the stable error code
+func (e *VersionConflictError) ErrorCode() string {
+	return "version_conflict"
+}
+
+// CheckVersion returns a *VersionConflictError when expected is set and is
+// not the current version of the entity. A zero expected version accepts
+// any version.
+func CheckVersion(entity string, id, current, expected int64) error {
+	if expected != 0 && expected != current {
+		return &VersionConflictError{Entity: entity, ID: id, Version: expected}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestCheckVersion tests that only a stale expected version is a conflict
+func TestCheckVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected int64
+		conflict bool
+	}{
+		{name: "any version", expected: 0, conflict: false},
+		{name: "current version", expected: 3, conflict: false},
+		{name: "stale version", expected: 2, conflict: true},
+		{name: "future version", expected: 4, conflict: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckVersion("Product", 7, 3, tt.expected)
+			if !tt.conflict {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var conflictErr *VersionConflictError
+			if !errors.As(err, &conflictErr) {
+				t.Fatalf("Expected VersionConflictError, got %v", err)
+			}
+			if conflictErr.ID != 7 || conflictErr.Version != tt.expected {
+				t.Errorf("Expected product 7 at version %d, got %+v", tt.expected, conflictErr)
+			}
+			if !errors.Is(err, ErrVersionConflict) || !errors.Is(err, ErrConflict) {
+				t.Errorf("Expected ErrVersionConflict and ErrConflict, got %v", err)
+			}
+			if code := conflictErr.ErrorCode(); code != "version_conflict" {
+				t.Errorf("Expected code version_conflict, got %s", code)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+)
+
+// Payment sources understood by the fake gateway. Any other source,
+// including an empty one, is approved.
+const (
+	// FakeSourceDecline is declined by the issuer
+	FakeSourceDecline = "tok_decline"
+	// FakeSourceInsufficientFunds is declined for insufficient funds
+	FakeSourceInsufficientFunds = "tok_insufficient_funds"
+	// FakeSourceTimeout never answers; the authorization fails once the
+	// context is done
+	FakeSourceTimeout = "tok_timeout"
+)
+
+// fakeAuthorization is an authorization held by the fake gateway
+type fakeAuthorization struct {
+	amount   domain.Money
+	captured domain.Money
+	refunded domain.Money
+	voided   bool
+}
+
+// FakeGateway is an in-process payment gateway for development and tests.
+// Its outcomes depend only on the payment source and the calls made, and
+// its references are numbered in order, so runs are reproducible.
+type FakeGateway struct {
+	mu             sync.Mutex
+	authorizations map[string]*fakeAuthorization
+	sequence       int
+}
+
+// NewFakeGateway creates a new fake payment gateway
+func NewFakeGateway() *FakeGateway {
+	return &FakeGateway{
+		authorizations: make(map[string]*fakeAuthorization),
+	}
+}
+
+// Name returns the provider name
+func (g *FakeGateway) Name() string {
+	return ProviderFake
+}
+
+// Authorize approves the authorization unless its source simulates a failure
+func (g *FakeGateway) Authorize(ctx context.Context, authorization *domain.PaymentAuthorization) (string, error) {
+	switch authorization.Source {
+	case FakeSourceDecline:
+		return "", &domain.GatewayError{Reason: domain.GatewayFailureDeclined, Message: "the card was declined"}
+	case FakeSourceInsufficientFunds:
+		return "", &domain.GatewayError{Reason: domain.GatewayFailureInsufficientFunds, Message: "the card has insufficient funds"}
+	case FakeSourceTimeout:
+		<-ctx.Done()
+		return "", &domain.GatewayError{Reason: domain.GatewayFailureTimeout, Message: ctx.Err().Error()}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", &domain.GatewayError{Reason: domain.GatewayFailureTimeout, Message: err.Error()}
+	}
+	if !authorization.Amount.IsPositive() {
+		return "", &domain.GatewayError{Reason: domain.GatewayFailureProcessing, Message: "the amount must be positive"}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	reference := g.nextReference("auth")
+	zero := domain.NewMoney(0, authorization.Amount.Currency)
+	g.authorizations[reference] = &fakeAuthorization{
+		amount:   authorization.Amount,
+		captured: zero,
+		refunded: zero,
+	}
+	return reference, nil
+}
+
+// Capture collects up to the authorized amount, once
+func (g *FakeGateway) Capture(ctx context.Context, reference string, amount domain.Money) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	auth, err := g.find(reference)
+	if err != nil {
+		return err
+	}
+	switch {
+	case auth.voided:
+		return processingError("the authorization was voided")
+	case auth.captured.IsPositive():
+		return processingError("the authorization was already captured")
+	case amount.Currency != auth.amount.Currency || !amount.IsPositive() || amount.Amount > auth.amount.Amount:
+		return processingError("the amount exceeds the authorization")
+	}
+
+	auth.captured = amount
+	return nil
+}
+
+// Void releases an authorization that has not been captured
+func (g *FakeGateway) Void(ctx context.Context, reference string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	auth, err := g.find(reference)
+	if err != nil {
+		return err
+	}
+	if auth.captured.IsPositive() {
+		return processingError("the authorization was already captured")
+	}
+
+	auth.voided = true
+	return nil
+}
+
+// Refund returns part of the captured amount that has not been refunded yet
+func (g *FakeGateway) Refund(ctx context.Context, reference string, amount domain.Money) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	auth, err := g.find(reference)
+	if err != nil {
+		return "", err
+	}
+	if amount.Currency != auth.captured.Currency || !amount.IsPositive() || auth.refunded.Amount+amount.Amount > auth.captured.Amount {
+		return "", processingError("the amount exceeds the captured amount")
+	}
+
+	auth.refunded.Amount += amount.Amount
+	return g.nextReference("refund"), nil
+}
+
+// find returns the authorization with the reference
+func (g *FakeGateway) find(reference string) (*fakeAuthorization, error) {
+	auth, ok := g.authorizations[reference]
+	if !ok {
+		return nil, processingError(fmt.Sprintf("unknown reference %q", reference))
+	}
+	return auth, nil
+}
+
+// nextReference returns the next reference of the kind
+func (g *FakeGateway) nextReference(kind string) string {
+	g.sequence++
+	return fmt.Sprintf("fake_%s_%06d", kind, g.sequence)
+}
+
+// processingError creates a gateway error for a request the gateway cannot process
+func processingError(message string) *domain.GatewayError {
+	return &domain.GatewayError{Reason: domain.GatewayFailureProcessing, Message: message}
+}
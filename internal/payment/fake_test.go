@@ -0,0 +1,87 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+)
+
+// TestFakeGateway_Authorize tests the outcomes simulated for each payment source
+func TestFakeGateway_Authorize(t *testing.T) {
+	amount := domain.NewMoney(1000, domain.CurrencyUSD)
+
+	tests := []struct {
+		source string
+		reason domain.GatewayFailure
+	}{
+		{source: "tok_visa"},
+		{source: ""},
+		{source: FakeSourceDecline, reason: domain.GatewayFailureDeclined},
+		{source: FakeSourceInsufficientFunds, reason: domain.GatewayFailureInsufficientFunds},
+		{source: FakeSourceTimeout, reason: domain.GatewayFailureTimeout},
+	}
+
+	for _, tt := range tests {
+		gateway := NewFakeGateway()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		reference, err := gateway.Authorize(ctx, &domain.PaymentAuthorization{Amount: amount, Source: tt.source})
+		cancel()
+
+		if tt.reason == "" {
+			if err != nil {
+				t.Errorf("%q: expected no error, got %v", tt.source, err)
+			}
+			if reference != "fake_auth_000001" {
+				t.Errorf("%q: expected reference fake_auth_000001, got %s", tt.source, reference)
+			}
+			continue
+		}
+
+		var gatewayErr *domain.GatewayError
+		if !errors.As(err, &gatewayErr) || gatewayErr.Reason != tt.reason {
+			t.Errorf("%q: expected %s failure, got %v", tt.source, tt.reason, err)
+		}
+	}
+}
+
+// TestFakeGateway_CaptureRefund tests that captures and refunds stay within the authorized amount
+func TestFakeGateway_CaptureRefund(t *testing.T) {
+	gateway := NewFakeGateway()
+	ctx := context.Background()
+	amount := domain.NewMoney(1000, domain.CurrencyUSD)
+
+	reference, err := gateway.Authorize(ctx, &domain.PaymentAuthorization{Amount: amount})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := gateway.Refund(ctx, reference, domain.NewMoney(100, domain.CurrencyUSD)); err == nil {
+		t.Error("Expected refunding an uncaptured payment to fail")
+	}
+	if err := gateway.Capture(ctx, reference, domain.NewMoney(1500, domain.CurrencyUSD)); err == nil {
+		t.Error("Expected capturing more than authorized to fail")
+	}
+	if err := gateway.Capture(ctx, reference, amount); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := gateway.Void(ctx, reference); err == nil {
+		t.Error("Expected voiding a captured payment to fail")
+	}
+
+	refund, err := gateway.Refund(ctx, reference, domain.NewMoney(600, domain.CurrencyUSD))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if refund != "fake_refund_000002" {
+		t.Errorf("Expected reference fake_refund_000002, got %s", refund)
+	}
+	if _, err := gateway.Refund(ctx, reference, domain.NewMoney(600, domain.CurrencyUSD)); err == nil {
+		t.Error("Expected refunding more than captured to fail")
+	}
+	if _, err := gateway.Refund(ctx, reference, domain.NewMoney(400, domain.CurrencyUSD)); err != nil {
+		t.Errorf("Expected refunding the rest to succeed, got %v", err)
+	}
+}
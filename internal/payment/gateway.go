@@ -0,0 +1,22 @@
+// Package payment provides the payment gateways the application can use.
+package payment
+
+import (
+	"fmt"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/config"
+)
+
+// ProviderFake is the name of the in-process fake gateway
+const ProviderFake = "fake"
+
+// NewGateway creates the payment gateway selected by the configuration
+func NewGateway(cfg config.PaymentConfig) (domain.PaymentGateway, error) {
+	switch cfg.Provider {
+	case ProviderFake:
+		return NewFakeGateway(), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", cfg.Provider)
+	}
+}
@@ -0,0 +1,278 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	pkgerrors "github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// cartItemLine is the conflict target matching idx_cart_items_line
+const cartItemLine = "(cart_id, product_id, COALESCE(variant_id, 0))"
+
+type cartRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// NewCartRepository creates a new cart repository
+func NewCartRepository(db *sql.DB, logger logger.Logger) domain.CartRepository {
+	return &cartRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// FindOrCreateByUserID finds a user's cart, creating an empty one if the
+// user has none yet
+func (r *cartRepository) FindOrCreateByUserID(ctx context.Context, userID int64) (*domain.Cart, error) {
+	// The no-op update makes RETURNING yield the existing row on conflict
+	query := `
+		INSERT INTO carts (user_id, created_at, updated_at)
+		VALUES ($1, $2, $2)
+		ON CONFLICT (user_id) DO UPDATE SET user_id = EXCLUDED.user_id
+		RETURNING id, created_at, updated_at
+	`
+
+	cart := &domain.Cart{UserID: &userID}
+	err := r.db.QueryRowContext(ctx, query, userID, time.Now().UTC()).Scan(&cart.ID, &cart.CreatedAt, &cart.UpdatedAt)
+	if err != nil {
+		r.logger.Error("Failed to find or create user cart", zap.Int64("userID", userID), zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
+	}
+
+	if cart.Items, err = r.findItems(ctx, cart.ID); err != nil {
+		return nil, err
+	}
+
+	return cart, nil
+}
+
+// FindByTokenHash finds an anonymous cart by the hash of its token
+func (r *cartRepository) FindByTokenHash(ctx context.Context, tokenHash string) (*domain.Cart, error) {
+	query := `SELECT id, created_at, updated_at FROM carts WHERE token_hash = $1`
+
+	cart := &domain.Cart{}
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(&cart.ID, &cart.CreatedAt, &cart.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, pkgerrors.NewNotFoundError("Cart", "token")
+		}
+		r.logger.Error("Failed to find cart by token", zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
+	}
+
+	if cart.Items, err = r.findItems(ctx, cart.ID); err != nil {
+		return nil, err
+	}
+
+	return cart, nil
+}
+
+// CreateAnonymous creates an empty anonymous cart found by tokenHash
+func (r *cartRepository) CreateAnonymous(ctx context.Context, tokenHash string) (*domain.Cart, error) {
+	query := `
+		INSERT INTO carts (token_hash, created_at, updated_at)
+		VALUES ($1, $2, $2)
+		RETURNING id
+	`
+
+	cart := &domain.Cart{Items: []domain.CartItem{}}
+	cart.CreatedAt = time.Now().UTC()
+	cart.UpdatedAt = cart.CreatedAt
+
+	if err := r.db.QueryRowContext(ctx, query, tokenHash, cart.CreatedAt).Scan(&cart.ID); err != nil {
+		r.logger.Error("Failed to create anonymous cart", zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
+	}
+
+	return cart, nil
+}
+
+// AddItem adds an item to a cart. If the cart already holds the product or
+// variant, the quantity is added to that line and its price replaced.
+func (r *cartRepository) AddItem(ctx context.Context, item *domain.CartItem) error {
+	query := `
+		INSERT INTO cart_items (cart_id, product_id, variant_id, quantity, price, currency, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT ` + cartItemLine + ` DO UPDATE
+		SET quantity = cart_items.quantity + EXCLUDED.quantity, price = EXCLUDED.price, currency = EXCLUDED.currency, updated_at = EXCLUDED.updated_at
+		RETURNING id, quantity, created_at
+	`
+
+	item.UpdatedAt = time.Now().UTC()
+
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		item.CartID,
+		item.ProductID,
+		item.VariantID,
+		item.Quantity,
+		item.Price.Amount,
+		item.Price.Currency,
+		item.UpdatedAt,
+	).Scan(&item.ID, &item.Quantity, &item.CreatedAt)
+
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return pkgerrors.NewNotFoundError("Product", item.ProductID)
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23514" {
+			return pkgerrors.NewBadRequestError("Cart item quantity is out of range")
+		}
+		r.logger.Error("Failed to add cart item", zap.Int64("cartID", item.CartID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	return nil
+}
+
+// UpdateItem updates the quantity and price of a cart item
+func (r *cartRepository) UpdateItem(ctx context.Context, item *domain.CartItem) error {
+	query := `
+		UPDATE cart_items
+		SET quantity = $1, price = $2, currency = $3, updated_at = $4
+		WHERE id = $5 AND cart_id = $6
+	`
+
+	item.UpdatedAt = time.Now().UTC()
+
+	result, err := r.db.ExecContext(ctx, query, item.Quantity, item.Price.Amount, item.Price.Currency, item.UpdatedAt, item.ID, item.CartID)
+	if err != nil {
+		r.logger.Error("Failed to update cart item", zap.Int64("id", item.ID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		return pkgerrors.NewNotFoundError("Cart item", item.ID)
+	}
+
+	return nil
+}
+
+// RemoveItem removes an item from a cart
+func (r *cartRepository) RemoveItem(ctx context.Context, cartID, itemID int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM cart_items WHERE id = $1 AND cart_id = $2`, itemID, cartID)
+	if err != nil {
+		r.logger.Error("Failed to remove cart item", zap.Int64("id", itemID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		return pkgerrors.NewNotFoundError("Cart item", itemID)
+	}
+
+	return nil
+}
+
+// Merge moves the items of one cart into another and deletes the emptied
+// cart. Lines for the same product or variant are combined by adding their
+// quantities.
+func (r *cartRepository) Merge(ctx context.Context, fromCartID, toCartID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+	defer r.rollback(tx)
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO cart_items (cart_id, product_id, variant_id, quantity, price, currency, created_at, updated_at)
+		SELECT $2, product_id, variant_id, quantity, price, currency, created_at, $3
+		FROM cart_items
+		WHERE cart_id = $1
+		ORDER BY id
+		ON CONFLICT `+cartItemLine+` DO UPDATE
+		SET quantity = cart_items.quantity + EXCLUDED.quantity, updated_at = EXCLUDED.updated_at`,
+		fromCartID,
+		toCartID,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		r.logger.Error("Failed to merge cart items", zap.Int64("fromCartID", fromCartID), zap.Int64("toCartID", toCartID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM carts WHERE id = $1`, fromCartID); err != nil {
+		r.logger.Error("Failed to delete merged cart", zap.Int64("id", fromCartID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	return nil
+}
+
+// findItems finds the items of a cart, oldest first
+func (r *cartRepository) findItems(ctx context.Context, cartID int64) ([]domain.CartItem, error) {
+	query := `
+		SELECT id, cart_id, product_id, variant_id, quantity, price, currency, created_at, updated_at
+		FROM cart_items
+		WHERE cart_id = $1
+		ORDER BY id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, cartID)
+	if err != nil {
+		r.logger.Error("Failed to find cart items", zap.Int64("cartID", cartID), zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
+	}
+	defer rows.Close()
+
+	items := []domain.CartItem{}
+	for rows.Next() {
+		var item domain.CartItem
+		if err := rows.Scan(
+			&item.ID,
+			&item.CartID,
+			&item.ProductID,
+			&item.VariantID,
+			&item.Quantity,
+			&item.Price.Amount,
+			&item.Price.Currency,
+			&item.CreatedAt,
+			&item.UpdatedAt,
+		); err != nil {
+			r.logger.Error("Failed to scan cart item", zap.Error(err))
+			return nil, pkgerrors.NewInternalError(err)
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Error iterating cart item rows", zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
+	}
+
+	return items, nil
+}
+
+// rollback rolls back tx unless it has already been committed
+func (r *cartRepository) rollback(tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		r.logger.Error("Failed to rollback transaction", zap.Error(err))
+	}
+}
@@ -0,0 +1,137 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+)
+
+// TestCartRepository_Merge tests combining cart lines when adding and merging
+func TestCartRepository_Merge(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewCartRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	productID := seedProduct(t, db, 10)
+	userID := seedUser(t, db)
+	price := domain.NewMoney(1000, domain.CurrencyUSD)
+
+	anonymous, err := repo.CreateAnonymous(ctx, uniqueName("token"))
+	if err != nil {
+		t.Fatalf("Failed to create anonymous cart: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := repo.AddItem(ctx, &domain.CartItem{CartID: anonymous.ID, ProductID: productID, Quantity: 2, Price: price}); err != nil {
+			t.Fatalf("Failed to add cart item: %v", err)
+		}
+	}
+
+	userCart, err := repo.FindOrCreateByUserID(ctx, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := repo.AddItem(ctx, &domain.CartItem{CartID: userCart.ID, ProductID: productID, Quantity: 1, Price: price}); err != nil {
+		t.Fatalf("Failed to add cart item: %v", err)
+	}
+
+	if err := repo.Merge(ctx, anonymous.ID, userCart.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	merged, err := repo.FindOrCreateByUserID(ctx, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if merged.ID != userCart.ID {
+		t.Errorf("Expected the existing cart %d, got %d", userCart.ID, merged.ID)
+	}
+	if len(merged.Items) != 1 || merged.Items[0].Quantity != 5 {
+		t.Errorf("Expected one line of 5, got %+v", merged.Items)
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM carts WHERE id = $1`, anonymous.ID).Scan(&count); err != nil {
+		t.Fatalf("Failed to count carts: %v", err)
+	}
+	if count != 0 {
+		t.Error("Expected the anonymous cart to be deleted")
+	}
+}
+
+// TestOrderRepository_Create_CartItems tests that checked out cart items are removed with the order, exactly once
+func TestOrderRepository_Create_CartItems(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewCartRepository(db, logger.NewLogger("error"))
+	orderRepo := NewOrderRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	productID := seedProduct(t, db, 10)
+	userID := seedUser(t, db)
+	price := domain.NewMoney(1000, domain.CurrencyUSD)
+
+	cart, err := repo.FindOrCreateByUserID(ctx, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := repo.AddItem(ctx, &domain.CartItem{CartID: cart.ID, ProductID: productID, Quantity: 2, Price: price}); err != nil {
+		t.Fatalf("Failed to add cart item: %v", err)
+	}
+	read, err := repo.FindOrCreateByUserID(ctx, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	newOrder := func() *domain.Order {
+		total, _ := price.Mul(2)
+		return &domain.Order{
+			UserID:        userID,
+			Status:        domain.OrderStatusPending,
+			Subtotal:      total,
+			TotalAmount:   total,
+			PaymentMethod: domain.PaymentMethodCreditCard,
+			Items:         []domain.OrderItem{{ProductID: productID, Quantity: 2, Price: price}},
+			CartItems:     read.Items,
+		}
+	}
+
+	// A line changed after the cart was read is not ordered
+	if err := repo.AddItem(ctx, &domain.CartItem{CartID: cart.ID, ProductID: productID, Quantity: 1, Price: price}); err != nil {
+		t.Fatalf("Failed to add cart item: %v", err)
+	}
+	var changedErr *domain.CartChangedError
+	if err := orderRepo.Create(ctx, newOrder()); !errors.As(err, &changedErr) {
+		t.Fatalf("Expected CartChangedError, got %v", err)
+	}
+	assertStock(t, db, productID, 10)
+
+	read, err = repo.FindOrCreateByUserID(ctx, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	read.Items[0].Quantity = 2
+	if _, err := db.ExecContext(ctx, `UPDATE cart_items SET quantity = 2 WHERE id = $1`, read.Items[0].ID); err != nil {
+		t.Fatalf("Failed to update cart item: %v", err)
+	}
+
+	if err := orderRepo.Create(ctx, newOrder()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	assertStock(t, db, productID, 8)
+
+	emptied, err := repo.FindOrCreateByUserID(ctx, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(emptied.Items) != 0 {
+		t.Errorf("Expected the cart to be emptied, got %+v", emptied.Items)
+	}
+
+	// Checking out the same items again places no second order
+	if err := orderRepo.Create(ctx, newOrder()); !errors.As(err, &changedErr) {
+		t.Errorf("Expected CartChangedError, got %v", err)
+	}
+	assertStock(t, db, productID, 8)
+}
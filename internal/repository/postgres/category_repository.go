@@ -4,14 +4,23 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net/http"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/milad-ahmd/go-clean-arch/internal/domain"
 	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
 	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
 	"go.uber.org/zap"
 )
 
+// categoryColumns are the columns read by scanCategory
+const categoryColumns = "id, name, description, slug, parent_id, path, created_at, updated_at, version"
+
+// categoryMoveLockID is the advisory lock key that serializes category moves
+// across every instance sharing the database
+const categoryMoveLockID int64 = 7_241_385_020
+
 type categoryRepository struct {
 	db     *sql.DB
 	logger logger.Logger
@@ -27,22 +36,9 @@ func NewCategoryRepository(db *sql.DB, logger logger.Logger) domain.CategoryRepo
 
 // FindByID finds a category by ID
 func (r *categoryRepository) FindByID(ctx context.Context, id int64) (*domain.Category, error) {
-	query := `
-		SELECT id, name, description, slug, created_at, updated_at
-		FROM categories
-		WHERE id = $1
-	`
-
-	var category domain.Category
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&category.ID,
-		&category.Name,
-		&category.Description,
-		&category.Slug,
-		&category.CreatedAt,
-		&category.UpdatedAt,
-	)
+	query := `SELECT ` + categoryColumns + ` FROM categories WHERE id = $1`
 
+	category, err := scanCategory(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.NewNotFoundError("Category", id)
@@ -54,95 +50,111 @@ func (r *categoryRepository) FindByID(ctx context.Context, id int64) (*domain.Ca
 	return &category, nil
 }
 
-// FindAll finds all categories with pagination
-func (r *categoryRepository) FindAll(ctx context.Context, limit, offset int) ([]domain.Category, int, error) {
-	query := `
-		SELECT id, name, description, slug, created_at, updated_at
-		FROM categories
-		ORDER BY id
-		LIMIT $1 OFFSET $2
-	`
+// FindAll finds a page of all categories
+func (r *categoryRepository) FindAll(ctx context.Context, page domain.PageRequest) (*domain.Page[domain.Category], error) {
+	query, args, err := pageQuery(`SELECT `+categoryColumns+` FROM categories`, idOrder("id"), nil, nil, page)
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		r.logger.Error("Failed to find all categories", zap.Error(err))
-		return nil, 0, errors.NewInternalError(err)
+		return nil, errors.NewInternalError(err)
 	}
 	defer rows.Close()
 
-	var categories []domain.Category
-	for rows.Next() {
-		var category domain.Category
-		if err := rows.Scan(
-			&category.ID,
-			&category.Name,
-			&category.Description,
-			&category.Slug,
-			&category.CreatedAt,
-			&category.UpdatedAt,
-		); err != nil {
-			r.logger.Error("Failed to scan category", zap.Error(err))
-			return nil, 0, errors.NewInternalError(err)
-		}
-		categories = append(categories, category)
-	}
-
-	if err := rows.Err(); err != nil {
-		r.logger.Error("Error iterating category rows", zap.Error(err))
-		return nil, 0, errors.NewInternalError(err)
+	categories, err := r.scanCategories(rows)
+	if err != nil {
+		return nil, err
 	}
 
-	// Get total count
-	var total int
-	countQuery := `SELECT COUNT(*) FROM categories`
-	err = r.db.QueryRowContext(ctx, countQuery).Scan(&total)
-	if err != nil {
-		r.logger.Error("Failed to get total category count", zap.Error(err))
-		return nil, 0, errors.NewInternalError(err)
+	result := newPage(categories, page, func(c domain.Category) domain.Cursor { return domain.Cursor{ID: c.ID} })
+	if !page.SkipTotal {
+		total, err := countRows(ctx, r.db, "categories", nil, nil)
+		if err != nil {
+			r.logger.Error("Failed to get total category count", zap.Error(err))
+			return nil, errors.NewInternalError(err)
+		}
+		result.Total = &total
 	}
 
-	return categories, total, nil
+	return result, nil
 }
 
-// Create creates a new category
+// Create creates a new category under its parent, or at the top level when
+// it has none
 func (r *categoryRepository) Create(ctx context.Context, category *domain.Category) error {
-	query := `
-		INSERT INTO categories (name, description, slug, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id
-	`
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+	defer r.rollback(tx)
+
+	// Lock the parent so that it cannot move before the child's path is set
+	var parent *domain.Category
+	if category.ParentID != nil {
+		parent, err = r.lockCategory(ctx, tx, *category.ParentID)
+		if err != nil {
+			return err
+		}
+	}
 
-	now := time.Now().Unix()
+	now := time.Now().UTC()
 	category.CreatedAt = now
 	category.UpdatedAt = now
 
-	err := r.db.QueryRowContext(
+	query := `
+		INSERT INTO categories (name, description, slug, parent_id, path, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, '', $5, $6)
+		RETURNING id, version
+	`
+
+	err = tx.QueryRowContext(
 		ctx,
 		query,
 		category.Name,
 		category.Description,
 		category.Slug,
+		category.ParentID,
 		category.CreatedAt,
 		category.UpdatedAt,
-	).Scan(&category.ID)
+	).Scan(&category.ID, &category.Version)
 
 	if err != nil {
 		r.logger.Error("Failed to create category", zap.Error(err))
 		return errors.NewInternalError(err)
 	}
 
+	// The path ends with the category's own ID, known only after the insert
+	category.Path = domain.CategoryRootPath(category.ID)
+	if parent != nil {
+		category.Path = parent.ChildPath(category.ID)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE categories SET path = $1 WHERE id = $2`, category.Path, category.ID); err != nil {
+		r.logger.Error("Failed to set category path", zap.Int64("id", category.ID), zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
 	return nil
 }
 
-// Update updates a category
+// Update updates a category, provided it is still at category.Version, and
+// moves it to the next version
 func (r *categoryRepository) Update(ctx context.Context, category *domain.Category) error {
 	query := `
 		UPDATE categories
-		SET name = $1, description = $2, slug = $3, updated_at = $4
-		WHERE id = $5
+		SET name = $1, description = $2, slug = $3, updated_at = $4, version = version + 1
+		WHERE id = $5 AND version = $6
 	`
 
-	category.UpdatedAt = time.Now().Unix()
+	category.UpdatedAt = time.Now().UTC()
 
 	result, err := r.db.ExecContext(
 		ctx,
@@ -152,6 +164,7 @@ func (r *categoryRepository) Update(ctx context.Context, category *domain.Catego
 		category.Slug,
 		category.UpdatedAt,
 		category.ID,
+		category.Version,
 	)
 
 	if err != nil {
@@ -166,9 +179,10 @@ func (r *categoryRepository) Update(ctx context.Context, category *domain.Catego
 	}
 
 	if rowsAffected == 0 {
-		return errors.NewNotFoundError("Category", category.ID)
+		return versionConflict(ctx, r.db, r.logger, "categories", "Category", category.ID, category.Version)
 	}
 
+	category.Version++
 	return nil
 }
 
@@ -178,6 +192,9 @@ func (r *categoryRepository) Delete(ctx context.Context, id int64) error {
 
 	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return errors.NewAppError(errors.ErrConflict, "Category still has subcategories or products", http.StatusConflict)
+		}
 		r.logger.Error("Failed to delete category", zap.Int64("id", id), zap.Error(err))
 		return errors.NewInternalError(err)
 	}
@@ -197,22 +214,9 @@ func (r *categoryRepository) Delete(ctx context.Context, id int64) error {
 
 // FindBySlug finds a category by slug
 func (r *categoryRepository) FindBySlug(ctx context.Context, slug string) (*domain.Category, error) {
-	query := `
-		SELECT id, name, description, slug, created_at, updated_at
-		FROM categories
-		WHERE slug = $1
-	`
-
-	var category domain.Category
-	err := r.db.QueryRowContext(ctx, query, slug).Scan(
-		&category.ID,
-		&category.Name,
-		&category.Description,
-		&category.Slug,
-		&category.CreatedAt,
-		&category.UpdatedAt,
-	)
+	query := `SELECT ` + categoryColumns + ` FROM categories WHERE slug = $1`
 
+	category, err := scanCategory(r.db.QueryRowContext(ctx, query, slug))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.NewNotFoundError("Category", fmt.Sprintf("slug=%s", slug))
@@ -226,29 +230,222 @@ func (r *categoryRepository) FindBySlug(ctx context.Context, slug string) (*doma
 
 // FindByName finds a category by name
 func (r *categoryRepository) FindByName(ctx context.Context, name string) (*domain.Category, error) {
+	query := `SELECT ` + categoryColumns + ` FROM categories WHERE name = $1`
+
+	category, err := scanCategory(r.db.QueryRowContext(ctx, query, name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("Category", fmt.Sprintf("name=%s", name))
+		}
+		r.logger.Error("Failed to find category by name", zap.String("name", name), zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
+
+	return &category, nil
+}
+
+// FindTree finds every category, ordered by name
+func (r *categoryRepository) FindTree(ctx context.Context) ([]domain.Category, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+categoryColumns+` FROM categories ORDER BY name, id`)
+	if err != nil {
+		r.logger.Error("Failed to find category tree", zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
+	defer rows.Close()
+
+	return r.scanCategories(rows)
+}
+
+// FindSubtree finds a category and its descendants, ordered by name
+func (r *categoryRepository) FindSubtree(ctx context.Context, id int64) ([]domain.Category, error) {
 	query := `
-		SELECT id, name, description, slug, created_at, updated_at
+		SELECT ` + categoryColumns + `
 		FROM categories
-		WHERE name = $1
+		WHERE path LIKE (SELECT path FROM categories WHERE id = $1) || '%'
+		ORDER BY name, id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		r.logger.Error("Failed to find category subtree", zap.Int64("id", id), zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
+	defer rows.Close()
+
+	categories, err := r.scanCategories(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(categories) == 0 {
+		return nil, errors.NewNotFoundError("Category", id)
+	}
+
+	return categories, nil
+}
+
+// FindAncestors finds the categories from the top level down to and
+// including a category
+func (r *categoryRepository) FindAncestors(ctx context.Context, id int64) ([]domain.Category, error) {
+	query := `
+		SELECT ` + qualifiedColumns("a", categoryColumns) + `
+		FROM categories c
+		JOIN categories a ON c.path LIKE a.path || '%'
+		WHERE c.id = $1
+		ORDER BY length(a.path)
 	`
 
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		r.logger.Error("Failed to find category ancestors", zap.Int64("id", id), zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
+	defer rows.Close()
+
+	categories, err := r.scanCategories(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(categories) == 0 {
+		return nil, errors.NewNotFoundError("Category", id)
+	}
+
+	return categories, nil
+}
+
+// Move places a category and its descendants under a new parent, or at the
+// top level when parentID is nil. Moves are serialized by an advisory lock
+// held until the transaction ends: a move that checks for a cycle while
+// another one rewrites the new parent's ancestors could otherwise miss it.
+// Both categories are also locked against changes while the paths are
+// rewritten.
+func (r *categoryRepository) Move(ctx context.Context, id int64, parentID *int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+	defer r.rollback(tx)
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, categoryMoveLockID); err != nil {
+		r.logger.Error("Failed to acquire category move lock", zap.Int64("id", id), zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	// Lock in ID order, so that concurrent writes to the same pair of
+	// categories wait for each other instead of deadlocking
+	ids := []int64{id}
+	if parentID != nil {
+		ids = append(ids, *parentID)
+	}
+	locked := make(map[int64]domain.Category)
+	rows, err := tx.QueryContext(ctx, `SELECT `+categoryColumns+` FROM categories WHERE id = ANY($1) ORDER BY id FOR UPDATE`, pq.Array(ids))
+	if err != nil {
+		r.logger.Error("Failed to lock categories", zap.Int64("id", id), zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+	categories, err := r.scanCategories(rows)
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	for _, category := range categories {
+		locked[category.ID] = category
+	}
+
+	category, ok := locked[id]
+	if !ok {
+		return errors.NewNotFoundError("Category", id)
+	}
+	newPath := domain.CategoryRootPath(id)
+	if parentID != nil {
+		parent, ok := locked[*parentID]
+		if !ok {
+			return errors.NewNotFoundError("Category", *parentID)
+		}
+		if category.Contains(parent) {
+			return &domain.CategoryCycleError{ID: id, ParentID: parent.ID}
+		}
+		newPath = parent.ChildPath(id)
+	}
+
+	now := time.Now().UTC()
+	if _, err := tx.ExecContext(ctx, `UPDATE categories SET parent_id = $1, updated_at = $2, version = version + 1 WHERE id = $3`, parentID, now, id); err != nil {
+		r.logger.Error("Failed to move category", zap.Int64("id", id), zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	// Rewrite the path prefix of the category and all of its descendants
+	query := `
+		UPDATE categories
+		SET path = $1 || substr(path, length($2) + 1)
+		WHERE path LIKE $2 || '%'
+	`
+	if _, err := tx.ExecContext(ctx, query, newPath, category.Path); err != nil {
+		r.logger.Error("Failed to rewrite category paths", zap.Int64("id", id), zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	return nil
+}
+
+// lockCategory finds a category and locks it against moves until tx ends
+func (r *categoryRepository) lockCategory(ctx context.Context, tx *sql.Tx, id int64) (*domain.Category, error) {
+	category, err := scanCategory(tx.QueryRowContext(ctx, `SELECT `+categoryColumns+` FROM categories WHERE id = $1 FOR SHARE`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("Category", id)
+		}
+		r.logger.Error("Failed to lock category", zap.Int64("id", id), zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
+	return &category, nil
+}
+
+// scanCategories scans every remaining row of categoryColumns
+func (r *categoryRepository) scanCategories(rows *sql.Rows) ([]domain.Category, error) {
+	var categories []domain.Category
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan category", zap.Error(err))
+			return nil, errors.NewInternalError(err)
+		}
+		categories = append(categories, category)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Error iterating category rows", zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
+
+	return categories, nil
+}
+
+// rollback rolls back tx unless it has already been committed
+func (r *categoryRepository) rollback(tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+		r.logger.Error("Failed to rollback transaction", zap.Error(err))
+	}
+}
+
+// scanCategory scans a row of categoryColumns
+func scanCategory(row rowScanner) (domain.Category, error) {
 	var category domain.Category
-	err := r.db.QueryRowContext(ctx, query, name).Scan(
+	err := row.Scan(
 		&category.ID,
 		&category.Name,
 		&category.Description,
 		&category.Slug,
+		&category.ParentID,
+		&category.Path,
 		&category.CreatedAt,
 		&category.UpdatedAt,
+		&category.Version,
 	)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.NewNotFoundError("Category", fmt.Sprintf("name=%s", name))
-		}
-		r.logger.Error("Failed to find category by name", zap.String("name", name), zap.Error(err))
-		return nil, errors.NewInternalError(err)
-	}
-
-	return &category, nil
+	return category, err
 }
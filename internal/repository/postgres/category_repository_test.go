@@ -0,0 +1,202 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+)
+
+// TestCategoryRepository_Tree tests subtrees, breadcrumbs and moves
+func TestCategoryRepository_Tree(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewCategoryRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	create := func(parentID *int64) *domain.Category {
+		name := uniqueName("category")
+		category := &domain.Category{Name: name, Slug: name, ParentID: parentID}
+		if err := repo.Create(ctx, category); err != nil {
+			t.Fatalf("Failed to create category: %v", err)
+		}
+		return category
+	}
+	clothing := create(nil)
+	shoes := create(&clothing.ID)
+	boots := create(&shoes.ID)
+
+	subtree, err := repo.FindSubtree(ctx, clothing.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(subtree) != 3 {
+		t.Errorf("Expected 3 categories in the subtree, got %d", len(subtree))
+	}
+
+	breadcrumbs, err := repo.FindAncestors(ctx, boots.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(breadcrumbs) != 3 || breadcrumbs[0].ID != clothing.ID || breadcrumbs[2].ID != boots.ID {
+		t.Errorf("Expected breadcrumbs clothing > shoes > boots, got %+v", breadcrumbs)
+	}
+
+	// Moving a category under its own descendant is rejected
+	var cycle *domain.CategoryCycleError
+	if err := repo.Move(ctx, clothing.ID, &boots.ID); !errors.As(err, &cycle) {
+		t.Errorf("Expected CategoryCycleError, got %v", err)
+	}
+	if err := repo.Move(ctx, shoes.ID, &shoes.ID); !errors.As(err, &cycle) {
+		t.Errorf("Expected CategoryCycleError, got %v", err)
+	}
+
+	// Moving shoes to the top level takes boots along
+	if err := repo.Move(ctx, shoes.ID, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	moved, err := repo.FindByID(ctx, boots.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if want := fmt.Sprintf("/%d/%d/", shoes.ID, boots.ID); moved.Path != want {
+		t.Errorf("Expected path %s, got %s", want, moved.Path)
+	}
+	subtree, err = repo.FindSubtree(ctx, clothing.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(subtree) != 1 {
+		t.Errorf("Expected clothing alone in its subtree, got %d categories", len(subtree))
+	}
+
+	// Categories with subcategories cannot be deleted
+	if err := repo.Delete(ctx, shoes.ID); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+}
+
+// TestCategoryRepository_Move_Concurrent tests that concurrent moves of disjoint categories cannot form a cycle
+func TestCategoryRepository_Move_Concurrent(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewCategoryRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	create := func(parentID *int64) *domain.Category {
+		name := uniqueName("category")
+		category := &domain.Category{Name: name, Slug: name, ParentID: parentID}
+		if err := repo.Create(ctx, category); err != nil {
+			t.Fatalf("Failed to create category: %v", err)
+		}
+		return category
+	}
+
+	for i := 0; i < 10; i++ {
+		first := create(nil)
+		firstChild := create(&first.ID)
+		second := create(nil)
+		secondChild := create(&second.ID)
+
+		// Each move locks a different pair of categories, but together
+		// they would put each tree under the other
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs[0] = repo.Move(ctx, first.ID, &secondChild.ID)
+		}()
+		go func() {
+			defer wg.Done()
+			errs[1] = repo.Move(ctx, second.ID, &firstChild.ID)
+		}()
+		wg.Wait()
+
+		var cycle *domain.CategoryCycleError
+		failed := 0
+		for _, err := range errs {
+			if err != nil {
+				if !errors.As(err, &cycle) {
+					t.Fatalf("Expected CategoryCycleError, got %v", err)
+				}
+				failed++
+			}
+		}
+		if failed != 1 {
+			t.Fatalf("Expected exactly one move to be rejected, got %d", failed)
+		}
+	}
+}
+
+// TestCategoryRepository_Update_Version tests that updates and moves change the version
+func TestCategoryRepository_Update_Version(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewCategoryRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	name := uniqueName("category")
+	category := &domain.Category{Name: name, Slug: name}
+	if err := repo.Create(ctx, category); err != nil {
+		t.Fatalf("Failed to create category: %v", err)
+	}
+	if category.Version != 1 {
+		t.Errorf("Expected version 1, got %d", category.Version)
+	}
+
+	stale := *category
+	category.Description = "Edited"
+	if err := repo.Update(ctx, category); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := repo.Update(ctx, &stale); !errors.Is(err, domain.ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict, got %v", err)
+	}
+
+	if err := repo.Move(ctx, category.ID, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	moved, err := repo.FindByID(ctx, category.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if moved.Version != 3 || moved.Description != "Edited" {
+		t.Errorf("Expected the edit at version 3, got %+v", moved)
+	}
+}
+
+// TestProductRepository_FindByCategory_Descendants tests including subcategory products
+func TestProductRepository_FindByCategory_Descendants(t *testing.T) {
+	db := openTestDB(t)
+	categories := NewCategoryRepository(db, logger.NewLogger("error"))
+	products := NewProductRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	parentID := seedCategory(t, db)
+	name := uniqueName("category")
+	child := &domain.Category{Name: name, Slug: name, ParentID: &parentID}
+	if err := categories.Create(ctx, child); err != nil {
+		t.Fatalf("Failed to create category: %v", err)
+	}
+	for _, categoryID := range []int64{parentID, child.ID} {
+		product := &domain.Product{Name: uniqueName("product"), SKU: uniqueName("sku"), Price: domain.NewMoney(1000, "USD"), Stock: 1, CategoryID: categoryID}
+		if err := products.Create(ctx, product); err != nil {
+			t.Fatalf("Failed to create product: %v", err)
+		}
+	}
+
+	for _, tt := range []struct {
+		includeDescendants bool
+		want               int
+	}{{false, 1}, {true, 2}} {
+		page, err := products.FindByCategory(ctx, parentID, tt.includeDescendants, domain.PageRequest{Limit: 10})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(page.Items) != tt.want {
+			t.Errorf("includeDescendants=%v: expected %d products, got %d", tt.includeDescendants, tt.want, len(page.Items))
+		}
+	}
+}
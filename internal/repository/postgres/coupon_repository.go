@@ -0,0 +1,316 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// couponColumns are the columns read by scanCoupon
+const couponColumns = `c.id, c.code, c.description, c.type, c.percent_off, c.amount_off, c.min_order_value, c.currency,
+	c.product_ids, c.category_ids, c.max_uses, c.max_uses_per_user, c.starts_at, c.ends_at, c.active, c.created_at, c.updated_at,
+	(SELECT COUNT(*) FROM coupon_redemptions cr WHERE cr.coupon_id = c.id)`
+
+type couponRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// NewCouponRepository creates a new coupon repository
+func NewCouponRepository(db *sql.DB, logger logger.Logger) domain.CouponRepository {
+	return &couponRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// FindByID finds a coupon by ID
+func (r *couponRepository) FindByID(ctx context.Context, id int64) (*domain.Coupon, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+couponColumns+` FROM coupons c WHERE c.id = $1`, id)
+
+	coupon, err := scanCoupon(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("Coupon", id)
+		}
+		r.logger.Error("Failed to find coupon by ID", zap.Int64("id", id), zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
+
+	return coupon, nil
+}
+
+// FindByCode finds a coupon by its normalized code
+func (r *couponRepository) FindByCode(ctx context.Context, code string) (*domain.Coupon, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+couponColumns+` FROM coupons c WHERE c.code = $1`, code)
+
+	coupon, err := scanCoupon(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("Coupon", code)
+		}
+		r.logger.Error("Failed to find coupon by code", zap.String("code", code), zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
+
+	return coupon, nil
+}
+
+// FindAll finds a page of all coupons
+func (r *couponRepository) FindAll(ctx context.Context, page domain.PageRequest) (*domain.Page[domain.Coupon], error) {
+	query, args, err := pageQuery(`SELECT `+couponColumns+` FROM coupons c`, idOrder("c.id"), nil, nil, page)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.Error("Failed to find all coupons", zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
+	defer rows.Close()
+
+	var coupons []domain.Coupon
+	for rows.Next() {
+		coupon, err := scanCoupon(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan coupon", zap.Error(err))
+			return nil, errors.NewInternalError(err)
+		}
+		coupons = append(coupons, *coupon)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Error iterating coupon rows", zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
+
+	result := newPage(coupons, page, func(c domain.Coupon) domain.Cursor { return domain.Cursor{ID: c.ID} })
+	if !page.SkipTotal {
+		total, err := countRows(ctx, r.db, "coupons c", nil, nil)
+		if err != nil {
+			r.logger.Error("Failed to get total coupon count", zap.Error(err))
+			return nil, errors.NewInternalError(err)
+		}
+		result.Total = &total
+	}
+
+	return result, nil
+}
+
+// Create creates a new coupon
+func (r *couponRepository) Create(ctx context.Context, coupon *domain.Coupon) error {
+	query := `
+		INSERT INTO coupons (code, description, type, percent_off, amount_off, min_order_value, currency, product_ids, category_ids,
+			max_uses, max_uses_per_user, starts_at, ends_at, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $15)
+		RETURNING id
+	`
+
+	now := time.Now().UTC()
+	coupon.CreatedAt = now
+	coupon.UpdatedAt = now
+
+	args := append([]interface{}{coupon.Code}, couponValues(coupon)...)
+	args = append(args, now)
+
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&coupon.ID); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return errors.NewConflictError("Coupon", "code", coupon.Code)
+		}
+		r.logger.Error("Failed to create coupon", zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	return nil
+}
+
+// Update updates a coupon. Its code cannot change.
+func (r *couponRepository) Update(ctx context.Context, coupon *domain.Coupon) error {
+	query := `
+		UPDATE coupons
+		SET description = $1, type = $2, percent_off = $3, amount_off = $4, min_order_value = $5, currency = $6, product_ids = $7,
+			category_ids = $8, max_uses = $9, max_uses_per_user = $10, starts_at = $11, ends_at = $12, active = $13, updated_at = $14
+		WHERE id = $15
+	`
+
+	coupon.UpdatedAt = time.Now().UTC()
+
+	args := append(couponValues(coupon), coupon.UpdatedAt, coupon.ID)
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		r.logger.Error("Failed to update coupon", zap.Int64("id", coupon.ID), zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.NewNotFoundError("Coupon", coupon.ID)
+	}
+
+	return nil
+}
+
+// Delete deletes a coupon. Orders keep the code and discounts of the
+// coupons they redeemed.
+func (r *couponRepository) Delete(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM coupons WHERE id = $1`, id)
+	if err != nil {
+		r.logger.Error("Failed to delete coupon", zap.Int64("id", id), zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.NewNotFoundError("Coupon", id)
+	}
+
+	return nil
+}
+
+// CountUses counts the redemptions of a coupon, overall and by a user
+func (r *couponRepository) CountUses(ctx context.Context, couponID, userID int64) (domain.CouponUsage, error) {
+	usage, err := countCouponUses(ctx, r.db, couponID, userID)
+	if err != nil {
+		r.logger.Error("Failed to count coupon uses", zap.Int64("couponID", couponID), zap.Error(err))
+		return domain.CouponUsage{}, errors.NewInternalError(err)
+	}
+	return usage, nil
+}
+
+// queryRower is satisfied by *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// countCouponUses counts the redemptions of a coupon, overall and by a user
+func countCouponUses(ctx context.Context, db queryRower, couponID, userID int64) (domain.CouponUsage, error) {
+	var usage domain.CouponUsage
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*), COUNT(*) FILTER (WHERE user_id = $2) FROM coupon_redemptions WHERE coupon_id = $1`,
+		couponID,
+		userID,
+	).Scan(&usage.Total, &usage.ByUser)
+	return usage, err
+}
+
+// couponValues returns the stored fields of a coupon after its code, in
+// column order
+func couponValues(coupon *domain.Coupon) []interface{} {
+	var percentOff, amountOff, minOrderValue sql.NullInt64
+	var currency sql.NullString
+	if coupon.Type == domain.CouponTypePercentage {
+		percentOff = sql.NullInt64{Int64: int64(coupon.PercentOff), Valid: true}
+	}
+	if coupon.AmountOff != nil {
+		amountOff = sql.NullInt64{Int64: coupon.AmountOff.Amount, Valid: true}
+		currency = sql.NullString{String: string(coupon.AmountOff.Currency), Valid: true}
+	}
+	if coupon.MinOrderValue != nil {
+		minOrderValue = sql.NullInt64{Int64: coupon.MinOrderValue.Amount, Valid: true}
+		currency = sql.NullString{String: string(coupon.MinOrderValue.Currency), Valid: true}
+	}
+
+	productIDs := coupon.ProductIDs
+	if productIDs == nil {
+		productIDs = []int64{}
+	}
+	categoryIDs := coupon.CategoryIDs
+	if categoryIDs == nil {
+		categoryIDs = []int64{}
+	}
+
+	return []interface{}{
+		coupon.Description,
+		coupon.Type,
+		percentOff,
+		amountOff,
+		minOrderValue,
+		currency,
+		pq.Array(productIDs),
+		pq.Array(categoryIDs),
+		coupon.MaxUses,
+		coupon.MaxUsesPerUser,
+		coupon.StartsAt,
+		coupon.EndsAt,
+		coupon.Active,
+	}
+}
+
+// scanCoupon scans a row of couponColumns
+func scanCoupon(row rowScanner) (*domain.Coupon, error) {
+	var coupon domain.Coupon
+	var percentOff, amountOff, minOrderValue, maxUses, maxUsesPerUser sql.NullInt64
+	var currency sql.NullString
+	var startsAt, endsAt sql.NullTime
+
+	err := row.Scan(
+		&coupon.ID,
+		&coupon.Code,
+		&coupon.Description,
+		&coupon.Type,
+		&percentOff,
+		&amountOff,
+		&minOrderValue,
+		&currency,
+		pq.Array(&coupon.ProductIDs),
+		pq.Array(&coupon.CategoryIDs),
+		&maxUses,
+		&maxUsesPerUser,
+		&startsAt,
+		&endsAt,
+		&coupon.Active,
+		&coupon.CreatedAt,
+		&coupon.UpdatedAt,
+		&coupon.Uses,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	coupon.PercentOff = int(percentOff.Int64)
+	if amountOff.Valid {
+		money := domain.NewMoney(amountOff.Int64, domain.Currency(currency.String))
+		coupon.AmountOff = &money
+	}
+	if minOrderValue.Valid {
+		money := domain.NewMoney(minOrderValue.Int64, domain.Currency(currency.String))
+		coupon.MinOrderValue = &money
+	}
+	if maxUses.Valid {
+		n := int(maxUses.Int64)
+		coupon.MaxUses = &n
+	}
+	if maxUsesPerUser.Valid {
+		n := int(maxUsesPerUser.Int64)
+		coupon.MaxUsesPerUser = &n
+	}
+	if startsAt.Valid {
+		t := startsAt.Time.UTC()
+		coupon.StartsAt = &t
+	}
+	if endsAt.Valid {
+		t := endsAt.Time.UTC()
+		coupon.EndsAt = &t
+	}
+
+	return &coupon, nil
+}
@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+)
+
+// TestOrderRepository_Create_CouponLimit tests that concurrent orders never redeem a coupon beyond its limit
+func TestOrderRepository_Create_CouponLimit(t *testing.T) {
+	db := openTestDB(t)
+	couponRepo := NewCouponRepository(db, logger.NewLogger("error"))
+	orderRepo := NewOrderRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	const maxUses = 3
+	const buyers = 10
+
+	limit := maxUses
+	coupon := &domain.Coupon{
+		Code:       strings.ToUpper(uniqueName("save")),
+		Type:       domain.CouponTypePercentage,
+		PercentOff: 10,
+		MaxUses:    &limit,
+		Active:     true,
+	}
+	if err := couponRepo.Create(ctx, coupon); err != nil {
+		t.Fatalf("Failed to create coupon: %v", err)
+	}
+
+	productID := seedProduct(t, db, buyers)
+	price := domain.NewMoney(1000, domain.CurrencyUSD)
+
+	userIDs := make([]int64, buyers)
+	for i := range userIDs {
+		userIDs[i] = seedUser(t, db)
+	}
+
+	var succeeded int64
+	var wg sync.WaitGroup
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func(userID int64) {
+			defer wg.Done()
+
+			order := &domain.Order{
+				UserID:         userID,
+				Status:         domain.OrderStatusPending,
+				Subtotal:       price,
+				DiscountAmount: domain.NewMoney(100, domain.CurrencyUSD),
+				TotalAmount:    domain.NewMoney(900, domain.CurrencyUSD),
+				CouponID:       &coupon.ID,
+				CouponCode:     coupon.Code,
+				PaymentMethod:  domain.PaymentMethodCreditCard,
+				Items: []domain.OrderItem{
+					{ProductID: productID, Quantity: 1, Price: price, Discount: domain.NewMoney(100, domain.CurrencyUSD)},
+				},
+			}
+			err := orderRepo.Create(ctx, order)
+			if err == nil {
+				atomic.AddInt64(&succeeded, 1)
+				return
+			}
+			var couponErr *domain.CouponError
+			if !errors.As(err, &couponErr) || couponErr.Reason != domain.CouponRejectionUsageLimit {
+				t.Errorf("Expected usage limit rejection, got %v", err)
+			}
+		}(userIDs[i])
+	}
+	wg.Wait()
+
+	if succeeded != maxUses {
+		t.Errorf("Expected %d orders to succeed, got %d", maxUses, succeeded)
+	}
+
+	stored, err := couponRepo.FindByID(ctx, coupon.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stored.Uses != maxUses {
+		t.Errorf("Expected %d uses, got %d", maxUses, stored.Uses)
+	}
+	assertStock(t, db, productID, buyers-maxUses)
+}
@@ -0,0 +1,140 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	pkgerrors "github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// idempotencyRepository implements domain.IdempotencyRepository
+type idempotencyRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// NewIdempotencyRepository creates a new idempotency key repository
+func NewIdempotencyRepository(db *sql.DB, logger logger.Logger) domain.IdempotencyRepository {
+	return &idempotencyRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Reserve claims a key for a user, returning the existing record when the
+// key is already in use. Expired keys are pruned first, so a key can be
+// reused once it has expired.
+func (r *idempotencyRepository) Reserve(ctx context.Context, record *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error) {
+	now := time.Now().UTC()
+
+	// Keys are only needed until they expire
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= $1`, now); err != nil {
+		r.logger.Warn("Failed to prune expired idempotency keys", zap.Error(err))
+	}
+
+	result, err := r.db.ExecContext(
+		ctx,
+		`INSERT INTO idempotency_keys (user_id, idempotency_key, fingerprint, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, idempotency_key) DO NOTHING`,
+		record.UserID,
+		record.Key,
+		record.Fingerprint,
+		record.CreatedAt,
+		record.ExpiresAt,
+	)
+	if err != nil {
+		r.logger.Error("Failed to reserve idempotency key", zap.Int64("userID", record.UserID), zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
+	}
+
+	if rowsAffected == 1 {
+		return nil, nil
+	}
+
+	existing := domain.IdempotencyRecord{UserID: record.UserID, Key: record.Key}
+	var statusCode sql.NullInt64
+	var headers []byte
+	err = r.db.QueryRowContext(
+		ctx,
+		`SELECT fingerprint, status_code, headers, body, created_at, expires_at
+		FROM idempotency_keys
+		WHERE user_id = $1 AND idempotency_key = $2`,
+		record.UserID,
+		record.Key,
+	).Scan(
+		&existing.Fingerprint,
+		&statusCode,
+		&headers,
+		&existing.Body,
+		&existing.CreatedAt,
+		&existing.ExpiresAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// Released by the first request in the meantime
+			return r.Reserve(ctx, record)
+		}
+		r.logger.Error("Failed to find idempotency key", zap.Int64("userID", record.UserID), zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
+	}
+
+	if err := json.Unmarshal(headers, &existing.Headers); err != nil {
+		r.logger.Error("Failed to unmarshal idempotent response headers", zap.Int64("userID", record.UserID), zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
+	}
+
+	existing.StatusCode = int(statusCode.Int64)
+	return &existing, nil
+}
+
+// Complete stores the response of a reserved key
+func (r *idempotencyRepository) Complete(ctx context.Context, record *domain.IdempotencyRecord) error {
+	headers, err := json.Marshal(record.Headers)
+	if err != nil {
+		r.logger.Error("Failed to marshal idempotent response headers", zap.Int64("userID", record.UserID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	_, err = r.db.ExecContext(
+		ctx,
+		`UPDATE idempotency_keys SET status_code = $1, headers = $2, body = $3
+		WHERE user_id = $4 AND idempotency_key = $5`,
+		record.StatusCode,
+		headers,
+		record.Body,
+		record.UserID,
+		record.Key,
+	)
+	if err != nil {
+		r.logger.Error("Failed to store idempotent response", zap.Int64("userID", record.UserID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+	return nil
+}
+
+// Release frees a reserved key that has no response yet
+func (r *idempotencyRepository) Release(ctx context.Context, userID int64, key string) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`DELETE FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2 AND status_code IS NULL`,
+		userID,
+		key,
+	)
+	if err != nil {
+		r.logger.Error("Failed to release idempotency key", zap.Int64("userID", userID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+	return nil
+}
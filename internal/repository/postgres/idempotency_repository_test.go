@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+)
+
+// TestIdempotencyRepository_Reserve tests reserving, completing, releasing and expiring keys
+func TestIdempotencyRepository_Reserve(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewIdempotencyRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	record := &domain.IdempotencyRecord{
+		UserID:      seedUser(t, db),
+		Key:         uniqueName("key"),
+		Fingerprint: "f1",
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(time.Hour),
+	}
+
+	existing, err := repo.Reserve(ctx, record)
+	if err != nil || existing != nil {
+		t.Fatalf("Expected the key to be reserved, got %+v, %v", existing, err)
+	}
+
+	existing, err = repo.Reserve(ctx, record)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if existing == nil || existing.IsComplete() || existing.Fingerprint != "f1" {
+		t.Errorf("Expected the key in progress, got %+v", existing)
+	}
+
+	// A released key can be reserved again
+	if err := repo.Release(ctx, record.UserID, record.Key); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if existing, err := repo.Reserve(ctx, record); err != nil || existing != nil {
+		t.Fatalf("Expected the key to be reserved again, got %+v, %v", existing, err)
+	}
+
+	record.StatusCode = 201
+	record.Headers = map[string]string{"Content-Type": "application/json", "Location": "/orders/1"}
+	record.Body = []byte(`{"id":1}`)
+	if err := repo.Complete(ctx, record); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Completed keys are not released
+	if err := repo.Release(ctx, record.UserID, record.Key); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	existing, err = repo.Reserve(ctx, record)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if existing == nil || existing.StatusCode != 201 || string(existing.Body) != `{"id":1}` || existing.Headers["Content-Type"] != "application/json" || existing.Headers["Location"] != "/orders/1" {
+		t.Errorf("Expected the stored response, got %+v", existing)
+	}
+
+	// Expired keys are pruned and can be reused
+	if _, err := db.Exec(`UPDATE idempotency_keys SET expires_at = $1 WHERE user_id = $2`, now.Add(-time.Minute), record.UserID); err != nil {
+		t.Fatalf("Failed to expire key: %v", err)
+	}
+	if existing, err := repo.Reserve(ctx, record); err != nil || existing != nil {
+		t.Errorf("Expected the expired key to be reserved, got %+v, %v", existing, err)
+	}
+}
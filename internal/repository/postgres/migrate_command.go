@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/milad-ahmd/go-clean-arch/pkg/config"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+)
+
+// migrateUsage describes the migrate subcommand
+const migrateUsage = `usage: migrate <command>
+
+commands:
+  up            apply every pending migration
+  down [n]      roll back the last n migrations (default 1)
+  status        list migrations and whether they are applied
+  new <name>    create an empty up/down migration pair`
+
+// RunMigrateCommand runs the "migrate" subcommand with the given arguments
+func RunMigrateCommand(ctx context.Context, cfg *config.Config, args []string, out io.Writer, logger logger.Logger) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", migrateUsage)
+	}
+
+	// Creating files does not need a database connection
+	if args[0] == "new" {
+		if len(args) != 2 {
+			return fmt.Errorf("usage: migrate new <name>")
+		}
+		upPath, downPath, err := CreateMigrationFiles(MigrationsDir, args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Created %s\nCreated %s\n", upPath, downPath)
+		return nil
+	}
+
+	db, err := NewPostgresConnection(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := NewMigrator(db, logger)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Applied %d migration(s)\n", applied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of migrations to roll back: %q", args[1])
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Rolled back %d migration(s)\n", rolledBack)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, status := range statuses {
+			state, appliedAt := "pending", ""
+			if status.Applied {
+				state = "applied"
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			if status.Modified {
+				state = "modified"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+		}
+		return w.Flush()
+
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], migrateUsage)
+	}
+
+	return nil
+}
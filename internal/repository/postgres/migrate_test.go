@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// TestLoadMigrations_Embedded tests that the embedded migrations are valid and ordered
+func TestLoadMigrations_Embedded(t *testing.T) {
+	migrations, err := loadMigrations(embeddedMigrations, "migrations")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("Expected embedded migrations")
+	}
+
+	for i, migration := range migrations {
+		if i > 0 && migration.Version <= migrations[i-1].Version {
+			t.Errorf("Expected migrations ordered by version, got %d after %d", migration.Version, migrations[i-1].Version)
+		}
+		if migration.Checksum == "" {
+			t.Errorf("Expected checksum for migration %d", migration.Version)
+		}
+	}
+}
+
+// TestLoadMigrations_Invalid tests that malformed migration sets are rejected
+func TestLoadMigrations_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{
+			name: "missing down",
+			files: fstest.MapFS{
+				"m/0001_init.up.sql": {Data: []byte("SELECT 1;")},
+			},
+		},
+		{
+			name: "duplicate version",
+			files: fstest.MapFS{
+				"m/0001_init.up.sql":    {Data: []byte("SELECT 1;")},
+				"m/0001_init.down.sql":  {Data: []byte("SELECT 1;")},
+				"m/0001_other.up.sql":   {Data: []byte("SELECT 1;")},
+				"m/0001_other.down.sql": {Data: []byte("SELECT 1;")},
+			},
+		},
+		{
+			name: "bad file name",
+			files: fstest.MapFS{
+				"m/init.sql": {Data: []byte("SELECT 1;")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadMigrations(tt.files, "m"); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+// TestCreateMigrationFiles tests that new migrations take the next version
+func TestCreateMigrationFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"0001_init.up.sql", "0001_init.down.sql"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;"), 0o644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	upPath, downPath, err := CreateMigrationFiles(dir, "add_widgets")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if filepath.Base(upPath) != "0002_add_widgets.up.sql" {
+		t.Errorf("Expected 0002_add_widgets.up.sql, got %s", filepath.Base(upPath))
+	}
+	if filepath.Base(downPath) != "0002_add_widgets.down.sql" {
+		t.Errorf("Expected 0002_add_widgets.down.sql, got %s", filepath.Base(downPath))
+	}
+
+	if _, _, err := CreateMigrationFiles(dir, "Bad Name"); err == nil {
+		t.Error("Expected error for invalid name, got nil")
+	}
+}
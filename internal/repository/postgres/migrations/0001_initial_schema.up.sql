@@ -0,0 +1,68 @@
+-- Tables may already exist on databases created before migrations were
+-- introduced, so this baseline only creates what is missing.
+
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	username VARCHAR(50) UNIQUE NOT NULL,
+	email VARCHAR(100) UNIQUE NOT NULL,
+	password VARCHAR(100) NOT NULL,
+	role VARCHAR(20) NOT NULL DEFAULT 'user',
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS categories (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) UNIQUE NOT NULL,
+	description TEXT,
+	slug VARCHAR(100) UNIQUE NOT NULL,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS products (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	description TEXT,
+	price DECIMAL(10, 2) NOT NULL,
+	sku VARCHAR(50) UNIQUE NOT NULL,
+	stock INT NOT NULL DEFAULT 0,
+	category_id INT NOT NULL REFERENCES categories(id),
+	images JSONB DEFAULT '[]',
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id),
+	status VARCHAR(20) NOT NULL DEFAULT 'pending',
+	total_amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+	payment_method VARCHAR(20) NOT NULL,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS order_items (
+	id SERIAL PRIMARY KEY,
+	order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+	product_id INT NOT NULL REFERENCES products(id),
+	quantity INT NOT NULL,
+	price DECIMAL(10, 2) NOT NULL,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS shipping_info (
+	id SERIAL PRIMARY KEY,
+	order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+	address TEXT NOT NULL,
+	city VARCHAR(100) NOT NULL,
+	state VARCHAR(100) NOT NULL,
+	country VARCHAR(100) NOT NULL,
+	postal_code VARCHAR(20) NOT NULL,
+	phone_number VARCHAR(20) NOT NULL,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT NOT NULL,
+	UNIQUE(order_id)
+);
@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS roles (
+	name VARCHAR(50) PRIMARY KEY,
+	description TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+	role VARCHAR(50) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+	permission VARCHAR(50) NOT NULL,
+	PRIMARY KEY (role, permission)
+);
+
+-- Seed the default roles; existing rows are left untouched so that
+-- permissions edited by an administrator are preserved
+INSERT INTO roles (name, description, created_at, updated_at) VALUES
+	('user', 'Customer with access to their own orders', NOW(), NOW()),
+	('admin', 'Full access to every resource', NOW(), NOW()),
+	('warehouse', 'Manages products, stock and order fulfilment', NOW(), NOW()),
+	('support', 'Assists customers with orders and refunds', NOW(), NOW())
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission)
+SELECT r.role, r.permission FROM (VALUES
+	('warehouse', 'product:write'),
+	('warehouse', 'inventory:write'),
+	('warehouse', 'order:read'),
+	('warehouse', 'order:status'),
+	('support', 'order:read'),
+	('support', 'order:refund'),
+	('support', 'user:read'),
+	('support', 'session:revoke')
+) AS r(role, permission)
+WHERE NOT EXISTS (SELECT 1 FROM role_permissions rp WHERE rp.role = r.role);
@@ -0,0 +1,26 @@
+-- Store every entity timestamp as TIMESTAMPTZ. Existing values are Unix
+-- seconds and are converted in place.
+
+ALTER TABLE users
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ USING to_timestamp(created_at),
+	ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING to_timestamp(updated_at);
+
+ALTER TABLE categories
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ USING to_timestamp(created_at),
+	ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING to_timestamp(updated_at);
+
+ALTER TABLE products
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ USING to_timestamp(created_at),
+	ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING to_timestamp(updated_at);
+
+ALTER TABLE orders
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ USING to_timestamp(created_at),
+	ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING to_timestamp(updated_at);
+
+ALTER TABLE order_items
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ USING to_timestamp(created_at),
+	ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING to_timestamp(updated_at);
+
+ALTER TABLE shipping_info
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ USING to_timestamp(created_at),
+	ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING to_timestamp(updated_at);
@@ -0,0 +1,16 @@
+-- Amounts in currencies with other than two minor unit digits cannot be
+-- represented faithfully once the currency column is dropped.
+
+ALTER TABLE order_items
+	DROP COLUMN currency,
+	ALTER COLUMN price TYPE DECIMAL(10, 2) USING price / 100.0;
+
+ALTER TABLE orders
+	DROP COLUMN currency,
+	ALTER COLUMN total_amount DROP DEFAULT,
+	ALTER COLUMN total_amount TYPE DECIMAL(10, 2) USING total_amount / 100.0,
+	ALTER COLUMN total_amount SET DEFAULT 0;
+
+ALTER TABLE products
+	DROP COLUMN currency,
+	ALTER COLUMN price TYPE DECIMAL(10, 2) USING price / 100.0;
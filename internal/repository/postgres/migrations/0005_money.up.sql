@@ -0,0 +1,20 @@
+-- Store prices and totals as integer minor units with an ISO 4217 currency
+-- code. Existing DECIMAL values are in USD cents precision.
+
+ALTER TABLE products
+	ALTER COLUMN price TYPE BIGINT USING ROUND(price * 100)::BIGINT,
+	ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE orders
+	ALTER COLUMN total_amount DROP DEFAULT,
+	ALTER COLUMN total_amount TYPE BIGINT USING ROUND(total_amount * 100)::BIGINT,
+	ALTER COLUMN total_amount SET DEFAULT 0,
+	ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE order_items
+	ALTER COLUMN price TYPE BIGINT USING ROUND(price * 100)::BIGINT,
+	ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+COMMENT ON COLUMN products.price IS 'Price in minor units of currency';
+COMMENT ON COLUMN orders.total_amount IS 'Total in minor units of currency';
+COMMENT ON COLUMN order_items.price IS 'Unit price in minor units of currency';
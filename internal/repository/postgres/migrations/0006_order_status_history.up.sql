@@ -0,0 +1,19 @@
+-- Every order status change is recorded with the acting user and a reason.
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+	id BIGSERIAL PRIMARY KEY,
+	order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+	from_status VARCHAR(20),
+	to_status VARCHAR(20) NOT NULL,
+	actor_id INT REFERENCES users(id) ON DELETE SET NULL,
+	reason TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order_id ON order_status_history(order_id, created_at);
+
+-- Existing orders get a single entry for their current status, since
+-- earlier transitions were never recorded.
+INSERT INTO order_status_history (order_id, from_status, to_status, actor_id, reason, created_at)
+SELECT id, NULL, status, NULL, 'Recorded when status history was introduced', updated_at
+FROM orders;
@@ -0,0 +1,5 @@
+ALTER TABLE order_items
+	DROP CONSTRAINT IF EXISTS order_items_restocked_quantity_check,
+	DROP CONSTRAINT IF EXISTS order_items_cancelled_quantity_check,
+	DROP COLUMN IF EXISTS restocked_quantity,
+	DROP COLUMN IF EXISTS cancelled_quantity;
@@ -0,0 +1,15 @@
+-- cancelled_quantity tracks units cancelled from a line; restocked_quantity
+-- tracks units returned to stock, so restocking is never applied twice.
+
+ALTER TABLE order_items
+	ADD COLUMN cancelled_quantity INT NOT NULL DEFAULT 0,
+	ADD COLUMN restocked_quantity INT NOT NULL DEFAULT 0,
+	ADD CONSTRAINT order_items_cancelled_quantity_check CHECK (cancelled_quantity BETWEEN 0 AND quantity),
+	ADD CONSTRAINT order_items_restocked_quantity_check CHECK (restocked_quantity BETWEEN 0 AND quantity);
+
+-- Stock of orders cancelled before this migration was never returned, but
+-- it is unknown whether it was corrected by hand, so it is not restocked now.
+UPDATE order_items oi
+SET restocked_quantity = oi.quantity
+FROM orders o
+WHERE o.id = oi.order_id AND o.status = 'cancelled';
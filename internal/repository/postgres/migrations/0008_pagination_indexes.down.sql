@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_orders_status_id;
+DROP INDEX IF EXISTS idx_orders_user_id_id;
+DROP INDEX IF EXISTS idx_products_category_id_id;
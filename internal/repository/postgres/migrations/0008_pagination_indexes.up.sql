@@ -0,0 +1,6 @@
+-- Lists are ordered by id and filtered lists page by (filter, id), so these
+-- indexes let a cursor seek straight to its position instead of scanning.
+
+CREATE INDEX IF NOT EXISTS idx_products_category_id_id ON products(category_id, id);
+CREATE INDEX IF NOT EXISTS idx_orders_user_id_id ON orders(user_id, id);
+CREATE INDEX IF NOT EXISTS idx_orders_status_id ON orders(status, id);
@@ -0,0 +1,5 @@
+-- Product lists can be sorted by price or creation time with id as the
+-- tie-breaker, so cursors over those orders seek through these indexes.
+
+CREATE INDEX IF NOT EXISTS idx_products_price_id ON products(price, id);
+CREATE INDEX IF NOT EXISTS idx_products_created_at_id ON products(created_at, id);
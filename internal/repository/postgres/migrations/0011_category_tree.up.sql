@@ -0,0 +1,15 @@
+-- Categories form a tree. path is the materialized path of IDs from the
+-- top-level category down to the row, such as '/1/4/9/', so a subtree is a
+-- single prefix match. Existing categories become top-level categories.
+
+ALTER TABLE categories
+	ADD COLUMN parent_id INT REFERENCES categories(id),
+	ADD COLUMN path TEXT,
+	ADD CONSTRAINT categories_parent_id_check CHECK (parent_id <> id);
+
+UPDATE categories SET path = '/' || id || '/';
+
+ALTER TABLE categories ALTER COLUMN path SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories(parent_id);
+CREATE INDEX IF NOT EXISTS idx_categories_path ON categories(path text_pattern_ops);
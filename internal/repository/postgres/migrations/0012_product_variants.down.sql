@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_order_items_variant_id;
+
+ALTER TABLE order_items
+	DROP CONSTRAINT IF EXISTS order_items_variant_id_fkey,
+	DROP COLUMN IF EXISTS variant_id;
+
+DROP TABLE IF EXISTS product_variants;
+
+ALTER TABLE products DROP COLUMN IF EXISTS options;
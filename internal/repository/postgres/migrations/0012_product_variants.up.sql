@@ -0,0 +1,28 @@
+-- Products can be sold in variants. options lists a product's option axes,
+-- such as [{"name": "size", "values": ["S", "M"]}], and each variant picks
+-- one value of every axis. A variant has its own SKU and stock, and a price
+-- in the product's currency that overrides the product price when set.
+
+ALTER TABLE products ADD COLUMN options JSONB NOT NULL DEFAULT '[]';
+
+CREATE TABLE IF NOT EXISTS product_variants (
+	id SERIAL PRIMARY KEY,
+	product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+	sku VARCHAR(50) UNIQUE NOT NULL,
+	options JSONB NOT NULL,
+	price BIGINT CHECK (price > 0),
+	stock INT NOT NULL DEFAULT 0 CHECK (stock >= 0),
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	UNIQUE (product_id, options),
+	UNIQUE (id, product_id)
+);
+
+COMMENT ON COLUMN product_variants.price IS 'Price in minor units of the product currency, NULL for the product price';
+
+-- An order item of a variant must name the variant's own product
+ALTER TABLE order_items
+	ADD COLUMN variant_id INT,
+	ADD CONSTRAINT order_items_variant_id_fkey FOREIGN KEY (variant_id, product_id) REFERENCES product_variants(id, product_id);
+
+CREATE INDEX IF NOT EXISTS idx_order_items_variant_id ON order_items(variant_id);
@@ -0,0 +1,29 @@
+-- A cart belongs to a user, or is anonymous and found by the SHA-256 hash of
+-- its random token. Cart items keep the unit price the customer last saw, so
+-- price changes can be flagged before checkout.
+
+CREATE TABLE IF NOT EXISTS carts (
+	id SERIAL PRIMARY KEY,
+	user_id INT UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+	token_hash CHAR(64) UNIQUE,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	CONSTRAINT carts_owner_check CHECK ((user_id IS NULL) <> (token_hash IS NULL))
+);
+
+CREATE TABLE IF NOT EXISTS cart_items (
+	id SERIAL PRIMARY KEY,
+	cart_id INT NOT NULL REFERENCES carts(id) ON DELETE CASCADE,
+	product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+	variant_id INT REFERENCES product_variants(id) ON DELETE CASCADE,
+	quantity INT NOT NULL CHECK (quantity > 0),
+	price BIGINT NOT NULL,
+	currency CHAR(3) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+
+-- One line per product or variant; adding it again raises the quantity
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cart_items_line ON cart_items(cart_id, product_id, COALESCE(variant_id, 0));
+
+COMMENT ON COLUMN cart_items.price IS 'Unit price in minor units of currency when last shown to the customer';
@@ -0,0 +1,64 @@
+-- Coupons discount orders by a percentage of each eligible line or by a
+-- fixed amount spread over the eligible lines. Without product or category
+-- rules every line is eligible. The fixed amount and the minimum order value
+-- are in the coupon's currency.
+
+CREATE TABLE IF NOT EXISTS coupons (
+	id SERIAL PRIMARY KEY,
+	code VARCHAR(50) UNIQUE NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	type VARCHAR(20) NOT NULL CHECK (type IN ('percentage', 'fixed')),
+	percent_off INT CHECK (percent_off BETWEEN 1 AND 100),
+	amount_off BIGINT CHECK (amount_off > 0),
+	min_order_value BIGINT CHECK (min_order_value > 0),
+	currency CHAR(3),
+	product_ids INT[] NOT NULL DEFAULT '{}',
+	category_ids INT[] NOT NULL DEFAULT '{}',
+	max_uses INT CHECK (max_uses > 0),
+	max_uses_per_user INT CHECK (max_uses_per_user > 0),
+	starts_at TIMESTAMPTZ,
+	ends_at TIMESTAMPTZ,
+	active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	CONSTRAINT coupons_discount_check CHECK (
+		(type = 'percentage' AND percent_off IS NOT NULL AND amount_off IS NULL) OR
+		(type = 'fixed' AND amount_off IS NOT NULL AND percent_off IS NULL)
+	),
+	CONSTRAINT coupons_currency_check CHECK ((amount_off IS NULL AND min_order_value IS NULL) OR currency IS NOT NULL),
+	CONSTRAINT coupons_window_check CHECK (ends_at > starts_at)
+);
+
+COMMENT ON COLUMN coupons.code IS 'Upper-case code customers enter';
+COMMENT ON COLUMN coupons.amount_off IS 'Fixed discount in minor units of currency';
+COMMENT ON COLUMN coupons.min_order_value IS 'Minimum order subtotal in minor units of currency';
+
+-- One redemption per order, counted against the coupon's usage limits.
+-- Cancelling the order releases it.
+CREATE TABLE IF NOT EXISTS coupon_redemptions (
+	id SERIAL PRIMARY KEY,
+	coupon_id INT NOT NULL REFERENCES coupons(id) ON DELETE CASCADE,
+	order_id INT UNIQUE NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_coupon_redemptions_coupon_user ON coupon_redemptions(coupon_id, user_id);
+
+-- Orders keep the coupon code and every discount, so totals can be audited
+-- after the coupon has changed or been deleted
+ALTER TABLE orders
+	ADD COLUMN subtotal_amount BIGINT,
+	ADD COLUMN discount_amount BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN coupon_id INT REFERENCES coupons(id) ON DELETE SET NULL,
+	ADD COLUMN coupon_code VARCHAR(50);
+
+UPDATE orders SET subtotal_amount = total_amount;
+
+ALTER TABLE orders ALTER COLUMN subtotal_amount SET NOT NULL;
+
+ALTER TABLE order_items ADD COLUMN discount BIGINT NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN orders.subtotal_amount IS 'Sum of line prices in minor units of currency';
+COMMENT ON COLUMN orders.discount_amount IS 'Sum of line discounts in minor units of currency';
+COMMENT ON COLUMN order_items.discount IS 'Discount on the whole line in minor units of currency';
@@ -0,0 +1,27 @@
+-- Every attempt to pay an order through a payment gateway, with the
+-- provider's reference once authorized and the reason when it failed. An
+-- order has at most one active payment: pending, authorized or captured.
+
+CREATE TABLE IF NOT EXISTS payments (
+	id SERIAL PRIMARY KEY,
+	order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+	provider VARCHAR(50) NOT NULL,
+	method VARCHAR(50) NOT NULL,
+	status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'authorized', 'captured', 'voided', 'failed')),
+	amount BIGINT NOT NULL CHECK (amount > 0),
+	captured_amount BIGINT NOT NULL DEFAULT 0 CHECK (captured_amount BETWEEN 0 AND amount),
+	currency CHAR(3) NOT NULL,
+	provider_reference VARCHAR(255),
+	failure_code VARCHAR(50),
+	failure_message TEXT,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_payments_order_id ON payments(order_id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_active_order ON payments(order_id)
+	WHERE status IN ('pending', 'authorized', 'captured');
+
+COMMENT ON COLUMN payments.amount IS 'Authorized amount in minor units of currency';
+COMMENT ON COLUMN payments.captured_amount IS 'Collected amount in minor units of currency';
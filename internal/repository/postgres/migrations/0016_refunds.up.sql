@@ -0,0 +1,36 @@
+-- Refunds return money from an order's captured payment, as an amount off
+-- the whole order or line by line. Pending and succeeded refunds count
+-- against the captured amount; failed ones are kept for the record.
+
+CREATE TABLE IF NOT EXISTS refunds (
+	id SERIAL PRIMARY KEY,
+	order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+	payment_id INT NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+	status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'succeeded', 'failed')),
+	amount BIGINT NOT NULL CHECK (amount > 0),
+	currency CHAR(3) NOT NULL,
+	reason TEXT NOT NULL DEFAULT '',
+	restock BOOLEAN NOT NULL DEFAULT FALSE,
+	provider_reference VARCHAR(255),
+	failure_message TEXT,
+	actor_id INT REFERENCES users(id) ON DELETE SET NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_refunds_order_id ON refunds(order_id);
+CREATE INDEX IF NOT EXISTS idx_refunds_payment_id ON refunds(payment_id);
+
+CREATE TABLE IF NOT EXISTS refund_items (
+	id SERIAL PRIMARY KEY,
+	refund_id INT NOT NULL REFERENCES refunds(id) ON DELETE CASCADE,
+	order_item_id INT NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+	quantity INT NOT NULL CHECK (quantity >= 0),
+	amount BIGINT NOT NULL CHECK (amount >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_refund_items_order_item_id ON refund_items(order_item_id);
+
+COMMENT ON COLUMN refunds.amount IS 'Refunded amount in minor units of currency';
+COMMENT ON COLUMN refund_items.quantity IS 'Units returned; zero for an amount without a return';
+COMMENT ON COLUMN refund_items.amount IS 'Part of the refund for the order item in minor units of the refund currency';
@@ -0,0 +1,3 @@
+DELETE FROM role_permissions WHERE permission = 'return:manage';
+DROP TABLE IF EXISTS return_items;
+DROP TABLE IF EXISTS returns;
@@ -0,0 +1,36 @@
+-- Returns let customers send back units of a completed order. Staff approve
+-- or reject them, receive the goods and inspect them; each returned item is
+-- then restocked or written off.
+
+CREATE TABLE IF NOT EXISTS returns (
+	id SERIAL PRIMARY KEY,
+	order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+	user_id INT NOT NULL REFERENCES users(id),
+	status VARCHAR(20) NOT NULL CHECK (status IN ('requested', 'approved', 'rejected', 'received', 'inspected')),
+	reason TEXT NOT NULL,
+	note TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_returns_order_id ON returns(order_id);
+
+CREATE TABLE IF NOT EXISTS return_items (
+	id SERIAL PRIMARY KEY,
+	return_id INT NOT NULL REFERENCES returns(id) ON DELETE CASCADE,
+	order_item_id INT NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+	quantity INT NOT NULL CHECK (quantity > 0),
+	reason TEXT NOT NULL DEFAULT '',
+	disposition VARCHAR(20) CHECK (disposition IN ('restock', 'write_off'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_return_items_order_item_id ON return_items(order_item_id);
+
+COMMENT ON COLUMN returns.note IS 'Staff note on the latest status change';
+COMMENT ON COLUMN return_items.disposition IS 'Set when the return is inspected';
+
+-- The warehouse handles returned goods and support reviews return requests
+INSERT INTO role_permissions (role, permission)
+SELECT r.role, 'return:manage' FROM (VALUES ('warehouse'), ('support')) AS r(role)
+WHERE EXISTS (SELECT 1 FROM roles WHERE name = r.role)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,20 @@
+-- Idempotency keys make retries of mutating requests safe: the response of
+-- the first request is stored per key and user and replayed for repeats
+-- until the key expires.
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	idempotency_key VARCHAR(255) NOT NULL,
+	fingerprint CHAR(64) NOT NULL,
+	status_code INT,
+	content_type VARCHAR(255) NOT NULL DEFAULT '',
+	body BYTEA,
+	created_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (user_id, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+
+COMMENT ON COLUMN idempotency_keys.fingerprint IS 'SHA-256 of the method, path and body of the first request';
+COMMENT ON COLUMN idempotency_keys.status_code IS 'NULL while the first request is in progress';
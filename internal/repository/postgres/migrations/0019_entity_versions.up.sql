@@ -0,0 +1,7 @@
+-- Products, categories and orders carry a version for optimistic
+-- concurrency control. Every change increments it, and updates only apply
+-- while the version they were based on is still current.
+
+ALTER TABLE products ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE categories ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE orders ADD COLUMN version INT NOT NULL DEFAULT 1;
@@ -0,0 +1,21 @@
+ALTER TABLE return_items
+	DROP CONSTRAINT IF EXISTS return_items_order_item_id_fkey,
+	ADD CONSTRAINT return_items_order_item_id_fkey FOREIGN KEY (order_item_id) REFERENCES order_items(id) ON DELETE CASCADE;
+
+ALTER TABLE returns
+	DROP CONSTRAINT IF EXISTS returns_order_id_fkey,
+	ADD CONSTRAINT returns_order_id_fkey FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE;
+
+ALTER TABLE refund_items
+	DROP CONSTRAINT IF EXISTS refund_items_order_item_id_fkey,
+	ADD CONSTRAINT refund_items_order_item_id_fkey FOREIGN KEY (order_item_id) REFERENCES order_items(id) ON DELETE CASCADE;
+
+ALTER TABLE refunds
+	DROP CONSTRAINT IF EXISTS refunds_payment_id_fkey,
+	DROP CONSTRAINT IF EXISTS refunds_order_id_fkey,
+	ADD CONSTRAINT refunds_payment_id_fkey FOREIGN KEY (payment_id) REFERENCES payments(id) ON DELETE CASCADE,
+	ADD CONSTRAINT refunds_order_id_fkey FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE;
+
+ALTER TABLE payments
+	DROP CONSTRAINT IF EXISTS payments_order_id_fkey,
+	ADD CONSTRAINT payments_order_id_fkey FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE;
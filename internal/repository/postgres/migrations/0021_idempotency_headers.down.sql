@@ -0,0 +1,5 @@
+ALTER TABLE idempotency_keys ADD COLUMN content_type VARCHAR(255) NOT NULL DEFAULT '';
+
+UPDATE idempotency_keys SET content_type = COALESCE(headers->>'Content-Type', '');
+
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS headers;
@@ -0,0 +1,12 @@
+-- Idempotent responses are replayed with their Location and ETag headers as
+-- well as their content type, so the stored content type becomes one of a
+-- set of headers.
+
+ALTER TABLE idempotency_keys ADD COLUMN headers JSONB NOT NULL DEFAULT '{}';
+
+UPDATE idempotency_keys SET headers = jsonb_build_object('Content-Type', content_type)
+WHERE content_type <> '';
+
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS content_type;
+
+COMMENT ON COLUMN idempotency_keys.headers IS 'Replayed response headers by name';
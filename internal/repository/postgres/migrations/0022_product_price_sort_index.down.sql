@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_product_variants_product_id_price_stock;
+
+CREATE INDEX IF NOT EXISTS idx_products_price_id ON products(price, id);
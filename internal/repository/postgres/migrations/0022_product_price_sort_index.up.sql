@@ -0,0 +1,9 @@
+-- Products are sorted and filtered by their lowest variant price, which is
+-- aggregated per product and cannot be served by an index on products.price.
+-- The per-product price and stock summary reads variants through a covering
+-- index instead, but price sorted pages still sort every matching product.
+
+DROP INDEX IF EXISTS idx_products_price_id;
+
+CREATE INDEX IF NOT EXISTS idx_product_variants_product_id_price_stock
+	ON product_variants(product_id) INCLUDE (price, stock);
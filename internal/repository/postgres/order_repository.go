@@ -3,8 +3,12 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/milad-ahmd/go-clean-arch/internal/domain"
@@ -29,7 +33,8 @@ func NewOrderRepository(db *sql.DB, logger logger.Logger) domain.OrderRepository
 // FindByID finds an order by ID
 func (r *orderRepository) FindByID(ctx context.Context, id int64) (*domain.Order, error) {
 	query := `
-		SELECT o.id, o.user_id, o.status, o.total_amount, o.payment_method, o.created_at, o.updated_at,
+		SELECT o.id, o.user_id, o.status, o.subtotal_amount, o.discount_amount, o.total_amount, o.currency, o.coupon_id, o.coupon_code,
+			   o.payment_method, o.created_at, o.updated_at, o.version,
 			   u.id, u.username, u.email, u.role, u.created_at, u.updated_at
 		FROM orders o
 		LEFT JOIN users u ON o.user_id = u.id
@@ -38,15 +43,22 @@ func (r *orderRepository) FindByID(ctx context.Context, id int64) (*domain.Order
 
 	var order domain.Order
 	var user domain.User
+	var couponCode sql.NullString
 
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&order.ID,
 		&order.UserID,
 		&order.Status,
-		&order.TotalAmount,
+		&order.Subtotal.Amount,
+		&order.DiscountAmount.Amount,
+		&order.TotalAmount.Amount,
+		&order.TotalAmount.Currency,
+		&order.CouponID,
+		&couponCode,
 		&order.PaymentMethod,
 		&order.CreatedAt,
 		&order.UpdatedAt,
+		&order.Version,
 		&user.ID,
 		&user.Username,
 		&user.Email,
@@ -64,6 +76,7 @@ func (r *orderRepository) FindByID(ctx context.Context, id int64) (*domain.Order
 	}
 
 	order.User = user
+	setOrderAmounts(&order, couponCode)
 
 	// Get order items
 	items, err := r.GetOrderItems(ctx, order.ID)
@@ -86,111 +99,105 @@ func (r *orderRepository) FindByID(ctx context.Context, id int64) (*domain.Order
 	return &order, nil
 }
 
-// FindAll finds all orders with pagination
-func (r *orderRepository) FindAll(ctx context.Context, limit, offset int) ([]domain.Order, int, error) {
-	query := `
-		SELECT o.id, o.user_id, o.status, o.total_amount, o.payment_method, o.created_at, o.updated_at,
-			   u.id, u.username, u.email, u.role, u.created_at, u.updated_at
-		FROM orders o
-		LEFT JOIN users u ON o.user_id = u.id
-		ORDER BY o.id
-		LIMIT $1 OFFSET $2
-	`
+// FindAll finds a page of all orders
+func (r *orderRepository) FindAll(ctx context.Context, page domain.PageRequest) (*domain.Page[domain.Order], error) {
+	return r.findPage(ctx, nil, nil, page)
+}
 
-	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+// Create creates a new order. The cart items it was checked out from, if
+// any, are removed in the same transaction.
+func (r *orderRepository) Create(ctx context.Context, order *domain.Order) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		r.logger.Error("Failed to find all orders", zap.Error(err))
-		return nil, 0, pkgerrors.NewInternalError(err)
-	}
-	defer rows.Close()
-
-	var orders []domain.Order
-	for rows.Next() {
-		var order domain.Order
-		var user domain.User
-
-		if err := rows.Scan(
-			&order.ID,
-			&order.UserID,
-			&order.Status,
-			&order.TotalAmount,
-			&order.PaymentMethod,
-			&order.CreatedAt,
-			&order.UpdatedAt,
-			&user.ID,
-			&user.Username,
-			&user.Email,
-			&user.Role,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-		); err != nil {
-			r.logger.Error("Failed to scan order", zap.Error(err))
-			return nil, 0, pkgerrors.NewInternalError(err)
-		}
-
-		order.User = user
-		orders = append(orders, order)
+		r.logger.Error("Failed to begin transaction", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
 	}
+	defer r.rollback(tx)
 
-	if err := rows.Err(); err != nil {
-		r.logger.Error("Error iterating order rows", zap.Error(err))
-		return nil, 0, pkgerrors.NewInternalError(err)
+	// Remove the checked out cart items first, so a concurrent checkout of
+	// the same cart fails here instead of placing a second order
+	if err = r.removeCartItems(ctx, tx, order.CartItems); err != nil {
+		return err
 	}
 
-	// Get total count
-	var total int
-	countQuery := `SELECT COUNT(*) FROM orders`
-	err = r.db.QueryRowContext(ctx, countQuery).Scan(&total)
-	if err != nil {
-		r.logger.Error("Failed to get total order count", zap.Error(err))
-		return nil, 0, pkgerrors.NewInternalError(err)
+	// Reserve stock first so concurrent orders for the same products
+	// serialize on the product rows before anything else is written
+	if err = r.reserveStock(ctx, tx, order.Items); err != nil {
+		return err
 	}
 
-	return orders, total, nil
-}
+	now := time.Now().UTC()
 
-// Create creates a new order
-func (r *orderRepository) Create(ctx context.Context, order *domain.Order) error {
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		r.logger.Error("Failed to begin transaction", zap.Error(err))
-		return pkgerrors.NewInternalError(err)
-	}
-	defer func() {
-		if err != nil {
-			rbErr := tx.Rollback()
-			if rbErr != nil {
-				r.logger.Error("Failed to rollback transaction", zap.Error(rbErr))
-			}
+	// Check the coupon's limits again with the coupon locked, so that
+	// concurrent orders cannot redeem it more often than allowed
+	if order.CouponID != nil {
+		if err = r.checkCoupon(ctx, tx, order, now); err != nil {
+			return err
 		}
-	}()
+	}
 
 	query := `
-		INSERT INTO orders (user_id, status, total_amount, payment_method, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id
+		INSERT INTO orders (user_id, status, subtotal_amount, discount_amount, total_amount, currency, coupon_id, coupon_code, payment_method, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, version
 	`
 
-	now := time.Now().Unix()
 	order.CreatedAt = now
 	order.UpdatedAt = now
 
+	var couponCode sql.NullString
+	if order.CouponCode != "" {
+		couponCode = sql.NullString{String: order.CouponCode, Valid: true}
+	}
+
 	err = tx.QueryRowContext(
 		ctx,
 		query,
 		order.UserID,
 		order.Status,
-		order.TotalAmount,
+		order.Subtotal.Amount,
+		order.DiscountAmount.Amount,
+		order.TotalAmount.Amount,
+		order.TotalAmount.Currency,
+		order.CouponID,
+		couponCode,
 		order.PaymentMethod,
 		order.CreatedAt,
 		order.UpdatedAt,
-	).Scan(&order.ID)
+	).Scan(&order.ID, &order.Version)
 
 	if err != nil {
 		r.logger.Error("Failed to create order", zap.Error(err))
 		return pkgerrors.NewInternalError(err)
 	}
 
+	if order.CouponID != nil {
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO coupon_redemptions (coupon_id, order_id, user_id, created_at) VALUES ($1, $2, $3, $4)`,
+			*order.CouponID,
+			order.ID,
+			order.UserID,
+			now,
+		)
+		if err != nil {
+			r.logger.Error("Failed to record coupon redemption", zap.Int64("couponID", *order.CouponID), zap.Error(err))
+			return pkgerrors.NewInternalError(err)
+		}
+	}
+
+	// Record the initial status
+	actorID := order.UserID
+	err = insertStatusHistory(ctx, tx, r.logger, &domain.OrderStatusHistory{
+		OrderID:   order.ID,
+		ToStatus:  order.Status,
+		ActorID:   &actorID,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+
 	// Insert order items
 	for i := range order.Items {
 		order.Items[i].OrderID = order.ID
@@ -198,8 +205,8 @@ func (r *orderRepository) Create(ctx context.Context, order *domain.Order) error
 		order.Items[i].UpdatedAt = now
 
 		itemQuery := `
-			INSERT INTO order_items (order_id, product_id, quantity, price, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6)
+			INSERT INTO order_items (order_id, product_id, variant_id, quantity, price, discount, currency, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 			RETURNING id
 		`
 
@@ -208,8 +215,11 @@ func (r *orderRepository) Create(ctx context.Context, order *domain.Order) error
 			itemQuery,
 			order.Items[i].OrderID,
 			order.Items[i].ProductID,
+			order.Items[i].VariantID,
 			order.Items[i].Quantity,
-			order.Items[i].Price,
+			order.Items[i].Price.Amount,
+			order.Items[i].Discount.Amount,
+			order.Items[i].Price.Currency,
 			order.Items[i].CreatedAt,
 			order.Items[i].UpdatedAt,
 		).Scan(&order.Items[i].ID)
@@ -218,32 +228,6 @@ func (r *orderRepository) Create(ctx context.Context, order *domain.Order) error
 			r.logger.Error("Failed to create order item", zap.Error(err))
 			return pkgerrors.NewInternalError(err)
 		}
-
-		// Update product stock
-		stockQuery := `
-			UPDATE products
-			SET stock = stock - $1, updated_at = $2
-			WHERE id = $3
-			RETURNING stock
-		`
-
-		var newStock int
-		err = tx.QueryRowContext(
-			ctx,
-			stockQuery,
-			order.Items[i].Quantity,
-			now,
-			order.Items[i].ProductID,
-		).Scan(&newStock)
-
-		if err != nil {
-			r.logger.Error("Failed to update product stock", zap.Error(err))
-			return pkgerrors.NewInternalError(err)
-		}
-
-		if newStock < 0 {
-			return pkgerrors.NewBadRequestError("Insufficient stock for product ID: " + fmt.Sprintf("%d", order.Items[i].ProductID))
-		}
 	}
 
 	// Insert shipping info if provided
@@ -286,38 +270,78 @@ func (r *orderRepository) Create(ctx context.Context, order *domain.Order) error
 	return nil
 }
 
-// Update updates an order
-func (r *orderRepository) Update(ctx context.Context, order *domain.Order) error {
-	query := `
-		UPDATE orders
-		SET status = $1, payment_method = $2, updated_at = $3
-		WHERE id = $4
-	`
+// removeCartItems removes checked out cart items within tx. An item that is
+// gone or whose quantity changed since the cart was read means the cart
+// changed, and the order is not placed.
+func (r *orderRepository) removeCartItems(ctx context.Context, tx *sql.Tx, items []domain.CartItem) error {
+	for _, item := range items {
+		result, err := tx.ExecContext(
+			ctx,
+			`DELETE FROM cart_items WHERE id = $1 AND cart_id = $2 AND quantity = $3`,
+			item.ID,
+			item.CartID,
+			item.Quantity,
+		)
+		if err != nil {
+			r.logger.Error("Failed to remove checked out cart item", zap.Int64("id", item.ID), zap.Error(err))
+			return pkgerrors.NewInternalError(err)
+		}
 
-	order.UpdatedAt = time.Now().Unix()
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			r.logger.Error("Failed to get rows affected", zap.Error(err))
+			return pkgerrors.NewInternalError(err)
+		}
 
-	result, err := r.db.ExecContext(
-		ctx,
-		query,
-		order.Status,
-		order.PaymentMethod,
-		order.UpdatedAt,
-		order.ID,
-	)
+		if rowsAffected == 0 {
+			return &domain.CartChangedError{}
+		}
+	}
 
+	return nil
+}
+
+// Update updates an order, provided it is still at order.Version, and moves
+// it to the next version
+func (r *orderRepository) Update(ctx context.Context, order *domain.Order) error {
+	return r.UpdateWithStatus(ctx, order, nil)
+}
+
+// UpdateWithStatus updates the payment method and shipping info of an order
+// and, unless change is nil, moves it to change.ToStatus, all in one
+// transaction. Nothing is changed unless the order is still at
+// order.Version, which is set to the order's new version.
+func (r *orderRepository) UpdateWithStatus(ctx context.Context, order *domain.Order, change *domain.OrderStatusHistory) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		r.logger.Error("Failed to update order", zap.Int64("id", order.ID), zap.Error(err))
+		r.logger.Error("Failed to begin transaction", zap.Error(err))
 		return pkgerrors.NewInternalError(err)
 	}
+	defer r.rollback(tx)
 
-	rowsAffected, err := result.RowsAffected()
+	current, err := r.lockOrder(ctx, tx, order.ID, order.Version)
 	if err != nil {
-		r.logger.Error("Failed to get rows affected", zap.Error(err))
-		return pkgerrors.NewInternalError(err)
+		return err
 	}
 
-	if rowsAffected == 0 {
-		return pkgerrors.NewNotFoundError("Order", order.ID)
+	if change != nil {
+		change.OrderID = order.ID
+		if err = r.changeStatus(ctx, tx, current, change); err != nil {
+			return err
+		}
+	}
+
+	order.UpdatedAt = time.Now().UTC()
+	err = tx.QueryRowContext(
+		ctx,
+		`UPDATE orders SET payment_method = $1, updated_at = $2, version = version + 1 WHERE id = $3 RETURNING version`,
+		order.PaymentMethod,
+		order.UpdatedAt,
+		order.ID,
+	).Scan(&order.Version)
+	if err != nil {
+		r.logger.Error("Failed to update order", zap.Int64("id", order.ID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
 	}
 
 	// Update shipping info if provided
@@ -328,7 +352,7 @@ func (r *orderRepository) Update(ctx context.Context, order *domain.Order) error
 			WHERE order_id = $8
 		`
 
-		_, err = r.db.ExecContext(
+		_, err = tx.ExecContext(
 			ctx,
 			shippingQuery,
 			order.ShippingInfo.Address,
@@ -347,24 +371,56 @@ func (r *orderRepository) Update(ctx context.Context, order *domain.Order) error
 		}
 	}
 
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
 	return nil
 }
 
 // Delete deletes an order
 func (r *orderRepository) Delete(ctx context.Context, id int64) error {
+	return r.DeleteVersion(ctx, id, 0)
+}
+
+// DeleteVersion deletes an order, provided it is still at version unless
+// version is zero
+func (r *orderRepository) DeleteVersion(ctx context.Context, id, version int64) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		r.logger.Error("Failed to begin transaction", zap.Error(err))
 		return pkgerrors.NewInternalError(err)
 	}
-	defer func() {
-		if err != nil {
-			rbErr := tx.Rollback()
-			if rbErr != nil {
-				r.logger.Error("Failed to rollback transaction", zap.Error(rbErr))
-			}
+	defer r.rollback(tx)
+
+	status, err := r.lockOrder(ctx, tx, id, version)
+	if err != nil {
+		return err
+	}
+
+	// Payments, refunds and returns outlive their order, so such orders are
+	// cancelled rather than deleted
+	var recorded bool
+	err = tx.QueryRowContext(
+		ctx,
+		`SELECT EXISTS(SELECT 1 FROM payments WHERE order_id = $1) OR EXISTS(SELECT 1 FROM returns WHERE order_id = $1)`,
+		id,
+	).Scan(&recorded)
+	if err != nil {
+		r.logger.Error("Failed to check order payments", zap.Int64("orderID", id), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+	if recorded {
+		return pkgerrors.NewAppError(pkgerrors.ErrConflict, "Orders with payments or returns cannot be deleted; cancel them instead", http.StatusConflict)
+	}
+
+	// Completed orders have been fulfilled, so their stock is gone for good
+	if status != domain.OrderStatusCompleted {
+		if err = r.restockItems(ctx, tx, id); err != nil {
+			return err
 		}
-	}()
+	}
 
 	// Delete shipping info
 	_, err = tx.ExecContext(ctx, `DELETE FROM shipping_info WHERE order_id = $1`, id)
@@ -405,22 +461,32 @@ func (r *orderRepository) Delete(ctx context.Context, id int64) error {
 	return nil
 }
 
-// FindByUserID finds orders by user ID
-func (r *orderRepository) FindByUserID(ctx context.Context, userID int64, limit, offset int) ([]domain.Order, int, error) {
-	query := `
-		SELECT o.id, o.user_id, o.status, o.total_amount, o.payment_method, o.created_at, o.updated_at,
+// FindByUserID finds a page of a user's orders
+func (r *orderRepository) FindByUserID(ctx context.Context, userID int64, page domain.PageRequest) (*domain.Page[domain.Order], error) {
+	return r.findPage(ctx, []string{"o.user_id = $1"}, []interface{}{userID}, page)
+}
+
+// FindByStatus finds a page of the orders in a status
+func (r *orderRepository) FindByStatus(ctx context.Context, status domain.OrderStatus, page domain.PageRequest) (*domain.Page[domain.Order], error) {
+	return r.findPage(ctx, []string{"o.status = $1"}, []interface{}{status}, page)
+}
+
+// findPage finds a page of the orders matching the filter conditions
+func (r *orderRepository) findPage(ctx context.Context, conditions []string, args []interface{}, page domain.PageRequest) (*domain.Page[domain.Order], error) {
+	query, queryArgs, err := pageQuery(`
+		SELECT o.id, o.user_id, o.status, o.subtotal_amount, o.discount_amount, o.total_amount, o.currency, o.coupon_id, o.coupon_code,
+			   o.payment_method, o.created_at, o.updated_at, o.version,
 			   u.id, u.username, u.email, u.role, u.created_at, u.updated_at
 		FROM orders o
-		LEFT JOIN users u ON o.user_id = u.id
-		WHERE o.user_id = $1
-		ORDER BY o.id
-		LIMIT $2 OFFSET $3
-	`
+		LEFT JOIN users u ON o.user_id = u.id`, idOrder("o.id"), conditions, args, page)
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := r.db.QueryContext(ctx, query, userID, limit, offset)
+	rows, err := r.db.QueryContext(ctx, query, queryArgs...)
 	if err != nil {
-		r.logger.Error("Failed to find orders by user ID", zap.Int64("userID", userID), zap.Error(err))
-		return nil, 0, pkgerrors.NewInternalError(err)
+		r.logger.Error("Failed to find orders", zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
 	}
 	defer rows.Close()
 
@@ -428,15 +494,22 @@ func (r *orderRepository) FindByUserID(ctx context.Context, userID int64, limit,
 	for rows.Next() {
 		var order domain.Order
 		var user domain.User
+		var couponCode sql.NullString
 
 		if err := rows.Scan(
 			&order.ID,
 			&order.UserID,
 			&order.Status,
-			&order.TotalAmount,
+			&order.Subtotal.Amount,
+			&order.DiscountAmount.Amount,
+			&order.TotalAmount.Amount,
+			&order.TotalAmount.Currency,
+			&order.CouponID,
+			&couponCode,
 			&order.PaymentMethod,
 			&order.CreatedAt,
 			&order.UpdatedAt,
+			&order.Version,
 			&user.ID,
 			&user.Username,
 			&user.Email,
@@ -445,120 +518,214 @@ func (r *orderRepository) FindByUserID(ctx context.Context, userID int64, limit,
 			&user.UpdatedAt,
 		); err != nil {
 			r.logger.Error("Failed to scan order", zap.Error(err))
-			return nil, 0, pkgerrors.NewInternalError(err)
+			return nil, pkgerrors.NewInternalError(err)
 		}
 
 		order.User = user
+		setOrderAmounts(&order, couponCode)
 		orders = append(orders, order)
 	}
 
 	if err := rows.Err(); err != nil {
 		r.logger.Error("Error iterating order rows", zap.Error(err))
-		return nil, 0, pkgerrors.NewInternalError(err)
+		return nil, pkgerrors.NewInternalError(err)
+	}
+
+	result := newPage(orders, page, func(o domain.Order) domain.Cursor { return domain.Cursor{ID: o.ID} })
+	if !page.SkipTotal {
+		total, err := countRows(ctx, r.db, "orders o", conditions, args)
+		if err != nil {
+			r.logger.Error("Failed to get total order count", zap.Error(err))
+			return nil, pkgerrors.NewInternalError(err)
+		}
+		result.Total = &total
+	}
+
+	return result, nil
+}
+
+// UpdateStatus moves an order to change.ToStatus and records the change in
+// its status history. The order row is locked while the transition is
+// validated, so concurrent changes cannot skip the state machine. Unless
+// version is zero, the order must still be at version.
+func (r *orderRepository) UpdateStatus(ctx context.Context, change *domain.OrderStatusHistory, version int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+	defer r.rollback(tx)
+
+	current, err := r.lockOrder(ctx, tx, change.OrderID, version)
+	if err != nil {
+		return err
+	}
+
+	if err = r.changeStatus(ctx, tx, current, change); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
 	}
 
-	// Get total count
-	var total int
-	countQuery := `SELECT COUNT(*) FROM orders WHERE user_id = $1`
-	err = r.db.QueryRowContext(ctx, countQuery, userID).Scan(&total)
+	return nil
+}
+
+// lockOrder locks an order until tx ends and returns its status. Unless
+// version is zero, the order must still be at version.
+func (r *orderRepository) lockOrder(ctx context.Context, tx *sql.Tx, id, version int64) (domain.OrderStatus, error) {
+	var status domain.OrderStatus
+	var current int64
+	err := tx.QueryRowContext(ctx, `SELECT status, version FROM orders WHERE id = $1 FOR UPDATE`, id).Scan(&status, &current)
 	if err != nil {
-		r.logger.Error("Failed to get total order count by user ID", zap.Int64("userID", userID), zap.Error(err))
-		return nil, 0, pkgerrors.NewInternalError(err)
+		if err == sql.ErrNoRows {
+			return "", pkgerrors.NewNotFoundError("Order", id)
+		}
+		r.logger.Error("Failed to lock order", zap.Int64("id", id), zap.Error(err))
+		return "", pkgerrors.NewInternalError(err)
 	}
 
-	return orders, total, nil
+	if err := domain.CheckVersion("Order", id, current, version); err != nil {
+		return "", err
+	}
+	return status, nil
 }
 
-// FindByStatus finds orders by status
-func (r *orderRepository) FindByStatus(ctx context.Context, status domain.OrderStatus, limit, offset int) ([]domain.Order, int, error) {
+// changeStatus moves an order locked in status current to change.ToStatus
+// within tx, restocking it when it is cancelled
+func (r *orderRepository) changeStatus(ctx context.Context, tx *sql.Tx, current domain.OrderStatus, change *domain.OrderStatusHistory) error {
+	if err := current.ValidateTransition(change.ToStatus); err != nil {
+		return err
+	}
+
+	// The money reserved for a cancelled order must have been released
+	if change.ToStatus == domain.OrderStatusCancelled {
+		if err := r.checkNoOpenPayment(ctx, tx, change.OrderID, "Void the order's payment before cancelling it"); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UTC()
+	change.FromStatus = current
+	change.CreatedAt = now
+
+	_, err := tx.ExecContext(ctx, `UPDATE orders SET status = $1, updated_at = $2, version = version + 1 WHERE id = $3`, change.ToStatus, now, change.OrderID)
+	if err != nil {
+		r.logger.Error("Failed to update order status", zap.Int64("id", change.OrderID), zap.String("status", string(change.ToStatus)), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	if change.ToStatus == domain.OrderStatusCancelled {
+		if err = r.restockItems(ctx, tx, change.OrderID); err != nil {
+			return err
+		}
+
+		// A cancelled order no longer counts against its coupon's limits
+		if _, err = tx.ExecContext(ctx, `DELETE FROM coupon_redemptions WHERE order_id = $1`, change.OrderID); err != nil {
+			r.logger.Error("Failed to release coupon redemption", zap.Int64("orderID", change.OrderID), zap.Error(err))
+			return pkgerrors.NewInternalError(err)
+		}
+	}
+
+	return insertStatusHistory(ctx, tx, r.logger, change)
+}
+
+// checkNoOpenPayment returns a conflict with message when an order locked by
+// tx has a pending or authorized payment. Their amount was fixed from the
+// order total, so the total must not change until they are captured or
+// voided.
+func (r *orderRepository) checkNoOpenPayment(ctx context.Context, tx *sql.Tx, orderID int64, message string) error {
+	var open bool
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT EXISTS(SELECT 1 FROM payments WHERE order_id = $1 AND status IN ($2, $3))`,
+		orderID,
+		domain.PaymentStatusPending,
+		domain.PaymentStatusAuthorized,
+	).Scan(&open)
+	if err != nil {
+		r.logger.Error("Failed to check open payments", zap.Int64("orderID", orderID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	if open {
+		return pkgerrors.NewAppError(pkgerrors.ErrConflict, message, http.StatusConflict)
+	}
+	return nil
+}
+
+// GetStatusHistory gets the status changes of an order, oldest first
+func (r *orderRepository) GetStatusHistory(ctx context.Context, orderID int64) ([]domain.OrderStatusHistory, error) {
 	query := `
-		SELECT o.id, o.user_id, o.status, o.total_amount, o.payment_method, o.created_at, o.updated_at,
-			   u.id, u.username, u.email, u.role, u.created_at, u.updated_at
-		FROM orders o
-		LEFT JOIN users u ON o.user_id = u.id
-		WHERE o.status = $1
-		ORDER BY o.id
-		LIMIT $2 OFFSET $3
+		SELECT id, order_id, from_status, to_status, actor_id, reason, created_at
+		FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY created_at, id
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, status, limit, offset)
+	rows, err := r.db.QueryContext(ctx, query, orderID)
 	if err != nil {
-		r.logger.Error("Failed to find orders by status", zap.String("status", string(status)), zap.Error(err))
-		return nil, 0, pkgerrors.NewInternalError(err)
+		r.logger.Error("Failed to get order status history", zap.Int64("orderID", orderID), zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
 	}
 	defer rows.Close()
 
-	var orders []domain.Order
+	history := []domain.OrderStatusHistory{}
 	for rows.Next() {
-		var order domain.Order
-		var user domain.User
+		var entry domain.OrderStatusHistory
+		var fromStatus sql.NullString
 
 		if err := rows.Scan(
-			&order.ID,
-			&order.UserID,
-			&order.Status,
-			&order.TotalAmount,
-			&order.PaymentMethod,
-			&order.CreatedAt,
-			&order.UpdatedAt,
-			&user.ID,
-			&user.Username,
-			&user.Email,
-			&user.Role,
-			&user.CreatedAt,
-			&user.UpdatedAt,
+			&entry.ID,
+			&entry.OrderID,
+			&fromStatus,
+			&entry.ToStatus,
+			&entry.ActorID,
+			&entry.Reason,
+			&entry.CreatedAt,
 		); err != nil {
-			r.logger.Error("Failed to scan order", zap.Error(err))
-			return nil, 0, pkgerrors.NewInternalError(err)
+			r.logger.Error("Failed to scan order status history", zap.Error(err))
+			return nil, pkgerrors.NewInternalError(err)
 		}
 
-		order.User = user
-		orders = append(orders, order)
+		entry.FromStatus = domain.OrderStatus(fromStatus.String)
+		history = append(history, entry)
 	}
 
 	if err := rows.Err(); err != nil {
-		r.logger.Error("Error iterating order rows", zap.Error(err))
-		return nil, 0, pkgerrors.NewInternalError(err)
-	}
-
-	// Get total count
-	var total int
-	countQuery := `SELECT COUNT(*) FROM orders WHERE status = $1`
-	err = r.db.QueryRowContext(ctx, countQuery, status).Scan(&total)
-	if err != nil {
-		r.logger.Error("Failed to get total order count by status", zap.String("status", string(status)), zap.Error(err))
-		return nil, 0, pkgerrors.NewInternalError(err)
+		r.logger.Error("Error iterating order status history rows", zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
 	}
 
-	return orders, total, nil
+	return history, nil
 }
 
-// UpdateStatus updates an order's status
-func (r *orderRepository) UpdateStatus(ctx context.Context, id int64, status domain.OrderStatus) error {
+// insertStatusHistory records a status change within tx
+func insertStatusHistory(ctx context.Context, tx *sql.Tx, logger logger.Logger, change *domain.OrderStatusHistory) error {
 	query := `
-		UPDATE orders
-		SET status = $1, updated_at = $2
-		WHERE id = $3
+		INSERT INTO order_status_history (order_id, from_status, to_status, actor_id, reason, created_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6)
+		RETURNING id
 	`
 
-	now := time.Now().Unix()
-
-	result, err := r.db.ExecContext(ctx, query, status, now, id)
-	if err != nil {
-		r.logger.Error("Failed to update order status", zap.Int64("id", id), zap.String("status", string(status)), zap.Error(err))
-		return pkgerrors.NewInternalError(err)
-	}
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		change.OrderID,
+		change.FromStatus,
+		change.ToStatus,
+		change.ActorID,
+		change.Reason,
+		change.CreatedAt,
+	).Scan(&change.ID)
 
-	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		r.logger.Error("Failed to get rows affected", zap.Error(err))
+		logger.Error("Failed to record order status change", zap.Int64("orderID", change.OrderID), zap.Error(err))
 		return pkgerrors.NewInternalError(err)
 	}
 
-	if rowsAffected == 0 {
-		return pkgerrors.NewNotFoundError("Order", id)
-	}
-
 	return nil
 }
 
@@ -569,50 +736,44 @@ func (r *orderRepository) AddOrderItem(ctx context.Context, item *domain.OrderIt
 		r.logger.Error("Failed to begin transaction", zap.Error(err))
 		return pkgerrors.NewInternalError(err)
 	}
-	defer func() {
-		if err != nil {
-			rbErr := tx.Rollback()
-			if rbErr != nil {
-				r.logger.Error("Failed to rollback transaction", zap.Error(rbErr))
-			}
-		}
-	}()
+	defer r.rollback(tx)
 
-	// Check if order exists
-	var exists bool
-	err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM orders WHERE id = $1)`, item.OrderID).Scan(&exists)
+	// Check if order exists and is billed in the item's currency
+	var currency domain.Currency
+	err = tx.QueryRowContext(ctx, `SELECT currency FROM orders WHERE id = $1 FOR UPDATE`, item.OrderID).Scan(&currency)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return pkgerrors.NewNotFoundError("Order", item.OrderID)
+		}
 		r.logger.Error("Failed to check if order exists", zap.Int64("orderID", item.OrderID), zap.Error(err))
 		return pkgerrors.NewInternalError(err)
 	}
 
-	if !exists {
-		return pkgerrors.NewNotFoundError("Order", item.OrderID)
+	if currency != item.Price.Currency {
+		return pkgerrors.NewBadRequestError("Item currency does not match order currency")
+	}
+
+	if err = r.checkNoOpenPayment(ctx, tx, item.OrderID, "Items cannot be added while the order has an open payment"); err != nil {
+		return err
 	}
 
-	// Check if product exists and has enough stock
-	var stock int
-	err = tx.QueryRowContext(ctx, `SELECT stock FROM products WHERE id = $1`, item.ProductID).Scan(&stock)
+	lineTotal, err := item.Price.Mul(int64(item.Quantity))
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return pkgerrors.NewNotFoundError("Product", item.ProductID)
-		}
-		r.logger.Error("Failed to get product stock", zap.Int64("productID", item.ProductID), zap.Error(err))
-		return pkgerrors.NewInternalError(err)
+		return pkgerrors.NewBadRequestError("Order item total is out of range")
 	}
 
-	if stock < item.Quantity {
-		return pkgerrors.NewBadRequestError("Insufficient stock")
+	if err = r.reserveStock(ctx, tx, []domain.OrderItem{*item}); err != nil {
+		return err
 	}
 
 	// Insert order item
-	now := time.Now().Unix()
+	now := time.Now().UTC()
 	item.CreatedAt = now
 	item.UpdatedAt = now
 
 	query := `
-		INSERT INTO order_items (order_id, product_id, quantity, price, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO order_items (order_id, product_id, variant_id, quantity, price, currency, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id
 	`
 
@@ -621,8 +782,10 @@ func (r *orderRepository) AddOrderItem(ctx context.Context, item *domain.OrderIt
 		query,
 		item.OrderID,
 		item.ProductID,
+		item.VariantID,
 		item.Quantity,
-		item.Price,
+		item.Price.Amount,
+		item.Price.Currency,
 		item.CreatedAt,
 		item.UpdatedAt,
 	).Scan(&item.ID)
@@ -632,25 +795,11 @@ func (r *orderRepository) AddOrderItem(ctx context.Context, item *domain.OrderIt
 		return pkgerrors.NewInternalError(err)
 	}
 
-	// Update product stock
-	_, err = tx.ExecContext(
-		ctx,
-		`UPDATE products SET stock = stock - $1, updated_at = $2 WHERE id = $3`,
-		item.Quantity,
-		now,
-		item.ProductID,
-	)
-
-	if err != nil {
-		r.logger.Error("Failed to update product stock", zap.Error(err))
-		return pkgerrors.NewInternalError(err)
-	}
-
 	// Update order total amount
 	_, err = tx.ExecContext(
 		ctx,
-		`UPDATE orders SET total_amount = total_amount + $1, updated_at = $2 WHERE id = $3`,
-		item.Price*float64(item.Quantity),
+		`UPDATE orders SET subtotal_amount = subtotal_amount + $1, total_amount = total_amount + $1, updated_at = $2, version = version + 1 WHERE id = $3`,
+		lineTotal.Amount,
 		now,
 		item.OrderID,
 	)
@@ -671,10 +820,12 @@ func (r *orderRepository) AddOrderItem(ctx context.Context, item *domain.OrderIt
 // GetOrderItems gets all items for an order
 func (r *orderRepository) GetOrderItems(ctx context.Context, orderID int64) ([]domain.OrderItem, error) {
 	query := `
-		SELECT oi.id, oi.order_id, oi.product_id, oi.quantity, oi.price, oi.created_at, oi.updated_at,
-			   p.id, p.name, p.description, p.price, p.sku, p.stock, p.category_id, p.created_at, p.updated_at
+		SELECT oi.id, oi.order_id, oi.product_id, oi.variant_id, oi.quantity, oi.cancelled_quantity, oi.price, oi.discount, oi.currency, oi.created_at, oi.updated_at,
+			   p.id, p.name, p.description, p.price, p.currency, p.sku, p.stock, p.category_id, p.created_at, p.updated_at,
+			   v.sku, v.options
 		FROM order_items oi
 		LEFT JOIN products p ON oi.product_id = p.id
+		LEFT JOIN product_variants v ON oi.variant_id = v.id
 		WHERE oi.order_id = $1
 	`
 
@@ -689,30 +840,47 @@ func (r *orderRepository) GetOrderItems(ctx context.Context, orderID int64) ([]d
 	for rows.Next() {
 		var item domain.OrderItem
 		var product domain.Product
+		var variantSKU sql.NullString
+		var variantOptions []byte
 
 		if err := rows.Scan(
 			&item.ID,
 			&item.OrderID,
 			&item.ProductID,
+			&item.VariantID,
 			&item.Quantity,
-			&item.Price,
+			&item.CancelledQuantity,
+			&item.Price.Amount,
+			&item.Discount.Amount,
+			&item.Price.Currency,
 			&item.CreatedAt,
 			&item.UpdatedAt,
 			&product.ID,
 			&product.Name,
 			&product.Description,
-			&product.Price,
+			&product.Price.Amount,
+			&product.Price.Currency,
 			&product.SKU,
 			&product.Stock,
 			&product.CategoryID,
 			&product.CreatedAt,
 			&product.UpdatedAt,
+			&variantSKU,
+			&variantOptions,
 		); err != nil {
 			r.logger.Error("Failed to scan order item", zap.Error(err))
 			return nil, pkgerrors.NewInternalError(err)
 		}
 
 		item.Product = product
+		item.Discount.Currency = item.Price.Currency
+		if item.VariantID != nil {
+			item.Variant = &domain.ProductVariant{ID: *item.VariantID, ProductID: item.ProductID, SKU: variantSKU.String}
+			if err := json.Unmarshal(variantOptions, &item.Variant.Options); err != nil {
+				r.logger.Error("Failed to unmarshal variant options", zap.Error(err))
+				return nil, pkgerrors.NewInternalError(err)
+			}
+		}
 		items = append(items, item)
 	}
 
@@ -724,6 +892,116 @@ func (r *orderRepository) GetOrderItems(ctx context.Context, orderID int64) ([]d
 	return items, nil
 }
 
+// CancelOrderItem sets the cancelled quantity of an order item, returns the
+// newly cancelled units to stock and lowers the order total. The quantity is
+// the line's total cancelled quantity rather than an increment, so repeating
+// a request has no further effect. Unless version is zero, the order must
+// still be at version.
+func (r *orderRepository) CancelOrderItem(ctx context.Context, orderID, itemID int64, quantity int, version int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+	defer r.rollback(tx)
+
+	status, err := r.lockOrder(ctx, tx, orderID, version)
+	if err != nil {
+		return err
+	}
+
+	if status != domain.OrderStatusPending && status != domain.OrderStatusProcessing {
+		return pkgerrors.NewAppError(pkgerrors.ErrConflict, "Items can only be cancelled on pending or processing orders", http.StatusConflict)
+	}
+
+	if err = r.checkNoOpenPayment(ctx, tx, orderID, "Items cannot be cancelled while the order has an open payment; void it first"); err != nil {
+		return err
+	}
+
+	var item domain.OrderItem
+	err = tx.QueryRowContext(
+		ctx,
+		`SELECT product_id, variant_id, quantity, cancelled_quantity, price, discount, currency FROM order_items WHERE id = $1 AND order_id = $2 FOR UPDATE`,
+		itemID,
+		orderID,
+	).Scan(&item.ProductID, &item.VariantID, &item.Quantity, &item.CancelledQuantity, &item.Price.Amount, &item.Discount.Amount, &item.Price.Currency)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return pkgerrors.NewNotFoundError("Order item", itemID)
+		}
+		r.logger.Error("Failed to get order item", zap.Int64("id", itemID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	if quantity > item.Quantity {
+		return pkgerrors.NewBadRequestError("Cancelled quantity exceeds the ordered quantity")
+	}
+	if quantity < item.CancelledQuantity {
+		return pkgerrors.NewBadRequestError("Cancelled items cannot be restored")
+	}
+
+	delta := quantity - item.CancelledQuantity
+	if delta == 0 {
+		return nil
+	}
+
+	item.Discount.Currency = item.Price.Currency
+
+	// The cancelled units take their share of the line discount with them
+	gross, err := item.Price.Mul(int64(delta))
+	if err != nil {
+		return pkgerrors.NewBadRequestError("Order item total is out of range")
+	}
+	cancelledTotal, err := item.UnitsTotal(quantity)
+	if err != nil {
+		return pkgerrors.NewBadRequestError("Order item total is out of range")
+	}
+	previousTotal, err := item.UnitsTotal(item.CancelledQuantity)
+	if err != nil {
+		return pkgerrors.NewBadRequestError("Order item total is out of range")
+	}
+	refund := cancelledTotal.Amount - previousTotal.Amount
+
+	now := time.Now().UTC()
+
+	if err = restockItem(ctx, tx, r.logger, item, delta, now); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE order_items SET cancelled_quantity = $1, restocked_quantity = restocked_quantity + $2, updated_at = $3 WHERE id = $4`,
+		quantity,
+		delta,
+		now,
+		itemID,
+	)
+	if err != nil {
+		r.logger.Error("Failed to cancel order item", zap.Int64("id", itemID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE orders SET subtotal_amount = subtotal_amount - $1, discount_amount = discount_amount - ($1 - $2), total_amount = total_amount - $2, updated_at = $3, version = version + 1 WHERE id = $4`,
+		gross.Amount,
+		refund,
+		now,
+		orderID,
+	)
+	if err != nil {
+		r.logger.Error("Failed to update order total amount", zap.Int64("id", orderID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	return nil
+}
+
 // SaveShippingInfo saves shipping information for an order
 func (r *orderRepository) SaveShippingInfo(ctx context.Context, info *domain.ShippingInfo) error {
 	// Check if shipping info already exists for this order
@@ -734,7 +1012,7 @@ func (r *orderRepository) SaveShippingInfo(ctx context.Context, info *domain.Shi
 		return pkgerrors.NewInternalError(err)
 	}
 
-	now := time.Now().Unix()
+	now := time.Now().UTC()
 	info.UpdatedAt = now
 
 	if exists {
@@ -827,3 +1105,215 @@ func (r *orderRepository) GetShippingInfo(ctx context.Context, orderID int64) (*
 
 	return &info, nil
 }
+
+// reserveStock decrements the stock of every product and variant in items
+// within tx. Items of a variant draw from the variant's stock, others from
+// the product's. Quantities are summed per product and variant, and rows are
+// updated products first, each in ascending ID order, so concurrent
+// reservations always lock rows in the same order and cannot deadlock. Each
+// decrement only applies while enough stock remains, so stock is never
+// oversold.
+func (r *orderRepository) reserveStock(ctx context.Context, tx *sql.Tx, items []domain.OrderItem) error {
+	productQuantities := make(map[int64]int, len(items))
+	variantQuantities := make(map[int64]int)
+	for _, item := range items {
+		if item.VariantID != nil {
+			variantQuantities[*item.VariantID] += item.Quantity
+		} else {
+			productQuantities[item.ProductID] += item.Quantity
+		}
+	}
+
+	now := time.Now().UTC()
+	for _, productID := range sortedIDs(productQuantities) {
+		if err := r.reserve(ctx, tx, "products", "Product", productID, productQuantities[productID], now); err != nil {
+			return err
+		}
+	}
+	for _, variantID := range sortedIDs(variantQuantities) {
+		if err := r.reserve(ctx, tx, "product_variants", "Product variant", variantID, variantQuantities[variantID], now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reserve decrements the stock of row id of table, which holds entity rows,
+// by quantity within tx
+func (r *orderRepository) reserve(ctx context.Context, tx *sql.Tx, table, entity string, id int64, quantity int, now time.Time) error {
+	set := "stock = stock - $1, updated_at = $2"
+	if table == "products" {
+		// Products are versioned, and their stock is part of the version
+		set += ", version = version + 1"
+	}
+
+	result, err := tx.ExecContext(
+		ctx,
+		`UPDATE `+table+` SET `+set+` WHERE id = $3 AND stock >= $1`,
+		quantity,
+		now,
+		id,
+	)
+	if err != nil {
+		r.logger.Error("Failed to reserve stock", zap.String("entity", entity), zap.Int64("id", id), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		var exists bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM `+table+` WHERE id = $1)`, id).Scan(&exists)
+		if err != nil {
+			r.logger.Error("Failed to check if stock row exists", zap.String("entity", entity), zap.Int64("id", id), zap.Error(err))
+			return pkgerrors.NewInternalError(err)
+		}
+		if !exists {
+			return pkgerrors.NewNotFoundError(entity, id)
+		}
+		return pkgerrors.NewBadRequestError(fmt.Sprintf("Insufficient stock for %s ID: %d", strings.ToLower(entity), id))
+	}
+
+	return nil
+}
+
+// sortedIDs returns the keys of quantities in ascending order
+func sortedIDs(quantities map[int64]int) []int64 {
+	ids := make([]int64, 0, len(quantities))
+	for id := range quantities {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// restockItems returns every unit of an order that has not been returned to
+// stock yet, within tx. Each item records how much it has restocked, so
+// calling this again for the same order restocks nothing. Products and then
+// variants are updated in ascending ID order, matching reserveStock.
+func (r *orderRepository) restockItems(ctx context.Context, tx *sql.Tx, orderID int64) error {
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT id, product_id, variant_id, quantity - restocked_quantity FROM order_items
+		WHERE order_id = $1 AND restocked_quantity < quantity
+		ORDER BY variant_id NULLS FIRST, product_id, id
+		FOR UPDATE`,
+		orderID,
+	)
+	if err != nil {
+		r.logger.Error("Failed to get order items to restock", zap.Int64("orderID", orderID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	var items []domain.OrderItem
+	for rows.Next() {
+		var item domain.OrderItem
+		if err := rows.Scan(&item.ID, &item.ProductID, &item.VariantID, &item.Quantity); err != nil {
+			rows.Close()
+			r.logger.Error("Failed to scan order item to restock", zap.Error(err))
+			return pkgerrors.NewInternalError(err)
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Error iterating order item rows", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	now := time.Now().UTC()
+	for _, item := range items {
+		if err := restockItem(ctx, tx, r.logger, item, item.Quantity, now); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `UPDATE order_items SET restocked_quantity = quantity, updated_at = $1 WHERE id = $2`, now, item.ID)
+		if err != nil {
+			r.logger.Error("Failed to mark order item restocked", zap.Int64("id", item.ID), zap.Error(err))
+			return pkgerrors.NewInternalError(err)
+		}
+	}
+
+	return nil
+}
+
+// restockItem returns quantity units of an order item to the stock they were
+// reserved from, within tx
+func restockItem(ctx context.Context, tx *sql.Tx, logger logger.Logger, item domain.OrderItem, quantity int, now time.Time) error {
+	query := `UPDATE products SET stock = stock + $1, updated_at = $2, version = version + 1 WHERE id = $3`
+	id := item.ProductID
+	if item.VariantID != nil {
+		query = `UPDATE product_variants SET stock = stock + $1, updated_at = $2 WHERE id = $3`
+		id = *item.VariantID
+	}
+
+	if _, err := tx.ExecContext(ctx, query, quantity, now, id); err != nil {
+		logger.Error("Failed to restock order item", zap.Int64("productID", item.ProductID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+	return nil
+}
+
+// checkCoupon locks the order's coupon and checks that the user may still
+// redeem it
+func (r *orderRepository) checkCoupon(ctx context.Context, tx *sql.Tx, order *domain.Order, now time.Time) error {
+	coupon := domain.Coupon{Code: order.CouponCode}
+	var maxUses, maxUsesPerUser sql.NullInt64
+	var startsAt, endsAt sql.NullTime
+
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT active, starts_at, ends_at, max_uses, max_uses_per_user FROM coupons WHERE id = $1 FOR UPDATE`,
+		*order.CouponID,
+	).Scan(&coupon.Active, &startsAt, &endsAt, &maxUses, &maxUsesPerUser)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.NewCouponError(order.CouponCode, domain.CouponRejectionUnknown)
+		}
+		r.logger.Error("Failed to lock coupon", zap.Int64("couponID", *order.CouponID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	if startsAt.Valid {
+		coupon.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		coupon.EndsAt = &endsAt.Time
+	}
+	if maxUses.Valid {
+		n := int(maxUses.Int64)
+		coupon.MaxUses = &n
+	}
+	if maxUsesPerUser.Valid {
+		n := int(maxUsesPerUser.Int64)
+		coupon.MaxUsesPerUser = &n
+	}
+
+	usage, err := countCouponUses(ctx, tx, *order.CouponID, order.UserID)
+	if err != nil {
+		r.logger.Error("Failed to count coupon uses", zap.Int64("couponID", *order.CouponID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	return coupon.CheckRedeemable(now, usage)
+}
+
+// setOrderAmounts completes the amounts of a scanned order with its
+// currency and sets its coupon code
+func setOrderAmounts(order *domain.Order, couponCode sql.NullString) {
+	order.Subtotal.Currency = order.TotalAmount.Currency
+	order.DiscountAmount.Currency = order.TotalAmount.Currency
+	order.CouponCode = couponCode.String
+}
+
+// rollback rolls back tx unless it has already been committed
+func (r *orderRepository) rollback(tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		r.logger.Error("Failed to rollback transaction", zap.Error(err))
+	}
+}
@@ -0,0 +1,290 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+)
+
+// TestOrderRepository_Create_ConcurrentStock tests that concurrent orders never oversell stock
+func TestOrderRepository_Create_ConcurrentStock(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewOrderRepository(db, logger.NewLogger("error"))
+
+	const stock = 10
+	const buyers = 40
+
+	userID := seedUser(t, db)
+	scarceID := seedProduct(t, db, stock)
+	plentyID := seedProduct(t, db, buyers*2)
+
+	var succeeded int64
+	var wg sync.WaitGroup
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			// Alternate the item order so reservations would deadlock
+			// unless rows are locked in a deterministic order
+			items := []domain.OrderItem{
+				{ProductID: scarceID, Quantity: 1, Price: domain.NewMoney(1000, domain.CurrencyUSD)},
+				{ProductID: plentyID, Quantity: 1, Price: domain.NewMoney(1000, domain.CurrencyUSD)},
+			}
+			if i%2 == 1 {
+				items[0], items[1] = items[1], items[0]
+			}
+
+			order := &domain.Order{
+				UserID:        userID,
+				Status:        domain.OrderStatusPending,
+				TotalAmount:   domain.NewMoney(2000, domain.CurrencyUSD),
+				PaymentMethod: domain.PaymentMethodCreditCard,
+				Items:         items,
+			}
+			if err := repo.Create(context.Background(), order); err == nil {
+				atomic.AddInt64(&succeeded, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if succeeded != stock {
+		t.Errorf("Expected %d orders to succeed, got %d", stock, succeeded)
+	}
+
+	var remaining int
+	if err := db.QueryRow(`SELECT stock FROM products WHERE id = $1`, scarceID).Scan(&remaining); err != nil {
+		t.Fatalf("Failed to read stock: %v", err)
+	}
+	if remaining != 0 {
+		t.Errorf("Expected stock 0, got %d", remaining)
+	}
+
+	if err := db.QueryRow(`SELECT stock FROM products WHERE id = $1`, plentyID).Scan(&remaining); err != nil {
+		t.Fatalf("Failed to read stock: %v", err)
+	}
+	if remaining != buyers*2-stock {
+		t.Errorf("Expected stock %d, got %d", buyers*2-stock, remaining)
+	}
+}
+
+// TestProductRepository_UpdateStock_Concurrent tests that concurrent decrements never go below zero
+func TestProductRepository_UpdateStock_Concurrent(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewProductRepository(db, logger.NewLogger("error"))
+
+	const stock = 5
+	productID := seedProduct(t, db, stock)
+
+	var succeeded int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := repo.UpdateStock(context.Background(), productID, -1); err == nil {
+				atomic.AddInt64(&succeeded, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != stock {
+		t.Errorf("Expected %d decrements to succeed, got %d", stock, succeeded)
+	}
+
+	var remaining int
+	if err := db.QueryRow(`SELECT stock FROM products WHERE id = $1`, productID).Scan(&remaining); err != nil {
+		t.Fatalf("Failed to read stock: %v", err)
+	}
+	if remaining != 0 {
+		t.Errorf("Expected stock 0, got %d", remaining)
+	}
+}
+
+// TestOrderRepository_UpdateStatus tests that transitions are enforced and recorded
+func TestOrderRepository_UpdateStatus(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewOrderRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	userID := seedUser(t, db)
+	productID := seedProduct(t, db, 1)
+
+	order := createTestOrder(t, repo, userID, productID, 1)
+
+	change := &domain.OrderStatusHistory{OrderID: order.ID, ToStatus: domain.OrderStatusProcessing, ActorID: &userID, Reason: "paid"}
+	if err := repo.UpdateStatus(ctx, change, 0); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if change.FromStatus != domain.OrderStatusPending {
+		t.Errorf("Expected from status %s, got %s", domain.OrderStatusPending, change.FromStatus)
+	}
+
+	err := repo.UpdateStatus(ctx, &domain.OrderStatusHistory{OrderID: order.ID, ToStatus: domain.OrderStatusPending}, 0)
+	if !errors.Is(err, domain.ErrInvalidStatusTransition) {
+		t.Errorf("Expected ErrInvalidStatusTransition, got %v", err)
+	}
+
+	history, err := repo.GetStatusHistory(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 history entries, got %d", len(history))
+	}
+	if history[0].FromStatus != "" || history[0].ToStatus != domain.OrderStatusPending {
+		t.Errorf("Expected initial entry to pending, got %s -> %s", history[0].FromStatus, history[0].ToStatus)
+	}
+	if history[1].ToStatus != domain.OrderStatusProcessing || history[1].Reason != "paid" {
+		t.Errorf("Expected processing entry with reason, got %+v", history[1])
+	}
+}
+
+// TestOrderRepository_Update_Version tests that stale updates, status changes, item cancellations and deletes change nothing
+func TestOrderRepository_Update_Version(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewOrderRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	userID := seedUser(t, db)
+	productID := seedProduct(t, db, 1)
+	order := createTestOrder(t, repo, userID, productID, 1)
+
+	stale, err := repo.FindByID(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := repo.UpdateStatus(ctx, &domain.OrderStatusHistory{OrderID: order.ID, ToStatus: domain.OrderStatusProcessing}, order.Version); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Neither the status change nor the payment method of a stale update applies
+	stale.PaymentMethod = domain.PaymentMethodPayPal
+	change := &domain.OrderStatusHistory{ToStatus: domain.OrderStatusCancelled}
+	var conflict *domain.VersionConflictError
+	if err := repo.UpdateWithStatus(ctx, stale, change); !errors.As(err, &conflict) || conflict.Version != order.Version {
+		t.Fatalf("Expected VersionConflictError at version %d, got %v", order.Version, err)
+	}
+	if err := repo.UpdateStatus(ctx, change, order.Version); !errors.Is(err, domain.ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict, got %v", err)
+	}
+	if err := repo.DeleteVersion(ctx, order.ID, order.Version); !errors.Is(err, domain.ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict, got %v", err)
+	}
+	if err := repo.CancelOrderItem(ctx, order.ID, order.Items[0].ID, 1, order.Version); !errors.Is(err, domain.ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict, got %v", err)
+	}
+
+	stored, err := repo.FindByID(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stored.Status != domain.OrderStatusProcessing || stored.PaymentMethod != domain.PaymentMethodCreditCard || stored.Version != order.Version+1 {
+		t.Errorf("Expected the processing order unchanged at version %d, got %+v", order.Version+1, stored)
+	}
+	assertStock(t, db, productID, 0)
+
+	// The status change and the update apply together
+	stored.PaymentMethod = domain.PaymentMethodPayPal
+	if err := repo.UpdateWithStatus(ctx, stored, &domain.OrderStatusHistory{ToStatus: domain.OrderStatusCompleted}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stored.Version != order.Version+3 {
+		t.Errorf("Expected version %d, got %d", order.Version+3, stored.Version)
+	}
+}
+
+// TestOrderRepository_Restock tests that cancelling and deleting orders restock exactly once
+func TestOrderRepository_Restock(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewOrderRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	userID := seedUser(t, db)
+	productID := seedProduct(t, db, 10)
+	order := createTestOrder(t, repo, userID, productID, 4)
+	assertStock(t, db, productID, 6)
+
+	// Cancelling 1 unit twice is the same request and restocks once
+	for i := 0; i < 2; i++ {
+		if err := repo.CancelOrderItem(ctx, order.ID, order.Items[0].ID, 1, 0); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	assertStock(t, db, productID, 7)
+
+	if err := repo.CancelOrderItem(ctx, order.ID, order.Items[0].ID, 5, 0); err == nil {
+		t.Error("Expected error for cancelling more than ordered, got nil")
+	}
+
+	var total int64
+	if err := db.QueryRow(`SELECT total_amount FROM orders WHERE id = $1`, order.ID).Scan(&total); err != nil {
+		t.Fatalf("Failed to read total: %v", err)
+	}
+	if total != 3000 {
+		t.Errorf("Expected total 3000, got %d", total)
+	}
+
+	if err := repo.UpdateStatus(ctx, &domain.OrderStatusHistory{OrderID: order.ID, ToStatus: domain.OrderStatusCancelled}, 0); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	assertStock(t, db, productID, 10)
+
+	// Deleting the cancelled order must not restock again
+	if err := repo.Delete(ctx, order.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	assertStock(t, db, productID, 10)
+
+	// Deleting an active order restocks it
+	order = createTestOrder(t, repo, userID, productID, 2)
+	assertStock(t, db, productID, 8)
+	if err := repo.Delete(ctx, order.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	assertStock(t, db, productID, 10)
+}
+
+// createTestOrder creates a pending order for quantity units of a product priced at 10.00 USD
+func createTestOrder(t *testing.T, repo domain.OrderRepository, userID, productID int64, quantity int) *domain.Order {
+	t.Helper()
+
+	price := domain.NewMoney(1000, domain.CurrencyUSD)
+	total, _ := price.Mul(int64(quantity))
+	order := &domain.Order{
+		UserID:        userID,
+		Status:        domain.OrderStatusPending,
+		Subtotal:      total,
+		TotalAmount:   total,
+		PaymentMethod: domain.PaymentMethodCreditCard,
+		Items: []domain.OrderItem{
+			{ProductID: productID, Quantity: quantity, Price: price},
+		},
+	}
+	if err := repo.Create(context.Background(), order); err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+	return order
+}
+
+// assertStock checks the current stock of a product
+func assertStock(t *testing.T, db *sql.DB, productID int64, want int) {
+	t.Helper()
+
+	var stock int
+	if err := db.QueryRow(`SELECT stock FROM products WHERE id = $1`, productID).Scan(&stock); err != nil {
+		t.Fatalf("Failed to read stock: %v", err)
+	}
+	if stock != want {
+		t.Errorf("Expected stock %d, got %d", want, stock)
+	}
+}
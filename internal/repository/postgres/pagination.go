@@ -0,0 +1,153 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+)
+
+// sortKey is a column a list is ordered by
+type sortKey struct {
+	column     string
+	descending bool
+}
+
+// idOrder orders a list by a unique ID column alone
+func idOrder(column string) []sortKey {
+	return []sortKey{{column: column}}
+}
+
+// pageQuery completes a SELECT statement with the filter conditions,
+// ordering and limit of a page request. The last sort key must be a unique
+// column, so that a cursor identifies an exact position: the cursor's
+// Values hold the other keys and its ID the unique one. One row more than
+// the limit is fetched to tell whether another page follows.
+func pageQuery(selectFrom string, keys []sortKey, conditions []string, args []interface{}, page domain.PageRequest) (string, []interface{}, error) {
+	conditions = append([]string(nil), conditions...)
+	args = append([]interface{}(nil), args...)
+
+	// Read backwards from a before cursor; newPage restores the order
+	backwards := page.After == nil && page.Before != nil
+	if cursor := page.Cursor(); cursor != nil {
+		if len(cursor.Values) != len(keys)-1 {
+			return "", nil, domain.ErrInvalidCursor
+		}
+		var condition string
+		condition, args = keysetCondition(keys, cursor, backwards, args)
+		conditions = append(conditions, condition)
+	}
+
+	order := make([]string, len(keys))
+	for i, key := range keys {
+		order[i] = key.column + " ASC"
+		if key.descending != backwards {
+			order[i] = key.column + " DESC"
+		}
+	}
+
+	query := selectFrom + whereClause(conditions)
+	args = append(args, page.Limit+1)
+	query += fmt.Sprintf(" ORDER BY %s LIMIT $%d", strings.Join(order, ", "), len(args))
+	if !page.IsKeyset() && page.Offset > 0 {
+		args = append(args, page.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	return query, args, nil
+}
+
+// keysetCondition matches the rows that come after the cursor in the sort
+// order, or before it when reading backwards. Cursor values are passed as
+// text and converted by PostgreSQL to the type of the column.
+func keysetCondition(keys []sortKey, cursor *domain.Cursor, backwards bool, args []interface{}) (string, []interface{}) {
+	placeholders := make([]string, len(keys))
+	for i := range keys {
+		if i < len(cursor.Values) {
+			args = append(args, cursor.Values[i])
+		} else {
+			args = append(args, cursor.ID)
+		}
+		placeholders[i] = fmt.Sprintf("$%d", len(args))
+	}
+
+	// A row follows the cursor if it ties on the first i keys and is past
+	// the cursor on key i, for some i
+	alternatives := make([]string, len(keys))
+	for i, key := range keys {
+		parts := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			parts = append(parts, keys[j].column+" = "+placeholders[j])
+		}
+		operator := ">"
+		if key.descending != backwards {
+			operator = "<"
+		}
+		parts = append(parts, key.column+" "+operator+" "+placeholders[i])
+		alternatives[i] = strings.Join(parts, " AND ")
+	}
+
+	if len(alternatives) == 1 {
+		return alternatives[0], args
+	}
+	return "((" + strings.Join(alternatives, ") OR (") + "))", args
+}
+
+// countRows counts the rows matching the filter conditions of a list
+func countRows(ctx context.Context, db *sql.DB, from string, conditions []string, args []interface{}) (int, error) {
+	var total int
+	query := "SELECT COUNT(*) FROM " + from + whereClause(conditions)
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// newPage drops the extra row fetched by pageQuery and sets the cursors of
+// the neighbouring pages
+func newPage[T any](items []T, page domain.PageRequest, cursor func(T) domain.Cursor) *domain.Page[T] {
+	hasMore := len(items) > page.Limit
+	if hasMore {
+		items = items[:page.Limit]
+	}
+	backwards := page.After == nil && page.Before != nil
+	if backwards {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	result := &domain.Page[T]{Items: items}
+	if len(items) == 0 {
+		return result
+	}
+
+	first := cursor(items[0])
+	last := cursor(items[len(items)-1])
+	if backwards {
+		// The cursor row itself follows this page
+		result.Next = &last
+		if hasMore {
+			result.Prev = &first
+		}
+		return result
+	}
+
+	if hasMore {
+		result.Next = &last
+	}
+	if page.After != nil || page.Offset > 0 {
+		result.Prev = &first
+	}
+	return result
+}
+
+// whereClause joins filter conditions into a WHERE clause
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
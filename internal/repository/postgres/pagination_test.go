@@ -0,0 +1,228 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+)
+
+// TestPageQuery tests the clauses added for offset and cursor pagination
+func TestPageQuery(t *testing.T) {
+	byPrice := []sortKey{{column: "t.price", descending: true}, {column: "t.name"}, {column: "t.id"}}
+	tests := []struct {
+		name      string
+		keys      []sortKey
+		page      domain.PageRequest
+		wantQuery string
+		wantArgs  []interface{}
+		wantErr   bool
+	}{
+		{
+			name:      "offset",
+			page:      domain.PageRequest{Limit: 10, Offset: 20},
+			wantQuery: "SELECT * FROM t WHERE t.a = $1 ORDER BY t.id ASC LIMIT $2 OFFSET $3",
+			wantArgs:  []interface{}{"x", 11, 20},
+		},
+		{
+			name:      "after",
+			page:      domain.PageRequest{Limit: 10, Offset: 20, After: &domain.Cursor{ID: 5}},
+			wantQuery: "SELECT * FROM t WHERE t.a = $1 AND t.id > $2 ORDER BY t.id ASC LIMIT $3",
+			wantArgs:  []interface{}{"x", int64(5), 11},
+		},
+		{
+			name:      "before",
+			page:      domain.PageRequest{Limit: 10, Before: &domain.Cursor{ID: 5}},
+			wantQuery: "SELECT * FROM t WHERE t.a = $1 AND t.id < $2 ORDER BY t.id DESC LIMIT $3",
+			wantArgs:  []interface{}{"x", int64(5), 11},
+		},
+		{
+			name:      "sorted offset",
+			keys:      byPrice,
+			page:      domain.PageRequest{Limit: 10},
+			wantQuery: "SELECT * FROM t WHERE t.a = $1 ORDER BY t.price DESC, t.name ASC, t.id ASC LIMIT $2",
+			wantArgs:  []interface{}{"x", 11},
+		},
+		{
+			name: "sorted after",
+			keys: byPrice,
+			page: domain.PageRequest{Limit: 10, After: &domain.Cursor{ID: 5, Values: []string{"1999", "Lamp"}}},
+			wantQuery: "SELECT * FROM t WHERE t.a = $1 AND " +
+				"((t.price < $2) OR (t.price = $2 AND t.name > $3) OR (t.price = $2 AND t.name = $3 AND t.id > $4)) " +
+				"ORDER BY t.price DESC, t.name ASC, t.id ASC LIMIT $5",
+			wantArgs: []interface{}{"x", "1999", "Lamp", int64(5), 11},
+		},
+		{
+			name: "sorted before",
+			keys: byPrice,
+			page: domain.PageRequest{Limit: 10, Before: &domain.Cursor{ID: 5, Values: []string{"1999", "Lamp"}}},
+			wantQuery: "SELECT * FROM t WHERE t.a = $1 AND " +
+				"((t.price > $2) OR (t.price = $2 AND t.name < $3) OR (t.price = $2 AND t.name = $3 AND t.id < $4)) " +
+				"ORDER BY t.price ASC, t.name DESC, t.id DESC LIMIT $5",
+			wantArgs: []interface{}{"x", "1999", "Lamp", int64(5), 11},
+		},
+		{
+			name:    "cursor of another order",
+			keys:    byPrice,
+			page:    domain.PageRequest{Limit: 10, After: &domain.Cursor{ID: 5}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := tt.keys
+			if keys == nil {
+				keys = idOrder("t.id")
+			}
+			query, args, err := pageQuery("SELECT * FROM t", keys, []string{"t.a = $1"}, []interface{}{"x"}, tt.page)
+			if tt.wantErr {
+				if !errors.Is(err, domain.ErrInvalidCursor) {
+					t.Errorf("Expected ErrInvalidCursor, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if query != tt.wantQuery {
+				t.Errorf("Expected query %q, got %q", tt.wantQuery, query)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("Expected args %v, got %v", tt.wantArgs, args)
+			}
+		})
+	}
+}
+
+// TestNewPage tests trimming the extra row and setting neighbouring cursors
+func TestNewPage(t *testing.T) {
+	cursor := func(i int64) domain.Cursor { return domain.Cursor{ID: i} }
+	after := &domain.Cursor{ID: 1}
+	before := &domain.Cursor{ID: 9}
+
+	tests := []struct {
+		name     string
+		items    []int64
+		page     domain.PageRequest
+		want     []int64
+		wantNext int64
+		wantPrev int64
+	}{
+		{name: "first page", items: []int64{1, 2, 3}, page: domain.PageRequest{Limit: 2}, want: []int64{1, 2}, wantNext: 2},
+		{name: "only page", items: []int64{1, 2}, page: domain.PageRequest{Limit: 2}, want: []int64{1, 2}},
+		{name: "offset page", items: []int64{3, 4}, page: domain.PageRequest{Limit: 2, Offset: 2}, want: []int64{3, 4}, wantPrev: 3},
+		{name: "after", items: []int64{2, 3, 4}, page: domain.PageRequest{Limit: 2, After: after}, want: []int64{2, 3}, wantNext: 3, wantPrev: 2},
+		{name: "before", items: []int64{8, 7, 6}, page: domain.PageRequest{Limit: 2, Before: before}, want: []int64{7, 8}, wantNext: 8, wantPrev: 7},
+		{name: "before first page", items: []int64{2, 1}, page: domain.PageRequest{Limit: 2, Before: before}, want: []int64{1, 2}, wantNext: 2},
+		{name: "empty", page: domain.PageRequest{Limit: 2, After: after}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := newPage(tt.items, tt.page, cursor)
+			if len(page.Items) != len(tt.want) || (len(tt.want) > 0 && !reflect.DeepEqual(page.Items, tt.want)) {
+				t.Errorf("Expected items %v, got %v", tt.want, page.Items)
+			}
+			if got := cursorID(page.Next); got != tt.wantNext {
+				t.Errorf("Expected next cursor %d, got %d", tt.wantNext, got)
+			}
+			if got := cursorID(page.Prev); got != tt.wantPrev {
+				t.Errorf("Expected prev cursor %d, got %d", tt.wantPrev, got)
+			}
+		})
+	}
+}
+
+// TestProductRepository_FindByCategory_Cursor tests walking a list forwards and backwards by cursor
+func TestProductRepository_FindByCategory_Cursor(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewProductRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	categoryID := seedCategory(t, db)
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		var id int64
+		err := db.QueryRow(
+			`INSERT INTO products (name, description, price, currency, sku, stock, category_id, created_at, updated_at)
+			VALUES ($1, '', 1000, 'USD', $1, 1, $2, $3, $3) RETURNING id`,
+			uniqueName("product"), categoryID, time.Now().UTC(),
+		).Scan(&id)
+		if err != nil {
+			t.Fatalf("Failed to seed product: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	// Forwards from the first page
+	var seen []int64
+	page := domain.PageRequest{Limit: 2}
+	var last *domain.Page[domain.Product]
+	for {
+		result, err := repo.FindByCategory(ctx, categoryID, false, page)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if result.Total == nil || *result.Total != len(ids) {
+			t.Errorf("Expected total %d, got %v", len(ids), result.Total)
+		}
+		for _, product := range result.Items {
+			seen = append(seen, product.ID)
+		}
+		last = result
+		if result.Next == nil {
+			break
+		}
+		page = domain.PageRequest{Limit: 2, After: result.Next}
+	}
+	if !reflect.DeepEqual(seen, ids) {
+		t.Fatalf("Expected %v walking forwards, got %v", ids, seen)
+	}
+
+	// Backwards from the last page, without counting
+	seen = nil
+	for _, product := range last.Items {
+		seen = append(seen, product.ID)
+	}
+	page = domain.PageRequest{Limit: 2, Before: last.Prev, SkipTotal: true}
+	for page.Before != nil {
+		result, err := repo.FindByCategory(ctx, categoryID, false, page)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if result.Total != nil {
+			t.Errorf("Expected no total, got %d", *result.Total)
+		}
+		var pageIDs []int64
+		for _, product := range result.Items {
+			pageIDs = append(pageIDs, product.ID)
+		}
+		seen = append(pageIDs, seen...)
+		page.Before = result.Prev
+	}
+	if !reflect.DeepEqual(seen, ids) {
+		t.Errorf("Expected %v walking backwards, got %v", ids, seen)
+	}
+}
+
+// cursorID returns the ID of a cursor, or 0 for nil
+func cursorID(cursor *domain.Cursor) int64 {
+	if cursor == nil {
+		return 0
+	}
+	return cursor.ID
+}
+
+// TestQualifiedColumns tests prefixing a shared column list with a table alias
+func TestQualifiedColumns(t *testing.T) {
+	got := qualifiedColumns("a", categoryColumns)
+	want := "a.id, a.name, a.description, a.slug, a.parent_id, a.path, a.created_at, a.updated_at, a.version"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
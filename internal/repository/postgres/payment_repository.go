@@ -0,0 +1,271 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	pkgerrors "github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// paymentColumns are the columns read by scanPayment
+const paymentColumns = `id, order_id, provider, method, status, amount, captured_amount, currency,
+	provider_reference, failure_code, failure_message, created_at, updated_at`
+
+type paymentRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// NewPaymentRepository creates a new payment repository
+func NewPaymentRepository(db *sql.DB, logger logger.Logger) domain.PaymentRepository {
+	return &paymentRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// FindByID finds a payment by ID
+func (r *paymentRepository) FindByID(ctx context.Context, id int64) (*domain.Payment, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+paymentColumns+` FROM payments WHERE id = $1`, id)
+
+	payment, err := scanPayment(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, pkgerrors.NewNotFoundError("Payment", id)
+		}
+		r.logger.Error("Failed to find payment by ID", zap.Int64("id", id), zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
+	}
+
+	return payment, nil
+}
+
+// FindByOrderID finds the payments of an order, oldest first
+func (r *paymentRepository) FindByOrderID(ctx context.Context, orderID int64) ([]domain.Payment, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+paymentColumns+` FROM payments WHERE order_id = $1 ORDER BY id`, orderID)
+	if err != nil {
+		r.logger.Error("Failed to find payments by order ID", zap.Int64("orderID", orderID), zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
+	}
+	defer rows.Close()
+
+	payments := []domain.Payment{}
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan payment", zap.Error(err))
+			return nil, pkgerrors.NewInternalError(err)
+		}
+		payments = append(payments, *payment)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Error iterating payment rows", zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
+	}
+
+	return payments, nil
+}
+
+// Create records a new payment attempt. An order can only have one active
+// payment at a time.
+func (r *paymentRepository) Create(ctx context.Context, payment *domain.Payment) error {
+	query := `
+		INSERT INTO payments (order_id, provider, method, status, amount, captured_amount, currency, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+		RETURNING id
+	`
+
+	now := time.Now().UTC()
+	payment.CreatedAt = now
+	payment.UpdatedAt = now
+
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		payment.OrderID,
+		payment.Provider,
+		payment.Method,
+		payment.Status,
+		payment.Amount.Amount,
+		payment.CapturedAmount.Amount,
+		payment.Amount.Currency,
+		now,
+	).Scan(&payment.ID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return pkgerrors.NewAppError(pkgerrors.ErrConflict, "Order already has an active payment", http.StatusConflict)
+		}
+		r.logger.Error("Failed to create payment", zap.Int64("orderID", payment.OrderID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	return nil
+}
+
+// Update stores the status, reference, captured amount and failure of a
+// payment that has moved on from status from
+func (r *paymentRepository) Update(ctx context.Context, payment *domain.Payment, from domain.PaymentStatus) error {
+	payment.UpdatedAt = time.Now().UTC()
+	return r.update(ctx, r.db, payment, from)
+}
+
+// Capture stores a captured payment and moves its order from pending to
+// processing. An order that has moved on in the meantime keeps its status,
+// since the money has been collected either way.
+func (r *paymentRepository) Capture(ctx context.Context, payment *domain.Payment, actorID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+	defer r.rollback(tx)
+
+	var status domain.OrderStatus
+	err = tx.QueryRowContext(ctx, `SELECT status FROM orders WHERE id = $1 FOR UPDATE`, payment.OrderID).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return pkgerrors.NewNotFoundError("Order", payment.OrderID)
+		}
+		r.logger.Error("Failed to get order status", zap.Int64("id", payment.OrderID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	now := time.Now().UTC()
+	payment.UpdatedAt = now
+	if err = r.update(ctx, tx, payment, domain.PaymentStatusAuthorized); err != nil {
+		return err
+	}
+
+	if status.CanTransitionTo(domain.OrderStatusProcessing) {
+		_, err = tx.ExecContext(ctx, `UPDATE orders SET status = $1, updated_at = $2, version = version + 1 WHERE id = $3`, domain.OrderStatusProcessing, now, payment.OrderID)
+		if err != nil {
+			r.logger.Error("Failed to update order status", zap.Int64("id", payment.OrderID), zap.Error(err))
+			return pkgerrors.NewInternalError(err)
+		}
+
+		err = insertStatusHistory(ctx, tx, r.logger, &domain.OrderStatusHistory{
+			OrderID:    payment.OrderID,
+			FromStatus: status,
+			ToStatus:   domain.OrderStatusProcessing,
+			ActorID:    &actorID,
+			Reason:     "Payment captured",
+			CreatedAt:  now,
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		r.logger.Warn("Payment captured for an order that is no longer pending", zap.Int64("orderID", payment.OrderID), zap.String("status", string(status)))
+	}
+
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	return nil
+}
+
+// execer is satisfied by *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// update stores the mutable fields of a payment, provided it is still in
+// status from. A payment that has moved on in the meantime, or is gone, is a
+// conflict.
+func (r *paymentRepository) update(ctx context.Context, db execer, payment *domain.Payment, from domain.PaymentStatus) error {
+	if !from.CanTransitionTo(payment.Status) {
+		return pkgerrors.NewAppError(pkgerrors.ErrConflict, fmt.Sprintf("Payment cannot move from %s to %s", from, payment.Status), http.StatusConflict)
+	}
+
+	query := `
+		UPDATE payments
+		SET status = $1, captured_amount = $2, provider_reference = $3, failure_code = $4, failure_message = $5, updated_at = $6
+		WHERE id = $7 AND status = $8
+	`
+
+	result, err := db.ExecContext(
+		ctx,
+		query,
+		payment.Status,
+		payment.CapturedAmount.Amount,
+		nullString(payment.ProviderReference),
+		nullString(payment.FailureCode),
+		nullString(payment.FailureMessage),
+		payment.UpdatedAt,
+		payment.ID,
+		from,
+	)
+	if err != nil {
+		r.logger.Error("Failed to update payment", zap.Int64("id", payment.ID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		return pkgerrors.NewAppError(pkgerrors.ErrConflict, fmt.Sprintf("Payment %d is no longer %s", payment.ID, from), http.StatusConflict)
+	}
+
+	return nil
+}
+
+// rollback rolls back tx unless it has already been committed
+func (r *paymentRepository) rollback(tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		r.logger.Error("Failed to rollback transaction", zap.Error(err))
+	}
+}
+
+// nullString stores an empty string as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// scanPayment scans a row of paymentColumns
+func scanPayment(row rowScanner) (*domain.Payment, error) {
+	var payment domain.Payment
+	var currency domain.Currency
+	var reference, failureCode, failureMessage sql.NullString
+
+	err := row.Scan(
+		&payment.ID,
+		&payment.OrderID,
+		&payment.Provider,
+		&payment.Method,
+		&payment.Status,
+		&payment.Amount.Amount,
+		&payment.CapturedAmount.Amount,
+		&currency,
+		&reference,
+		&failureCode,
+		&failureMessage,
+		&payment.CreatedAt,
+		&payment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	payment.Amount.Currency = currency
+	payment.CapturedAmount.Currency = currency
+	payment.ProviderReference = reference.String
+	payment.FailureCode = failureCode.String
+	payment.FailureMessage = failureMessage.String
+
+	return &payment, nil
+}
@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+)
+
+// TestPaymentRepository_Capture tests that capturing a payment moves its order to processing
+func TestPaymentRepository_Capture(t *testing.T) {
+	db := openTestDB(t)
+	orderRepo := NewOrderRepository(db, logger.NewLogger("error"))
+	repo := NewPaymentRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	userID := seedUser(t, db)
+	order := createTestOrder(t, orderRepo, userID, seedProduct(t, db, 5), 1)
+
+	payment := &domain.Payment{
+		OrderID:        order.ID,
+		Provider:       "fake",
+		Method:         order.PaymentMethod,
+		Status:         domain.PaymentStatusPending,
+		Amount:         order.TotalAmount,
+		CapturedAmount: domain.NewMoney(0, order.TotalAmount.Currency),
+	}
+	if err := repo.Create(ctx, payment); err != nil {
+		t.Fatalf("Failed to create payment: %v", err)
+	}
+
+	payment.Status = domain.PaymentStatusAuthorized
+	payment.ProviderReference = "fake_auth_000001"
+	if err := repo.Update(ctx, payment, domain.PaymentStatusPending); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Only one active payment per order
+	second := *payment
+	if err := repo.Create(ctx, &second); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("Expected conflict, got %v", err)
+	}
+
+	payment.Status = domain.PaymentStatusCaptured
+	payment.CapturedAmount = payment.Amount
+	if err := repo.Capture(ctx, payment, userID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// A void racing the capture finds the payment no longer authorized
+	voided := *payment
+	voided.Status = domain.PaymentStatusVoided
+	voided.CapturedAmount = domain.NewMoney(0, payment.Amount.Currency)
+	if err := repo.Update(ctx, &voided, domain.PaymentStatusAuthorized); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("Expected conflict, got %v", err)
+	}
+
+	stored, err := repo.FindByID(ctx, payment.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stored.Status != domain.PaymentStatusCaptured || stored.CapturedAmount != payment.Amount || stored.ProviderReference != "fake_auth_000001" {
+		t.Errorf("Expected the captured payment, got %+v", stored)
+	}
+
+	history, err := orderRepo.GetStatusHistory(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	last := history[len(history)-1]
+	if last.FromStatus != domain.OrderStatusPending || last.ToStatus != domain.OrderStatusProcessing || last.Reason != "Payment captured" {
+		t.Errorf("Expected pending -> processing on capture, got %+v", last)
+	}
+}
+
+// TestPaymentRepository_OpenPayment tests that an authorized payment keeps the order total and status fixed
+func TestPaymentRepository_OpenPayment(t *testing.T) {
+	db := openTestDB(t)
+	orderRepo := NewOrderRepository(db, logger.NewLogger("error"))
+	repo := NewPaymentRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	userID := seedUser(t, db)
+	order := createTestOrder(t, orderRepo, userID, seedProduct(t, db, 5), 2)
+
+	payment := &domain.Payment{
+		OrderID:        order.ID,
+		Provider:       "fake",
+		Method:         order.PaymentMethod,
+		Status:         domain.PaymentStatusAuthorized,
+		Amount:         order.TotalAmount,
+		CapturedAmount: domain.NewMoney(0, order.TotalAmount.Currency),
+	}
+	if err := repo.Create(ctx, payment); err != nil {
+		t.Fatalf("Failed to create payment: %v", err)
+	}
+
+	if err := orderRepo.CancelOrderItem(ctx, order.ID, order.Items[0].ID, 1, 0); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("Expected conflict when cancelling an item, got %v", err)
+	}
+	cancel := &domain.OrderStatusHistory{OrderID: order.ID, ToStatus: domain.OrderStatusCancelled}
+	if err := orderRepo.UpdateStatus(ctx, cancel, 0); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("Expected conflict when cancelling the order, got %v", err)
+	}
+
+	payment.Status = domain.PaymentStatusVoided
+	if err := repo.Update(ctx, payment, domain.PaymentStatusAuthorized); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := orderRepo.UpdateStatus(ctx, cancel, 0); err != nil {
+		t.Errorf("Expected the order to be cancelled once the payment is voided, got %v", err)
+	}
+}
+
+// TestOrderRepository_Delete_Payments tests that orders with payments cannot be deleted
+func TestOrderRepository_Delete_Payments(t *testing.T) {
+	db := openTestDB(t)
+	orderRepo := NewOrderRepository(db, logger.NewLogger("error"))
+	repo := NewPaymentRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	userID := seedUser(t, db)
+	order := createTestOrder(t, orderRepo, userID, seedProduct(t, db, 5), 1)
+
+	payment := &domain.Payment{
+		OrderID:        order.ID,
+		Provider:       "fake",
+		Method:         order.PaymentMethod,
+		Status:         domain.PaymentStatusCaptured,
+		Amount:         order.TotalAmount,
+		CapturedAmount: order.TotalAmount,
+	}
+	if err := repo.Create(ctx, payment); err != nil {
+		t.Fatalf("Failed to create payment: %v", err)
+	}
+
+	if err := orderRepo.Delete(ctx, order.ID); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("Expected conflict, got %v", err)
+	}
+	if _, err := repo.FindByID(ctx, payment.ID); err != nil {
+		t.Errorf("Expected the payment to be kept, got %v", err)
+	}
+
+	// The foreign key keeps the payment even when the check is bypassed
+	if _, err := db.ExecContext(ctx, `DELETE FROM orders WHERE id = $1`, order.ID); err == nil {
+		t.Error("Expected the order delete to be restricted")
+	}
+}
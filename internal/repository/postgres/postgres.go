@@ -1,8 +1,10 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	_ "github.com/lib/pq" // PostgreSQL driver
 	"github.com/milad-ahmd/go-clean-arch/pkg/config"
@@ -12,8 +14,9 @@ import (
 
 // NewPostgresConnection creates a new PostgreSQL connection
 func NewPostgresConnection(cfg *config.Config, logger logger.Logger) (*sql.DB, error) {
+	// Sessions use UTC so that TIMESTAMPTZ values are scanned as UTC times
 	dsn := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s timezone=UTC",
 		cfg.Database.Host,
 		cfg.Database.Port,
 		cfg.Database.User,
@@ -37,7 +40,36 @@ func NewPostgresConnection(cfg *config.Config, logger logger.Logger) (*sql.DB, e
 	return db, nil
 }
 
-// InitTables initializes the database tables
-func InitTables(db *sql.DB, logger logger.Logger) error {
-	return CreateTables(db, logger)
+// Migrate applies every pending embedded migration
+func Migrate(ctx context.Context, db *sql.DB, logger logger.Logger) error {
+	migrator, err := NewMigrator(db, logger)
+	if err != nil {
+		logger.Error("Failed to load migrations", zap.Error(err))
+		return err
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Database migrations are up to date", zap.Int("applied", applied))
+	return nil
+}
+
+// qualifiedColumns prefixes each column of a comma separated column list
+// with a table alias, so a list shared with a scan function can be selected
+// from a join
+func qualifiedColumns(alias, columns string) string {
+	names := strings.Split(columns, ",")
+	for i, name := range names {
+		names[i] = alias + "." + strings.TrimSpace(name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// rowScanner is a query result row, either a *sql.Row or the current row of
+// *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
 }
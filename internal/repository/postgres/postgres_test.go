@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+)
+
+// testDatabaseURLEnv names the variable holding the DSN of a disposable
+// database used by the integration tests in this package
+const testDatabaseURLEnv = "TEST_DATABASE_URL"
+
+// openTestDB connects to the integration test database and applies every
+// migration. Tests that need it are skipped when no database is configured.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv(testDatabaseURLEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDatabaseURLEnv)
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := Migrate(context.Background(), db, logger.NewLogger("error")); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	return db
+}
+
+// uniqueName returns a name that does not collide with earlier test runs
+func uniqueName(prefix string) string {
+	return fmt.Sprintf("%s_%d", prefix, time.Now().UnixNano())
+}
+
+// seedProduct inserts a category and a product with the given stock and returns the product ID
+func seedProduct(t *testing.T, db *sql.DB, stock int) int64 {
+	t.Helper()
+
+	now := time.Now().UTC()
+	name := uniqueName("product")
+	categoryID := seedCategory(t, db)
+
+	var productID int64
+	err := db.QueryRow(
+		`INSERT INTO products (name, description, price, currency, sku, stock, category_id, created_at, updated_at)
+		VALUES ($1, '', 1000, 'USD', $1, $2, $3, $4, $4) RETURNING id`,
+		name, stock, categoryID, now,
+	).Scan(&productID)
+	if err != nil {
+		t.Fatalf("Failed to seed product: %v", err)
+	}
+
+	return productID
+}
+
+// seedCategory inserts a top-level category and returns its ID
+func seedCategory(t *testing.T, db *sql.DB) int64 {
+	t.Helper()
+
+	now := time.Now().UTC()
+	name := uniqueName("category")
+
+	var categoryID int64
+	err := db.QueryRow(
+		`WITH next AS (SELECT nextval(pg_get_serial_sequence('categories', 'id')) AS id)
+		INSERT INTO categories (id, name, description, slug, path, created_at, updated_at)
+		SELECT id, $1, '', $1, '/' || id || '/', $2, $2 FROM next RETURNING id`,
+		name, now,
+	).Scan(&categoryID)
+	if err != nil {
+		t.Fatalf("Failed to seed category: %v", err)
+	}
+
+	return categoryID
+}
+
+// seedUser inserts a user and returns its ID
+func seedUser(t *testing.T, db *sql.DB) int64 {
+	t.Helper()
+
+	now := time.Now().UTC()
+	name := uniqueName("user")
+
+	var userID int64
+	err := db.QueryRow(
+		`INSERT INTO users (username, email, password, role, created_at, updated_at) VALUES ($1, $2, 'x', 'user', $3, $3) RETURNING id`,
+		name, name+"@example.com", now,
+	).Scan(&userID)
+	if err != nil {
+		t.Fatalf("Failed to seed user: %v", err)
+	}
+
+	return userID
+}
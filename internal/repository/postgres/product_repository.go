@@ -5,14 +5,65 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/milad-ahmd/go-clean-arch/internal/domain"
 	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
 	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
 	"go.uber.org/zap"
 )
 
+// productSelect and productFrom select the columns read by scanProduct
+const (
+	productSelect = `
+		SELECT p.id, p.name, p.description, p.price, p.currency, p.sku, p.stock, p.category_id, p.images, p.options, p.created_at, p.updated_at, p.version,
+			   c.id, c.name, c.description, c.slug, c.parent_id, c.path, c.created_at, c.updated_at, c.version,
+			   ` + productMinPrice + `, ` + productMaxPrice + `, ` + productAvailableStock
+	productFrom = `
+		FROM products p
+		LEFT JOIN categories c ON p.category_id = c.id` + productVariantJoin
+)
+
+// productVariantJoin summarises the variants of each product as pv. Every
+// product gets one row, with NULLs when it has no variants, so the summary
+// columns fall back to the product's own price and stock.
+const (
+	productVariantJoin = `
+		LEFT JOIN LATERAL (
+			SELECT MIN(COALESCE(v.price, p.price)) AS min_price, MAX(COALESCE(v.price, p.price)) AS max_price, SUM(v.stock) AS stock
+			FROM product_variants v
+			WHERE v.product_id = p.id
+		) pv ON true`
+	productMinPrice       = "COALESCE(pv.min_price, p.price)"
+	productMaxPrice       = "COALESCE(pv.max_price, p.price)"
+	productAvailableStock = "COALESCE(pv.stock, p.stock)"
+)
+
+// Product search matches the search query, the first query argument, against
+// the search_vector column. Websearch syntax accepts quoted phrases, "or" and
+// -word exclusions, and never fails to parse.
+const (
+	productSearchQuery      = "websearch_to_tsquery('english', $1)"
+	productSearchCondition  = "(p.search_vector @@ " + productSearchQuery + " OR p.name % $1 OR p.sku % $1)"
+	productSearchRank       = "ts_rank(p.search_vector, " + productSearchQuery + ")"
+	productSearchSimilarity = "GREATEST(similarity(p.name, $1), similarity(p.sku, $1))"
+
+	// productSearchSort names the relevance order in search cursors
+	productSearchSort = "relevance"
+)
+
+// productSortColumns maps the fields products can be sorted by to columns.
+// Products with variants sort by their lowest price and total stock.
+var productSortColumns = map[domain.ProductSortField]string{
+	domain.ProductSortID:        "p.id",
+	domain.ProductSortName:      "p.name",
+	domain.ProductSortPrice:     productMinPrice,
+	domain.ProductSortStock:     productAvailableStock,
+	domain.ProductSortCreatedAt: "p.created_at",
+}
+
 type productRepository struct {
 	db     *sql.DB
 	logger logger.Logger
@@ -28,37 +79,11 @@ func NewProductRepository(db *sql.DB, logger logger.Logger) domain.ProductReposi
 
 // FindByID finds a product by ID
 func (r *productRepository) FindByID(ctx context.Context, id int64) (*domain.Product, error) {
-	query := `
-		SELECT p.id, p.name, p.description, p.price, p.sku, p.stock, p.category_id, p.images, p.created_at, p.updated_at,
-			   c.id, c.name, c.description, c.slug, c.created_at, c.updated_at
-		FROM products p
-		LEFT JOIN categories c ON p.category_id = c.id
+	query := productSelect + productFrom + `
 		WHERE p.id = $1
 	`
 
-	var product domain.Product
-	var category domain.Category
-	var imagesJSON []byte
-
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&product.ID,
-		&product.Name,
-		&product.Description,
-		&product.Price,
-		&product.SKU,
-		&product.Stock,
-		&product.CategoryID,
-		&imagesJSON,
-		&product.CreatedAt,
-		&product.UpdatedAt,
-		&category.ID,
-		&category.Name,
-		&category.Description,
-		&category.Slug,
-		&category.CreatedAt,
-		&category.UpdatedAt,
-	)
-
+	product, err := scanProduct(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.NewNotFoundError("Product", id)
@@ -67,126 +92,62 @@ func (r *productRepository) FindByID(ctx context.Context, id int64) (*domain.Pro
 		return nil, errors.NewInternalError(err)
 	}
 
-	// Parse images JSON
-	if imagesJSON != nil {
-		if err := json.Unmarshal(imagesJSON, &product.Images); err != nil {
-			r.logger.Error("Failed to unmarshal product images", zap.Error(err))
-			return nil, errors.NewInternalError(err)
-		}
+	if product.Variants, err = r.FindVariants(ctx, product.ID); err != nil {
+		return nil, err
 	}
 
-	product.Category = category
-
 	return &product, nil
 }
 
-// FindAll finds all products with pagination
-func (r *productRepository) FindAll(ctx context.Context, limit, offset int) ([]domain.Product, int, error) {
-	query := `
-		SELECT p.id, p.name, p.description, p.price, p.sku, p.stock, p.category_id, p.images, p.created_at, p.updated_at,
-			   c.id, c.name, c.description, c.slug, c.created_at, c.updated_at
-		FROM products p
-		LEFT JOIN categories c ON p.category_id = c.id
-		ORDER BY p.id
-		LIMIT $1 OFFSET $2
-	`
-
-	rows, err := r.db.QueryContext(ctx, query, limit, offset)
-	if err != nil {
-		r.logger.Error("Failed to find all products", zap.Error(err))
-		return nil, 0, errors.NewInternalError(err)
-	}
-	defer rows.Close()
-
-	var products []domain.Product
-	for rows.Next() {
-		var product domain.Product
-		var category domain.Category
-		var imagesJSON []byte
-
-		if err := rows.Scan(
-			&product.ID,
-			&product.Name,
-			&product.Description,
-			&product.Price,
-			&product.SKU,
-			&product.Stock,
-			&product.CategoryID,
-			&imagesJSON,
-			&product.CreatedAt,
-			&product.UpdatedAt,
-			&category.ID,
-			&category.Name,
-			&category.Description,
-			&category.Slug,
-			&category.CreatedAt,
-			&category.UpdatedAt,
-		); err != nil {
-			r.logger.Error("Failed to scan product", zap.Error(err))
-			return nil, 0, errors.NewInternalError(err)
-		}
-
-		// Parse images JSON
-		if imagesJSON != nil {
-			if err := json.Unmarshal(imagesJSON, &product.Images); err != nil {
-				r.logger.Error("Failed to unmarshal product images", zap.Error(err))
-				return nil, 0, errors.NewInternalError(err)
-			}
-		}
-
-		product.Category = category
-		products = append(products, product)
-	}
-
-	if err := rows.Err(); err != nil {
-		r.logger.Error("Error iterating product rows", zap.Error(err))
-		return nil, 0, errors.NewInternalError(err)
-	}
-
-	// Get total count
-	var total int
-	countQuery := `SELECT COUNT(*) FROM products`
-	err = r.db.QueryRowContext(ctx, countQuery).Scan(&total)
-	if err != nil {
-		r.logger.Error("Failed to get total product count", zap.Error(err))
-		return nil, 0, errors.NewInternalError(err)
-	}
+// FindAll finds a page of all products
+func (r *productRepository) FindAll(ctx context.Context, page domain.PageRequest) (*domain.Page[domain.Product], error) {
+	return r.findPage(ctx, domain.ProductFilter{}, nil, nil, page)
+}
 
-	return products, total, nil
+// FindByFilter finds a page of the products matching filter, in its sort order
+func (r *productRepository) FindByFilter(ctx context.Context, filter domain.ProductFilter, page domain.PageRequest) (*domain.Page[domain.Product], error) {
+	return r.findPage(ctx, filter, nil, nil, page)
 }
 
 // Create creates a new product
 func (r *productRepository) Create(ctx context.Context, product *domain.Product) error {
 	query := `
-		INSERT INTO products (name, description, price, sku, stock, category_id, images, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		RETURNING id
+		INSERT INTO products (name, description, price, currency, sku, stock, category_id, images, options, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, version
 	`
 
-	now := time.Now().Unix()
+	now := time.Now().UTC()
 	product.CreatedAt = now
 	product.UpdatedAt = now
 
-	// Convert images to JSON
+	// Convert images and options to JSON
 	imagesJSON, err := json.Marshal(product.Images)
 	if err != nil {
 		r.logger.Error("Failed to marshal product images", zap.Error(err))
 		return errors.NewInternalError(err)
 	}
+	optionsJSON, err := marshalProductOptions(product.Options)
+	if err != nil {
+		r.logger.Error("Failed to marshal product options", zap.Error(err))
+		return errors.NewInternalError(err)
+	}
 
 	err = r.db.QueryRowContext(
 		ctx,
 		query,
 		product.Name,
 		product.Description,
-		product.Price,
+		product.Price.Amount,
+		product.Price.Currency,
 		product.SKU,
 		product.Stock,
 		product.CategoryID,
 		imagesJSON,
+		optionsJSON,
 		product.CreatedAt,
 		product.UpdatedAt,
-	).Scan(&product.ID)
+	).Scan(&product.ID, &product.Version)
 
 	if err != nil {
 		r.logger.Error("Failed to create product", zap.Error(err))
@@ -196,35 +157,45 @@ func (r *productRepository) Create(ctx context.Context, product *domain.Product)
 	return nil
 }
 
-// Update updates a product
+// Update updates a product, provided it is still at product.Version, and
+// moves it to the next version
 func (r *productRepository) Update(ctx context.Context, product *domain.Product) error {
 	query := `
 		UPDATE products
-		SET name = $1, description = $2, price = $3, sku = $4, stock = $5, category_id = $6, images = $7, updated_at = $8
-		WHERE id = $9
+		SET name = $1, description = $2, price = $3, currency = $4, sku = $5, stock = $6, category_id = $7, images = $8, options = $9, updated_at = $10,
+			version = version + 1
+		WHERE id = $11 AND version = $12
 	`
 
-	product.UpdatedAt = time.Now().Unix()
+	product.UpdatedAt = time.Now().UTC()
 
-	// Convert images to JSON
+	// Convert images and options to JSON
 	imagesJSON, err := json.Marshal(product.Images)
 	if err != nil {
 		r.logger.Error("Failed to marshal product images", zap.Error(err))
 		return errors.NewInternalError(err)
 	}
+	optionsJSON, err := marshalProductOptions(product.Options)
+	if err != nil {
+		r.logger.Error("Failed to marshal product options", zap.Error(err))
+		return errors.NewInternalError(err)
+	}
 
 	result, err := r.db.ExecContext(
 		ctx,
 		query,
 		product.Name,
 		product.Description,
-		product.Price,
+		product.Price.Amount,
+		product.Price.Currency,
 		product.SKU,
 		product.Stock,
 		product.CategoryID,
 		imagesJSON,
+		optionsJSON,
 		product.UpdatedAt,
 		product.ID,
+		product.Version,
 	)
 
 	if err != nil {
@@ -239,9 +210,10 @@ func (r *productRepository) Update(ctx context.Context, product *domain.Product)
 	}
 
 	if rowsAffected == 0 {
-		return errors.NewNotFoundError("Product", product.ID)
+		return versionConflict(ctx, r.db, r.logger, "products", "Product", product.ID, product.Version)
 	}
 
+	product.Version++
 	return nil
 }
 
@@ -270,37 +242,11 @@ func (r *productRepository) Delete(ctx context.Context, id int64) error {
 
 // FindBySKU finds a product by SKU
 func (r *productRepository) FindBySKU(ctx context.Context, sku string) (*domain.Product, error) {
-	query := `
-		SELECT p.id, p.name, p.description, p.price, p.sku, p.stock, p.category_id, p.images, p.created_at, p.updated_at,
-			   c.id, c.name, c.description, c.slug, c.created_at, c.updated_at
-		FROM products p
-		LEFT JOIN categories c ON p.category_id = c.id
+	query := productSelect + productFrom + `
 		WHERE p.sku = $1
 	`
 
-	var product domain.Product
-	var category domain.Category
-	var imagesJSON []byte
-
-	err := r.db.QueryRowContext(ctx, query, sku).Scan(
-		&product.ID,
-		&product.Name,
-		&product.Description,
-		&product.Price,
-		&product.SKU,
-		&product.Stock,
-		&product.CategoryID,
-		&imagesJSON,
-		&product.CreatedAt,
-		&product.UpdatedAt,
-		&category.ID,
-		&category.Name,
-		&category.Description,
-		&category.Slug,
-		&category.CreatedAt,
-		&category.UpdatedAt,
-	)
-
+	product, err := scanProduct(r.db.QueryRowContext(ctx, query, sku))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.NewNotFoundError("Product", fmt.Sprintf("sku=%s", sku))
@@ -309,202 +255,417 @@ func (r *productRepository) FindBySKU(ctx context.Context, sku string) (*domain.
 		return nil, errors.NewInternalError(err)
 	}
 
-	// Parse images JSON
-	if imagesJSON != nil {
-		if err := json.Unmarshal(imagesJSON, &product.Images); err != nil {
-			r.logger.Error("Failed to unmarshal product images", zap.Error(err))
-			return nil, errors.NewInternalError(err)
-		}
+	if product.Variants, err = r.FindVariants(ctx, product.ID); err != nil {
+		return nil, err
 	}
 
-	product.Category = category
-
 	return &product, nil
 }
 
-// FindByCategory finds products by category ID
-func (r *productRepository) FindByCategory(ctx context.Context, categoryID int64, limit, offset int) ([]domain.Product, int, error) {
+// FindByCategory finds a page of the products in a category and, when
+// includeDescendants is set, in all of its subcategories
+func (r *productRepository) FindByCategory(ctx context.Context, categoryID int64, includeDescendants bool, page domain.PageRequest) (*domain.Page[domain.Product], error) {
+	condition := "p.category_id = $1"
+	if includeDescendants {
+		condition = "p.category_id IN (SELECT id FROM categories WHERE path LIKE (SELECT path FROM categories WHERE id = $1) || '%')"
+	}
+	return r.findPage(ctx, domain.ProductFilter{}, []string{condition}, []interface{}{categoryID}, page)
+}
+
+// UpdateStock adjusts a product's stock by quantity. The adjustment is a
+// single conditional update, so concurrent calls can never drive the stock
+// below zero.
+func (r *productRepository) UpdateStock(ctx context.Context, id int64, quantity int) error {
 	query := `
-		SELECT p.id, p.name, p.description, p.price, p.sku, p.stock, p.category_id, p.images, p.created_at, p.updated_at,
-			   c.id, c.name, c.description, c.slug, c.created_at, c.updated_at
-		FROM products p
-		LEFT JOIN categories c ON p.category_id = c.id
-		WHERE p.category_id = $1
-		ORDER BY p.id
-		LIMIT $2 OFFSET $3
+		UPDATE products
+		SET stock = stock + $1, updated_at = $2, version = version + 1
+		WHERE id = $3 AND stock + $1 >= 0
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, categoryID, limit, offset)
+	now := time.Now().UTC()
+
+	result, err := r.db.ExecContext(ctx, query, quantity, now, id)
+	if err != nil {
+		r.logger.Error("Failed to update product stock", zap.Int64("id", id), zap.Int("quantity", quantity), zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		r.logger.Error("Failed to find products by category", zap.Int64("categoryID", categoryID), zap.Error(err))
-		return nil, 0, errors.NewInternalError(err)
+		r.logger.Error("Failed to get rows affected", zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		var exists bool
+		err = r.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM products WHERE id = $1)`, id).Scan(&exists)
+		if err != nil {
+			r.logger.Error("Failed to check if product exists", zap.Int64("id", id), zap.Error(err))
+			return errors.NewInternalError(err)
+		}
+		if !exists {
+			return errors.NewNotFoundError("Product", id)
+		}
+		return errors.NewBadRequestError("Insufficient stock")
+	}
+
+	return nil
+}
+
+// SearchProducts finds a page of the products matching a web search style
+// query and filter, most relevant first. Name and description are matched by
+// word stem; names and SKUs also match by trigram similarity, which tolerates
+// typos. The sort order of filter is ignored.
+func (r *productRepository) SearchProducts(ctx context.Context, query string, filter domain.ProductFilter, page domain.PageRequest) (*domain.Page[domain.ProductSearchHit], error) {
+	if cursor := page.Cursor(); cursor != nil && cursor.Sort != productSearchSort {
+		return nil, domain.ErrInvalidCursor
+	}
+
+	conditions, args := productFilterConditions(filter, []string{productSearchCondition}, []interface{}{query})
+	keys := []sortKey{
+		{column: productSearchRank, descending: true},
+		{column: productSearchSimilarity, descending: true},
+		{column: "p.id"},
+	}
+
+	selectFrom := productSelect + `,
+			   ` + productSearchRank + `::text, ` + productSearchSimilarity + `::text,
+			   ts_headline('english', COALESCE(p.description, ''), ` + productSearchQuery + `, 'MaxFragments=2, MinWords=5, MaxWords=20')` + productFrom
+	sqlQuery, queryArgs, err := pageQuery(selectFrom, keys, conditions, args, page)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, sqlQuery, queryArgs...)
+	if err != nil {
+		r.logger.Error("Failed to search products", zap.String("query", query), zap.Error(err))
+		return nil, errors.NewInternalError(err)
 	}
 	defer rows.Close()
 
-	var products []domain.Product
+	var hits []domain.ProductSearchHit
+	values := make(map[int64][]string)
 	for rows.Next() {
-		var product domain.Product
-		var category domain.Category
-		var imagesJSON []byte
-
-		if err := rows.Scan(
-			&product.ID,
-			&product.Name,
-			&product.Description,
-			&product.Price,
-			&product.SKU,
-			&product.Stock,
-			&product.CategoryID,
-			&imagesJSON,
-			&product.CreatedAt,
-			&product.UpdatedAt,
-			&category.ID,
-			&category.Name,
-			&category.Description,
-			&category.Slug,
-			&category.CreatedAt,
-			&category.UpdatedAt,
-		); err != nil {
-			r.logger.Error("Failed to scan product", zap.Error(err))
-			return nil, 0, errors.NewInternalError(err)
+		var hit domain.ProductSearchHit
+		var rank, similarity string
+		hit.Product, err = scanProduct(rows, &rank, &similarity, &hit.Headline)
+		if err == nil {
+			hit.Rank, err = strconv.ParseFloat(rank, 64)
 		}
-
-		// Parse images JSON
-		if imagesJSON != nil {
-			if err := json.Unmarshal(imagesJSON, &product.Images); err != nil {
-				r.logger.Error("Failed to unmarshal product images", zap.Error(err))
-				return nil, 0, errors.NewInternalError(err)
-			}
+		if err != nil {
+			r.logger.Error("Failed to scan product search hit", zap.Error(err))
+			return nil, errors.NewInternalError(err)
 		}
-
-		product.Category = category
-		products = append(products, product)
+		// The cursor keeps the database's text form of the sort values, so
+		// it compares exactly equal to them on the next page
+		values[hit.ID] = []string{rank, similarity}
+		hits = append(hits, hit)
 	}
 
 	if err := rows.Err(); err != nil {
-		r.logger.Error("Error iterating product rows", zap.Error(err))
-		return nil, 0, errors.NewInternalError(err)
+		r.logger.Error("Error iterating product search rows", zap.Error(err))
+		return nil, errors.NewInternalError(err)
 	}
 
-	// Get total count
-	var total int
-	countQuery := `SELECT COUNT(*) FROM products WHERE category_id = $1`
-	err = r.db.QueryRowContext(ctx, countQuery, categoryID).Scan(&total)
-	if err != nil {
-		r.logger.Error("Failed to get total product count by category", zap.Int64("categoryID", categoryID), zap.Error(err))
-		return nil, 0, errors.NewInternalError(err)
+	result := newPage(hits, page, func(hit domain.ProductSearchHit) domain.Cursor {
+		return domain.Cursor{ID: hit.ID, Values: values[hit.ID], Sort: productSearchSort}
+	})
+	if !page.SkipTotal {
+		total, err := countRows(ctx, r.db, "products p"+productVariantJoin, conditions, args)
+		if err != nil {
+			r.logger.Error("Failed to get total search result count", zap.Error(err))
+			return nil, errors.NewInternalError(err)
+		}
+		result.Total = &total
 	}
 
-	return products, total, nil
+	return result, nil
 }
 
-// UpdateStock updates a product's stock
-func (r *productRepository) UpdateStock(ctx context.Context, id int64, quantity int) error {
-	query := `
-		UPDATE products
-		SET stock = stock + $1, updated_at = $2
-		WHERE id = $3
-		RETURNING stock
-	`
+// SearchFacets counts the products matching a search query and filter by
+// category, by the price ranges starting at zero and at each of priceBounds,
+// and by stock. Each count leaves out the filter on its own dimension. The
+// counts are read from a single snapshot, so they add up.
+func (r *productRepository) SearchFacets(ctx context.Context, query string, filter domain.ProductFilter, priceBounds []int64) (*domain.ProductFacets, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
+	defer tx.Rollback()
 
-	now := time.Now().Unix()
-	var newStock int
+	facets := &domain.ProductFacets{}
+	if facets.Categories, err = categoryFacets(ctx, tx, query, filter); err != nil {
+		r.logger.Error("Failed to count products by category", zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
+	if facets.PriceRanges, err = priceRangeFacets(ctx, tx, query, filter, priceBounds); err != nil {
+		r.logger.Error("Failed to count products by price range", zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
+	if facets.Stock, err = stockFacet(ctx, tx, query, filter); err != nil {
+		r.logger.Error("Failed to count products by stock", zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
 
-	err := r.db.QueryRowContext(ctx, query, quantity, now, id).Scan(&newStock)
+	return facets, nil
+}
+
+// categoryFacets counts the matching products of each category, largest first
+func categoryFacets(ctx context.Context, tx *sql.Tx, query string, filter domain.ProductFilter) ([]domain.CategoryFacet, error) {
+	filter.CategoryIDs = nil
+	conditions, args := productFilterConditions(filter, []string{productSearchCondition}, []interface{}{query})
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT c.id, c.name, COUNT(*)
+		FROM products p
+		JOIN categories c ON p.category_id = c.id`+productVariantJoin+whereClause(conditions)+`
+		GROUP BY c.id, c.name
+		ORDER BY COUNT(*) DESC, c.name`, args...)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return errors.NewNotFoundError("Product", id)
+		return nil, err
+	}
+	defer rows.Close()
+
+	facets := []domain.CategoryFacet{}
+	for rows.Next() {
+		var facet domain.CategoryFacet
+		if err := rows.Scan(&facet.CategoryID, &facet.Name, &facet.Count); err != nil {
+			return nil, err
 		}
-		r.logger.Error("Failed to update product stock", zap.Int64("id", id), zap.Int("quantity", quantity), zap.Error(err))
-		return errors.NewInternalError(err)
+		facets = append(facets, facet)
 	}
+	return facets, rows.Err()
+}
 
-	// Check if stock is negative
-	if newStock < 0 {
-		// Revert the change
-		_, err := r.db.ExecContext(ctx, `UPDATE products SET stock = stock - $1 WHERE id = $2`, quantity, id)
-		if err != nil {
-			r.logger.Error("Failed to revert stock update", zap.Int64("id", id), zap.Error(err))
+// priceRangeFacets counts the matching products of every price range,
+// including the empty ones
+func priceRangeFacets(ctx context.Context, tx *sql.Tx, query string, filter domain.ProductFilter, bounds []int64) ([]domain.PriceRangeFacet, error) {
+	filter.MinPrice, filter.MaxPrice = nil, nil
+	conditions, args := productFilterConditions(filter, []string{productSearchCondition}, []interface{}{query})
+	args = append(args, pq.Array(bounds))
+
+	// width_bucket numbers the range below the first bound 0 and the range
+	// from bound i-1 up to bound i as i. Products with variants count in the
+	// range of their lowest price.
+	rows, err := tx.QueryContext(ctx, `
+		SELECT width_bucket(`+productMinPrice+`, $`+strconv.Itoa(len(args))+`::BIGINT[]), COUNT(*)
+		FROM products p`+productVariantJoin+whereClause(conditions)+`
+		GROUP BY 1`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	facets := make([]domain.PriceRangeFacet, len(bounds)+1)
+	for i := range facets {
+		if i > 0 {
+			facets[i].Min = bounds[i-1]
 		}
-		return errors.NewBadRequestError("Insufficient stock")
+		if i < len(bounds) {
+			max := bounds[i]
+			facets[i].Max = &max
+		}
+	}
+	for rows.Next() {
+		var bucket, count int
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, err
+		}
+		facets[bucket].Count = count
 	}
+	return facets, rows.Err()
+}
 
-	return nil
+// stockFacet counts the matching products in and out of stock
+func stockFacet(ctx context.Context, tx *sql.Tx, query string, filter domain.ProductFilter) (domain.StockFacet, error) {
+	filter.InStock = nil
+	conditions, args := productFilterConditions(filter, []string{productSearchCondition}, []interface{}{query})
+
+	var facet domain.StockFacet
+	err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FILTER (WHERE `+productAvailableStock+` > 0), COUNT(*) FILTER (WHERE `+productAvailableStock+` = 0)
+		FROM products p`+productVariantJoin+whereClause(conditions), args...).Scan(&facet.InStock, &facet.OutOfStock)
+	return facet, err
 }
 
-// SearchProducts searches for products by name or description
-func (r *productRepository) SearchProducts(ctx context.Context, query string, limit, offset int) ([]domain.Product, int, error) {
-	sqlQuery := `
-		SELECT p.id, p.name, p.description, p.price, p.sku, p.stock, p.category_id, p.images, p.created_at, p.updated_at,
-			   c.id, c.name, c.description, c.slug, c.created_at, c.updated_at
-		FROM products p
-		LEFT JOIN categories c ON p.category_id = c.id
-		WHERE p.name ILIKE $1 OR p.description ILIKE $1
-		ORDER BY p.id
-		LIMIT $2 OFFSET $3
-	`
+// findPage finds a page of the products matching both filter and the filter
+// conditions, in the sort order of filter
+func (r *productRepository) findPage(ctx context.Context, filter domain.ProductFilter, conditions []string, args []interface{}, page domain.PageRequest) (*domain.Page[domain.Product], error) {
+	// A cursor only marks a position in the order it was issued for
+	sort := filter.SortString()
+	if cursor := page.Cursor(); cursor != nil && cursor.Sort != sort {
+		return nil, domain.ErrInvalidCursor
+	}
 
-	searchPattern := "%" + query + "%"
-	rows, err := r.db.QueryContext(ctx, sqlQuery, searchPattern, limit, offset)
+	conditions, args = productFilterConditions(filter, conditions, args)
+	sortKeys := filter.SortKeys()
+	keys := make([]sortKey, len(sortKeys))
+	for i, key := range sortKeys {
+		keys[i] = sortKey{column: productSortColumns[key.Field], descending: key.Descending}
+	}
+
+	query, queryArgs, err := pageQuery(productSelect+productFrom, keys, conditions, args, page)
 	if err != nil {
-		r.logger.Error("Failed to search products", zap.String("query", query), zap.Error(err))
-		return nil, 0, errors.NewInternalError(err)
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, queryArgs...)
+	if err != nil {
+		r.logger.Error("Failed to find products", zap.Error(err))
+		return nil, errors.NewInternalError(err)
 	}
 	defer rows.Close()
 
 	var products []domain.Product
 	for rows.Next() {
-		var product domain.Product
-		var category domain.Category
-		var imagesJSON []byte
-
-		if err := rows.Scan(
-			&product.ID,
-			&product.Name,
-			&product.Description,
-			&product.Price,
-			&product.SKU,
-			&product.Stock,
-			&product.CategoryID,
-			&imagesJSON,
-			&product.CreatedAt,
-			&product.UpdatedAt,
-			&category.ID,
-			&category.Name,
-			&category.Description,
-			&category.Slug,
-			&category.CreatedAt,
-			&category.UpdatedAt,
-		); err != nil {
+		product, err := scanProduct(rows)
+		if err != nil {
 			r.logger.Error("Failed to scan product", zap.Error(err))
-			return nil, 0, errors.NewInternalError(err)
-		}
-
-		// Parse images JSON
-		if imagesJSON != nil {
-			if err := json.Unmarshal(imagesJSON, &product.Images); err != nil {
-				r.logger.Error("Failed to unmarshal product images", zap.Error(err))
-				return nil, 0, errors.NewInternalError(err)
-			}
+			return nil, errors.NewInternalError(err)
 		}
-
-		product.Category = category
 		products = append(products, product)
 	}
 
 	if err := rows.Err(); err != nil {
 		r.logger.Error("Error iterating product rows", zap.Error(err))
-		return nil, 0, errors.NewInternalError(err)
+		return nil, errors.NewInternalError(err)
 	}
 
-	// Get total count
-	var total int
-	countQuery := `SELECT COUNT(*) FROM products WHERE name ILIKE $1 OR description ILIKE $1`
-	err = r.db.QueryRowContext(ctx, countQuery, searchPattern).Scan(&total)
-	if err != nil {
-		r.logger.Error("Failed to get total product count for search", zap.String("query", query), zap.Error(err))
-		return nil, 0, errors.NewInternalError(err)
+	result := newPage(products, page, func(p domain.Product) domain.Cursor {
+		return productCursor(p, sortKeys, sort)
+	})
+	if !page.SkipTotal {
+		total, err := countRows(ctx, r.db, "products p"+productVariantJoin, conditions, args)
+		if err != nil {
+			r.logger.Error("Failed to get total product count", zap.Error(err))
+			return nil, errors.NewInternalError(err)
+		}
+		result.Total = &total
+	}
+
+	return result, nil
+}
+
+// productFilterConditions appends the conditions selecting the products that
+// match filter. Every value is passed as a query argument. A product with
+// variants matches a price filter when its price range overlaps it, and is
+// in stock when any variant is.
+func productFilterConditions(filter domain.ProductFilter, conditions []string, args []interface{}) ([]string, []interface{}) {
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.MinPrice != nil {
+		add(productMaxPrice+" >= $%d", *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		add(productMinPrice+" <= $%d", *filter.MaxPrice)
+	}
+	if len(filter.CategoryIDs) > 0 {
+		add("p.category_id = ANY($%d)", pq.Array(filter.CategoryIDs))
+	}
+	if filter.InStock != nil {
+		if *filter.InStock {
+			conditions = append(conditions, productAvailableStock+" > 0")
+		} else {
+			conditions = append(conditions, productAvailableStock+" = 0")
+		}
+	}
+	if filter.CreatedFrom != nil {
+		add("p.created_at >= $%d", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		add("p.created_at <= $%d", *filter.CreatedTo)
 	}
 
-	return products, total, nil
+	return conditions, args
+}
+
+// productCursor returns the position of a product in a list sorted by keys,
+// which end with the product ID
+func productCursor(product domain.Product, keys []domain.ProductSort, sort string) domain.Cursor {
+	cursor := domain.Cursor{ID: product.ID, Sort: sort}
+	for _, key := range keys[:len(keys)-1] {
+		var value string
+		switch key.Field {
+		case domain.ProductSortName:
+			value = product.Name
+		case domain.ProductSortPrice:
+			value = strconv.FormatInt(product.PriceRange.Min.Amount, 10)
+		case domain.ProductSortStock:
+			value = strconv.Itoa(product.AvailableStock)
+		case domain.ProductSortCreatedAt:
+			value = product.CreatedAt.Format(time.RFC3339Nano)
+		}
+		cursor.Values = append(cursor.Values, value)
+	}
+	return cursor
+}
+
+// scanProduct scans a row of the productSelect columns followed by extra
+// columns into dest
+func scanProduct(row rowScanner, dest ...interface{}) (domain.Product, error) {
+	var product domain.Product
+	var imagesJSON, optionsJSON []byte
+
+	columns := []interface{}{
+		&product.ID,
+		&product.Name,
+		&product.Description,
+		&product.Price.Amount,
+		&product.Price.Currency,
+		&product.SKU,
+		&product.Stock,
+		&product.CategoryID,
+		&imagesJSON,
+		&optionsJSON,
+		&product.CreatedAt,
+		&product.UpdatedAt,
+		&product.Version,
+		&product.Category.ID,
+		&product.Category.Name,
+		&product.Category.Description,
+		&product.Category.Slug,
+		&product.Category.ParentID,
+		&product.Category.Path,
+		&product.Category.CreatedAt,
+		&product.Category.UpdatedAt,
+		&product.Category.Version,
+		&product.PriceRange.Min.Amount,
+		&product.PriceRange.Max.Amount,
+		&product.AvailableStock,
+	}
+	if err := row.Scan(append(columns, dest...)...); err != nil {
+		return product, err
+	}
+	product.PriceRange.Min.Currency = product.Price.Currency
+	product.PriceRange.Max.Currency = product.Price.Currency
+
+	// Parse images and options JSON
+	if imagesJSON != nil {
+		if err := json.Unmarshal(imagesJSON, &product.Images); err != nil {
+			return product, err
+		}
+	}
+	if err := json.Unmarshal(optionsJSON, &product.Options); err != nil {
+		return product, err
+	}
+
+	return product, nil
+}
+
+// marshalProductOptions encodes product options as JSON, storing no options
+// as an empty array rather than null
+func marshalProductOptions(options []domain.ProductOption) ([]byte, error) {
+	if options == nil {
+		options = []domain.ProductOption{}
+	}
+	return json.Marshal(options)
 }
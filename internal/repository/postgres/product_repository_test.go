@@ -0,0 +1,268 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+)
+
+// TestProductRepository_FindByFilter tests filtering and walking a sorted list by cursor
+func TestProductRepository_FindByFilter(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewProductRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	categoryID := seedCategory(t, db)
+	ids := make(map[string]int64)
+	for _, seed := range []struct {
+		name  string
+		price int64
+		stock int
+	}{
+		{"a", 200, 1},
+		{"b", 300, 1},
+		{"c", 200, 1},
+		{"d", 100, 1},
+		{"e", 500, 0},
+	} {
+		var id int64
+		err := db.QueryRow(
+			`INSERT INTO products (name, description, price, currency, sku, stock, category_id, created_at, updated_at)
+			VALUES ($1, '', $2, 'USD', $3, $4, $5, $6, $6) RETURNING id`,
+			seed.name, seed.price, uniqueName("sku"), seed.stock, categoryID, time.Now().UTC(),
+		).Scan(&id)
+		if err != nil {
+			t.Fatalf("Failed to seed product: %v", err)
+		}
+		ids[seed.name] = id
+	}
+
+	inStock := true
+	maxPrice := int64(300)
+	filter := domain.ProductFilter{
+		CategoryIDs: []int64{categoryID},
+		InStock:     &inStock,
+		MaxPrice:    &maxPrice,
+		Sort:        []domain.ProductSort{{Field: domain.ProductSortPrice, Descending: true}, {Field: domain.ProductSortName}},
+	}
+	want := []int64{ids["b"], ids["a"], ids["c"], ids["d"]}
+
+	var seen []int64
+	page := domain.PageRequest{Limit: 2}
+	for {
+		result, err := repo.FindByFilter(ctx, filter, page)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if result.Total == nil || *result.Total != len(want) {
+			t.Errorf("Expected total %d, got %v", len(want), result.Total)
+		}
+		for _, product := range result.Items {
+			seen = append(seen, product.ID)
+		}
+		if result.Next == nil {
+			break
+		}
+		page = domain.PageRequest{Limit: 2, After: result.Next}
+	}
+	if !reflect.DeepEqual(seen, want) {
+		t.Errorf("Expected %v, got %v", want, seen)
+	}
+
+	// A cursor is only valid for the order it was issued in
+	filter.Sort = nil
+	if _, err := repo.FindByFilter(ctx, filter, page); err != domain.ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+// TestProductRepository_SearchProducts tests stemmed, ranked and typo tolerant search
+func TestProductRepository_SearchProducts(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewProductRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	categoryID := seedCategory(t, db)
+	word := searchableWord()
+	ids := make(map[string]int64)
+	for _, seed := range []struct {
+		key, name, description, sku string
+	}{
+		{"name", word + " lamp", "A desk lamp", uniqueName("sku")},
+		{"description", "Desk", "Pairs well with " + word + "s of every kind", uniqueName("sku")},
+		{"sku", "Chair", "A chair", word + "-sku"},
+	} {
+		var id int64
+		err := db.QueryRow(
+			`INSERT INTO products (name, description, price, currency, sku, stock, category_id, created_at, updated_at)
+			VALUES ($1, $2, 1000, 'USD', $3, 1, $4, $5, $5) RETURNING id`,
+			seed.name, seed.description, seed.sku, categoryID, time.Now().UTC(),
+		).Scan(&id)
+		if err != nil {
+			t.Fatalf("Failed to seed product: %v", err)
+		}
+		ids[seed.key] = id
+	}
+
+	// Name matches rank above description matches, and the plural matches by stem
+	var seen []int64
+	var headlines []string
+	page := domain.PageRequest{Limit: 1}
+	for {
+		result, err := repo.SearchProducts(ctx, word, domain.ProductFilter{}, page)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		for _, hit := range result.Items {
+			seen = append(seen, hit.ID)
+			headlines = append(headlines, hit.Headline)
+		}
+		if result.Next == nil {
+			break
+		}
+		page = domain.PageRequest{Limit: 1, After: result.Next}
+	}
+	if len(seen) < 2 || seen[0] != ids["name"] || seen[1] != ids["description"] {
+		t.Fatalf("Expected name then description match, got %v", seen)
+	}
+	if !strings.Contains(headlines[1], "<b>") {
+		t.Errorf("Expected highlighted headline, got %q", headlines[1])
+	}
+
+	// A misspelled SKU still finds its product
+	result, err := repo.SearchProducts(ctx, word+"-skv", domain.ProductFilter{}, domain.PageRequest{Limit: 10})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	found := false
+	for _, hit := range result.Items {
+		found = found || hit.ID == ids["sku"]
+	}
+	if !found {
+		t.Errorf("Expected product %d for misspelled SKU", ids["sku"])
+	}
+}
+
+// TestProductRepository_SearchFacets tests that each facet applies every filter but its own
+func TestProductRepository_SearchFacets(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewProductRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	word := searchableWord()
+	shoes, hats := seedCategory(t, db), seedCategory(t, db)
+	for _, seed := range []struct {
+		categoryID int64
+		price      int64
+		stock      int
+	}{
+		{shoes, 500, 1},
+		{shoes, 3000, 0},
+		{hats, 30000, 1},
+	} {
+		_, err := db.Exec(
+			`INSERT INTO products (name, description, price, currency, sku, stock, category_id, created_at, updated_at)
+			VALUES ($1, '', $2, 'USD', $3, $4, $5, $6, $6)`,
+			word, seed.price, uniqueName("sku"), seed.stock, seed.categoryID, time.Now().UTC(),
+		)
+		if err != nil {
+			t.Fatalf("Failed to seed product: %v", err)
+		}
+	}
+
+	inStock := true
+	filter := domain.ProductFilter{CategoryIDs: []int64{shoes}, InStock: &inStock}
+	facets, err := repo.SearchFacets(ctx, word, filter, []int64{1000, 10000})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// In stock products of any category
+	categories := make(map[int64]int)
+	for _, facet := range facets.Categories {
+		categories[facet.CategoryID] = facet.Count
+	}
+	if categories[shoes] != 1 || categories[hats] != 1 {
+		t.Errorf("Expected one product in each category, got %+v", facets.Categories)
+	}
+
+	// In stock shoes by price
+	var counts []int
+	for _, facet := range facets.PriceRanges {
+		counts = append(counts, facet.Count)
+	}
+	if !reflect.DeepEqual(counts, []int{1, 0, 0}) {
+		t.Errorf("Expected price range counts [1 0 0], got %v", counts)
+	}
+	if facets.PriceRanges[0].Min != 0 || *facets.PriceRanges[0].Max != 1000 || facets.PriceRanges[2].Max != nil {
+		t.Errorf("Expected ranges bounded by 0, 1000, 10000, got %+v", facets.PriceRanges)
+	}
+
+	// Shoes by stock
+	if facets.Stock.InStock != 1 || facets.Stock.OutOfStock != 1 {
+		t.Errorf("Expected 1 in stock and 1 out of stock, got %+v", facets.Stock)
+	}
+}
+
+// TestProductRepository_Update_Version tests that updates based on a stale version are refused
+func TestProductRepository_Update_Version(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewProductRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	productID := seedProduct(t, db, 5)
+	first, err := repo.FindByID(ctx, productID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	second := *first
+
+	first.Name = "First edit"
+	if err := repo.Update(ctx, first); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if first.Version != second.Version+1 {
+		t.Errorf("Expected version %d, got %d", second.Version+1, first.Version)
+	}
+
+	// The second edit was based on the version the first one replaced
+	second.Name = "Second edit"
+	var conflict *domain.VersionConflictError
+	if err := repo.Update(ctx, &second); !errors.As(err, &conflict) {
+		t.Fatalf("Expected VersionConflictError, got %v", err)
+	}
+
+	// Stock changes move the product to the next version too
+	if err := repo.UpdateStock(ctx, productID, -1); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	stored, err := repo.FindByID(ctx, productID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stored.Name != "First edit" || stored.Version != first.Version+1 {
+		t.Errorf("Expected the first edit at version %d, got %q at version %d", first.Version+1, stored.Name, stored.Version)
+	}
+
+	missing := *stored
+	missing.ID = -1
+	if err := repo.Update(ctx, &missing); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+// searchableWord returns a made-up word of letters only, so that it is
+// stemmed like English and matches no other test's products
+func searchableWord() string {
+	word := "zorblat"
+	for n := time.Now().UnixNano(); n > 0; n /= 26 {
+		word += string(rune('a' + n%26))
+	}
+	return word
+}
@@ -0,0 +1,288 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// productVariantColumns are the product_variants columns read by scanVariant
+const productVariantColumns = `v.id, v.product_id, v.sku, v.options, v.price, p.currency, v.stock, v.created_at, v.updated_at`
+
+// FindVariants finds the variants of a product, oldest first
+func (r *productRepository) FindVariants(ctx context.Context, productID int64) ([]domain.ProductVariant, error) {
+	query := `
+		SELECT ` + productVariantColumns + `
+		FROM product_variants v
+		JOIN products p ON v.product_id = p.id
+		WHERE v.product_id = $1
+		ORDER BY v.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, productID)
+	if err != nil {
+		r.logger.Error("Failed to find product variants", zap.Int64("productID", productID), zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
+	defer rows.Close()
+
+	var variants []domain.ProductVariant
+	for rows.Next() {
+		variant, err := scanVariant(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan product variant", zap.Error(err))
+			return nil, errors.NewInternalError(err)
+		}
+		variants = append(variants, variant)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Error iterating product variant rows", zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
+
+	return variants, nil
+}
+
+// FindVariantByID finds a product variant by ID
+func (r *productRepository) FindVariantByID(ctx context.Context, id int64) (*domain.ProductVariant, error) {
+	query := `
+		SELECT ` + productVariantColumns + `
+		FROM product_variants v
+		JOIN products p ON v.product_id = p.id
+		WHERE v.id = $1
+	`
+
+	variant, err := scanVariant(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("Product variant", id)
+		}
+		r.logger.Error("Failed to find product variant by ID", zap.Int64("id", id), zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
+
+	return &variant, nil
+}
+
+// CreateVariant creates a new product variant
+func (r *productRepository) CreateVariant(ctx context.Context, variant *domain.ProductVariant) error {
+	query := `
+		INSERT INTO product_variants (product_id, sku, options, price, stock, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+
+	now := time.Now().UTC()
+	variant.CreatedAt = now
+	variant.UpdatedAt = now
+
+	optionsJSON, err := json.Marshal(variant.Options)
+	if err != nil {
+		r.logger.Error("Failed to marshal variant options", zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	err = r.db.QueryRowContext(
+		ctx,
+		query,
+		variant.ProductID,
+		variant.SKU,
+		optionsJSON,
+		variantPrice(variant),
+		variant.Stock,
+		variant.CreatedAt,
+		variant.UpdatedAt,
+	).Scan(&variant.ID)
+
+	if err != nil {
+		if err := variantConstraintError(err, variant); err != nil {
+			return err
+		}
+		r.logger.Error("Failed to create product variant", zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	return nil
+}
+
+// UpdateVariant updates a product variant
+func (r *productRepository) UpdateVariant(ctx context.Context, variant *domain.ProductVariant) error {
+	query := `
+		UPDATE product_variants
+		SET sku = $1, options = $2, price = $3, stock = $4, updated_at = $5
+		WHERE id = $6
+	`
+
+	variant.UpdatedAt = time.Now().UTC()
+
+	optionsJSON, err := json.Marshal(variant.Options)
+	if err != nil {
+		r.logger.Error("Failed to marshal variant options", zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		variant.SKU,
+		optionsJSON,
+		variantPrice(variant),
+		variant.Stock,
+		variant.UpdatedAt,
+		variant.ID,
+	)
+
+	if err != nil {
+		if err := variantConstraintError(err, variant); err != nil {
+			return err
+		}
+		r.logger.Error("Failed to update product variant", zap.Int64("id", variant.ID), zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.NewNotFoundError("Product variant", variant.ID)
+	}
+
+	return nil
+}
+
+// DeleteVariant deletes a product variant. Variants that have been ordered
+// are kept for the order history and cannot be deleted.
+func (r *productRepository) DeleteVariant(ctx context.Context, id int64) error {
+	query := `DELETE FROM product_variants WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return errors.NewAppError(errors.ErrConflict, "Product variant has been ordered", http.StatusConflict)
+		}
+		r.logger.Error("Failed to delete product variant", zap.Int64("id", id), zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.NewNotFoundError("Product variant", id)
+	}
+
+	return nil
+}
+
+// UpdateVariantStock adjusts a variant's stock by quantity. Like
+// UpdateStock, the adjustment never drives the stock below zero.
+func (r *productRepository) UpdateVariantStock(ctx context.Context, id int64, quantity int) error {
+	query := `
+		UPDATE product_variants
+		SET stock = stock + $1, updated_at = $2
+		WHERE id = $3 AND stock + $1 >= 0
+	`
+
+	now := time.Now().UTC()
+
+	result, err := r.db.ExecContext(ctx, query, quantity, now, id)
+	if err != nil {
+		r.logger.Error("Failed to update variant stock", zap.Int64("id", id), zap.Int("quantity", quantity), zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", zap.Error(err))
+		return errors.NewInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		var exists bool
+		err = r.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM product_variants WHERE id = $1)`, id).Scan(&exists)
+		if err != nil {
+			r.logger.Error("Failed to check if product variant exists", zap.Int64("id", id), zap.Error(err))
+			return errors.NewInternalError(err)
+		}
+		if !exists {
+			return errors.NewNotFoundError("Product variant", id)
+		}
+		return errors.NewBadRequestError("Insufficient stock")
+	}
+
+	return nil
+}
+
+// variantPrice returns the price column of a variant, NULL when the variant
+// sells at the product price
+func variantPrice(variant *domain.ProductVariant) interface{} {
+	if variant.Price == nil {
+		return nil
+	}
+	return variant.Price.Amount
+}
+
+// variantConstraintError maps the unique and foreign key violations of a
+// variant write to application errors, or returns nil for other errors
+func variantConstraintError(err error, variant *domain.ProductVariant) error {
+	pqErr, ok := err.(*pq.Error)
+	if !ok {
+		return nil
+	}
+	switch {
+	case pqErr.Code == "23505" && pqErr.Constraint == "product_variants_sku_key":
+		return errors.NewConflictError("Product variant", "sku", variant.SKU)
+	case pqErr.Code == "23505":
+		return errors.NewAppError(errors.ErrConflict, "Product already has a variant with these options", http.StatusConflict)
+	case pqErr.Code == "23503":
+		return errors.NewNotFoundError("Product", variant.ProductID)
+	}
+	return nil
+}
+
+// scanVariant scans a row of the productVariantColumns
+func scanVariant(row rowScanner) (domain.ProductVariant, error) {
+	var variant domain.ProductVariant
+	var optionsJSON []byte
+	var price sql.NullInt64
+	var currency domain.Currency
+
+	err := row.Scan(
+		&variant.ID,
+		&variant.ProductID,
+		&variant.SKU,
+		&optionsJSON,
+		&price,
+		&currency,
+		&variant.Stock,
+		&variant.CreatedAt,
+		&variant.UpdatedAt,
+	)
+	if err != nil {
+		return variant, err
+	}
+
+	if price.Valid {
+		amount := domain.NewMoney(price.Int64, currency)
+		variant.Price = &amount
+	}
+	if err := json.Unmarshal(optionsJSON, &variant.Options); err != nil {
+		return variant, err
+	}
+
+	return variant, nil
+}
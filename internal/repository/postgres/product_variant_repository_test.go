@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+)
+
+// TestProductRepository_Variants tests variant price ranges, availability and variant orders
+func TestProductRepository_Variants(t *testing.T) {
+	db := openTestDB(t)
+	products := NewProductRepository(db, logger.NewLogger("error"))
+	orders := NewOrderRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	productID := seedProduct(t, db, 0)
+	if _, err := db.Exec(`UPDATE products SET options = '[{"name": "size", "values": ["S", "M"]}]' WHERE id = $1`, productID); err != nil {
+		t.Fatalf("Failed to set product options: %v", err)
+	}
+
+	override := domain.NewMoney(1500, domain.CurrencyUSD)
+	small := &domain.ProductVariant{ProductID: productID, SKU: uniqueName("variant"), Options: map[string]string{"size": "S"}}
+	medium := &domain.ProductVariant{ProductID: productID, SKU: uniqueName("variant"), Options: map[string]string{"size": "M"}, Price: &override, Stock: 3}
+	for _, variant := range []*domain.ProductVariant{small, medium} {
+		if err := products.CreateVariant(ctx, variant); err != nil {
+			t.Fatalf("Failed to create variant: %v", err)
+		}
+	}
+
+	duplicate := &domain.ProductVariant{ProductID: productID, SKU: uniqueName("variant"), Options: map[string]string{"size": "S"}}
+	if err := products.CreateVariant(ctx, duplicate); err == nil {
+		t.Error("Expected error for duplicate variant options, got nil")
+	}
+
+	product, err := products.FindByID(ctx, productID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(product.Variants) != 2 || product.Variants[1].Price == nil || *product.Variants[1].Price != override {
+		t.Fatalf("Expected both variants with the override, got %+v", product.Variants)
+	}
+	if product.PriceRange.Min.Amount != 1000 || product.PriceRange.Max.Amount != 1500 {
+		t.Errorf("Expected price range 1000-1500, got %+v", product.PriceRange)
+	}
+	if product.AvailableStock != 3 {
+		t.Errorf("Expected available stock 3, got %d", product.AvailableStock)
+	}
+
+	// The product is in stock through its variants, and its price range
+	// overlaps a filter above its own price
+	inStock := true
+	minPrice := int64(1200)
+	page, err := products.FindByFilter(ctx, domain.ProductFilter{
+		CategoryIDs: []int64{product.CategoryID},
+		InStock:     &inStock,
+		MinPrice:    &minPrice,
+	}, domain.PageRequest{Limit: 10})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].ID != productID || page.Items[0].Variants != nil {
+		t.Errorf("Expected the product without its variants, got %+v", page.Items)
+	}
+
+	// Orders draw from and return to the variant's stock
+	userID := seedUser(t, db)
+	newOrder := func() *domain.Order {
+		return &domain.Order{
+			UserID:        userID,
+			Status:        domain.OrderStatusPending,
+			TotalAmount:   domain.NewMoney(3000, domain.CurrencyUSD),
+			PaymentMethod: domain.PaymentMethodCreditCard,
+			Items:         []domain.OrderItem{{ProductID: productID, VariantID: &medium.ID, Quantity: 2, Price: override}},
+		}
+	}
+	order := newOrder()
+	if err := orders.Create(ctx, order); err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+	assertVariantStock(t, products, medium.ID, 1)
+
+	if err := orders.Create(ctx, newOrder()); err == nil {
+		t.Error("Expected error for insufficient variant stock, got nil")
+	}
+
+	items, err := orders.GetOrderItems(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(items) != 1 || items[0].Variant == nil || items[0].Variant.Options["size"] != "M" {
+		t.Errorf("Expected the ordered variant, got %+v", items)
+	}
+
+	if err := orders.UpdateStatus(ctx, &domain.OrderStatusHistory{OrderID: order.ID, ToStatus: domain.OrderStatusCancelled}, 0); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	assertVariantStock(t, products, medium.ID, 3)
+	assertStock(t, db, productID, 0)
+
+	// Ordered variants are kept for the order history
+	if err := products.DeleteVariant(ctx, medium.ID); err == nil {
+		t.Error("Expected error for deleting an ordered variant, got nil")
+	}
+	if err := products.DeleteVariant(ctx, small.ID); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+// assertVariantStock checks the current stock of a product variant
+func assertVariantStock(t *testing.T, repo domain.ProductRepository, variantID int64, want int) {
+	t.Helper()
+
+	variant, err := repo.FindVariantByID(context.Background(), variantID)
+	if err != nil {
+		t.Fatalf("Failed to read variant: %v", err)
+	}
+	if variant.Stock != want {
+		t.Errorf("Expected variant stock %d, got %d", want, variant.Stock)
+	}
+}
@@ -0,0 +1,449 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	pkgerrors "github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// refundCounted matches the refunds that count against a payment
+const refundCounted = `status IN ('pending', 'succeeded')`
+
+type refundRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// NewRefundRepository creates a new refund repository
+func NewRefundRepository(db *sql.DB, logger logger.Logger) domain.RefundRepository {
+	return &refundRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// FindByOrderID finds the refunds of an order with their items, oldest first
+func (r *refundRepository) FindByOrderID(ctx context.Context, orderID int64) ([]domain.Refund, error) {
+	query := `
+		SELECT id, order_id, payment_id, status, amount, currency, reason, restock, provider_reference, failure_message,
+			actor_id, created_at, updated_at
+		FROM refunds
+		WHERE order_id = $1
+		ORDER BY id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		r.logger.Error("Failed to find refunds by order ID", zap.Int64("orderID", orderID), zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
+	}
+	defer rows.Close()
+
+	refunds := []domain.Refund{}
+	index := make(map[int64]int)
+	for rows.Next() {
+		var refund domain.Refund
+		var reference, failureMessage sql.NullString
+
+		if err := rows.Scan(
+			&refund.ID,
+			&refund.OrderID,
+			&refund.PaymentID,
+			&refund.Status,
+			&refund.Amount.Amount,
+			&refund.Amount.Currency,
+			&refund.Reason,
+			&refund.Restock,
+			&reference,
+			&failureMessage,
+			&refund.ActorID,
+			&refund.CreatedAt,
+			&refund.UpdatedAt,
+		); err != nil {
+			r.logger.Error("Failed to scan refund", zap.Error(err))
+			return nil, pkgerrors.NewInternalError(err)
+		}
+
+		refund.ProviderReference = reference.String
+		refund.FailureMessage = failureMessage.String
+		refund.Items = []domain.RefundItem{}
+		index[refund.ID] = len(refunds)
+		refunds = append(refunds, refund)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Error iterating refund rows", zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
+	}
+
+	itemRows, err := r.db.QueryContext(
+		ctx,
+		`SELECT ri.id, ri.refund_id, ri.order_item_id, ri.quantity, ri.amount
+		FROM refund_items ri
+		JOIN refunds rf ON rf.id = ri.refund_id
+		WHERE rf.order_id = $1
+		ORDER BY ri.id`,
+		orderID,
+	)
+	if err != nil {
+		r.logger.Error("Failed to find refund items", zap.Int64("orderID", orderID), zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var item domain.RefundItem
+		var refundID int64
+		if err := itemRows.Scan(&item.ID, &refundID, &item.OrderItemID, &item.Quantity, &item.Amount.Amount); err != nil {
+			r.logger.Error("Failed to scan refund item", zap.Error(err))
+			return nil, pkgerrors.NewInternalError(err)
+		}
+
+		refund := &refunds[index[refundID]]
+		item.Amount.Currency = refund.Amount.Currency
+		refund.Items = append(refund.Items, item)
+	}
+
+	if err := itemRows.Err(); err != nil {
+		r.logger.Error("Error iterating refund item rows", zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
+	}
+
+	return refunds, nil
+}
+
+// RefundedTotals totals the pending and succeeded refunds of a payment
+func (r *refundRepository) RefundedTotals(ctx context.Context, paymentID int64) (domain.RefundedTotals, error) {
+	totals, err := refundedTotals(ctx, r.db, paymentID)
+	if err != nil {
+		r.logger.Error("Failed to total refunds", zap.Int64("paymentID", paymentID), zap.Error(err))
+		return domain.RefundedTotals{}, pkgerrors.NewInternalError(err)
+	}
+	return totals, nil
+}
+
+// Create records a pending refund. The payment row is locked while the
+// refund is checked against the captured amount and earlier refunds.
+func (r *refundRepository) Create(ctx context.Context, refund *domain.Refund) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+	defer r.rollback(tx)
+
+	var status domain.PaymentStatus
+	var captured domain.Money
+	err = tx.QueryRowContext(
+		ctx,
+		`SELECT status, captured_amount, currency FROM payments WHERE id = $1 AND order_id = $2 FOR UPDATE`,
+		refund.PaymentID,
+		refund.OrderID,
+	).Scan(&status, &captured.Amount, &captured.Currency)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return pkgerrors.NewNotFoundError("Payment", refund.PaymentID)
+		}
+		r.logger.Error("Failed to lock payment", zap.Int64("paymentID", refund.PaymentID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	if status != domain.PaymentStatusCaptured {
+		return pkgerrors.NewAppError(pkgerrors.ErrConflict, "Only captured payments can be refunded", http.StatusConflict)
+	}
+
+	totals, err := refundedTotals(ctx, tx, refund.PaymentID)
+	if err != nil {
+		r.logger.Error("Failed to total refunds", zap.Int64("paymentID", refund.PaymentID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	items, err := readOrderItems(ctx, tx, r.logger, refund.OrderID)
+	if err != nil {
+		return err
+	}
+
+	if err = domain.CheckRefund(refund, captured, items, totals); err != nil {
+		return err
+	}
+
+	if refund.Restock {
+		if err = checkRestockable(ctx, tx, r.logger, refund.Items); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UTC()
+	refund.Status = domain.RefundStatusPending
+	refund.CreatedAt = now
+	refund.UpdatedAt = now
+
+	err = tx.QueryRowContext(
+		ctx,
+		`INSERT INTO refunds (order_id, payment_id, status, amount, currency, reason, restock, actor_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+		RETURNING id`,
+		refund.OrderID,
+		refund.PaymentID,
+		refund.Status,
+		refund.Amount.Amount,
+		refund.Amount.Currency,
+		refund.Reason,
+		refund.Restock,
+		refund.ActorID,
+		now,
+	).Scan(&refund.ID)
+	if err != nil {
+		r.logger.Error("Failed to create refund", zap.Int64("orderID", refund.OrderID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	for i := range refund.Items {
+		err = tx.QueryRowContext(
+			ctx,
+			`INSERT INTO refund_items (refund_id, order_item_id, quantity, amount) VALUES ($1, $2, $3, $4) RETURNING id`,
+			refund.ID,
+			refund.Items[i].OrderItemID,
+			refund.Items[i].Quantity,
+			refund.Items[i].Amount.Amount,
+		).Scan(&refund.Items[i].ID)
+		if err != nil {
+			r.logger.Error("Failed to create refund item", zap.Int64("refundID", refund.ID), zap.Error(err))
+			return pkgerrors.NewInternalError(err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	return nil
+}
+
+// Complete stores the final status of a pending refund. The units of a
+// succeeded refund that asked for a restock are returned to stock, at most
+// once per unit ordered.
+func (r *refundRepository) Complete(ctx context.Context, refund *domain.Refund) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+	defer r.rollback(tx)
+
+	now := time.Now().UTC()
+	refund.UpdatedAt = now
+
+	result, err := tx.ExecContext(
+		ctx,
+		`UPDATE refunds SET status = $1, provider_reference = $2, failure_message = $3, updated_at = $4 WHERE id = $5 AND status = 'pending'`,
+		refund.Status,
+		nullString(refund.ProviderReference),
+		nullString(refund.FailureMessage),
+		now,
+		refund.ID,
+	)
+	if err != nil {
+		r.logger.Error("Failed to update refund", zap.Int64("id", refund.ID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		return pkgerrors.NewAppError(pkgerrors.ErrConflict, "Refund has already been completed", http.StatusConflict)
+	}
+
+	if refund.Status == domain.RefundStatusSucceeded && refund.Restock {
+		if err = r.restock(ctx, tx, refund, now); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	return nil
+}
+
+// restock returns the units of a refund to stock within tx. Lines are
+// locked in ID order so concurrent restocks cannot deadlock.
+func (r *refundRepository) restock(ctx context.Context, tx *sql.Tx, refund *domain.Refund, now time.Time) error {
+	lines := append([]domain.RefundItem{}, refund.Items...)
+	sort.Slice(lines, func(i, j int) bool { return lines[i].OrderItemID < lines[j].OrderItemID })
+
+	for _, line := range lines {
+		if line.Quantity == 0 {
+			continue
+		}
+		if err := restockReturnedUnits(ctx, tx, r.logger, line.OrderItemID, line.Quantity, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rollback rolls back tx unless it has already been committed
+func (r *refundRepository) rollback(tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		r.logger.Error("Failed to rollback transaction", zap.Error(err))
+	}
+}
+
+// querier is satisfied by *sql.DB and *sql.Tx
+type querier interface {
+	queryRower
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// refundedTotals totals the pending and succeeded refunds of a payment
+func refundedTotals(ctx context.Context, db querier, paymentID int64) (domain.RefundedTotals, error) {
+	totals := domain.RefundedTotals{Items: make(map[int64]domain.RefundedItem)}
+
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE payment_id = $1 AND `+refundCounted,
+		paymentID,
+	).Scan(&totals.Amount)
+	if err != nil {
+		return totals, err
+	}
+
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT ri.order_item_id, SUM(ri.quantity), SUM(ri.amount)
+		FROM refund_items ri
+		JOIN refunds rf ON rf.id = ri.refund_id
+		WHERE rf.payment_id = $1 AND rf.`+refundCounted+`
+		GROUP BY ri.order_item_id`,
+		paymentID,
+	)
+	if err != nil {
+		return totals, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var itemID int64
+		var item domain.RefundedItem
+		if err := rows.Scan(&itemID, &item.Quantity, &item.Amount); err != nil {
+			return totals, err
+		}
+		totals.Items[itemID] = item
+	}
+
+	return totals, rows.Err()
+}
+
+// readOrderItems reads the quantities and prices of an order's items within tx
+func readOrderItems(ctx context.Context, tx *sql.Tx, logger logger.Logger, orderID int64) ([]domain.OrderItem, error) {
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT id, quantity, cancelled_quantity, price, discount, currency FROM order_items WHERE order_id = $1`,
+		orderID,
+	)
+	if err != nil {
+		logger.Error("Failed to get order items", zap.Int64("orderID", orderID), zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
+	}
+	defer rows.Close()
+
+	var items []domain.OrderItem
+	for rows.Next() {
+		var item domain.OrderItem
+		if err := rows.Scan(&item.ID, &item.Quantity, &item.CancelledQuantity, &item.Price.Amount, &item.Discount.Amount, &item.Price.Currency); err != nil {
+			logger.Error("Failed to scan order item", zap.Error(err))
+			return nil, pkgerrors.NewInternalError(err)
+		}
+		item.OrderID = orderID
+		item.Discount.Currency = item.Price.Currency
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating order item rows", zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
+	}
+
+	return items, nil
+}
+
+// checkRestockable returns a conflict when refund lines would restock more
+// units of an order item than are still out of stock, so the refund is
+// rejected before any money is returned
+func checkRestockable(ctx context.Context, tx *sql.Tx, logger logger.Logger, lines []domain.RefundItem) error {
+	quantities := make(map[int64]int, len(lines))
+	for _, line := range lines {
+		quantities[line.OrderItemID] += line.Quantity
+	}
+
+	for _, id := range sortedIDs(quantities) {
+		if quantities[id] == 0 {
+			continue
+		}
+		var remaining int
+		err := tx.QueryRowContext(ctx, `SELECT quantity - restocked_quantity FROM order_items WHERE id = $1`, id).Scan(&remaining)
+		if err != nil {
+			logger.Error("Failed to get restockable units", zap.Int64("orderItemID", id), zap.Error(err))
+			return pkgerrors.NewInternalError(err)
+		}
+		if quantities[id] > remaining {
+			return restockConflict(id, quantities[id])
+		}
+	}
+
+	return nil
+}
+
+// restockConflict returns the conflict of restocking quantity units of an
+// order item that has fewer units out of stock
+func restockConflict(orderItemID int64, quantity int) error {
+	return &pkgerrors.AppError{
+		Err:        pkgerrors.ErrConflict,
+		Message:    fmt.Sprintf("Order item %d has fewer than %d units left to restock", orderItemID, quantity),
+		StatusCode: http.StatusConflict,
+		Code:       "restock_limit_exceeded",
+	}
+}
+
+// restockReturnedUnits returns quantity units of an order item to stock
+// within tx. Units already back in stock, whether cancelled, refunded or
+// returned, are never restocked twice; asking for more is a conflict.
+func restockReturnedUnits(ctx context.Context, tx *sql.Tx, logger logger.Logger, orderItemID int64, quantity int, now time.Time) error {
+	item := domain.OrderItem{ID: orderItemID}
+	err := tx.QueryRowContext(
+		ctx,
+		`UPDATE order_items SET restocked_quantity = restocked_quantity + $1, updated_at = $2
+		WHERE id = $3 AND restocked_quantity + $1 <= quantity
+		RETURNING product_id, variant_id`,
+		quantity,
+		now,
+		orderItemID,
+	).Scan(&item.ProductID, &item.VariantID)
+	if err == sql.ErrNoRows {
+		return restockConflict(orderItemID, quantity)
+	}
+	if err != nil {
+		logger.Error("Failed to mark order item restocked", zap.Int64("orderItemID", orderItemID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	return restockItem(ctx, tx, logger, item, quantity, now)
+}
@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+)
+
+// TestRefundRepository_Create tests that refunds stay within the captured amount and restock once
+func TestRefundRepository_Create(t *testing.T) {
+	db := openTestDB(t)
+	orderRepo := NewOrderRepository(db, logger.NewLogger("error"))
+	paymentRepo := NewPaymentRepository(db, logger.NewLogger("error"))
+	repo := NewRefundRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	productID := seedProduct(t, db, 5)
+	order := createTestOrder(t, orderRepo, seedUser(t, db), productID, 2)
+	assertStock(t, db, productID, 3)
+
+	payment := &domain.Payment{
+		OrderID:        order.ID,
+		Provider:       "fake",
+		Method:         order.PaymentMethod,
+		Status:         domain.PaymentStatusCaptured,
+		Amount:         order.TotalAmount,
+		CapturedAmount: order.TotalAmount,
+	}
+	if err := paymentRepo.Create(ctx, payment); err != nil {
+		t.Fatalf("Failed to create payment: %v", err)
+	}
+
+	usd := func(amount int64) domain.Money { return domain.NewMoney(amount, domain.CurrencyUSD) }
+	itemID := order.Items[0].ID
+
+	refund := &domain.Refund{
+		OrderID:   order.ID,
+		PaymentID: payment.ID,
+		Amount:    usd(1000),
+		Restock:   true,
+		Items:     []domain.RefundItem{{OrderItemID: itemID, Quantity: 1, Amount: usd(1000)}},
+	}
+	if err := repo.Create(ctx, refund); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The pending refund counts against the captured amount
+	tooMuch := &domain.Refund{OrderID: order.ID, PaymentID: payment.ID, Amount: usd(1001)}
+	var validationErr *domain.ValidationError
+	if err := repo.Create(ctx, tooMuch); !errors.As(err, &validationErr) {
+		t.Errorf("Expected validation error, got %v", err)
+	}
+
+	refund.Status = domain.RefundStatusSucceeded
+	refund.ProviderReference = "fake_refund_000001"
+	if err := repo.Complete(ctx, refund); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	assertStock(t, db, productID, 4)
+
+	// A refund is completed, and its units restocked, only once
+	if err := repo.Complete(ctx, refund); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("Expected conflict, got %v", err)
+	}
+	assertStock(t, db, productID, 4)
+
+	// Units back in stock, here through a return, cannot be restocked again
+	if _, err := db.ExecContext(ctx, `UPDATE order_items SET restocked_quantity = quantity WHERE id = $1`, itemID); err != nil {
+		t.Fatalf("Failed to restock order item: %v", err)
+	}
+	again := &domain.Refund{
+		OrderID:   order.ID,
+		PaymentID: payment.ID,
+		Amount:    usd(1),
+		Restock:   true,
+		Items:     []domain.RefundItem{{OrderItemID: itemID, Quantity: 1, Amount: usd(1)}},
+	}
+	if err := repo.Create(ctx, again); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("Expected conflict, got %v", err)
+	}
+	assertStock(t, db, productID, 4)
+
+	totals, err := repo.RefundedTotals(ctx, payment.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if totals.Amount != 1000 || totals.Items[itemID].Quantity != 1 {
+		t.Errorf("Expected 1000 refunded over 1 unit, got %+v", totals)
+	}
+
+	refunds, err := repo.FindByOrderID(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(refunds) != 1 || refunds[0].Status != domain.RefundStatusSucceeded || len(refunds[0].Items) != 1 {
+		t.Errorf("Expected one succeeded refund with its item, got %+v", refunds)
+	}
+}
@@ -0,0 +1,332 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	pkgerrors "github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const returnColumns = `id, order_id, user_id, status, reason, note, created_at, updated_at`
+
+type returnRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// NewReturnRepository creates a new return repository
+func NewReturnRepository(db *sql.DB, logger logger.Logger) domain.ReturnRepository {
+	return &returnRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// FindByID finds a return by ID with its items
+func (r *returnRepository) FindByID(ctx context.Context, id int64) (*domain.Return, error) {
+	returns, err := r.find(ctx, `id = $1`, id)
+	if err != nil {
+		r.logger.Error("Failed to find return by ID", zap.Int64("id", id), zap.Error(err))
+		return nil, err
+	}
+
+	if len(returns) == 0 {
+		return nil, pkgerrors.NewNotFoundError("Return", id)
+	}
+
+	return &returns[0], nil
+}
+
+// FindByOrderID finds the returns of an order with their items, oldest first
+func (r *returnRepository) FindByOrderID(ctx context.Context, orderID int64) ([]domain.Return, error) {
+	returns, err := r.find(ctx, `order_id = $1`, orderID)
+	if err != nil {
+		r.logger.Error("Failed to find returns by order ID", zap.Int64("orderID", orderID), zap.Error(err))
+		return nil, err
+	}
+	return returns, nil
+}
+
+// Create records a requested return. The order row is locked while the
+// return is checked against the units ordered and those in other returns.
+func (r *returnRepository) Create(ctx context.Context, rma *domain.Return) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+	defer r.rollback(tx)
+
+	var orderID int64
+	err = tx.QueryRowContext(ctx, `SELECT id FROM orders WHERE id = $1 FOR UPDATE`, rma.OrderID).Scan(&orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return pkgerrors.NewNotFoundError("Order", rma.OrderID)
+		}
+		r.logger.Error("Failed to lock order", zap.Int64("orderID", rma.OrderID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	items, err := readOrderItems(ctx, tx, r.logger, rma.OrderID)
+	if err != nil {
+		return err
+	}
+
+	returned, err := r.returnedQuantities(ctx, tx, rma.OrderID)
+	if err != nil {
+		return err
+	}
+
+	if err = domain.CheckReturn(rma, items, returned); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	rma.Status = domain.ReturnStatusRequested
+	rma.CreatedAt = now
+	rma.UpdatedAt = now
+
+	err = tx.QueryRowContext(
+		ctx,
+		`INSERT INTO returns (order_id, user_id, status, reason, note, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		RETURNING id`,
+		rma.OrderID,
+		rma.UserID,
+		rma.Status,
+		rma.Reason,
+		rma.Note,
+		now,
+	).Scan(&rma.ID)
+	if err != nil {
+		r.logger.Error("Failed to create return", zap.Int64("orderID", rma.OrderID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	for i := range rma.Items {
+		rma.Items[i].ReturnID = rma.ID
+		err = tx.QueryRowContext(
+			ctx,
+			`INSERT INTO return_items (return_id, order_item_id, quantity, reason) VALUES ($1, $2, $3, $4) RETURNING id`,
+			rma.ID,
+			rma.Items[i].OrderItemID,
+			rma.Items[i].Quantity,
+			rma.Items[i].Reason,
+		).Scan(&rma.Items[i].ID)
+		if err != nil {
+			r.logger.Error("Failed to create return item", zap.Int64("returnID", rma.ID), zap.Error(err))
+			return pkgerrors.NewInternalError(err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	return nil
+}
+
+// UpdateStatus moves a return from status from to rma.Status. Inspecting a
+// return stores the disposition of each item and restocks the items to
+// restock in the same transaction.
+func (r *returnRepository) UpdateStatus(ctx context.Context, rma *domain.Return, from domain.ReturnStatus) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+	defer r.rollback(tx)
+
+	now := time.Now().UTC()
+	rma.UpdatedAt = now
+
+	result, err := tx.ExecContext(
+		ctx,
+		`UPDATE returns SET status = $1, note = $2, updated_at = $3 WHERE id = $4 AND status = $5`,
+		rma.Status,
+		rma.Note,
+		now,
+		rma.ID,
+		from,
+	)
+	if err != nil {
+		r.logger.Error("Failed to update return status", zap.Int64("id", rma.ID), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		return pkgerrors.NewAppError(pkgerrors.ErrConflict, "Return has been changed by another request", http.StatusConflict)
+	}
+
+	if rma.Status == domain.ReturnStatusInspected {
+		if err = r.applyDispositions(ctx, tx, rma, now); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+
+	return nil
+}
+
+// applyDispositions stores the disposition of each returned item within tx
+// and restocks the items to restock. Lines are locked in order item ID order
+// so concurrent restocks cannot deadlock.
+func (r *returnRepository) applyDispositions(ctx context.Context, tx *sql.Tx, rma *domain.Return, now time.Time) error {
+	lines := append([]domain.ReturnItem{}, rma.Items...)
+	sort.Slice(lines, func(i, j int) bool { return lines[i].OrderItemID < lines[j].OrderItemID })
+
+	for _, line := range lines {
+		_, err := tx.ExecContext(
+			ctx,
+			`UPDATE return_items SET disposition = $1 WHERE id = $2 AND return_id = $3`,
+			line.Disposition,
+			line.ID,
+			rma.ID,
+		)
+		if err != nil {
+			r.logger.Error("Failed to update return item disposition", zap.Int64("returnItemID", line.ID), zap.Error(err))
+			return pkgerrors.NewInternalError(err)
+		}
+
+		if line.Disposition != domain.ReturnDispositionRestock {
+			continue
+		}
+		if err = restockReturnedUnits(ctx, tx, r.logger, line.OrderItemID, line.Quantity, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// find finds the returns matching condition, oldest first, with their items
+func (r *returnRepository) find(ctx context.Context, condition string, args ...interface{}) ([]domain.Return, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+returnColumns+` FROM returns WHERE `+condition+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, pkgerrors.NewInternalError(err)
+	}
+	defer rows.Close()
+
+	returns := []domain.Return{}
+	index := make(map[int64]int)
+	var ids []int64
+	for rows.Next() {
+		var rma domain.Return
+		if err := rows.Scan(
+			&rma.ID,
+			&rma.OrderID,
+			&rma.UserID,
+			&rma.Status,
+			&rma.Reason,
+			&rma.Note,
+			&rma.CreatedAt,
+			&rma.UpdatedAt,
+		); err != nil {
+			return nil, pkgerrors.NewInternalError(err)
+		}
+
+		rma.Items = []domain.ReturnItem{}
+		index[rma.ID] = len(returns)
+		ids = append(ids, rma.ID)
+		returns = append(returns, rma)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, pkgerrors.NewInternalError(err)
+	}
+
+	if len(ids) == 0 {
+		return returns, nil
+	}
+
+	itemRows, err := r.db.QueryContext(
+		ctx,
+		`SELECT id, return_id, order_item_id, quantity, reason, disposition FROM return_items WHERE return_id = ANY($1) ORDER BY id`,
+		pq.Array(ids),
+	)
+	if err != nil {
+		return nil, pkgerrors.NewInternalError(err)
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var item domain.ReturnItem
+		var disposition sql.NullString
+		if err := itemRows.Scan(&item.ID, &item.ReturnID, &item.OrderItemID, &item.Quantity, &item.Reason, &disposition); err != nil {
+			return nil, pkgerrors.NewInternalError(err)
+		}
+
+		item.Disposition = domain.ReturnDisposition(disposition.String)
+		rma := &returns[index[item.ReturnID]]
+		rma.Items = append(rma.Items, item)
+	}
+
+	if err := itemRows.Err(); err != nil {
+		return nil, pkgerrors.NewInternalError(err)
+	}
+
+	return returns, nil
+}
+
+// returnedQuantities totals the units of each order item in returns that
+// have not been rejected, within tx
+func (r *returnRepository) returnedQuantities(ctx context.Context, tx *sql.Tx, orderID int64) (map[int64]int, error) {
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT ri.order_item_id, SUM(ri.quantity)
+		FROM return_items ri
+		JOIN returns rt ON rt.id = ri.return_id
+		WHERE rt.order_id = $1 AND rt.status <> 'rejected'
+		GROUP BY ri.order_item_id`,
+		orderID,
+	)
+	if err != nil {
+		r.logger.Error("Failed to total returned quantities", zap.Int64("orderID", orderID), zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
+	}
+	defer rows.Close()
+
+	returned := make(map[int64]int)
+	for rows.Next() {
+		var itemID int64
+		var quantity int
+		if err := rows.Scan(&itemID, &quantity); err != nil {
+			r.logger.Error("Failed to scan returned quantity", zap.Error(err))
+			return nil, pkgerrors.NewInternalError(err)
+		}
+		returned[itemID] = quantity
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Error iterating returned quantity rows", zap.Error(err))
+		return nil, pkgerrors.NewInternalError(err)
+	}
+
+	return returned, nil
+}
+
+// rollback rolls back tx unless it has already been committed
+func (r *returnRepository) rollback(tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		r.logger.Error("Failed to rollback transaction", zap.Error(err))
+	}
+}
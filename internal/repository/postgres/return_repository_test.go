@@ -0,0 +1,68 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+)
+
+// TestReturnRepository_Inspect tests that returns stay within the units ordered and restock on inspection
+func TestReturnRepository_Inspect(t *testing.T) {
+	db := openTestDB(t)
+	orderRepo := NewOrderRepository(db, logger.NewLogger("error"))
+	repo := NewReturnRepository(db, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	productID := seedProduct(t, db, 5)
+	userID := seedUser(t, db)
+	order := createTestOrder(t, orderRepo, userID, productID, 2)
+	assertStock(t, db, productID, 3)
+	itemID := order.Items[0].ID
+
+	rma := &domain.Return{
+		OrderID: order.ID,
+		UserID:  userID,
+		Reason:  "Wrong size",
+		Items:   []domain.ReturnItem{{OrderItemID: itemID, Quantity: 2}},
+	}
+	if err := repo.Create(ctx, rma); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	second := &domain.Return{OrderID: order.ID, UserID: userID, Reason: "Wrong color", Items: []domain.ReturnItem{{OrderItemID: itemID, Quantity: 1}}}
+	var validationErr *domain.ValidationError
+	if err := repo.Create(ctx, second); !errors.As(err, &validationErr) {
+		t.Errorf("Expected validation error, got %v", err)
+	}
+
+	for _, next := range []domain.ReturnStatus{domain.ReturnStatusApproved, domain.ReturnStatusReceived} {
+		from := rma.Status
+		rma.Status = next
+		if err := repo.UpdateStatus(ctx, rma, from); err != nil {
+			t.Fatalf("%s: expected no error, got %v", next, err)
+		}
+	}
+
+	// A stale status is refused
+	rma.Status = domain.ReturnStatusInspected
+	if err := repo.UpdateStatus(ctx, rma, domain.ReturnStatusApproved); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("Expected conflict, got %v", err)
+	}
+
+	rma.Items[0].Disposition = domain.ReturnDispositionRestock
+	if err := repo.UpdateStatus(ctx, rma, domain.ReturnStatusReceived); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	assertStock(t, db, productID, 5)
+
+	stored, err := repo.FindByID(ctx, rma.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stored.Status != domain.ReturnStatusInspected || len(stored.Items) != 1 || stored.Items[0].Disposition != domain.ReturnDispositionRestock {
+		t.Errorf("Expected an inspected return with its disposition, got %+v", stored)
+	}
+}
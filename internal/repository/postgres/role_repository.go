@@ -0,0 +1,301 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// roleRepository implements domain.RoleRepository
+type roleRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// NewRoleRepository creates a new role repository
+func NewRoleRepository(db *sql.DB, logger logger.Logger) domain.RoleRepository {
+	return &roleRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// FindAll gets every role with its permissions
+func (r *roleRepository) FindAll(ctx context.Context) ([]domain.RoleDefinition, error) {
+	query := `
+		SELECT r.name, r.description, r.created_at, r.updated_at,
+			COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role = r.name
+		GROUP BY r.name
+		ORDER BY r.name
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		r.logger.Error("Failed to list roles", zap.Error(err))
+		return nil, domain.ErrInternalServer
+	}
+	defer rows.Close()
+
+	roles := []domain.RoleDefinition{}
+	for rows.Next() {
+		var role domain.RoleDefinition
+		var permissions pq.StringArray
+		if err := rows.Scan(&role.Name, &role.Description, &role.CreatedAt, &role.UpdatedAt, &permissions); err != nil {
+			r.logger.Error("Failed to scan role", zap.Error(err))
+			return nil, domain.ErrInternalServer
+		}
+		role.Permissions = toPermissions(permissions)
+		roles = append(roles, role)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Error iterating roles", zap.Error(err))
+		return nil, domain.ErrInternalServer
+	}
+
+	return roles, nil
+}
+
+// FindByName gets a role with its permissions
+func (r *roleRepository) FindByName(ctx context.Context, name domain.Role) (*domain.RoleDefinition, error) {
+	query := `
+		SELECT name, description, created_at, updated_at
+		FROM roles
+		WHERE name = $1
+	`
+
+	var role domain.RoleDefinition
+	err := r.db.QueryRowContext(ctx, query, name).Scan(
+		&role.Name,
+		&role.Description,
+		&role.CreatedAt,
+		&role.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &domain.NotFoundError{
+				Entity: "Role",
+				ID:     name,
+			}
+		}
+		r.logger.Error("Failed to get role", zap.String("name", string(name)), zap.Error(err))
+		return nil, domain.ErrInternalServer
+	}
+
+	role.Permissions, err = r.GetPermissions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+// Create creates a role and grants its permissions
+func (r *roleRepository) Create(ctx context.Context, role *domain.RoleDefinition) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", zap.Error(err))
+		return domain.ErrInternalServer
+	}
+	defer func() {
+		// Rollback is a no-op once the transaction has been committed
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			r.logger.Error("Failed to rollback transaction", zap.Error(rbErr))
+		}
+	}()
+
+	now := time.Now().UTC()
+	role.CreatedAt = now
+	role.UpdatedAt = now
+
+	query := `
+		INSERT INTO roles (name, description, created_at, updated_at)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	if _, err := tx.ExecContext(ctx, query, role.Name, role.Description, role.CreatedAt, role.UpdatedAt); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return &domain.ConflictError{
+				Entity: "Role",
+				Field:  "name",
+				Value:  role.Name,
+			}
+		}
+		r.logger.Error("Failed to create role", zap.String("name", string(role.Name)), zap.Error(err))
+		return domain.ErrInternalServer
+	}
+
+	if err := r.replacePermissions(ctx, tx, role); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", zap.Error(err))
+		return domain.ErrInternalServer
+	}
+
+	return nil
+}
+
+// Update updates a role's description and replaces its permissions
+func (r *roleRepository) Update(ctx context.Context, role *domain.RoleDefinition) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", zap.Error(err))
+		return domain.ErrInternalServer
+	}
+	defer func() {
+		// Rollback is a no-op once the transaction has been committed
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			r.logger.Error("Failed to rollback transaction", zap.Error(rbErr))
+		}
+	}()
+
+	role.UpdatedAt = time.Now().UTC()
+
+	query := `
+		UPDATE roles
+		SET description = $1, updated_at = $2
+		WHERE name = $3
+	`
+
+	result, err := tx.ExecContext(ctx, query, role.Description, role.UpdatedAt, role.Name)
+	if err != nil {
+		r.logger.Error("Failed to update role", zap.String("name", string(role.Name)), zap.Error(err))
+		return domain.ErrInternalServer
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", zap.Error(err))
+		return domain.ErrInternalServer
+	}
+
+	if rowsAffected == 0 {
+		return &domain.NotFoundError{
+			Entity: "Role",
+			ID:     role.Name,
+		}
+	}
+
+	if err := r.replacePermissions(ctx, tx, role); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", zap.Error(err))
+		return domain.ErrInternalServer
+	}
+
+	return nil
+}
+
+// Delete deletes a role; its permission grants are removed by cascade
+func (r *roleRepository) Delete(ctx context.Context, name domain.Role) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM roles WHERE name = $1", name)
+	if err != nil {
+		r.logger.Error("Failed to delete role", zap.String("name", string(name)), zap.Error(err))
+		return domain.ErrInternalServer
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", zap.Error(err))
+		return domain.ErrInternalServer
+	}
+
+	if rowsAffected == 0 {
+		return &domain.NotFoundError{
+			Entity: "Role",
+			ID:     name,
+		}
+	}
+
+	return nil
+}
+
+// GetPermissions gets the permissions granted to a role
+func (r *roleRepository) GetPermissions(ctx context.Context, name domain.Role) ([]domain.Permission, error) {
+	query := `
+		SELECT permission
+		FROM role_permissions
+		WHERE role = $1
+		ORDER BY permission
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, name)
+	if err != nil {
+		r.logger.Error("Failed to get role permissions", zap.String("name", string(name)), zap.Error(err))
+		return nil, domain.ErrInternalServer
+	}
+	defer rows.Close()
+
+	permissions := []domain.Permission{}
+	for rows.Next() {
+		var permission domain.Permission
+		if err := rows.Scan(&permission); err != nil {
+			r.logger.Error("Failed to scan role permission", zap.Error(err))
+			return nil, domain.ErrInternalServer
+		}
+		permissions = append(permissions, permission)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Error iterating role permissions", zap.Error(err))
+		return nil, domain.ErrInternalServer
+	}
+
+	return permissions, nil
+}
+
+// CountUsers counts the users that currently hold a role
+func (r *roleRepository) CountUsers(ctx context.Context, name domain.Role) (int, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE role = $1", name).Scan(&count); err != nil {
+		r.logger.Error("Failed to count users with role", zap.String("name", string(name)), zap.Error(err))
+		return 0, domain.ErrInternalServer
+	}
+	return count, nil
+}
+
+// replacePermissions replaces the permission grants of a role within a transaction
+func (r *roleRepository) replacePermissions(ctx context.Context, tx *sql.Tx, role *domain.RoleDefinition) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM role_permissions WHERE role = $1", role.Name); err != nil {
+		r.logger.Error("Failed to clear role permissions", zap.String("name", string(role.Name)), zap.Error(err))
+		return domain.ErrInternalServer
+	}
+
+	for _, permission := range role.Permissions {
+		_, err := tx.ExecContext(
+			ctx,
+			"INSERT INTO role_permissions (role, permission) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+			role.Name,
+			permission,
+		)
+		if err != nil {
+			r.logger.Error("Failed to grant role permission", zap.String("name", string(role.Name)), zap.String("permission", string(permission)), zap.Error(err))
+			return domain.ErrInternalServer
+		}
+	}
+
+	return nil
+}
+
+// toPermissions converts a scanned text array into permissions
+func toPermissions(values []string) []domain.Permission {
+	permissions := make([]domain.Permission, len(values))
+	for i, value := range values {
+		permissions[i] = domain.Permission(value)
+	}
+	return permissions
+}
@@ -0,0 +1,183 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// refreshTokenRepository implements domain.RefreshTokenRepository
+type refreshTokenRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// NewRefreshTokenRepository creates a new refresh token repository
+func NewRefreshTokenRepository(db *sql.DB, logger logger.Logger) domain.RefreshTokenRepository {
+	return &refreshTokenRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create stores a new refresh token
+func (r *refreshTokenRepository) Create(ctx context.Context, token *domain.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	token.CreatedAt = time.Now().UTC()
+
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		token.UserID,
+		token.TokenHash,
+		token.FamilyID,
+		token.ExpiresAt,
+		token.CreatedAt,
+	).Scan(&token.ID)
+
+	if err != nil {
+		r.logger.Error("Failed to create refresh token", zap.Int64("userID", token.UserID), zap.Error(err))
+		return domain.ErrInternalServer
+	}
+
+	return nil
+}
+
+// GetByHash gets a refresh token by the hash of its value
+func (r *refreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	query := `
+		SELECT id, user_id, token_hash, family_id, expires_at, revoked_at, created_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`
+
+	var token domain.RefreshToken
+	var revokedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.FamilyID,
+		&token.ExpiresAt,
+		&revokedAt,
+		&token.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &domain.NotFoundError{
+				Entity: "RefreshToken",
+				ID:     "hash",
+			}
+		}
+		r.logger.Error("Failed to get refresh token", zap.Error(err))
+		return nil, domain.ErrInternalServer
+	}
+
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+
+	return &token, nil
+}
+
+// Revoke revokes a single refresh token. It reports false when the token was
+// already revoked, which lets callers detect concurrent reuse during rotation.
+func (r *refreshTokenRepository) Revoke(ctx context.Context, id int64) (bool, error) {
+	query := `UPDATE refresh_tokens SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		r.logger.Error("Failed to revoke refresh token", zap.Int64("id", id), zap.Error(err))
+		return false, domain.ErrInternalServer
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", zap.Error(err))
+		return false, domain.ErrInternalServer
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// RevokeFamily revokes every token in a rotation chain
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL`
+
+	if _, err := r.db.ExecContext(ctx, query, time.Now(), familyID); err != nil {
+		r.logger.Error("Failed to revoke refresh token family", zap.String("familyID", familyID), zap.Error(err))
+		return domain.ErrInternalServer
+	}
+
+	return nil
+}
+
+// RevokeAllForUser revokes every active refresh token of a user
+func (r *refreshTokenRepository) RevokeAllForUser(ctx context.Context, userID int64) error {
+	query := `UPDATE refresh_tokens SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`
+
+	if _, err := r.db.ExecContext(ctx, query, time.Now(), userID); err != nil {
+		r.logger.Error("Failed to revoke refresh tokens for user", zap.Int64("userID", userID), zap.Error(err))
+		return domain.ErrInternalServer
+	}
+
+	return nil
+}
+
+// revokedTokenRepository implements domain.RevokedTokenRepository
+type revokedTokenRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// NewRevokedTokenRepository creates a new revoked access token repository
+func NewRevokedTokenRepository(db *sql.DB, logger logger.Logger) domain.RevokedTokenRepository {
+	return &revokedTokenRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Revoke records an access token ID as revoked until it expires
+func (r *revokedTokenRepository) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens (jti, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, jti, expiresAt); err != nil {
+		r.logger.Error("Failed to revoke access token", zap.String("jti", jti), zap.Error(err))
+		return domain.ErrInternalServer
+	}
+
+	// Entries are only needed until the token would have expired anyway
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at < $1`, time.Now()); err != nil {
+		r.logger.Warn("Failed to prune expired revoked tokens", zap.Error(err))
+	}
+
+	return nil
+}
+
+// IsRevoked checks whether an access token ID has been revoked
+func (r *revokedTokenRepository) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = $1)`, jti).Scan(&exists)
+	if err != nil {
+		r.logger.Error("Failed to check revoked token", zap.String("jti", jti), zap.Error(err))
+		return false, domain.ErrInternalServer
+	}
+
+	return exists, nil
+}
@@ -0,0 +1,27 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	pkgerrors "github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// versionConflict explains why an update of row id of table, which holds
+// entity rows, at version matched no rows: either the row does not exist or
+// it has moved past that version
+func versionConflict(ctx context.Context, db *sql.DB, logger logger.Logger, table, entity string, id, version int64) error {
+	var exists bool
+	err := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM `+table+` WHERE id = $1)`, id).Scan(&exists)
+	if err != nil {
+		logger.Error("Failed to check if row exists", zap.String("entity", entity), zap.Int64("id", id), zap.Error(err))
+		return pkgerrors.NewInternalError(err)
+	}
+	if !exists {
+		return pkgerrors.NewNotFoundError(entity, id)
+	}
+	return &domain.VersionConflictError{Entity: entity, ID: id, Version: version}
+}
@@ -0,0 +1,338 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	pkgerrors "github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type cartUseCase struct {
+	cartRepo     domain.CartRepository
+	productRepo  domain.ProductRepository
+	orderUseCase domain.OrderUseCase
+	logger       logger.Logger
+}
+
+// NewCartUseCase creates a new cart use case
+func NewCartUseCase(cartRepo domain.CartRepository, productRepo domain.ProductRepository, orderUseCase domain.OrderUseCase, logger logger.Logger) domain.CartUseCase {
+	return &cartUseCase{
+		cartRepo:     cartRepo,
+		productRepo:  productRepo,
+		orderUseCase: orderUseCase,
+		logger:       logger,
+	}
+}
+
+// Get gets a cart with its prices and stock revalidated
+func (u *cartUseCase) Get(ctx context.Context, owner domain.CartOwner) (*domain.Cart, error) {
+	cart, err := u.find(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.revalidate(ctx, cart); err != nil {
+		return nil, err
+	}
+
+	return cart, nil
+}
+
+// AddItem adds a product or variant to a cart at its current price. An
+// anonymous owner without a token gets a new cart, whose token is returned
+// with it.
+func (u *cartUseCase) AddItem(ctx context.Context, owner domain.CartOwner, createDTO *domain.CartItemCreateDTO) (*domain.Cart, error) {
+	product, err := u.productRepo.FindByID(ctx, createDTO.ProductID)
+	if err != nil {
+		u.logger.Error("Failed to find product for cart item", zap.Int64("productID", createDTO.ProductID), zap.Error(err))
+		return nil, pkgerrors.NewBadRequestError("Invalid product ID: " + fmt.Sprintf("%d", createDTO.ProductID))
+	}
+
+	// Products with variants are added by variant
+	variant, err := product.ResolveVariant(createDTO.VariantID)
+	if err != nil {
+		return nil, err
+	}
+
+	cart, err := u.find(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	created := cart.ID == 0
+	if created {
+		token, err := newCartToken()
+		if err != nil {
+			u.logger.Error("Failed to generate cart token", zap.Error(err))
+			return nil, domain.ErrInternalServer
+		}
+		if cart, err = u.cartRepo.CreateAnonymous(ctx, hashCartToken(token)); err != nil {
+			return nil, err
+		}
+		owner.Token = token
+	}
+
+	item := &domain.CartItem{
+		CartID:    cart.ID,
+		ProductID: createDTO.ProductID,
+		VariantID: createDTO.VariantID,
+		Quantity:  createDTO.Quantity,
+		Price:     product.PriceOf(variant),
+	}
+	if err := u.cartRepo.AddItem(ctx, item); err != nil {
+		u.logger.Error("Failed to add cart item", zap.Int64("cartID", cart.ID), zap.Error(err))
+		return nil, err
+	}
+
+	if cart, err = u.Get(ctx, owner); err != nil {
+		return nil, err
+	}
+	if created {
+		cart.Token = owner.Token
+	}
+
+	return cart, nil
+}
+
+// UpdateItem changes the quantity of a cart item
+func (u *cartUseCase) UpdateItem(ctx context.Context, owner domain.CartOwner, itemID int64, updateDTO *domain.CartItemUpdateDTO) (*domain.Cart, error) {
+	cart, err := u.find(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	item := cartItem(cart, itemID)
+	if item == nil {
+		return nil, pkgerrors.NewNotFoundError("Cart item", itemID)
+	}
+
+	item.Quantity = updateDTO.Quantity
+	if err := u.cartRepo.UpdateItem(ctx, item); err != nil {
+		u.logger.Error("Failed to update cart item", zap.Int64("id", itemID), zap.Error(err))
+		return nil, err
+	}
+
+	return u.Get(ctx, owner)
+}
+
+// RemoveItem removes an item from a cart
+func (u *cartUseCase) RemoveItem(ctx context.Context, owner domain.CartOwner, itemID int64) (*domain.Cart, error) {
+	cart, err := u.find(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	if cartItem(cart, itemID) == nil {
+		return nil, pkgerrors.NewNotFoundError("Cart item", itemID)
+	}
+
+	if err := u.cartRepo.RemoveItem(ctx, cart.ID, itemID); err != nil {
+		u.logger.Error("Failed to remove cart item", zap.Int64("id", itemID), zap.Error(err))
+		return nil, err
+	}
+
+	return u.Get(ctx, owner)
+}
+
+// Merge moves the items of the anonymous cart with the given token into the
+// user's cart. An unknown token, such as one already merged, is ignored.
+func (u *cartUseCase) Merge(ctx context.Context, token string, userID int64) error {
+	from, err := u.cartRepo.FindByTokenHash(ctx, hashCartToken(token))
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	to, err := u.cartRepo.FindOrCreateByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := u.cartRepo.Merge(ctx, from.ID, to.ID); err != nil {
+		u.logger.Error("Failed to merge carts", zap.Int64("fromCartID", from.ID), zap.Int64("toCartID", to.ID), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// Checkout turns a user's cart into an order and removes the ordered items
+// from the cart in the same transaction. A cart with issues is refused so
+// the customer can review the changes first; the revalidation has already
+// updated the prices, so checking out again succeeds unless something
+// changed in between. A cart changed after it was read, such as by a
+// concurrent checkout, is refused as well.
+func (u *cartUseCase) Checkout(ctx context.Context, userID int64, checkoutDTO *domain.CartCheckoutDTO) (*domain.Order, error) {
+	cart, err := u.Get(ctx, domain.CartOwner{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cart.Items) == 0 {
+		return nil, pkgerrors.NewBadRequestError("Cart is empty")
+	}
+	if len(cart.Issues) > 0 {
+		return nil, &domain.CartChangedError{Issues: cart.Issues}
+	}
+
+	createDTO := &domain.OrderCreateDTO{
+		UserID:        userID,
+		PaymentMethod: checkoutDTO.PaymentMethod,
+		ShippingInfo:  checkoutDTO.ShippingInfo,
+		CouponCode:    checkoutDTO.CouponCode,
+		CartItems:     cart.Items,
+	}
+	for _, item := range cart.Items {
+		createDTO.Items = append(createDTO.Items, domain.OrderItemCreateDTO{
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			Quantity:  item.Quantity,
+		})
+	}
+
+	order, err := u.orderUseCase.Create(ctx, createDTO)
+	if err != nil {
+		u.logger.Error("Failed to check out cart", zap.Int64("cartID", cart.ID), zap.Error(err))
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// find finds the owner's cart. An anonymous owner without a token, or with
+// one whose cart is gone (such as after it was merged), has an empty cart
+// that is not stored yet.
+func (u *cartUseCase) find(ctx context.Context, owner domain.CartOwner) (*domain.Cart, error) {
+	if !owner.IsAnonymous() {
+		return u.cartRepo.FindOrCreateByUserID(ctx, owner.UserID)
+	}
+
+	if owner.Token != "" {
+		cart, err := u.cartRepo.FindByTokenHash(ctx, hashCartToken(owner.Token))
+		if err == nil || !errors.Is(err, domain.ErrNotFound) {
+			return cart, err
+		}
+	}
+
+	return &domain.Cart{Items: []domain.CartItem{}}, nil
+}
+
+// revalidate checks every item against the current product: changed prices
+// are updated in the cart, and anything the customer should review is
+// recorded as an issue. The subtotal covers the items priced in the cart's
+// currency, which is that of its first item.
+func (u *cartUseCase) revalidate(ctx context.Context, cart *domain.Cart) error {
+	cart.Issues = []domain.CartIssue{}
+	cart.Subtotal = nil
+
+	products := make(map[int64]*domain.Product)
+	for i := range cart.Items {
+		item := &cart.Items[i]
+
+		product, ok := products[item.ProductID]
+		if !ok {
+			var err error
+			if product, err = u.productRepo.FindByID(ctx, item.ProductID); err != nil {
+				u.logger.Error("Failed to find product for cart item", zap.Int64("productID", item.ProductID), zap.Error(err))
+				return err
+			}
+			products[item.ProductID] = product
+		}
+		item.Product = product
+
+		variant, err := product.ResolveVariant(item.VariantID)
+		if err != nil {
+			cart.Issues = append(cart.Issues, domain.CartIssue{
+				ItemID:  item.ID,
+				Code:    domain.CartIssueUnavailable,
+				Message: "No longer available as chosen; please choose again",
+			})
+			continue
+		}
+		item.Variant = variant
+
+		if price := product.PriceOf(variant); price != item.Price {
+			cart.Issues = append(cart.Issues, domain.CartIssue{
+				ItemID:  item.ID,
+				Code:    domain.CartIssuePriceChanged,
+				Message: fmt.Sprintf("Price changed from %s to %s", item.Price, price),
+			})
+			item.Price = price
+			if err := u.cartRepo.UpdateItem(ctx, item); err != nil {
+				u.logger.Error("Failed to update cart item price", zap.Int64("id", item.ID), zap.Error(err))
+				return err
+			}
+		}
+
+		stock := product.Stock
+		if variant != nil {
+			stock = variant.Stock
+		}
+		if item.Quantity > stock {
+			cart.Issues = append(cart.Issues, domain.CartIssue{
+				ItemID:  item.ID,
+				Code:    domain.CartIssueInsufficientStock,
+				Message: fmt.Sprintf("Only %d in stock", stock),
+			})
+		}
+
+		lineTotal, err := item.Price.Mul(int64(item.Quantity))
+		if err != nil {
+			return pkgerrors.NewBadRequestError("Cart total is out of range")
+		}
+		if cart.Subtotal == nil {
+			subtotal := domain.NewMoney(0, item.Price.Currency)
+			cart.Subtotal = &subtotal
+		}
+		subtotal, err := cart.Subtotal.Add(lineTotal)
+		if err != nil {
+			if errors.Is(err, domain.ErrCurrencyMismatch) {
+				cart.Issues = append(cart.Issues, domain.CartIssue{
+					ItemID:  item.ID,
+					Code:    domain.CartIssueCurrencyMismatch,
+					Message: fmt.Sprintf("Priced in %s, the cart is in %s", item.Price.Currency, cart.Subtotal.Currency),
+				})
+				continue
+			}
+			return pkgerrors.NewBadRequestError("Cart total is out of range")
+		}
+		*cart.Subtotal = subtotal
+	}
+
+	return nil
+}
+
+// cartItem finds an item of the cart by ID
+func cartItem(cart *domain.Cart, itemID int64) *domain.CartItem {
+	for i := range cart.Items {
+		if cart.Items[i].ID == itemID {
+			return &cart.Items[i]
+		}
+	}
+	return nil
+}
+
+// hashCartToken returns the hex SHA-256 of a cart token, which is all that
+// is stored
+func hashCartToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newCartToken generates a random token for an anonymous cart
+func newCartToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
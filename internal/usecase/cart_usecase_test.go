@@ -0,0 +1,310 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+)
+
+// mockCartRepository is an in-memory cart repository
+type mockCartRepository struct {
+	carts  map[int64]*domain.Cart
+	tokens map[string]int64
+	nextID int64
+}
+
+// newMockCartRepository creates a new mock cart repository
+func newMockCartRepository() *mockCartRepository {
+	return &mockCartRepository{
+		carts:  make(map[int64]*domain.Cart),
+		tokens: make(map[string]int64),
+	}
+}
+
+// FindOrCreateByUserID finds or creates a user's cart
+func (m *mockCartRepository) FindOrCreateByUserID(_ context.Context, userID int64) (*domain.Cart, error) {
+	for _, cart := range m.carts {
+		if cart.UserID != nil && *cart.UserID == userID {
+			return m.copy(cart), nil
+		}
+	}
+	m.nextID++
+	m.carts[m.nextID] = &domain.Cart{ID: m.nextID, UserID: &userID}
+	return m.copy(m.carts[m.nextID]), nil
+}
+
+// FindByTokenHash finds an anonymous cart by token hash
+func (m *mockCartRepository) FindByTokenHash(_ context.Context, tokenHash string) (*domain.Cart, error) {
+	id, ok := m.tokens[tokenHash]
+	if !ok {
+		return nil, &domain.NotFoundError{Entity: "Cart", ID: "token"}
+	}
+	return m.copy(m.carts[id]), nil
+}
+
+// CreateAnonymous creates an anonymous cart
+func (m *mockCartRepository) CreateAnonymous(_ context.Context, tokenHash string) (*domain.Cart, error) {
+	m.nextID++
+	m.carts[m.nextID] = &domain.Cart{ID: m.nextID}
+	m.tokens[tokenHash] = m.nextID
+	return m.copy(m.carts[m.nextID]), nil
+}
+
+// AddItem adds an item, combining it with a line for the same product and variant
+func (m *mockCartRepository) AddItem(_ context.Context, item *domain.CartItem) error {
+	cart := m.carts[item.CartID]
+	for i := range cart.Items {
+		line := &cart.Items[i]
+		if line.ProductID == item.ProductID && variantKey(line.VariantID) == variantKey(item.VariantID) {
+			line.Quantity += item.Quantity
+			line.Price = item.Price
+			item.ID = line.ID
+			return nil
+		}
+	}
+	m.nextID++
+	item.ID = m.nextID
+	cart.Items = append(cart.Items, *item)
+	return nil
+}
+
+// UpdateItem updates an item's quantity and price
+func (m *mockCartRepository) UpdateItem(_ context.Context, item *domain.CartItem) error {
+	cart := m.carts[item.CartID]
+	for i := range cart.Items {
+		if cart.Items[i].ID == item.ID {
+			cart.Items[i].Quantity = item.Quantity
+			cart.Items[i].Price = item.Price
+			return nil
+		}
+	}
+	return &domain.NotFoundError{Entity: "Cart item", ID: item.ID}
+}
+
+// RemoveItem removes an item
+func (m *mockCartRepository) RemoveItem(_ context.Context, cartID, itemID int64) error {
+	cart := m.carts[cartID]
+	for i := range cart.Items {
+		if cart.Items[i].ID == itemID {
+			cart.Items = append(cart.Items[:i], cart.Items[i+1:]...)
+			return nil
+		}
+	}
+	return &domain.NotFoundError{Entity: "Cart item", ID: itemID}
+}
+
+// Merge moves the items of one cart into another and deletes the first
+func (m *mockCartRepository) Merge(ctx context.Context, fromCartID, toCartID int64) error {
+	for _, item := range m.carts[fromCartID].Items {
+		item.CartID = toCartID
+		if err := m.AddItem(ctx, &item); err != nil {
+			return err
+		}
+	}
+	delete(m.carts, fromCartID)
+	for hash, id := range m.tokens {
+		if id == fromCartID {
+			delete(m.tokens, hash)
+		}
+	}
+	return nil
+}
+
+// copy returns a cart with its own items, as a fresh read would
+func (m *mockCartRepository) copy(cart *domain.Cart) *domain.Cart {
+	c := *cart
+	c.Items = append([]domain.CartItem{}, cart.Items...)
+	return &c
+}
+
+// variantKey maps a missing variant to 0, like the line index
+func variantKey(id *int64) int64 {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
+// mockProductRepository serves products from memory; only FindByID is used
+type mockProductRepository struct {
+	domain.ProductRepository
+	products map[int64]*domain.Product
+}
+
+// FindByID finds a product by ID
+func (m *mockProductRepository) FindByID(_ context.Context, id int64) (*domain.Product, error) {
+	product, ok := m.products[id]
+	if !ok {
+		return nil, &domain.NotFoundError{Entity: "Product", ID: id}
+	}
+	p := *product
+	return &p, nil
+}
+
+// mockOrderUseCase records the orders created and removes their cart items
+// from carts; only Create is used
+type mockOrderUseCase struct {
+	domain.OrderUseCase
+	carts   *mockCartRepository
+	created []*domain.OrderCreateDTO
+}
+
+// Create records the order, refusing it when a cart item changed since it was read
+func (m *mockOrderUseCase) Create(ctx context.Context, createDTO *domain.OrderCreateDTO) (*domain.Order, error) {
+	for _, item := range createDTO.CartItems {
+		current := cartItem(m.carts.carts[item.CartID], item.ID)
+		if current == nil || current.Quantity != item.Quantity {
+			return nil, &domain.CartChangedError{}
+		}
+	}
+	for _, item := range createDTO.CartItems {
+		if err := m.carts.RemoveItem(ctx, item.CartID, item.ID); err != nil {
+			return nil, err
+		}
+	}
+	m.created = append(m.created, createDTO)
+	return &domain.Order{ID: int64(len(m.created)), UserID: createDTO.UserID}, nil
+}
+
+// TestCartUseCase_AnonymousCart tests creating an anonymous cart and merging it at login
+func TestCartUseCase_AnonymousCart(t *testing.T) {
+	cartRepo := newMockCartRepository()
+	productRepo := &mockProductRepository{products: map[int64]*domain.Product{
+		1: {ID: 1, Price: domain.NewMoney(1000, domain.CurrencyUSD), Stock: 5},
+	}}
+	useCase := NewCartUseCase(cartRepo, productRepo, &mockOrderUseCase{}, &mockLogger{})
+	ctx := context.Background()
+
+	// Without a token there is nothing stored yet
+	cart, err := useCase.Get(ctx, domain.CartOwner{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cart.ID != 0 || len(cart.Items) != 0 {
+		t.Errorf("Expected an empty unsaved cart, got %+v", cart)
+	}
+
+	cart, err = useCase.AddItem(ctx, domain.CartOwner{}, &domain.CartItemCreateDTO{ProductID: 1, Quantity: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cart.Token == "" {
+		t.Fatal("Expected a token for the new cart")
+	}
+	if cart.Subtotal == nil || cart.Subtotal.Amount != 2000 {
+		t.Errorf("Expected subtotal 2000, got %v", cart.Subtotal)
+	}
+
+	// The token finds the cart again without being returned
+	owner := domain.CartOwner{Token: cart.Token}
+	cart, err = useCase.AddItem(ctx, owner, &domain.CartItemCreateDTO{ProductID: 1, Quantity: 1})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cart.Token != "" || len(cart.Items) != 1 || cart.Items[0].Quantity != 3 {
+		t.Errorf("Expected one line of 3 in the same cart, got %+v", cart)
+	}
+
+	if err := useCase.Merge(ctx, owner.Token, 42); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	cart, err = useCase.Get(ctx, domain.CartOwner{UserID: 42})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(cart.Items) != 1 || cart.Items[0].Quantity != 3 {
+		t.Errorf("Expected the merged line, got %+v", cart.Items)
+	}
+
+	// A merged token no longer has a cart
+	if err := useCase.Merge(ctx, owner.Token, 42); err != nil {
+		t.Errorf("Expected merging a spent token to be ignored, got %v", err)
+	}
+	if cart, err = useCase.Get(ctx, owner); err != nil || len(cart.Items) != 0 {
+		t.Errorf("Expected an empty cart for a spent token, got %+v, %v", cart, err)
+	}
+}
+
+// TestCartUseCase_Checkout tests that changed carts are refused until reviewed
+func TestCartUseCase_Checkout(t *testing.T) {
+	cartRepo := newMockCartRepository()
+	product := &domain.Product{ID: 1, Price: domain.NewMoney(1000, domain.CurrencyUSD), Stock: 5}
+	productRepo := &mockProductRepository{products: map[int64]*domain.Product{1: product}}
+	orderUseCase := &mockOrderUseCase{carts: cartRepo}
+	useCase := NewCartUseCase(cartRepo, productRepo, orderUseCase, &mockLogger{})
+	ctx := context.Background()
+	owner := domain.CartOwner{UserID: 7}
+	checkout := &domain.CartCheckoutDTO{PaymentMethod: domain.PaymentMethodCreditCard}
+
+	if _, err := useCase.Checkout(ctx, owner.UserID, checkout); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("Expected bad request for an empty cart, got %v", err)
+	}
+
+	if _, err := useCase.AddItem(ctx, owner, &domain.CartItemCreateDTO{ProductID: 1, Quantity: 2}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The price went up and stock ran low since the item was added
+	product.Price = domain.NewMoney(1200, domain.CurrencyUSD)
+	product.Stock = 1
+
+	_, err := useCase.Checkout(ctx, owner.UserID, checkout)
+	var changedErr *domain.CartChangedError
+	if !errors.As(err, &changedErr) || !errors.Is(err, domain.ErrConflict) {
+		t.Fatalf("Expected a cart changed conflict, got %v", err)
+	}
+	codes := map[domain.CartIssueCode]bool{}
+	for _, issue := range changedErr.Issues {
+		codes[issue.Code] = true
+	}
+	if !codes[domain.CartIssuePriceChanged] || !codes[domain.CartIssueInsufficientStock] {
+		t.Errorf("Expected price and stock issues, got %+v", changedErr.Issues)
+	}
+
+	// The new price was kept, so only the quantity needs fixing
+	cart, err := useCase.UpdateItem(ctx, owner, changedErr.Issues[0].ItemID, &domain.CartItemUpdateDTO{Quantity: 1})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(cart.Issues) != 0 || cart.Subtotal.Amount != 1200 {
+		t.Errorf("Expected no issues and subtotal 1200, got %+v, %v", cart.Issues, cart.Subtotal)
+	}
+
+	order, err := useCase.Checkout(ctx, owner.UserID, checkout)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if order == nil || len(orderUseCase.created) != 1 || orderUseCase.created[0].Items[0].Quantity != 1 {
+		t.Errorf("Expected an order of one unit, got %+v", orderUseCase.created)
+	}
+
+	if cart, _ = useCase.Get(ctx, owner); len(cart.Items) != 0 {
+		t.Errorf("Expected the cart to be emptied, got %+v", cart.Items)
+	}
+}
+
+// TestCartUseCase_RemoveItem tests that items of other carts cannot be removed
+func TestCartUseCase_RemoveItem(t *testing.T) {
+	cartRepo := newMockCartRepository()
+	productRepo := &mockProductRepository{products: map[int64]*domain.Product{
+		1: {ID: 1, Price: domain.NewMoney(1000, domain.CurrencyUSD), Stock: 5},
+	}}
+	useCase := NewCartUseCase(cartRepo, productRepo, &mockOrderUseCase{}, &mockLogger{})
+	ctx := context.Background()
+
+	cart, err := useCase.AddItem(ctx, domain.CartOwner{UserID: 1}, &domain.CartItemCreateDTO{ProductID: 1, Quantity: 1})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	itemID := cart.Items[0].ID
+
+	if _, err = useCase.RemoveItem(ctx, domain.CartOwner{UserID: 2}, itemID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Expected not found for another user's item, got %v", err)
+	}
+
+	if cart, err = useCase.RemoveItem(ctx, domain.CartOwner{UserID: 1}, itemID); err != nil || len(cart.Items) != 0 {
+		t.Errorf("Expected an empty cart, got %+v, %v", cart, err)
+	}
+}
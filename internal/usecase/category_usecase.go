@@ -33,13 +33,13 @@ func (u *categoryUseCase) GetByID(ctx context.Context, id int64) (*domain.Catego
 }
 
 // List lists categories with pagination
-func (u *categoryUseCase) List(ctx context.Context, limit, offset int) ([]domain.Category, int, error) {
-	categories, total, err := u.categoryRepo.FindAll(ctx, limit, offset)
+func (u *categoryUseCase) List(ctx context.Context, page domain.PageRequest) (*domain.Page[domain.Category], error) {
+	categories, err := u.categoryRepo.FindAll(ctx, page)
 	if err != nil {
-		u.logger.Error("Failed to list categories", zap.Int("limit", limit), zap.Int("offset", offset), zap.Error(err))
-		return nil, 0, err
+		u.logger.Error("Failed to list categories", zap.Int("limit", page.Limit), zap.Int("offset", page.Offset), zap.Error(err))
+		return nil, err
 	}
-	return categories, total, nil
+	return categories, nil
 }
 
 // Create creates a new category
@@ -61,6 +61,7 @@ func (u *categoryUseCase) Create(ctx context.Context, createDTO *domain.Category
 		Name:        createDTO.Name,
 		Description: createDTO.Description,
 		Slug:        createDTO.Slug,
+		ParentID:    createDTO.ParentID,
 	}
 
 	if err := u.categoryRepo.Create(ctx, category); err != nil {
@@ -80,6 +81,11 @@ func (u *categoryUseCase) Update(ctx context.Context, id int64, updateDTO *domai
 		return nil, err
 	}
 
+	// The update only applies to the version the client last read
+	if err := domain.CheckVersion("Category", id, category.Version, updateDTO.Version); err != nil {
+		return nil, err
+	}
+
 	// Check if name is being updated and if it's already taken
 	if updateDTO.Name != "" && updateDTO.Name != category.Name {
 		existingCategory, err := u.categoryRepo.FindByName(ctx, updateDTO.Name)
@@ -130,3 +136,45 @@ func (u *categoryUseCase) GetBySlug(ctx context.Context, slug string) (*domain.C
 	}
 	return category, nil
 }
+
+// GetTree gets every category arranged as a tree, siblings ordered by name
+func (u *categoryUseCase) GetTree(ctx context.Context) ([]*domain.CategoryNode, error) {
+	categories, err := u.categoryRepo.FindTree(ctx)
+	if err != nil {
+		u.logger.Error("Failed to get category tree", zap.Error(err))
+		return nil, err
+	}
+	return domain.BuildCategoryTree(categories), nil
+}
+
+// GetSubtree gets a category with its descendants arranged as a tree
+func (u *categoryUseCase) GetSubtree(ctx context.Context, id int64) (*domain.CategoryNode, error) {
+	categories, err := u.categoryRepo.FindSubtree(ctx, id)
+	if err != nil {
+		u.logger.Error("Failed to get category subtree", zap.Int64("id", id), zap.Error(err))
+		return nil, err
+	}
+
+	// The category's parent is outside the subtree, so it is the only root
+	return domain.BuildCategoryTree(categories)[0], nil
+}
+
+// GetBreadcrumbs gets the categories from the top level down to a category
+func (u *categoryUseCase) GetBreadcrumbs(ctx context.Context, id int64) ([]domain.Category, error) {
+	categories, err := u.categoryRepo.FindAncestors(ctx, id)
+	if err != nil {
+		u.logger.Error("Failed to get category breadcrumbs", zap.Int64("id", id), zap.Error(err))
+		return nil, err
+	}
+	return categories, nil
+}
+
+// Move moves a category, with its descendants, under a new parent or to the
+// top level
+func (u *categoryUseCase) Move(ctx context.Context, id int64, moveDTO *domain.CategoryMoveDTO) (*domain.Category, error) {
+	if err := u.categoryRepo.Move(ctx, id, moveDTO.ParentID); err != nil {
+		u.logger.Error("Failed to move category", zap.Int64("id", id), zap.Error(err))
+		return nil, err
+	}
+	return u.GetByID(ctx, id)
+}
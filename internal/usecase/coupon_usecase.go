@@ -0,0 +1,139 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type couponUseCase struct {
+	couponRepo domain.CouponRepository
+	logger     logger.Logger
+}
+
+// NewCouponUseCase creates a new coupon use case
+func NewCouponUseCase(couponRepo domain.CouponRepository, logger logger.Logger) domain.CouponUseCase {
+	return &couponUseCase{
+		couponRepo: couponRepo,
+		logger:     logger,
+	}
+}
+
+// GetByID gets a coupon by ID
+func (u *couponUseCase) GetByID(ctx context.Context, id int64) (*domain.Coupon, error) {
+	coupon, err := u.couponRepo.FindByID(ctx, id)
+	if err != nil {
+		u.logger.Error("Failed to get coupon by ID", zap.Int64("id", id), zap.Error(err))
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// List lists coupons with pagination
+func (u *couponUseCase) List(ctx context.Context, page domain.PageRequest) (*domain.Page[domain.Coupon], error) {
+	coupons, err := u.couponRepo.FindAll(ctx, page)
+	if err != nil {
+		u.logger.Error("Failed to list coupons", zap.Int("limit", page.Limit), zap.Int("offset", page.Offset), zap.Error(err))
+		return nil, err
+	}
+	return coupons, nil
+}
+
+// Create creates a new coupon. Codes are stored in upper case.
+func (u *couponUseCase) Create(ctx context.Context, createDTO *domain.CouponCreateDTO) (*domain.Coupon, error) {
+	coupon := &domain.Coupon{
+		Code:           domain.NormalizeCouponCode(createDTO.Code),
+		Description:    createDTO.Description,
+		Type:           createDTO.Type,
+		PercentOff:     createDTO.PercentOff,
+		AmountOff:      createDTO.AmountOff,
+		MinOrderValue:  createDTO.MinOrderValue,
+		ProductIDs:     createDTO.ProductIDs,
+		CategoryIDs:    createDTO.CategoryIDs,
+		MaxUses:        createDTO.MaxUses,
+		MaxUsesPerUser: createDTO.MaxUsesPerUser,
+		StartsAt:       createDTO.StartsAt,
+		EndsAt:         createDTO.EndsAt,
+		Active:         true,
+	}
+	if createDTO.Active != nil {
+		coupon.Active = *createDTO.Active
+	}
+
+	if err := coupon.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := u.couponRepo.Create(ctx, coupon); err != nil {
+		u.logger.Error("Failed to create coupon", zap.String("code", coupon.Code), zap.Error(err))
+		return nil, err
+	}
+
+	return coupon, nil
+}
+
+// Update updates a coupon. Changes apply to orders placed from now on;
+// orders already placed keep their discounts.
+func (u *couponUseCase) Update(ctx context.Context, id int64, updateDTO *domain.CouponUpdateDTO) (*domain.Coupon, error) {
+	coupon, err := u.couponRepo.FindByID(ctx, id)
+	if err != nil {
+		u.logger.Error("Failed to get coupon for update", zap.Int64("id", id), zap.Error(err))
+		return nil, err
+	}
+
+	if updateDTO.Description != nil {
+		coupon.Description = *updateDTO.Description
+	}
+	if updateDTO.PercentOff != nil {
+		coupon.PercentOff = *updateDTO.PercentOff
+	}
+	if updateDTO.AmountOff != nil {
+		coupon.AmountOff = updateDTO.AmountOff
+	}
+	if updateDTO.MinOrderValue != nil {
+		coupon.MinOrderValue = updateDTO.MinOrderValue
+	}
+	if updateDTO.ProductIDs != nil {
+		coupon.ProductIDs = *updateDTO.ProductIDs
+	}
+	if updateDTO.CategoryIDs != nil {
+		coupon.CategoryIDs = *updateDTO.CategoryIDs
+	}
+	if updateDTO.MaxUses != nil {
+		coupon.MaxUses = updateDTO.MaxUses
+	}
+	if updateDTO.MaxUsesPerUser != nil {
+		coupon.MaxUsesPerUser = updateDTO.MaxUsesPerUser
+	}
+	if updateDTO.StartsAt != nil {
+		coupon.StartsAt = updateDTO.StartsAt
+	}
+	if updateDTO.EndsAt != nil {
+		coupon.EndsAt = updateDTO.EndsAt
+	}
+	if updateDTO.Active != nil {
+		coupon.Active = *updateDTO.Active
+	}
+
+	if err := coupon.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := u.couponRepo.Update(ctx, coupon); err != nil {
+		u.logger.Error("Failed to update coupon", zap.Int64("id", id), zap.Error(err))
+		return nil, err
+	}
+
+	return coupon, nil
+}
+
+// Delete deletes a coupon
+func (u *couponUseCase) Delete(ctx context.Context, id int64) error {
+	if err := u.couponRepo.Delete(ctx, id); err != nil {
+		u.logger.Error("Failed to delete coupon", zap.Int64("id", id), zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/milad-ahmd/go-clean-arch/internal/domain"
 	pkgerrors "github.com/milad-ahmd/go-clean-arch/pkg/errors"
@@ -12,19 +13,26 @@ import (
 )
 
 type orderUseCase struct {
-	orderRepo   domain.OrderRepository
-	productRepo domain.ProductRepository
-	userRepo    domain.UserRepository
-	logger      logger.Logger
+	orderRepo      domain.OrderRepository
+	productRepo    domain.ProductRepository
+	userRepo       domain.UserRepository
+	categoryRepo   domain.CategoryRepository
+	couponRepo     domain.CouponRepository
+	paymentUseCase domain.PaymentUseCase
+	logger         logger.Logger
 }
 
-// NewOrderUseCase creates a new order use case
-func NewOrderUseCase(orderRepo domain.OrderRepository, productRepo domain.ProductRepository, userRepo domain.UserRepository, logger logger.Logger) domain.OrderUseCase {
+// NewOrderUseCase creates a new order use case. The payment use case voids
+// the authorized payments of cancelled orders.
+func NewOrderUseCase(orderRepo domain.OrderRepository, productRepo domain.ProductRepository, userRepo domain.UserRepository, categoryRepo domain.CategoryRepository, couponRepo domain.CouponRepository, paymentUseCase domain.PaymentUseCase, logger logger.Logger) domain.OrderUseCase {
 	return &orderUseCase{
-		orderRepo:   orderRepo,
-		productRepo: productRepo,
-		userRepo:    userRepo,
-		logger:      logger,
+		orderRepo:      orderRepo,
+		productRepo:    productRepo,
+		userRepo:       userRepo,
+		categoryRepo:   categoryRepo,
+		couponRepo:     couponRepo,
+		paymentUseCase: paymentUseCase,
+		logger:         logger,
 	}
 }
 
@@ -39,13 +47,13 @@ func (u *orderUseCase) GetByID(ctx context.Context, id int64) (*domain.Order, er
 }
 
 // List lists orders with pagination
-func (u *orderUseCase) List(ctx context.Context, limit, offset int) ([]domain.Order, int, error) {
-	orders, total, err := u.orderRepo.FindAll(ctx, limit, offset)
+func (u *orderUseCase) List(ctx context.Context, page domain.PageRequest) (*domain.Page[domain.Order], error) {
+	orders, err := u.orderRepo.FindAll(ctx, page)
 	if err != nil {
-		u.logger.Error("Failed to list orders", zap.Int("limit", limit), zap.Int("offset", offset), zap.Error(err))
-		return nil, 0, err
+		u.logger.Error("Failed to list orders", zap.Int("limit", page.Limit), zap.Int("offset", page.Offset), zap.Error(err))
+		return nil, err
 	}
-	return orders, total, nil
+	return orders, nil
 }
 
 // Create creates a new order
@@ -64,30 +72,53 @@ func (u *orderUseCase) Create(ctx context.Context, createDTO *domain.OrderCreate
 
 	// Create order items and calculate total amount
 	var orderItems []domain.OrderItem
-	var totalAmount float64
+	var totalAmount domain.Money
 
 	for _, itemDTO := range createDTO.Items {
-		// Check if product exists and has enough stock
+		// Check if product exists. Stock is reserved atomically by the
+		// repository when the order is stored.
 		product, err := u.productRepo.FindByID(ctx, itemDTO.ProductID)
 		if err != nil {
 			u.logger.Error("Failed to find product for order item", zap.Int64("productID", itemDTO.ProductID), zap.Error(err))
 			return nil, pkgerrors.NewBadRequestError("Invalid product ID: " + fmt.Sprintf("%d", itemDTO.ProductID))
 		}
 
-		if product.Stock < itemDTO.Quantity {
-			return nil, pkgerrors.NewBadRequestError("Insufficient stock for product: " + product.Name)
+		// Products with variants are ordered by variant
+		variant, err := product.ResolveVariant(itemDTO.VariantID)
+		if err != nil {
+			return nil, err
 		}
+		price := product.PriceOf(variant) // Use the current product or variant price
 
 		// Create order item
 		orderItem := domain.OrderItem{
 			ProductID: itemDTO.ProductID,
+			VariantID: itemDTO.VariantID,
+			Variant:   variant,
 			Quantity:  itemDTO.Quantity,
-			Price:     product.Price, // Use the current product price
+			Price:     price,
+			Discount:  domain.NewMoney(0, price.Currency),
 			Product:   *product,
 		}
 
+		lineTotal, err := price.Mul(int64(itemDTO.Quantity))
+		if err != nil {
+			return nil, pkgerrors.NewBadRequestError("Order total is out of range")
+		}
+
+		// All items of an order must be priced in the same currency
+		if len(orderItems) == 0 {
+			totalAmount = domain.NewMoney(0, price.Currency)
+		}
+		totalAmount, err = totalAmount.Add(lineTotal)
+		if err != nil {
+			if errors.Is(err, domain.ErrCurrencyMismatch) {
+				return nil, pkgerrors.NewBadRequestError("All order items must be priced in the same currency")
+			}
+			return nil, pkgerrors.NewBadRequestError("Order total is out of range")
+		}
+
 		orderItems = append(orderItems, orderItem)
-		totalAmount += product.Price * float64(itemDTO.Quantity)
 	}
 
 	// Create shipping info
@@ -102,13 +133,22 @@ func (u *orderUseCase) Create(ctx context.Context, createDTO *domain.OrderCreate
 
 	// Create the order
 	order := &domain.Order{
-		UserID:        createDTO.UserID,
-		Status:        domain.OrderStatusPending,
-		TotalAmount:   totalAmount,
-		Items:         orderItems,
-		PaymentMethod: createDTO.PaymentMethod,
-		ShippingInfo:  shippingInfo,
-		User:          *user,
+		UserID:         createDTO.UserID,
+		Status:         domain.OrderStatusPending,
+		Subtotal:       totalAmount,
+		DiscountAmount: domain.NewMoney(0, totalAmount.Currency),
+		TotalAmount:    totalAmount,
+		Items:          orderItems,
+		PaymentMethod:  createDTO.PaymentMethod,
+		ShippingInfo:   shippingInfo,
+		User:           *user,
+		CartItems:      createDTO.CartItems,
+	}
+
+	if createDTO.CouponCode != "" {
+		if err := u.applyCoupon(ctx, order, createDTO.CouponCode); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := u.orderRepo.Create(ctx, order); err != nil {
@@ -119,6 +159,74 @@ func (u *orderUseCase) Create(ctx context.Context, createDTO *domain.OrderCreate
 	return order, nil
 }
 
+// applyCoupon discounts the order's lines by the coupon with the given code.
+// The repository checks the coupon's limits again when the order is stored.
+func (u *orderUseCase) applyCoupon(ctx context.Context, order *domain.Order, code string) error {
+	code = domain.NormalizeCouponCode(code)
+	coupon, err := u.couponRepo.FindByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.NewCouponError(code, domain.CouponRejectionUnknown)
+		}
+		return err
+	}
+
+	usage, err := u.couponRepo.CountUses(ctx, coupon.ID, order.UserID)
+	if err != nil {
+		return err
+	}
+	if err := coupon.CheckRedeemable(time.Now().UTC(), usage); err != nil {
+		return err
+	}
+
+	lines := make([]domain.DiscountLine, len(order.Items))
+	categories := make(map[int64]string)
+	for i, item := range order.Items {
+		lineTotal, err := item.Price.Mul(int64(item.Quantity))
+		if err != nil {
+			return pkgerrors.NewBadRequestError("Order total is out of range")
+		}
+		lines[i] = domain.DiscountLine{ProductID: item.ProductID, Total: lineTotal}
+
+		// Category rules need the path of the product's category
+		if len(coupon.CategoryIDs) > 0 {
+			path, ok := categories[item.Product.CategoryID]
+			if !ok {
+				category, err := u.categoryRepo.FindByID(ctx, item.Product.CategoryID)
+				if err != nil {
+					u.logger.Error("Failed to find category for coupon", zap.Int64("categoryID", item.Product.CategoryID), zap.Error(err))
+					return err
+				}
+				path = category.Path
+				categories[item.Product.CategoryID] = path
+			}
+			lines[i].CategoryPath = path
+		}
+	}
+
+	discounts, err := coupon.Discounts(lines, order.Subtotal)
+	if err != nil {
+		return err
+	}
+
+	discountAmount := domain.NewMoney(0, order.Subtotal.Currency)
+	for i := range order.Items {
+		order.Items[i].Discount = discounts[i]
+		if discountAmount, err = discountAmount.Add(discounts[i]); err != nil {
+			return pkgerrors.NewBadRequestError("Order total is out of range")
+		}
+	}
+
+	if order.TotalAmount, err = order.Subtotal.Sub(discountAmount); err != nil {
+		return pkgerrors.NewBadRequestError("Order total is out of range")
+	}
+	order.DiscountAmount = discountAmount
+	order.CouponID = &coupon.ID
+	order.CouponCode = coupon.Code
+
+	return nil
+}
+
 // Update updates an order
 func (u *orderUseCase) Update(ctx context.Context, id int64, updateDTO *domain.OrderUpdateDTO) (*domain.Order, error) {
 	// Get the existing order
@@ -128,9 +236,18 @@ func (u *orderUseCase) Update(ctx context.Context, id int64, updateDTO *domain.O
 		return nil, err
 	}
 
-	// Update status if provided
-	if updateDTO.Status != "" {
-		order.Status = updateDTO.Status
+	// The update only applies to the version the client last read
+	if err := domain.CheckVersion("Order", id, order.Version, updateDTO.Version); err != nil {
+		return nil, err
+	}
+
+	// Change the status through the state machine if a new one is provided
+	var change *domain.OrderStatusHistory
+	if updateDTO.Status != "" && updateDTO.Status != order.Status {
+		if err := u.releasePayment(ctx, order, updateDTO.Status); err != nil {
+			return nil, err
+		}
+		change = newStatusChange(id, updateDTO.Status, updateDTO.Reason, updateDTO.ActorID)
 	}
 
 	// Update payment method if provided
@@ -148,8 +265,9 @@ func (u *orderUseCase) Update(ctx context.Context, id int64, updateDTO *domain.O
 		order.ShippingInfo.PhoneNumber = updateDTO.ShippingInfo.PhoneNumber
 	}
 
-	// Update the order
-	if err := u.orderRepo.Update(ctx, order); err != nil {
+	// Update the order and its status together, provided it is still at the
+	// version read above
+	if err := u.orderRepo.UpdateWithStatus(ctx, order, change); err != nil {
 		u.logger.Error("Failed to update order", zap.Int64("id", id), zap.Error(err))
 		return nil, err
 	}
@@ -166,7 +284,13 @@ func (u *orderUseCase) Update(ctx context.Context, id int64, updateDTO *domain.O
 
 // Delete deletes an order
 func (u *orderUseCase) Delete(ctx context.Context, id int64) error {
-	if err := u.orderRepo.Delete(ctx, id); err != nil {
+	return u.DeleteVersion(ctx, id, 0)
+}
+
+// DeleteVersion deletes an order, provided it is still at version unless
+// version is zero
+func (u *orderUseCase) DeleteVersion(ctx context.Context, id, version int64) error {
+	if err := u.orderRepo.DeleteVersion(ctx, id, version); err != nil {
 		u.logger.Error("Failed to delete order", zap.Int64("id", id), zap.Error(err))
 		return err
 	}
@@ -174,55 +298,119 @@ func (u *orderUseCase) Delete(ctx context.Context, id int64) error {
 }
 
 // GetByUserID gets orders by user ID
-func (u *orderUseCase) GetByUserID(ctx context.Context, userID int64, page, perPage int) ([]domain.Order, int, error) {
+func (u *orderUseCase) GetByUserID(ctx context.Context, userID int64, page domain.PageRequest) (*domain.Page[domain.Order], error) {
 	// Check if user exists
 	_, err := u.userRepo.GetByID(ctx, userID)
 	if err != nil {
 		u.logger.Error("Failed to find user", zap.Int64("userID", userID), zap.Error(err))
-		return nil, 0, err
-	}
-
-	// Calculate offset
-	offset := (page - 1) * perPage
-	if offset < 0 {
-		offset = 0
+		return nil, err
 	}
 
-	orders, total, err := u.orderRepo.FindByUserID(ctx, userID, perPage, offset)
+	orders, err := u.orderRepo.FindByUserID(ctx, userID, page)
 	if err != nil {
 		u.logger.Error("Failed to get orders by user ID", zap.Int64("userID", userID), zap.Error(err))
-		return nil, 0, err
+		return nil, err
 	}
 
-	return orders, total, nil
+	return orders, nil
 }
 
 // GetByStatus gets orders by status
-func (u *orderUseCase) GetByStatus(ctx context.Context, status domain.OrderStatus, page, perPage int) ([]domain.Order, int, error) {
-	// Calculate offset
-	offset := (page - 1) * perPage
-	if offset < 0 {
-		offset = 0
-	}
-
-	orders, total, err := u.orderRepo.FindByStatus(ctx, status, perPage, offset)
+func (u *orderUseCase) GetByStatus(ctx context.Context, status domain.OrderStatus, page domain.PageRequest) (*domain.Page[domain.Order], error) {
+	orders, err := u.orderRepo.FindByStatus(ctx, status, page)
 	if err != nil {
 		u.logger.Error("Failed to get orders by status", zap.String("status", string(status)), zap.Error(err))
-		return nil, 0, err
+		return nil, err
 	}
 
-	return orders, total, nil
+	return orders, nil
 }
 
-// UpdateStatus updates an order's status
-func (u *orderUseCase) UpdateStatus(ctx context.Context, id int64, status domain.OrderStatus) error {
-	if err := u.orderRepo.UpdateStatus(ctx, id, status); err != nil {
-		u.logger.Error("Failed to update order status", zap.Int64("id", id), zap.String("status", string(status)), zap.Error(err))
+// UpdateStatus moves an order to a new status if the transition is allowed
+func (u *orderUseCase) UpdateStatus(ctx context.Context, id int64, statusDTO *domain.OrderStatusUpdateDTO) error {
+	if !statusDTO.Status.IsValid() {
+		return pkgerrors.NewBadRequestError("Invalid status")
+	}
+
+	if statusDTO.Status == domain.OrderStatusCancelled {
+		order, err := u.orderRepo.FindByID(ctx, id)
+		if err != nil {
+			u.logger.Error("Failed to get order for status update", zap.Int64("id", id), zap.Error(err))
+			return err
+		}
+		if err := domain.CheckVersion("Order", id, order.Version, statusDTO.Version); err != nil {
+			return err
+		}
+		if err := u.releasePayment(ctx, order, statusDTO.Status); err != nil {
+			return err
+		}
+	}
+
+	change := newStatusChange(id, statusDTO.Status, statusDTO.Reason, statusDTO.ActorID)
+	if err := u.orderRepo.UpdateStatus(ctx, change, statusDTO.Version); err != nil {
+		u.logger.Error("Failed to update order status", zap.Int64("id", id), zap.String("status", string(statusDTO.Status)), zap.Error(err))
 		return err
 	}
 	return nil
 }
 
+// releasePayment voids the authorized payments of an order about to be
+// cancelled. It runs before the order is cancelled, so an authorization the
+// gateway cannot void keeps the order as it is; at worst an order that then
+// fails to be cancelled has to be paid again.
+func (u *orderUseCase) releasePayment(ctx context.Context, order *domain.Order, status domain.OrderStatus) error {
+	if status != domain.OrderStatusCancelled {
+		return nil
+	}
+	if err := order.Status.ValidateTransition(status); err != nil {
+		return err
+	}
+
+	if err := u.paymentUseCase.VoidOrder(ctx, order.ID); err != nil {
+		u.logger.Error("Failed to void payments of cancelled order", zap.Int64("id", order.ID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// newStatusChange returns the status history entry of moving an order to
+// status, made by the user actorID unless it is zero
+func newStatusChange(orderID int64, status domain.OrderStatus, reason string, actorID int64) *domain.OrderStatusHistory {
+	change := &domain.OrderStatusHistory{
+		OrderID:  orderID,
+		ToStatus: status,
+		Reason:   reason,
+	}
+	if actorID != 0 {
+		change.ActorID = &actorID
+	}
+	return change
+}
+
+// GetStatusHistory gets the status changes of an order, oldest first
+func (u *orderUseCase) GetStatusHistory(ctx context.Context, id int64) ([]domain.OrderStatusHistory, error) {
+	history, err := u.orderRepo.GetStatusHistory(ctx, id)
+	if err != nil {
+		u.logger.Error("Failed to get order status history", zap.Int64("id", id), zap.Error(err))
+		return nil, err
+	}
+	return history, nil
+}
+
+// CancelItem cancels part or all of an order item and returns the updated order
+func (u *orderUseCase) CancelItem(ctx context.Context, orderID, itemID int64, cancelDTO *domain.OrderItemCancelDTO) (*domain.Order, error) {
+	if cancelDTO.Quantity <= 0 {
+		return nil, pkgerrors.NewBadRequestError("Quantity must be greater than zero")
+	}
+
+	if err := u.orderRepo.CancelOrderItem(ctx, orderID, itemID, cancelDTO.Quantity, cancelDTO.Version); err != nil {
+		u.logger.Error("Failed to cancel order item", zap.Int64("orderID", orderID), zap.Int64("itemID", itemID), zap.Error(err))
+		return nil, err
+	}
+
+	return u.GetOrderWithDetails(ctx, orderID)
+}
+
 // GetOrderWithDetails gets an order with all details
 func (u *orderUseCase) GetOrderWithDetails(ctx context.Context, id int64) (*domain.Order, error) {
 	order, err := u.orderRepo.FindByID(ctx, id)
@@ -0,0 +1,278 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	pkgerrors "github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type paymentUseCase struct {
+	paymentRepo domain.PaymentRepository
+	orderRepo   domain.OrderRepository
+	gateway     domain.PaymentGateway
+	timeout     time.Duration
+	logger      logger.Logger
+}
+
+// NewPaymentUseCase creates a new payment use case. Every gateway call is
+// given at most timeout to answer.
+func NewPaymentUseCase(paymentRepo domain.PaymentRepository, orderRepo domain.OrderRepository, gateway domain.PaymentGateway, timeout time.Duration, logger logger.Logger) domain.PaymentUseCase {
+	return &paymentUseCase{
+		paymentRepo: paymentRepo,
+		orderRepo:   orderRepo,
+		gateway:     gateway,
+		timeout:     timeout,
+		logger:      logger,
+	}
+}
+
+// GetByOrderID gets every payment attempt of an order, oldest first
+func (u *paymentUseCase) GetByOrderID(ctx context.Context, orderID int64) ([]domain.Payment, error) {
+	payments, err := u.paymentRepo.FindByOrderID(ctx, orderID)
+	if err != nil {
+		u.logger.Error("Failed to get payments by order ID", zap.Int64("orderID", orderID), zap.Error(err))
+		return nil, err
+	}
+	return payments, nil
+}
+
+// Authorize reserves the total of a pending order with the gateway, and
+// captures it as well when requested. The attempt is recorded before the
+// gateway is called, so declined and timed out attempts are kept.
+func (u *paymentUseCase) Authorize(ctx context.Context, orderID int64, authorizeDTO *domain.PaymentAuthorizeDTO) (*domain.Payment, error) {
+	order, err := u.orderRepo.FindByID(ctx, orderID)
+	if err != nil {
+		u.logger.Error("Failed to get order for payment", zap.Int64("orderID", orderID), zap.Error(err))
+		return nil, err
+	}
+
+	if order.Status != domain.OrderStatusPending {
+		return nil, pkgerrors.NewAppError(pkgerrors.ErrConflict, "Only pending orders can be paid", http.StatusConflict)
+	}
+	if !order.TotalAmount.IsPositive() {
+		return nil, pkgerrors.NewBadRequestError("Order has nothing to pay")
+	}
+
+	payment := &domain.Payment{
+		OrderID:        order.ID,
+		Provider:       u.gateway.Name(),
+		Method:         order.PaymentMethod,
+		Status:         domain.PaymentStatusPending,
+		Amount:         order.TotalAmount,
+		CapturedAmount: domain.NewMoney(0, order.TotalAmount.Currency),
+	}
+	if err := u.paymentRepo.Create(ctx, payment); err != nil {
+		u.logger.Error("Failed to record payment", zap.Int64("orderID", orderID), zap.Error(err))
+		return nil, err
+	}
+
+	gatewayCtx, cancel := context.WithTimeout(ctx, u.timeout)
+	reference, err := u.gateway.Authorize(gatewayCtx, &domain.PaymentAuthorization{
+		OrderID:   order.ID,
+		PaymentID: payment.ID,
+		Amount:    payment.Amount,
+		Method:    payment.Method,
+		Source:    authorizeDTO.PaymentToken,
+	})
+	cancel()
+
+	if err != nil {
+		failure := gatewayFailure(err)
+		u.logger.Warn("Payment authorization failed", zap.Int64("orderID", orderID), zap.Int64("paymentID", payment.ID), zap.Error(err))
+
+		payment.Status = domain.PaymentStatusFailed
+		payment.FailureCode = string(failure.Reason)
+		payment.FailureMessage = failure.Message
+		if updateErr := u.paymentRepo.Update(ctx, payment, domain.PaymentStatusPending); updateErr != nil {
+			u.logger.Error("Failed to record payment failure", zap.Int64("paymentID", payment.ID), zap.Error(updateErr))
+		}
+		return nil, paymentError(failure)
+	}
+
+	payment.Status = domain.PaymentStatusAuthorized
+	payment.ProviderReference = reference
+	if err := u.paymentRepo.Update(ctx, payment, domain.PaymentStatusPending); err != nil {
+		u.logger.Error("Failed to record payment authorization", zap.Int64("paymentID", payment.ID), zap.String("reference", reference), zap.Error(err))
+		return nil, err
+	}
+
+	if authorizeDTO.Capture {
+		return u.capture(ctx, payment, authorizeDTO.ActorID)
+	}
+
+	return payment, nil
+}
+
+// Capture collects an authorized payment and moves its order to processing
+func (u *paymentUseCase) Capture(ctx context.Context, orderID, paymentID, actorID int64) (*domain.Payment, error) {
+	payment, err := u.findPayment(ctx, orderID, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if payment.Status != domain.PaymentStatusAuthorized {
+		return nil, pkgerrors.NewAppError(pkgerrors.ErrConflict, fmt.Sprintf("Payment is %s and cannot be captured", payment.Status), http.StatusConflict)
+	}
+
+	return u.capture(ctx, payment, actorID)
+}
+
+// Void releases an authorized payment without collecting it
+func (u *paymentUseCase) Void(ctx context.Context, orderID, paymentID int64) (*domain.Payment, error) {
+	payment, err := u.findPayment(ctx, orderID, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if payment.Status != domain.PaymentStatusAuthorized {
+		return nil, pkgerrors.NewAppError(pkgerrors.ErrConflict, fmt.Sprintf("Payment is %s and cannot be voided", payment.Status), http.StatusConflict)
+	}
+
+	if err := u.void(ctx, payment); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// VoidOrder voids the authorized payments of an order, so cancelling the
+// order releases the money reserved for it
+func (u *paymentUseCase) VoidOrder(ctx context.Context, orderID int64) error {
+	payments, err := u.paymentRepo.FindByOrderID(ctx, orderID)
+	if err != nil {
+		u.logger.Error("Failed to get payments by order ID", zap.Int64("orderID", orderID), zap.Error(err))
+		return err
+	}
+
+	for i := range payments {
+		if payments[i].Status != domain.PaymentStatusAuthorized {
+			continue
+		}
+		if err := u.void(ctx, &payments[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// void releases an authorized payment with the gateway and records it
+func (u *paymentUseCase) void(ctx context.Context, payment *domain.Payment) error {
+	gatewayCtx, cancel := context.WithTimeout(ctx, u.timeout)
+	err := u.gateway.Void(gatewayCtx, payment.ProviderReference)
+	cancel()
+	if err != nil {
+		u.logger.Warn("Payment void failed", zap.Int64("paymentID", payment.ID), zap.Error(err))
+		return paymentError(gatewayFailure(err))
+	}
+
+	payment.Status = domain.PaymentStatusVoided
+	if err := u.paymentRepo.Update(ctx, payment, domain.PaymentStatusAuthorized); err != nil {
+		u.logger.Error("Failed to record voided payment", zap.Int64("paymentID", payment.ID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// capture collects an authorized payment. The order total is read again, so
+// items cancelled since the authorization are not charged; the authorized
+// amount caps what is collected. A failed capture leaves the payment
+// authorized, so it can be retried.
+func (u *paymentUseCase) capture(ctx context.Context, payment *domain.Payment, actorID int64) (*domain.Payment, error) {
+	order, err := u.orderRepo.FindByID(ctx, payment.OrderID)
+	if err != nil {
+		u.logger.Error("Failed to get order for payment", zap.Int64("orderID", payment.OrderID), zap.Error(err))
+		return nil, err
+	}
+
+	if order.Status == domain.OrderStatusCancelled {
+		return nil, pkgerrors.NewAppError(pkgerrors.ErrConflict, "Payments of cancelled orders cannot be captured", http.StatusConflict)
+	}
+
+	amount := payment.Amount
+	if order.TotalAmount.Currency == amount.Currency && order.TotalAmount.Amount < amount.Amount {
+		amount = order.TotalAmount
+	}
+	if !amount.IsPositive() {
+		return nil, pkgerrors.NewAppError(pkgerrors.ErrConflict, "Order has nothing left to capture; void the payment instead", http.StatusConflict)
+	}
+
+	gatewayCtx, cancel := context.WithTimeout(ctx, u.timeout)
+	err = u.gateway.Capture(gatewayCtx, payment.ProviderReference, amount)
+	cancel()
+	if err != nil {
+		u.logger.Warn("Payment capture failed", zap.Int64("paymentID", payment.ID), zap.Error(err))
+		return nil, paymentError(gatewayFailure(err))
+	}
+
+	payment.Status = domain.PaymentStatusCaptured
+	payment.CapturedAmount = amount
+	if err := u.paymentRepo.Capture(ctx, payment, actorID); err != nil {
+		// The gateway has collected the money; the record must be repaired
+		u.logger.Error("Failed to record captured payment", zap.Int64("paymentID", payment.ID), zap.String("reference", payment.ProviderReference), zap.Error(err))
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// findPayment finds a payment of an order
+func (u *paymentUseCase) findPayment(ctx context.Context, orderID, paymentID int64) (*domain.Payment, error) {
+	payment, err := u.paymentRepo.FindByID(ctx, paymentID)
+	if err != nil {
+		u.logger.Error("Failed to get payment", zap.Int64("paymentID", paymentID), zap.Error(err))
+		return nil, err
+	}
+	if payment.OrderID != orderID {
+		return nil, pkgerrors.NewNotFoundError("Payment", paymentID)
+	}
+	return payment, nil
+}
+
+// gatewayFailure returns the gateway's reason for an error. Errors other
+// than gateway errors count as timeouts when the deadline passed and as
+// processing errors otherwise.
+func gatewayFailure(err error) *domain.GatewayError {
+	var gatewayErr *domain.GatewayError
+	if errors.As(err, &gatewayErr) {
+		return gatewayErr
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &domain.GatewayError{Reason: domain.GatewayFailureTimeout, Message: err.Error()}
+	}
+	return &domain.GatewayError{Reason: domain.GatewayFailureProcessing, Message: err.Error()}
+}
+
+// paymentError returns the application error for a gateway failure:
+// declines are 402 Payment Required, timeouts 504 Gateway Timeout and other
+// failures 502 Bad Gateway
+func paymentError(failure *domain.GatewayError) *pkgerrors.AppError {
+	switch failure.Reason {
+	case domain.GatewayFailureDeclined, domain.GatewayFailureInsufficientFunds:
+		return &pkgerrors.AppError{
+			Err:        failure,
+			Message:    "Payment was declined: " + failure.Message,
+			StatusCode: http.StatusPaymentRequired,
+			Code:       "payment_" + string(failure.Reason),
+		}
+	case domain.GatewayFailureTimeout:
+		return &pkgerrors.AppError{
+			Err:        failure,
+			Message:    "Payment provider did not answer in time",
+			StatusCode: http.StatusGatewayTimeout,
+			Code:       "payment_timeout",
+		}
+	default:
+		return &pkgerrors.AppError{
+			Err:        failure,
+			Message:    "Payment provider could not process the payment",
+			StatusCode: http.StatusBadGateway,
+			Code:       "payment_failed",
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/internal/payment"
+	pkgerrors "github.com/milad-ahmd/go-clean-arch/pkg/errors"
+)
+
+// mockPaymentRepository is an in-memory payment repository
+type mockPaymentRepository struct {
+	payments map[int64]*domain.Payment
+	orders   *mockOrderRepository
+	nextID   int64
+}
+
+// newMockPaymentRepository creates a new mock payment repository
+func newMockPaymentRepository(orders *mockOrderRepository) *mockPaymentRepository {
+	return &mockPaymentRepository{
+		payments: make(map[int64]*domain.Payment),
+		orders:   orders,
+	}
+}
+
+// FindByID finds a payment by ID
+func (m *mockPaymentRepository) FindByID(_ context.Context, id int64) (*domain.Payment, error) {
+	payment, ok := m.payments[id]
+	if !ok {
+		return nil, pkgerrors.NewNotFoundError("Payment", id)
+	}
+	p := *payment
+	return &p, nil
+}
+
+// FindByOrderID finds the payments of an order
+func (m *mockPaymentRepository) FindByOrderID(_ context.Context, orderID int64) ([]domain.Payment, error) {
+	payments := []domain.Payment{}
+	for id := int64(1); id <= m.nextID; id++ {
+		if payment, ok := m.payments[id]; ok && payment.OrderID == orderID {
+			payments = append(payments, *payment)
+		}
+	}
+	return payments, nil
+}
+
+// Create records a payment attempt, allowing one active payment per order
+func (m *mockPaymentRepository) Create(_ context.Context, payment *domain.Payment) error {
+	for _, existing := range m.payments {
+		if existing.OrderID == payment.OrderID && existing.Status.IsActive() {
+			return pkgerrors.NewAppError(pkgerrors.ErrConflict, "Order already has an active payment", http.StatusConflict)
+		}
+	}
+	m.nextID++
+	payment.ID = m.nextID
+	p := *payment
+	m.payments[payment.ID] = &p
+	return nil
+}
+
+// Update stores a payment that is still in status from
+func (m *mockPaymentRepository) Update(_ context.Context, payment *domain.Payment, from domain.PaymentStatus) error {
+	if stored, ok := m.payments[payment.ID]; !ok || stored.Status != from {
+		return pkgerrors.NewAppError(pkgerrors.ErrConflict, "Payment has changed", http.StatusConflict)
+	}
+	p := *payment
+	m.payments[payment.ID] = &p
+	return nil
+}
+
+// Capture stores a captured payment and moves a pending order to processing
+func (m *mockPaymentRepository) Capture(ctx context.Context, payment *domain.Payment, _ int64) error {
+	if order := m.orders.orders[payment.OrderID]; order.Status == domain.OrderStatusPending {
+		order.Status = domain.OrderStatusProcessing
+	}
+	return m.Update(ctx, payment, domain.PaymentStatusAuthorized)
+}
+
+// mockOrderRepository serves orders from memory; only FindByID is used
+type mockOrderRepository struct {
+	domain.OrderRepository
+	orders map[int64]*domain.Order
+}
+
+// FindByID finds an order by ID
+func (m *mockOrderRepository) FindByID(_ context.Context, id int64) (*domain.Order, error) {
+	order, ok := m.orders[id]
+	if !ok {
+		return nil, pkgerrors.NewNotFoundError("Order", id)
+	}
+	o := *order
+	return &o, nil
+}
+
+// newTestPaymentUseCase creates a payment use case with the fake gateway and one pending order of 10.00 USD
+func newTestPaymentUseCase() (domain.PaymentUseCase, *mockPaymentRepository, *mockOrderRepository) {
+	orderRepo := &mockOrderRepository{orders: map[int64]*domain.Order{
+		1: {ID: 1, UserID: 1, Status: domain.OrderStatusPending, TotalAmount: domain.NewMoney(1000, domain.CurrencyUSD), PaymentMethod: domain.PaymentMethodCreditCard},
+	}}
+	paymentRepo := newMockPaymentRepository(orderRepo)
+	useCase := NewPaymentUseCase(paymentRepo, orderRepo, payment.NewFakeGateway(), 20*time.Millisecond, &mockLogger{})
+	return useCase, paymentRepo, orderRepo
+}
+
+// TestPaymentUseCase_Authorize tests authorizing and capturing an order's payment
+func TestPaymentUseCase_Authorize(t *testing.T) {
+	useCase, _, orderRepo := newTestPaymentUseCase()
+	ctx := context.Background()
+
+	authorized, err := useCase.Authorize(ctx, 1, &domain.PaymentAuthorizeDTO{PaymentToken: "tok_visa"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if authorized.Status != domain.PaymentStatusAuthorized || authorized.ProviderReference == "" {
+		t.Errorf("Expected an authorized payment with a reference, got %+v", authorized)
+	}
+	if authorized.Amount.Amount != 1000 {
+		t.Errorf("Expected amount 1000, got %d", authorized.Amount.Amount)
+	}
+
+	// The order cannot be paid twice
+	if _, err := useCase.Authorize(ctx, 1, &domain.PaymentAuthorizeDTO{}); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("Expected conflict, got %v", err)
+	}
+
+	captured, err := useCase.Capture(ctx, 1, authorized.ID, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if captured.Status != domain.PaymentStatusCaptured || captured.CapturedAmount.Amount != 1000 {
+		t.Errorf("Expected a captured payment of 1000, got %+v", captured)
+	}
+	if status := orderRepo.orders[1].Status; status != domain.OrderStatusProcessing {
+		t.Errorf("Expected order status processing, got %s", status)
+	}
+
+	if _, err := useCase.Void(ctx, 1, authorized.ID); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("Expected conflict when voiding a captured payment, got %v", err)
+	}
+}
+
+// TestPaymentUseCase_Authorize_Failures tests that declined and timed out attempts are recorded
+func TestPaymentUseCase_Authorize_Failures(t *testing.T) {
+	tests := []struct {
+		source string
+		status int
+		code   string
+	}{
+		{source: payment.FakeSourceDecline, status: http.StatusPaymentRequired, code: "payment_declined"},
+		{source: payment.FakeSourceInsufficientFunds, status: http.StatusPaymentRequired, code: "payment_insufficient_funds"},
+		{source: payment.FakeSourceTimeout, status: http.StatusGatewayTimeout, code: "payment_timeout"},
+	}
+
+	for _, tt := range tests {
+		useCase, _, orderRepo := newTestPaymentUseCase()
+		ctx := context.Background()
+
+		_, err := useCase.Authorize(ctx, 1, &domain.PaymentAuthorizeDTO{PaymentToken: tt.source, Capture: true})
+		if status := pkgerrors.GetStatusCode(err); status != tt.status {
+			t.Errorf("%s: expected status %d, got %d (%v)", tt.source, tt.status, status, err)
+		}
+		if code := pkgerrors.GetCode(err); code != tt.code {
+			t.Errorf("%s: expected code %s, got %s", tt.source, tt.code, code)
+		}
+
+		payments, err := useCase.GetByOrderID(ctx, 1)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(payments) != 1 || payments[0].Status != domain.PaymentStatusFailed || payments[0].FailureCode == "" {
+			t.Errorf("%s: expected one failed attempt, got %+v", tt.source, payments)
+		}
+		if status := orderRepo.orders[1].Status; status != domain.OrderStatusPending {
+			t.Errorf("%s: expected order status pending, got %s", tt.source, status)
+		}
+
+		// A failed attempt does not block paying again
+		if _, err := useCase.Authorize(ctx, 1, &domain.PaymentAuthorizeDTO{PaymentToken: "tok_visa", Capture: true}); err != nil {
+			t.Errorf("%s: expected retry to succeed, got %v", tt.source, err)
+		}
+	}
+}
+
+// TestPaymentUseCase_Capture_LoweredTotal tests that capturing collects the current order total when it dropped below the authorized amount
+func TestPaymentUseCase_Capture_LoweredTotal(t *testing.T) {
+	useCase, _, orderRepo := newTestPaymentUseCase()
+	ctx := context.Background()
+
+	authorized, err := useCase.Authorize(ctx, 1, &domain.PaymentAuthorizeDTO{PaymentToken: "tok_visa"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	orderRepo.orders[1].TotalAmount = domain.NewMoney(600, domain.CurrencyUSD)
+	captured, err := useCase.Capture(ctx, 1, authorized.ID, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if captured.CapturedAmount.Amount != 600 || captured.Amount.Amount != 1000 {
+		t.Errorf("Expected 600 captured of 1000 authorized, got %+v", captured)
+	}
+}
+
+// TestPaymentUseCase_VoidOrder tests that the authorized payments of an order are voided
+func TestPaymentUseCase_VoidOrder(t *testing.T) {
+	useCase, paymentRepo, _ := newTestPaymentUseCase()
+	ctx := context.Background()
+
+	authorized, err := useCase.Authorize(ctx, 1, &domain.PaymentAuthorizeDTO{PaymentToken: "tok_visa"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := useCase.VoidOrder(ctx, 1); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if status := paymentRepo.payments[authorized.ID].Status; status != domain.PaymentStatusVoided {
+		t.Errorf("Expected payment status voided, got %s", status)
+	}
+
+	// Orders without an authorized payment have nothing to void
+	if err := useCase.VoidOrder(ctx, 1); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
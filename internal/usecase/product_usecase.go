@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/milad-ahmd/go-clean-arch/internal/domain"
 	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
@@ -35,13 +36,13 @@ func (u *productUseCase) GetByID(ctx context.Context, id int64) (*domain.Product
 }
 
 // List lists products with pagination
-func (u *productUseCase) List(ctx context.Context, limit, offset int) ([]domain.Product, int, error) {
-	products, total, err := u.productRepo.FindAll(ctx, limit, offset)
+func (u *productUseCase) List(ctx context.Context, page domain.PageRequest) (*domain.Page[domain.Product], error) {
+	products, err := u.productRepo.FindAll(ctx, page)
 	if err != nil {
-		u.logger.Error("Failed to list products", zap.Int("limit", limit), zap.Int("offset", offset), zap.Error(err))
-		return nil, 0, err
+		u.logger.Error("Failed to list products", zap.Int("limit", page.Limit), zap.Int("offset", page.Offset), zap.Error(err))
+		return nil, err
 	}
-	return products, total, nil
+	return products, nil
 }
 
 // Create creates a new product
@@ -59,6 +60,13 @@ func (u *productUseCase) Create(ctx context.Context, createDTO *domain.ProductCr
 		return nil, errors.NewBadRequestError("Invalid category ID")
 	}
 
+	if err := validatePrice(createDTO.Price); err != nil {
+		return nil, err
+	}
+	if err := domain.ValidateProductOptions(createDTO.Options); err != nil {
+		return nil, err
+	}
+
 	// Create the product
 	product := &domain.Product{
 		Name:        createDTO.Name,
@@ -68,6 +76,7 @@ func (u *productUseCase) Create(ctx context.Context, createDTO *domain.ProductCr
 		Stock:       createDTO.Stock,
 		CategoryID:  createDTO.CategoryID,
 		Images:      createDTO.Images,
+		Options:     createDTO.Options,
 	}
 
 	if err := u.productRepo.Create(ctx, product); err != nil {
@@ -94,6 +103,11 @@ func (u *productUseCase) Update(ctx context.Context, id int64, updateDTO *domain
 		return nil, err
 	}
 
+	// The update only applies to the version the client last read
+	if err := domain.CheckVersion("Product", id, product.Version, updateDTO.Version); err != nil {
+		return nil, err
+	}
+
 	// Check if SKU is being updated and if it's already taken
 	if updateDTO.SKU != "" && updateDTO.SKU != product.SKU {
 		existingProduct, err := u.productRepo.FindBySKU(ctx, updateDTO.SKU)
@@ -120,8 +134,26 @@ func (u *productUseCase) Update(ctx context.Context, id int64, updateDTO *domain
 	if updateDTO.Description != "" {
 		product.Description = updateDTO.Description
 	}
-	if updateDTO.Price > 0 {
-		product.Price = updateDTO.Price
+	if updateDTO.Price != nil {
+		if err := validatePrice(*updateDTO.Price); err != nil {
+			return nil, err
+		}
+		// Variant prices are stored in the product currency
+		if updateDTO.Price.Currency != product.Price.Currency && hasVariantPrices(product.Variants) {
+			return nil, errors.NewAppError(errors.ErrConflict, "Product currency cannot change while variants override its price", http.StatusConflict)
+		}
+		product.Price = *updateDTO.Price
+	}
+	if updateDTO.Options != nil {
+		if err := domain.ValidateProductOptions(updateDTO.Options); err != nil {
+			return nil, err
+		}
+		product.Options = updateDTO.Options
+		for _, variant := range product.Variants {
+			if product.ValidateVariantOptions(variant.Options) != nil {
+				return nil, errors.NewAppError(errors.ErrConflict, "Options no longer match variant "+variant.SKU, http.StatusConflict)
+			}
+		}
 	}
 	if updateDTO.Stock >= 0 {
 		product.Stock = updateDTO.Stock
@@ -165,28 +197,23 @@ func (u *productUseCase) GetBySKU(ctx context.Context, sku string) (*domain.Prod
 	return product, nil
 }
 
-// GetByCategory gets products by category ID
-func (u *productUseCase) GetByCategory(ctx context.Context, categoryID int64, page, perPage int) ([]domain.Product, int, error) {
+// GetByCategory gets products by category ID, optionally including the
+// products of every subcategory
+func (u *productUseCase) GetByCategory(ctx context.Context, categoryID int64, includeDescendants bool, page domain.PageRequest) (*domain.Page[domain.Product], error) {
 	// Check if category exists
 	_, err := u.categoryRepo.FindByID(ctx, categoryID)
 	if err != nil {
 		u.logger.Error("Failed to find category", zap.Int64("categoryID", categoryID), zap.Error(err))
-		return nil, 0, err
-	}
-
-	// Calculate offset
-	offset := (page - 1) * perPage
-	if offset < 0 {
-		offset = 0
+		return nil, err
 	}
 
-	products, total, err := u.productRepo.FindByCategory(ctx, categoryID, perPage, offset)
+	products, err := u.productRepo.FindByCategory(ctx, categoryID, includeDescendants, page)
 	if err != nil {
 		u.logger.Error("Failed to get products by category", zap.Int64("categoryID", categoryID), zap.Error(err))
-		return nil, 0, err
+		return nil, err
 	}
 
-	return products, total, nil
+	return products, nil
 }
 
 // UpdateStock updates a product's stock
@@ -198,19 +225,191 @@ func (u *productUseCase) UpdateStock(ctx context.Context, id int64, quantity int
 	return nil
 }
 
-// Search searches for products
-func (u *productUseCase) Search(ctx context.Context, query string, page, perPage int) ([]domain.Product, int, error) {
-	// Calculate offset
-	offset := (page - 1) * perPage
-	if offset < 0 {
-		offset = 0
+// Search searches for the products matching a filter, most relevant first,
+// optionally with the facets of every match
+func (u *productUseCase) Search(ctx context.Context, query string, filter domain.ProductFilter, page domain.PageRequest, withFacets bool) (*domain.ProductSearchResult, error) {
+	hits, err := u.productRepo.SearchProducts(ctx, query, filter, page)
+	if err != nil {
+		u.logger.Error("Failed to search products", zap.String("query", query), zap.Error(err))
+		return nil, err
+	}
+
+	result := &domain.ProductSearchResult{Page: hits}
+	if withFacets {
+		result.Facets, err = u.productRepo.SearchFacets(ctx, query, filter, domain.ProductPriceBounds)
+		if err != nil {
+			u.logger.Error("Failed to count search facets", zap.String("query", query), zap.Error(err))
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// ListByFilter lists the products matching a filter, in its sort order
+func (u *productUseCase) ListByFilter(ctx context.Context, filter domain.ProductFilter, page domain.PageRequest) (*domain.Page[domain.Product], error) {
+	products, err := u.productRepo.FindByFilter(ctx, filter, page)
+	if err != nil {
+		u.logger.Error("Failed to list filtered products", zap.String("sort", filter.SortString()), zap.Error(err))
+		return nil, err
 	}
 
-	products, total, err := u.productRepo.SearchProducts(ctx, query, perPage, offset)
+	return products, nil
+}
+
+// GetVariants gets the variants of a product
+func (u *productUseCase) GetVariants(ctx context.Context, productID int64) ([]domain.ProductVariant, error) {
+	product, err := u.productRepo.FindByID(ctx, productID)
 	if err != nil {
-		u.logger.Error("Failed to search products", zap.String("query", query), zap.Error(err))
-		return nil, 0, err
+		u.logger.Error("Failed to get product for variants", zap.Int64("productID", productID), zap.Error(err))
+		return nil, err
+	}
+	if product.Variants == nil {
+		return []domain.ProductVariant{}, nil
+	}
+	return product.Variants, nil
+}
+
+// CreateVariant creates a variant of a product
+func (u *productUseCase) CreateVariant(ctx context.Context, productID int64, createDTO *domain.ProductVariantCreateDTO) (*domain.ProductVariant, error) {
+	product, err := u.productRepo.FindByID(ctx, productID)
+	if err != nil {
+		u.logger.Error("Failed to get product for variant creation", zap.Int64("productID", productID), zap.Error(err))
+		return nil, err
+	}
+
+	if err := product.ValidateVariantOptions(createDTO.Options); err != nil {
+		return nil, err
+	}
+	if createDTO.Price != nil {
+		if err := validateVariantPrice(product, *createDTO.Price); err != nil {
+			return nil, err
+		}
+	}
+
+	variant := &domain.ProductVariant{
+		ProductID: productID,
+		SKU:       createDTO.SKU,
+		Options:   createDTO.Options,
+		Price:     createDTO.Price,
+		Stock:     createDTO.Stock,
+	}
+
+	if err := u.productRepo.CreateVariant(ctx, variant); err != nil {
+		u.logger.Error("Failed to create product variant", zap.Int64("productID", productID), zap.Error(err))
+		return nil, err
+	}
+
+	return variant, nil
+}
+
+// UpdateVariant updates a variant of a product
+func (u *productUseCase) UpdateVariant(ctx context.Context, productID, variantID int64, updateDTO *domain.ProductVariantUpdateDTO) (*domain.ProductVariant, error) {
+	product, variant, err := u.findVariant(ctx, productID, variantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if updateDTO.SKU != "" {
+		variant.SKU = updateDTO.SKU
+	}
+	if updateDTO.Options != nil {
+		if err := product.ValidateVariantOptions(updateDTO.Options); err != nil {
+			return nil, err
+		}
+		variant.Options = updateDTO.Options
+	}
+	if updateDTO.ClearPrice {
+		variant.Price = nil
+	} else if updateDTO.Price != nil {
+		if err := validateVariantPrice(product, *updateDTO.Price); err != nil {
+			return nil, err
+		}
+		variant.Price = updateDTO.Price
+	}
+	if updateDTO.Stock != nil {
+		variant.Stock = *updateDTO.Stock
+	}
+
+	if err := u.productRepo.UpdateVariant(ctx, variant); err != nil {
+		u.logger.Error("Failed to update product variant", zap.Int64("id", variantID), zap.Error(err))
+		return nil, err
+	}
+
+	return variant, nil
+}
+
+// DeleteVariant deletes a variant of a product
+func (u *productUseCase) DeleteVariant(ctx context.Context, productID, variantID int64) error {
+	if _, _, err := u.findVariant(ctx, productID, variantID); err != nil {
+		return err
+	}
+
+	if err := u.productRepo.DeleteVariant(ctx, variantID); err != nil {
+		u.logger.Error("Failed to delete product variant", zap.Int64("id", variantID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// UpdateVariantStock updates the stock of a variant of a product
+func (u *productUseCase) UpdateVariantStock(ctx context.Context, productID, variantID int64, quantity int) error {
+	if _, _, err := u.findVariant(ctx, productID, variantID); err != nil {
+		return err
+	}
+
+	if err := u.productRepo.UpdateVariantStock(ctx, variantID, quantity); err != nil {
+		u.logger.Error("Failed to update variant stock", zap.Int64("id", variantID), zap.Int("quantity", quantity), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// findVariant finds a product and one of its variants. A variant of another
+// product is reported as not found.
+func (u *productUseCase) findVariant(ctx context.Context, productID, variantID int64) (*domain.Product, *domain.ProductVariant, error) {
+	product, err := u.productRepo.FindByID(ctx, productID)
+	if err != nil {
+		u.logger.Error("Failed to get product for variant", zap.Int64("productID", productID), zap.Error(err))
+		return nil, nil, err
+	}
+
+	variant := product.Variant(variantID)
+	if variant == nil {
+		return nil, nil, errors.NewNotFoundError("Product variant", variantID)
+	}
+	return product, variant, nil
+}
+
+// hasVariantPrices reports whether any variant overrides the product price
+func hasVariantPrices(variants []domain.ProductVariant) bool {
+	for _, variant := range variants {
+		if variant.Price != nil {
+			return true
+		}
 	}
+	return false
+}
 
-	return products, total, nil
+// validateVariantPrice rejects variant prices that are invalid or not in the
+// product currency
+func validateVariantPrice(product *domain.Product, price domain.Money) error {
+	if err := validatePrice(price); err != nil {
+		return err
+	}
+	if price.Currency != product.Price.Currency {
+		return errors.NewBadRequestError("Variant price must be in the product currency: " + string(product.Price.Currency))
+	}
+	return nil
+}
+
+// validatePrice rejects prices in unsupported currencies and non-positive amounts
+func validatePrice(price domain.Money) error {
+	if !price.Currency.IsValid() {
+		return errors.NewBadRequestError("Unsupported price currency: " + string(price.Currency))
+	}
+	if !price.IsPositive() {
+		return errors.NewBadRequestError("Price must be greater than zero")
+	}
+	return nil
 }
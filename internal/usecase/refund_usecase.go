@@ -0,0 +1,222 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	pkgerrors "github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type refundUseCase struct {
+	refundRepo  domain.RefundRepository
+	paymentRepo domain.PaymentRepository
+	orderRepo   domain.OrderRepository
+	gateway     domain.PaymentGateway
+	timeout     time.Duration
+	logger      logger.Logger
+}
+
+// NewRefundUseCase creates a new refund use case. Every gateway call is
+// given at most timeout to answer.
+func NewRefundUseCase(refundRepo domain.RefundRepository, paymentRepo domain.PaymentRepository, orderRepo domain.OrderRepository, gateway domain.PaymentGateway, timeout time.Duration, logger logger.Logger) domain.RefundUseCase {
+	return &refundUseCase{
+		refundRepo:  refundRepo,
+		paymentRepo: paymentRepo,
+		orderRepo:   orderRepo,
+		gateway:     gateway,
+		timeout:     timeout,
+		logger:      logger,
+	}
+}
+
+// GetByOrderID gets every refund of an order, oldest first
+func (u *refundUseCase) GetByOrderID(ctx context.Context, orderID int64) ([]domain.Refund, error) {
+	refunds, err := u.refundRepo.FindByOrderID(ctx, orderID)
+	if err != nil {
+		u.logger.Error("Failed to get refunds by order ID", zap.Int64("orderID", orderID), zap.Error(err))
+		return nil, err
+	}
+	return refunds, nil
+}
+
+// Create refunds part or all of a completed or cancelled order's captured
+// payment. The refund is recorded as pending before the gateway is called,
+// so concurrent refunds cannot exceed the captured amount, and failed
+// refunds are kept.
+func (u *refundUseCase) Create(ctx context.Context, orderID int64, createDTO *domain.RefundCreateDTO) (*domain.Refund, error) {
+	order, err := u.orderRepo.FindByID(ctx, orderID)
+	if err != nil {
+		u.logger.Error("Failed to get order for refund", zap.Int64("orderID", orderID), zap.Error(err))
+		return nil, err
+	}
+
+	switch order.Status {
+	case domain.OrderStatusCompleted:
+	case domain.OrderStatusCancelled:
+		if createDTO.Restock {
+			return nil, pkgerrors.NewAppError(pkgerrors.ErrConflict, "Items of cancelled orders have already been restocked", http.StatusConflict)
+		}
+	default:
+		return nil, pkgerrors.NewAppError(pkgerrors.ErrConflict, "Only completed or cancelled orders can be refunded", http.StatusConflict)
+	}
+
+	payment, err := u.capturedPayment(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	refunded, err := u.refundRepo.RefundedTotals(ctx, payment.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	refund, err := newRefund(order, payment, refunded, createDTO)
+	if err != nil {
+		return nil, err
+	}
+
+	// Checked again by the repository with the payment locked
+	if err := domain.CheckRefund(refund, payment.CapturedAmount, order.Items, refunded); err != nil {
+		return nil, err
+	}
+
+	if err := u.refundRepo.Create(ctx, refund); err != nil {
+		u.logger.Error("Failed to record refund", zap.Int64("orderID", orderID), zap.Error(err))
+		return nil, err
+	}
+
+	gatewayCtx, cancel := context.WithTimeout(ctx, u.timeout)
+	reference, err := u.gateway.Refund(gatewayCtx, payment.ProviderReference, refund.Amount)
+	cancel()
+
+	if err != nil {
+		failure := gatewayFailure(err)
+		u.logger.Warn("Refund failed", zap.Int64("orderID", orderID), zap.Int64("refundID", refund.ID), zap.Error(err))
+
+		refund.Status = domain.RefundStatusFailed
+		refund.FailureMessage = failure.Message
+		if completeErr := u.refundRepo.Complete(ctx, refund); completeErr != nil {
+			u.logger.Error("Failed to record refund failure", zap.Int64("refundID", refund.ID), zap.Error(completeErr))
+		}
+		return nil, paymentError(failure)
+	}
+
+	refund.Status = domain.RefundStatusSucceeded
+	refund.ProviderReference = reference
+	if err := u.refundRepo.Complete(ctx, refund); err != nil {
+		// The gateway has returned the money; the record must be repaired
+		u.logger.Error("Failed to record refund", zap.Int64("refundID", refund.ID), zap.String("reference", reference), zap.Error(err))
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+// capturedPayment finds the captured payment of an order
+func (u *refundUseCase) capturedPayment(ctx context.Context, orderID int64) (*domain.Payment, error) {
+	payments, err := u.paymentRepo.FindByOrderID(ctx, orderID)
+	if err != nil {
+		u.logger.Error("Failed to get payments by order ID", zap.Int64("orderID", orderID), zap.Error(err))
+		return nil, err
+	}
+
+	for i := range payments {
+		if payments[i].Status == domain.PaymentStatusCaptured {
+			return &payments[i], nil
+		}
+	}
+
+	return nil, pkgerrors.NewAppError(pkgerrors.ErrConflict, "Order has no captured payment to refund", http.StatusConflict)
+}
+
+// newRefund builds the refund requested by createDTO. Lines without an
+// amount are refunded at what was paid for their units, and a refund
+// without lines or amount returns everything not refunded yet.
+func newRefund(order *domain.Order, payment *domain.Payment, refunded domain.RefundedTotals, createDTO *domain.RefundCreateDTO) (*domain.Refund, error) {
+	currency := payment.CapturedAmount.Currency
+	refund := &domain.Refund{
+		OrderID:   order.ID,
+		PaymentID: payment.ID,
+		Reason:    createDTO.Reason,
+		Restock:   createDTO.Restock,
+		Items:     []domain.RefundItem{},
+	}
+	if createDTO.ActorID != 0 {
+		actorID := createDTO.ActorID
+		refund.ActorID = &actorID
+	}
+
+	if len(createDTO.Items) == 0 {
+		if createDTO.Restock {
+			return nil, &domain.ValidationError{Field: "restock", Message: "requires the items to restock"}
+		}
+
+		remaining := payment.CapturedAmount.Amount - refunded.Amount
+		if remaining <= 0 {
+			return nil, pkgerrors.NewAppError(pkgerrors.ErrConflict, "Order has been refunded in full", http.StatusConflict)
+		}
+
+		refund.Amount = domain.NewMoney(remaining, currency)
+		if createDTO.Amount != nil {
+			refund.Amount = *createDTO.Amount
+		}
+		return refund, nil
+	}
+
+	if createDTO.Amount != nil {
+		return nil, &domain.ValidationError{Field: "amount", Message: "must be omitted when items are given"}
+	}
+
+	items := make(map[int64]*domain.OrderItem, len(order.Items))
+	for i := range order.Items {
+		items[order.Items[i].ID] = &order.Items[i]
+	}
+
+	// Units refunded so far, including earlier lines of this refund
+	quantities := make(map[int64]int, len(createDTO.Items))
+	for id, item := range refunded.Items {
+		quantities[id] = item.Quantity
+	}
+
+	refund.Amount = domain.NewMoney(0, currency)
+	for _, line := range createDTO.Items {
+		if line.Quantity == 0 && line.Amount == nil {
+			return nil, &domain.ValidationError{Field: "items", Message: fmt.Sprintf("order item %d needs a quantity or an amount", line.OrderItemID)}
+		}
+
+		amount := domain.NewMoney(0, currency)
+		if line.Amount != nil {
+			amount = *line.Amount
+		} else {
+			item, ok := items[line.OrderItemID]
+			if !ok {
+				return nil, &domain.ValidationError{Field: "items", Message: fmt.Sprintf("order item %d is not part of the order", line.OrderItemID)}
+			}
+			total, err := item.RefundTotal(quantities[line.OrderItemID], line.Quantity)
+			if err != nil {
+				return nil, &domain.ValidationError{Field: "items", Message: fmt.Sprintf("quantity of order item %d is out of range", line.OrderItemID)}
+			}
+			amount = total
+		}
+		quantities[line.OrderItemID] += line.Quantity
+
+		sum, err := refund.Amount.Add(amount)
+		if err != nil {
+			return nil, &domain.ValidationError{Field: "items", Message: fmt.Sprintf("amount of order item %d is invalid", line.OrderItemID)}
+		}
+		refund.Amount = sum
+
+		refund.Items = append(refund.Items, domain.RefundItem{
+			OrderItemID: line.OrderItemID,
+			Quantity:    line.Quantity,
+			Amount:      amount,
+		})
+	}
+
+	return refund, nil
+}
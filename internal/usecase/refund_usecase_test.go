@@ -0,0 +1,187 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/internal/payment"
+	pkgerrors "github.com/milad-ahmd/go-clean-arch/pkg/errors"
+)
+
+// mockRefundRepository is an in-memory refund repository
+type mockRefundRepository struct {
+	refunds  []domain.Refund
+	payments *mockPaymentRepository
+	orders   *mockOrderRepository
+}
+
+// FindByOrderID finds the refunds of an order
+func (m *mockRefundRepository) FindByOrderID(_ context.Context, orderID int64) ([]domain.Refund, error) {
+	refunds := []domain.Refund{}
+	for _, refund := range m.refunds {
+		if refund.OrderID == orderID {
+			refunds = append(refunds, refund)
+		}
+	}
+	return refunds, nil
+}
+
+// RefundedTotals totals the pending and succeeded refunds of a payment
+func (m *mockRefundRepository) RefundedTotals(_ context.Context, paymentID int64) (domain.RefundedTotals, error) {
+	totals := domain.RefundedTotals{Items: make(map[int64]domain.RefundedItem)}
+	for _, refund := range m.refunds {
+		if refund.PaymentID != paymentID || refund.Status == domain.RefundStatusFailed {
+			continue
+		}
+		totals.Amount += refund.Amount.Amount
+		for _, line := range refund.Items {
+			item := totals.Items[line.OrderItemID]
+			item.Quantity += line.Quantity
+			item.Amount += line.Amount.Amount
+			totals.Items[line.OrderItemID] = item
+		}
+	}
+	return totals, nil
+}
+
+// Create checks and records a pending refund
+func (m *mockRefundRepository) Create(ctx context.Context, refund *domain.Refund) error {
+	payment := m.payments.payments[refund.PaymentID]
+	totals, _ := m.RefundedTotals(ctx, refund.PaymentID)
+	if err := domain.CheckRefund(refund, payment.CapturedAmount, m.orders.orders[refund.OrderID].Items, totals); err != nil {
+		return err
+	}
+	refund.ID = int64(len(m.refunds) + 1)
+	refund.Status = domain.RefundStatusPending
+	m.refunds = append(m.refunds, *refund)
+	return nil
+}
+
+// Complete stores the final status of a refund
+func (m *mockRefundRepository) Complete(_ context.Context, refund *domain.Refund) error {
+	m.refunds[refund.ID-1] = *refund
+	return nil
+}
+
+// newTestRefundUseCase creates a refund use case with the fake gateway and
+// one completed order of 3 x 10.00 USD, paid and captured
+func newTestRefundUseCase(t *testing.T) (domain.RefundUseCase, *mockRefundRepository) {
+	gateway := payment.NewFakeGateway()
+	total := domain.NewMoney(3000, domain.CurrencyUSD)
+
+	reference, err := gateway.Authorize(context.Background(), &domain.PaymentAuthorization{Amount: total})
+	if err != nil {
+		t.Fatalf("Failed to authorize payment: %v", err)
+	}
+	if err := gateway.Capture(context.Background(), reference, total); err != nil {
+		t.Fatalf("Failed to capture payment: %v", err)
+	}
+
+	orderRepo := &mockOrderRepository{orders: map[int64]*domain.Order{
+		1: {ID: 1, UserID: 1, Status: domain.OrderStatusCompleted, TotalAmount: total, Items: []domain.OrderItem{
+			{ID: 11, OrderID: 1, ProductID: 1, Quantity: 3, Price: domain.NewMoney(1000, domain.CurrencyUSD), Discount: domain.NewMoney(0, domain.CurrencyUSD)},
+		}},
+	}}
+	paymentRepo := newMockPaymentRepository(orderRepo)
+	if err := paymentRepo.Create(context.Background(), &domain.Payment{
+		OrderID:           1,
+		Status:            domain.PaymentStatusCaptured,
+		Amount:            total,
+		CapturedAmount:    total,
+		ProviderReference: reference,
+	}); err != nil {
+		t.Fatalf("Failed to create payment: %v", err)
+	}
+
+	refundRepo := &mockRefundRepository{payments: paymentRepo, orders: orderRepo}
+	useCase := NewRefundUseCase(refundRepo, paymentRepo, orderRepo, gateway, 20*time.Millisecond, &mockLogger{})
+	return useCase, refundRepo
+}
+
+// TestRefundUseCase_Create tests partial and full refunds within the captured amount
+func TestRefundUseCase_Create(t *testing.T) {
+	useCase, _ := newTestRefundUseCase(t)
+	ctx := context.Background()
+
+	// One unit, refunded at what was paid for it and restocked
+	refund, err := useCase.Create(ctx, 1, &domain.RefundCreateDTO{
+		Items:   []domain.RefundItemCreateDTO{{OrderItemID: 11, Quantity: 1}},
+		Restock: true,
+		ActorID: 2,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if refund.Status != domain.RefundStatusSucceeded || refund.Amount.Amount != 1000 || refund.ProviderReference == "" {
+		t.Errorf("Expected a succeeded refund of 1000, got %+v", refund)
+	}
+	if refund.ActorID == nil || *refund.ActorID != 2 {
+		t.Errorf("Expected actor 2, got %v", refund.ActorID)
+	}
+
+	// More than remains
+	amount := domain.NewMoney(2500, domain.CurrencyUSD)
+	_, err = useCase.Create(ctx, 1, &domain.RefundCreateDTO{Amount: &amount})
+	var validationErr *domain.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf("Expected validation error, got %v", err)
+	}
+
+	// The rest of the order
+	refund, err = useCase.Create(ctx, 1, &domain.RefundCreateDTO{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if refund.Amount.Amount != 2000 {
+		t.Errorf("Expected a refund of the remaining 2000, got %d", refund.Amount.Amount)
+	}
+
+	if _, err := useCase.Create(ctx, 1, &domain.RefundCreateDTO{}); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("Expected conflict once refunded in full, got %v", err)
+	}
+
+	refunds, err := useCase.GetByOrderID(ctx, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(refunds) != 2 {
+		t.Errorf("Expected 2 refunds, got %d", len(refunds))
+	}
+}
+
+// TestRefundUseCase_Create_Rules tests the refunds that are refused before reaching the gateway
+func TestRefundUseCase_Create_Rules(t *testing.T) {
+	amount := domain.NewMoney(500, domain.CurrencyUSD)
+
+	tests := []struct {
+		name   string
+		status domain.OrderStatus
+		dto    domain.RefundCreateDTO
+		code   int
+	}{
+		{name: "pending order", status: domain.OrderStatusPending, dto: domain.RefundCreateDTO{}, code: http.StatusConflict},
+		{name: "restock a cancelled order", status: domain.OrderStatusCancelled, dto: domain.RefundCreateDTO{Items: []domain.RefundItemCreateDTO{{OrderItemID: 11, Quantity: 1}}, Restock: true}, code: http.StatusConflict},
+		{name: "restock without items", status: domain.OrderStatusCompleted, dto: domain.RefundCreateDTO{Restock: true}, code: http.StatusBadRequest},
+		{name: "amount with items", status: domain.OrderStatusCompleted, dto: domain.RefundCreateDTO{Amount: &amount, Items: []domain.RefundItemCreateDTO{{OrderItemID: 11, Quantity: 1}}}, code: http.StatusBadRequest},
+		{name: "line without quantity or amount", status: domain.OrderStatusCompleted, dto: domain.RefundCreateDTO{Items: []domain.RefundItemCreateDTO{{OrderItemID: 11}}}, code: http.StatusBadRequest},
+		{name: "too many units", status: domain.OrderStatusCompleted, dto: domain.RefundCreateDTO{Items: []domain.RefundItemCreateDTO{{OrderItemID: 11, Quantity: 4}}}, code: http.StatusBadRequest},
+		{name: "unknown item", status: domain.OrderStatusCompleted, dto: domain.RefundCreateDTO{Items: []domain.RefundItemCreateDTO{{OrderItemID: 12, Quantity: 1}}}, code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		useCase, refundRepo := newTestRefundUseCase(t)
+		refundRepo.orders.orders[1].Status = tt.status
+
+		_, err := useCase.Create(context.Background(), 1, &tt.dto)
+		if status := pkgerrors.GetStatusCode(err); status != tt.code {
+			t.Errorf("%s: expected status %d, got %d (%v)", tt.name, tt.code, status, err)
+		}
+		if len(refundRepo.refunds) != 0 {
+			t.Errorf("%s: expected no refund recorded, got %d", tt.name, len(refundRepo.refunds))
+		}
+	}
+}
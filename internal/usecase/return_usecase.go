@@ -0,0 +1,149 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	pkgerrors "github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type returnUseCase struct {
+	returnRepo domain.ReturnRepository
+	orderRepo  domain.OrderRepository
+	logger     logger.Logger
+}
+
+// NewReturnUseCase creates a new return use case
+func NewReturnUseCase(returnRepo domain.ReturnRepository, orderRepo domain.OrderRepository, logger logger.Logger) domain.ReturnUseCase {
+	return &returnUseCase{
+		returnRepo: returnRepo,
+		orderRepo:  orderRepo,
+		logger:     logger,
+	}
+}
+
+// GetByID gets a return by ID
+func (u *returnUseCase) GetByID(ctx context.Context, id int64) (*domain.Return, error) {
+	rma, err := u.returnRepo.FindByID(ctx, id)
+	if err != nil {
+		u.logger.Error("Failed to get return by ID", zap.Int64("id", id), zap.Error(err))
+		return nil, err
+	}
+	return rma, nil
+}
+
+// GetByOrderID gets every return of an order, oldest first
+func (u *returnUseCase) GetByOrderID(ctx context.Context, orderID int64) ([]domain.Return, error) {
+	returns, err := u.returnRepo.FindByOrderID(ctx, orderID)
+	if err != nil {
+		u.logger.Error("Failed to get returns by order ID", zap.Int64("orderID", orderID), zap.Error(err))
+		return nil, err
+	}
+	return returns, nil
+}
+
+// Create requests the return of units of a completed order on behalf of
+// the order's customer
+func (u *returnUseCase) Create(ctx context.Context, orderID int64, createDTO *domain.ReturnCreateDTO) (*domain.Return, error) {
+	order, err := u.orderRepo.FindByID(ctx, orderID)
+	if err != nil {
+		u.logger.Error("Failed to get order for return", zap.Int64("orderID", orderID), zap.Error(err))
+		return nil, err
+	}
+
+	if order.Status != domain.OrderStatusCompleted {
+		return nil, pkgerrors.NewAppError(pkgerrors.ErrConflict, "Only completed orders can be returned", http.StatusConflict)
+	}
+
+	rma := &domain.Return{
+		OrderID: order.ID,
+		UserID:  order.UserID,
+		Reason:  createDTO.Reason,
+		Items:   make([]domain.ReturnItem, 0, len(createDTO.Items)),
+	}
+	for _, item := range createDTO.Items {
+		rma.Items = append(rma.Items, domain.ReturnItem{
+			OrderItemID: item.OrderItemID,
+			Quantity:    item.Quantity,
+			Reason:      item.Reason,
+		})
+	}
+
+	if err := u.returnRepo.Create(ctx, rma); err != nil {
+		u.logger.Error("Failed to create return", zap.Int64("orderID", orderID), zap.Error(err))
+		return nil, err
+	}
+
+	return rma, nil
+}
+
+// UpdateStatus approves, rejects or receives a return
+func (u *returnUseCase) UpdateStatus(ctx context.Context, id int64, statusDTO *domain.ReturnStatusUpdateDTO) (*domain.Return, error) {
+	rma, err := u.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusDTO.Status == domain.ReturnStatusInspected {
+		return nil, &domain.ValidationError{Field: "status", Message: "returns are inspected with their item dispositions"}
+	}
+
+	return u.transition(ctx, rma, statusDTO.Status, statusDTO.Note)
+}
+
+// Inspect records the disposition of every item of a received return and
+// applies it to stock: restocked items go back on sale, written off items
+// do not
+func (u *returnUseCase) Inspect(ctx context.Context, id int64, inspectDTO *domain.ReturnInspectDTO) (*domain.Return, error) {
+	rma, err := u.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rma.Status.ValidateTransition(domain.ReturnStatusInspected); err != nil {
+		return nil, err
+	}
+
+	dispositions := make(map[int64]domain.ReturnDisposition, len(inspectDTO.Items))
+	for _, item := range inspectDTO.Items {
+		if _, ok := dispositions[item.ReturnItemID]; ok {
+			return nil, &domain.ValidationError{Field: "items", Message: fmt.Sprintf("return item %d is listed more than once", item.ReturnItemID)}
+		}
+		dispositions[item.ReturnItemID] = item.Disposition
+	}
+
+	for i := range rma.Items {
+		disposition, ok := dispositions[rma.Items[i].ID]
+		if !ok {
+			return nil, &domain.ValidationError{Field: "items", Message: fmt.Sprintf("return item %d needs a disposition", rma.Items[i].ID)}
+		}
+		rma.Items[i].Disposition = disposition
+		delete(dispositions, rma.Items[i].ID)
+	}
+	for itemID := range dispositions {
+		return nil, &domain.ValidationError{Field: "items", Message: fmt.Sprintf("return item %d is not part of the return", itemID)}
+	}
+
+	return u.transition(ctx, rma, domain.ReturnStatusInspected, inspectDTO.Note)
+}
+
+// transition moves a return to status next with the staff's note
+func (u *returnUseCase) transition(ctx context.Context, rma *domain.Return, next domain.ReturnStatus, note string) (*domain.Return, error) {
+	from := rma.Status
+	if err := from.ValidateTransition(next); err != nil {
+		return nil, err
+	}
+
+	rma.Status = next
+	rma.Note = note
+	if err := u.returnRepo.UpdateStatus(ctx, rma, from); err != nil {
+		u.logger.Error("Failed to update return status", zap.Int64("id", rma.ID), zap.String("status", string(next)), zap.Error(err))
+		return nil, err
+	}
+
+	return rma, nil
+}
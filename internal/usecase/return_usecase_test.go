@@ -0,0 +1,178 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	pkgerrors "github.com/milad-ahmd/go-clean-arch/pkg/errors"
+)
+
+// mockReturnRepository is an in-memory return repository
+type mockReturnRepository struct {
+	returns  []domain.Return
+	orders   *mockOrderRepository
+	restocks map[int64]int
+}
+
+// FindByID finds a return by ID
+func (m *mockReturnRepository) FindByID(_ context.Context, id int64) (*domain.Return, error) {
+	if id < 1 || id > int64(len(m.returns)) {
+		return nil, pkgerrors.NewNotFoundError("Return", id)
+	}
+	rma := m.returns[id-1]
+	rma.Items = append([]domain.ReturnItem{}, rma.Items...)
+	return &rma, nil
+}
+
+// FindByOrderID finds the returns of an order
+func (m *mockReturnRepository) FindByOrderID(_ context.Context, orderID int64) ([]domain.Return, error) {
+	returns := []domain.Return{}
+	for _, rma := range m.returns {
+		if rma.OrderID == orderID {
+			returns = append(returns, rma)
+		}
+	}
+	return returns, nil
+}
+
+// Create checks and records a requested return
+func (m *mockReturnRepository) Create(_ context.Context, rma *domain.Return) error {
+	returned := make(map[int64]int)
+	for _, existing := range m.returns {
+		if existing.OrderID != rma.OrderID || existing.Status == domain.ReturnStatusRejected {
+			continue
+		}
+		for _, item := range existing.Items {
+			returned[item.OrderItemID] += item.Quantity
+		}
+	}
+	if err := domain.CheckReturn(rma, m.orders.orders[rma.OrderID].Items, returned); err != nil {
+		return err
+	}
+
+	rma.ID = int64(len(m.returns) + 1)
+	rma.Status = domain.ReturnStatusRequested
+	for i := range rma.Items {
+		rma.Items[i].ID = rma.ID*10 + int64(i)
+		rma.Items[i].ReturnID = rma.ID
+	}
+	m.returns = append(m.returns, *rma)
+	return nil
+}
+
+// UpdateStatus stores a return and counts the units restocked on inspection
+func (m *mockReturnRepository) UpdateStatus(_ context.Context, rma *domain.Return, _ domain.ReturnStatus) error {
+	if rma.Status == domain.ReturnStatusInspected {
+		for _, item := range rma.Items {
+			if item.Disposition == domain.ReturnDispositionRestock {
+				m.restocks[item.OrderItemID] += item.Quantity
+			}
+		}
+	}
+	m.returns[rma.ID-1] = *rma
+	return nil
+}
+
+// newTestReturnUseCase creates a return use case with one completed order of
+// two lines: 2 units of item 11 and 1 unit of item 12
+func newTestReturnUseCase() (domain.ReturnUseCase, *mockReturnRepository) {
+	orderRepo := &mockOrderRepository{orders: map[int64]*domain.Order{
+		1: {ID: 1, UserID: 7, Status: domain.OrderStatusCompleted, Items: []domain.OrderItem{
+			{ID: 11, OrderID: 1, ProductID: 1, Quantity: 2},
+			{ID: 12, OrderID: 1, ProductID: 2, Quantity: 1},
+		}},
+	}}
+	returnRepo := &mockReturnRepository{orders: orderRepo, restocks: make(map[int64]int)}
+	return NewReturnUseCase(returnRepo, orderRepo, &mockLogger{}), returnRepo
+}
+
+// TestReturnUseCase_Workflow tests a return from request to inspection
+func TestReturnUseCase_Workflow(t *testing.T) {
+	useCase, returnRepo := newTestReturnUseCase()
+	ctx := context.Background()
+
+	rma, err := useCase.Create(ctx, 1, &domain.ReturnCreateDTO{
+		Reason: "Wrong size",
+		Items: []domain.ReturnItemCreateDTO{
+			{OrderItemID: 11, Quantity: 2},
+			{OrderItemID: 12, Quantity: 1, Reason: "Damaged in transit"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if rma.Status != domain.ReturnStatusRequested || rma.UserID != 7 {
+		t.Errorf("Expected a requested return of user 7, got %+v", rma)
+	}
+
+	// Inspection must wait until the goods are received
+	inspectDTO := &domain.ReturnInspectDTO{Items: []domain.ReturnItemInspectDTO{
+		{ReturnItemID: rma.Items[0].ID, Disposition: domain.ReturnDispositionRestock},
+		{ReturnItemID: rma.Items[1].ID, Disposition: domain.ReturnDispositionWriteOff},
+	}}
+	if _, err := useCase.Inspect(ctx, rma.ID, inspectDTO); !errors.Is(err, domain.ErrInvalidStatusTransition) {
+		t.Errorf("Expected invalid status transition, got %v", err)
+	}
+
+	for _, status := range []domain.ReturnStatus{domain.ReturnStatusApproved, domain.ReturnStatusReceived} {
+		if _, err := useCase.UpdateStatus(ctx, rma.ID, &domain.ReturnStatusUpdateDTO{Status: status}); err != nil {
+			t.Fatalf("%s: expected no error, got %v", status, err)
+		}
+	}
+
+	// Every item needs a disposition
+	partial := &domain.ReturnInspectDTO{Items: inspectDTO.Items[:1]}
+	var validationErr *domain.ValidationError
+	if _, err := useCase.Inspect(ctx, rma.ID, partial); !errors.As(err, &validationErr) {
+		t.Errorf("Expected validation error, got %v", err)
+	}
+
+	inspected, err := useCase.Inspect(ctx, rma.ID, inspectDTO)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if inspected.Status != domain.ReturnStatusInspected || inspected.Items[1].Disposition != domain.ReturnDispositionWriteOff {
+		t.Errorf("Expected an inspected return with dispositions, got %+v", inspected)
+	}
+	if returnRepo.restocks[11] != 2 || returnRepo.restocks[12] != 0 {
+		t.Errorf("Expected only item 11 restocked, got %v", returnRepo.restocks)
+	}
+}
+
+// TestReturnUseCase_Create_Limits tests that returns stay within the units ordered
+func TestReturnUseCase_Create_Limits(t *testing.T) {
+	useCase, returnRepo := newTestReturnUseCase()
+	ctx := context.Background()
+
+	create := func(quantity int) (*domain.Return, error) {
+		return useCase.Create(ctx, 1, &domain.ReturnCreateDTO{
+			Reason: "Changed my mind",
+			Items:  []domain.ReturnItemCreateDTO{{OrderItemID: 11, Quantity: quantity}},
+		})
+	}
+
+	first, err := create(2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var validationErr *domain.ValidationError
+	if _, err := create(1); !errors.As(err, &validationErr) {
+		t.Errorf("Expected validation error, got %v", err)
+	}
+
+	// Rejected returns free their units
+	if _, err := useCase.UpdateStatus(ctx, first.ID, &domain.ReturnStatusUpdateDTO{Status: domain.ReturnStatusRejected, Note: "Outside the return window"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := create(1); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	returnRepo.orders.orders[1].Status = domain.OrderStatusProcessing
+	if _, err := create(1); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("Expected conflict for an order not completed, got %v", err)
+	}
+}
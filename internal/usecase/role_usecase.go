@@ -0,0 +1,177 @@
+package usecase
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// roleNamePattern restricts role names to lowercase identifiers
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{2,49}$`)
+
+// roleUseCase implements domain.RoleUseCase
+type roleUseCase struct {
+	roleRepo domain.RoleRepository
+	userRepo domain.UserRepository
+	logger   logger.Logger
+}
+
+// NewRoleUseCase creates a new role use case
+func NewRoleUseCase(roleRepo domain.RoleRepository, userRepo domain.UserRepository, logger logger.Logger) domain.RoleUseCase {
+	return &roleUseCase{
+		roleRepo: roleRepo,
+		userRepo: userRepo,
+		logger:   logger,
+	}
+}
+
+// List lists every role with its permissions
+func (u *roleUseCase) List(ctx context.Context) ([]domain.RoleDefinition, error) {
+	roles, err := u.roleRepo.FindAll(ctx)
+	if err != nil {
+		u.logger.Error("Failed to list roles", zap.Error(err))
+		return nil, err
+	}
+	return roles, nil
+}
+
+// GetByName gets a role by name
+func (u *roleUseCase) GetByName(ctx context.Context, name domain.Role) (*domain.RoleDefinition, error) {
+	role, err := u.roleRepo.FindByName(ctx, name)
+	if err != nil {
+		u.logger.Error("Failed to get role", zap.String("name", string(name)), zap.Error(err))
+		return nil, err
+	}
+	return role, nil
+}
+
+// Create creates a new role
+func (u *roleUseCase) Create(ctx context.Context, createDTO *domain.RoleCreateDTO) (*domain.RoleDefinition, error) {
+	if !roleNamePattern.MatchString(string(createDTO.Name)) {
+		return nil, &domain.ValidationError{
+			Field:   "name",
+			Message: "must be 3-50 lowercase letters, digits or underscores and start with a letter",
+		}
+	}
+
+	permissions, err := validatePermissions(createDTO.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := &domain.RoleDefinition{
+		Name:        createDTO.Name,
+		Description: createDTO.Description,
+		Permissions: permissions,
+	}
+
+	if err := u.roleRepo.Create(ctx, role); err != nil {
+		u.logger.Error("Failed to create role", zap.String("name", string(role.Name)), zap.Error(err))
+		return nil, err
+	}
+
+	return role, nil
+}
+
+// Update updates a role's description and, when given, its permissions
+func (u *roleUseCase) Update(ctx context.Context, name domain.Role, updateDTO *domain.RoleUpdateDTO) (*domain.RoleDefinition, error) {
+	role, err := u.roleRepo.FindByName(ctx, name)
+	if err != nil {
+		u.logger.Error("Failed to get role for update", zap.String("name", string(name)), zap.Error(err))
+		return nil, err
+	}
+
+	if updateDTO.Description != "" {
+		role.Description = updateDTO.Description
+	}
+
+	if updateDTO.Permissions != nil {
+		role.Permissions, err = validatePermissions(updateDTO.Permissions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := u.roleRepo.Update(ctx, role); err != nil {
+		u.logger.Error("Failed to update role", zap.String("name", string(name)), zap.Error(err))
+		return nil, err
+	}
+
+	return role, nil
+}
+
+// Delete deletes a role that is neither built in nor assigned to any user
+func (u *roleUseCase) Delete(ctx context.Context, name domain.Role) error {
+	if name.IsBuiltIn() {
+		return &domain.ValidationError{
+			Field:   "name",
+			Message: "built-in roles cannot be deleted",
+		}
+	}
+
+	count, err := u.roleRepo.CountUsers(ctx, name)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return &domain.ConflictError{
+			Entity: "Role",
+			Field:  "assigned users",
+			Value:  count,
+		}
+	}
+
+	if err := u.roleRepo.Delete(ctx, name); err != nil {
+		u.logger.Error("Failed to delete role", zap.String("name", string(name)), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// AssignRole assigns an existing role to a user
+func (u *roleUseCase) AssignRole(ctx context.Context, userID int64, role domain.Role) error {
+	if _, err := u.roleRepo.FindByName(ctx, role); err != nil {
+		u.logger.Error("Failed to get role for assignment", zap.String("name", string(role)), zap.Error(err))
+		return err
+	}
+
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		u.logger.Error("Failed to get user for role assignment", zap.Int64("id", userID), zap.Error(err))
+		return err
+	}
+
+	user.Role = role
+	user.UpdatedAt = time.Now().UTC()
+
+	if err := u.userRepo.Update(ctx, user); err != nil {
+		u.logger.Error("Failed to assign role", zap.Int64("id", userID), zap.String("role", string(role)), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// validatePermissions rejects unknown permissions and removes duplicates
+func validatePermissions(permissions []domain.Permission) ([]domain.Permission, error) {
+	seen := make(map[domain.Permission]bool, len(permissions))
+	result := make([]domain.Permission, 0, len(permissions))
+	for _, permission := range permissions {
+		if !permission.IsValid() {
+			return nil, &domain.ValidationError{
+				Field:   "permissions",
+				Message: "contains unknown permission " + string(permission),
+			}
+		}
+		if !seen[permission] {
+			seen[permission] = true
+			result = append(result, permission)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,100 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+)
+
+// TestRoleUseCase_Create tests the Create method
+func TestRoleUseCase_Create(t *testing.T) {
+	roleRepo := newMockRoleRepository()
+	useCase := NewRoleUseCase(roleRepo, newMockUserRepository(), &mockLogger{})
+	ctx := context.Background()
+
+	role, err := useCase.Create(ctx, &domain.RoleCreateDTO{
+		Name:        "warehouse",
+		Permissions: []domain.Permission{domain.PermissionInventoryWrite, domain.PermissionInventoryWrite},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(role.Permissions) != 1 {
+		t.Errorf("Expected duplicate permissions to be removed, got %v", role.Permissions)
+	}
+
+	// Unknown permissions are rejected
+	_, err = useCase.Create(ctx, &domain.RoleCreateDTO{
+		Name:        "auditor",
+		Permissions: []domain.Permission{"audit:everything"},
+	})
+	if !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("Expected validation error, got %v", err)
+	}
+
+	// Invalid names are rejected
+	_, err = useCase.Create(ctx, &domain.RoleCreateDTO{Name: "Bad Name"})
+	if !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("Expected validation error, got %v", err)
+	}
+}
+
+// TestRoleUseCase_Delete tests that built-in and assigned roles cannot be deleted
+func TestRoleUseCase_Delete(t *testing.T) {
+	roleRepo := newMockRoleRepository()
+	roleRepo.roles["support"] = &domain.RoleDefinition{Name: "support"}
+	roleRepo.users["support"] = 2
+	useCase := NewRoleUseCase(roleRepo, newMockUserRepository(), &mockLogger{})
+	ctx := context.Background()
+
+	if err := useCase.Delete(ctx, domain.RoleAdmin); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("Expected validation error for built-in role, got %v", err)
+	}
+
+	if err := useCase.Delete(ctx, "support"); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("Expected conflict error for assigned role, got %v", err)
+	}
+
+	roleRepo.users["support"] = 0
+	if err := useCase.Delete(ctx, "support"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+// TestUserUseCase_ValidateToken tests that the user's permissions are loaded from their role
+func TestUserUseCase_ValidateToken(t *testing.T) {
+	repo := newMockUserRepository()
+	roleRepo := newMockRoleRepository()
+	roleRepo.roles["support"] = &domain.RoleDefinition{
+		Name:        "support",
+		Permissions: []domain.Permission{domain.PermissionOrderRead},
+	}
+	useCase := NewUserUseCase(repo, newMockRefreshTokenRepository(), roleRepo, &mockJWTService{}, &mockLogger{})
+	roleUseCase := NewRoleUseCase(roleRepo, repo, &mockLogger{})
+	ctx := context.Background()
+
+	user := &domain.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password",
+	}
+	if err := useCase.Register(ctx, user); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := roleUseCase.AssignRole(ctx, user.ID, "support"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	validated, err := useCase.ValidateToken(ctx, "token")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !validated.HasPermission(domain.PermissionOrderRead) {
+		t.Error("Expected user to have order:read")
+	}
+	if validated.HasPermission(domain.PermissionOrderRefund) {
+		t.Error("Expected user not to have order:refund")
+	}
+}
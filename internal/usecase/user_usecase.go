@@ -2,6 +2,10 @@ package usecase
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"time"
 
 	"github.com/milad-ahmd/go-clean-arch/internal/domain"
@@ -12,17 +16,21 @@ import (
 
 // userUseCase implements domain.UserUseCase
 type userUseCase struct {
-	userRepo   domain.UserRepository
-	jwtService domain.JWTService
-	logger     logger.Logger
+	userRepo         domain.UserRepository
+	refreshTokenRepo domain.RefreshTokenRepository
+	roleRepo         domain.RoleRepository
+	jwtService       domain.JWTService
+	logger           logger.Logger
 }
 
 // NewUserUseCase creates a new user use case
-func NewUserUseCase(userRepo domain.UserRepository, jwtService domain.JWTService, logger logger.Logger) domain.UserUseCase {
+func NewUserUseCase(userRepo domain.UserRepository, refreshTokenRepo domain.RefreshTokenRepository, roleRepo domain.RoleRepository, jwtService domain.JWTService, logger logger.Logger) domain.UserUseCase {
 	return &userUseCase{
-		userRepo:   userRepo,
-		jwtService: jwtService,
-		logger:     logger,
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		roleRepo:         roleRepo,
+		jwtService:       jwtService,
+		logger:           logger,
 	}
 }
 
@@ -67,7 +75,7 @@ func (u *userUseCase) Create(ctx context.Context, user *domain.User) error {
 	user.Password = string(hashedPassword)
 
 	// Set timestamps
-	now := time.Now()
+	now := time.Now().UTC()
 	user.CreatedAt = now
 	user.UpdatedAt = now
 
@@ -126,8 +134,11 @@ func (u *userUseCase) Update(ctx context.Context, user *domain.User) error {
 		user.Password = existingUser.Password
 	}
 
+	// Roles are only changed through role assignment
+	user.Role = existingUser.Role
+
 	// Update timestamp
-	user.UpdatedAt = time.Now()
+	user.UpdatedAt = time.Now().UTC()
 	user.CreatedAt = existingUser.CreatedAt
 
 	// Update the user
@@ -167,30 +178,30 @@ func (u *userUseCase) List(ctx context.Context, limit, offset int) ([]*domain.Us
 	return users, nil
 }
 
-// Login authenticates a user and returns a JWT token
-func (u *userUseCase) Login(ctx context.Context, email, password string) (string, error) {
+// Login authenticates a user and returns an access and refresh token pair
+func (u *userUseCase) Login(ctx context.Context, email, password string) (*domain.TokenResponse, error) {
 	// Get the user by email
 	user, err := u.userRepo.GetByEmail(ctx, email)
 	if err != nil {
 		u.logger.Error("Failed to get user by email", zap.String("email", email), zap.Error(err))
-		return "", domain.ErrUnauthorized
+		return nil, domain.ErrUnauthorized
 	}
 
 	// Compare passwords
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if err != nil {
 		u.logger.Error("Invalid password", zap.String("email", email), zap.Error(err))
-		return "", domain.ErrUnauthorized
+		return nil, domain.ErrUnauthorized
 	}
 
-	// Generate token
-	token, err := u.jwtService.GenerateToken(user.ID, user.Username, user.Role)
+	// Start a new refresh token family for this session
+	familyID, err := newTokenFamilyID()
 	if err != nil {
-		u.logger.Error("Failed to generate token", zap.String("email", email), zap.Error(err))
-		return "", domain.ErrInternalServer
+		u.logger.Error("Failed to generate token family", zap.String("email", email), zap.Error(err))
+		return nil, domain.ErrInternalServer
 	}
 
-	return token, nil
+	return u.issueTokens(ctx, user, familyID)
 }
 
 // Register registers a new user
@@ -207,7 +218,7 @@ func (u *userUseCase) Register(ctx context.Context, user *domain.User) error {
 // ValidateToken validates a JWT token and returns the user
 func (u *userUseCase) ValidateToken(ctx context.Context, token string) (*domain.User, error) {
 	// Validate the token
-	claims, err := u.jwtService.ValidateToken(token)
+	claims, err := u.jwtService.ValidateToken(ctx, token)
 	if err != nil {
 		u.logger.Error("Failed to validate token", zap.Error(err))
 		return nil, domain.ErrUnauthorized
@@ -220,5 +231,160 @@ func (u *userUseCase) ValidateToken(ctx context.Context, token string) (*domain.
 		return nil, domain.ErrUnauthorized
 	}
 
+	// Load the permissions of the user's current role; a role change takes
+	// effect on the next request rather than when the token is reissued
+	user.Permissions, err = u.roleRepo.GetPermissions(ctx, user.Role)
+	if err != nil {
+		u.logger.Error("Failed to get permissions for user", zap.Int64("id", user.ID), zap.Error(err))
+		return nil, domain.ErrInternalServer
+	}
+
 	return user, nil
 }
+
+// Refresh rotates a refresh token and returns a new token pair. Presenting a
+// token that was already rotated is treated as theft and revokes the family.
+func (u *userUseCase) Refresh(ctx context.Context, refreshToken string) (*domain.TokenResponse, error) {
+	stored, err := u.refreshTokenRepo.GetByHash(ctx, hashRefreshToken(refreshToken))
+	if err != nil {
+		u.logger.Error("Failed to find refresh token", zap.Error(err))
+		return nil, domain.ErrUnauthorized
+	}
+
+	if stored.IsRevoked() {
+		u.revokeFamilyOnReuse(ctx, stored)
+		return nil, domain.ErrUnauthorized
+	}
+
+	if stored.IsExpired(time.Now()) {
+		return nil, domain.ErrUnauthorized
+	}
+
+	// Rotate the token; losing the race means someone else already used it
+	rotated, err := u.refreshTokenRepo.Revoke(ctx, stored.ID)
+	if err != nil {
+		u.logger.Error("Failed to rotate refresh token", zap.Int64("id", stored.ID), zap.Error(err))
+		return nil, err
+	}
+	if !rotated {
+		u.revokeFamilyOnReuse(ctx, stored)
+		return nil, domain.ErrUnauthorized
+	}
+
+	user, err := u.userRepo.GetByID(ctx, stored.UserID)
+	if err != nil {
+		u.logger.Error("Failed to get user for refresh", zap.Int64("id", stored.UserID), zap.Error(err))
+		return nil, domain.ErrUnauthorized
+	}
+
+	return u.issueTokens(ctx, user, stored.FamilyID)
+}
+
+// Logout revokes the presented access token and its refresh token family,
+// or every session of the user when req.All is set
+func (u *userUseCase) Logout(ctx context.Context, accessToken string, req *domain.LogoutRequest) error {
+	claims, err := u.jwtService.ValidateToken(ctx, accessToken)
+	if err != nil {
+		u.logger.Error("Failed to validate token for logout", zap.Error(err))
+		return domain.ErrUnauthorized
+	}
+
+	if err := u.jwtService.RevokeToken(ctx, claims); err != nil {
+		return domain.ErrInternalServer
+	}
+
+	if req.All {
+		return u.RevokeSessions(ctx, claims.UserID)
+	}
+
+	if req.RefreshToken == "" {
+		return nil
+	}
+
+	stored, err := u.refreshTokenRepo.GetByHash(ctx, hashRefreshToken(req.RefreshToken))
+	if err != nil {
+		var notFoundErr *domain.NotFoundError
+		if errors.As(err, &notFoundErr) {
+			return nil
+		}
+		return err
+	}
+
+	// Never let a user revoke somebody else's session
+	if stored.UserID != claims.UserID {
+		return domain.ErrForbidden
+	}
+
+	if err := u.refreshTokenRepo.RevokeFamily(ctx, stored.FamilyID); err != nil {
+		u.logger.Error("Failed to revoke refresh token family", zap.String("familyID", stored.FamilyID), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// RevokeSessions revokes every refresh token of a user
+func (u *userUseCase) RevokeSessions(ctx context.Context, userID int64) error {
+	if err := u.refreshTokenRepo.RevokeAllForUser(ctx, userID); err != nil {
+		u.logger.Error("Failed to revoke user sessions", zap.Int64("userID", userID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// issueTokens generates an access token and a refresh token in the given family
+func (u *userUseCase) issueTokens(ctx context.Context, user *domain.User, familyID string) (*domain.TokenResponse, error) {
+	accessToken, claims, err := u.jwtService.GenerateToken(user.ID, user.Username, user.Role)
+	if err != nil {
+		u.logger.Error("Failed to generate token", zap.Int64("userID", user.ID), zap.Error(err))
+		return nil, domain.ErrInternalServer
+	}
+
+	refreshToken, expiresAt, err := u.jwtService.GenerateRefreshToken()
+	if err != nil {
+		u.logger.Error("Failed to generate refresh token", zap.Int64("userID", user.ID), zap.Error(err))
+		return nil, domain.ErrInternalServer
+	}
+
+	if err := u.refreshTokenRepo.Create(ctx, &domain.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hashRefreshToken(refreshToken),
+		FamilyID:  familyID,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		u.logger.Error("Failed to store refresh token", zap.Int64("userID", user.ID), zap.Error(err))
+		return nil, err
+	}
+
+	return &domain.TokenResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    claims.ExpiresAt.UTC(),
+	}, nil
+}
+
+// revokeFamilyOnReuse revokes the rotation chain of a reused refresh token
+func (u *userUseCase) revokeFamilyOnReuse(ctx context.Context, token *domain.RefreshToken) {
+	u.logger.Warn("Refresh token reuse detected",
+		zap.Int64("userID", token.UserID),
+		zap.String("familyID", token.FamilyID),
+	)
+	if err := u.refreshTokenRepo.RevokeFamily(ctx, token.FamilyID); err != nil {
+		u.logger.Error("Failed to revoke refresh token family", zap.String("familyID", token.FamilyID), zap.Error(err))
+	}
+}
+
+// hashRefreshToken returns the hex SHA-256 of a refresh token
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newTokenFamilyID returns a random identifier for a refresh token family
+func newTokenFamilyID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
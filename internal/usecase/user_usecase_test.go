@@ -3,6 +3,7 @@ package usecase
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -125,22 +126,209 @@ func (m *mockLogger) Error(_ string, _ ...zapcore.Field) {}
 func (m *mockLogger) Fatal(_ string, _ ...zapcore.Field) {}
 
 // mockJWTService is a mock implementation of domain.JWTService
-type mockJWTService struct{}
+type mockJWTService struct {
+	issued  int
+	revoked map[string]bool
+}
 
 // GenerateToken generates a mock token
-func (m *mockJWTService) GenerateToken(_ int64, _ string, _ domain.Role) (string, error) {
-	return "mock-token", nil
+func (m *mockJWTService) GenerateToken(userID int64, username string, role domain.Role) (string, *domain.JWTClaims, error) {
+	return "mock-token", &domain.JWTClaims{
+		ID:        "mock-jti",
+		UserID:    userID,
+		Username:  username,
+		Role:      role,
+		ExpiresAt: time.Now().Add(time.Minute),
+	}, nil
+}
+
+// GenerateRefreshToken generates a unique mock refresh token
+func (m *mockJWTService) GenerateRefreshToken() (string, time.Time, error) {
+	m.issued++
+	return fmt.Sprintf("refresh-%d", m.issued), time.Now().Add(time.Hour), nil
 }
 
 // ValidateToken validates a mock token
-func (m *mockJWTService) ValidateToken(_ string) (*domain.JWTClaims, error) {
+func (m *mockJWTService) ValidateToken(_ context.Context, _ string) (*domain.JWTClaims, error) {
+	if m.revoked["mock-jti"] {
+		return nil, errors.New("token has been revoked")
+	}
 	return &domain.JWTClaims{
+		ID:       "mock-jti",
 		UserID:   1,
 		Username: "testuser",
 		Role:     domain.RoleUser,
 	}, nil
 }
 
+// RevokeToken revokes a mock token
+func (m *mockJWTService) RevokeToken(_ context.Context, claims *domain.JWTClaims) error {
+	if m.revoked == nil {
+		m.revoked = make(map[string]bool)
+	}
+	m.revoked[claims.ID] = true
+	return nil
+}
+
+// mockRefreshTokenRepository is a mock implementation of domain.RefreshTokenRepository
+type mockRefreshTokenRepository struct {
+	tokens map[string]*domain.RefreshToken
+}
+
+// newMockRefreshTokenRepository creates a new mock refresh token repository
+func newMockRefreshTokenRepository() *mockRefreshTokenRepository {
+	return &mockRefreshTokenRepository{
+		tokens: make(map[string]*domain.RefreshToken),
+	}
+}
+
+// Create stores a refresh token
+func (m *mockRefreshTokenRepository) Create(_ context.Context, token *domain.RefreshToken) error {
+	token.ID = int64(len(m.tokens) + 1)
+	m.tokens[token.TokenHash] = token
+	return nil
+}
+
+// GetByHash gets a refresh token by hash
+func (m *mockRefreshTokenRepository) GetByHash(_ context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	token, ok := m.tokens[tokenHash]
+	if !ok {
+		return nil, &domain.NotFoundError{
+			Entity: "RefreshToken",
+			ID:     tokenHash,
+		}
+	}
+	copied := *token
+	return &copied, nil
+}
+
+// Revoke revokes a refresh token
+func (m *mockRefreshTokenRepository) Revoke(_ context.Context, id int64) (bool, error) {
+	for _, token := range m.tokens {
+		if token.ID == id {
+			if token.IsRevoked() {
+				return false, nil
+			}
+			now := time.Now()
+			token.RevokedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RevokeFamily revokes a refresh token family
+func (m *mockRefreshTokenRepository) RevokeFamily(_ context.Context, familyID string) error {
+	now := time.Now()
+	for _, token := range m.tokens {
+		if token.FamilyID == familyID && !token.IsRevoked() {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// RevokeAllForUser revokes every refresh token of a user
+func (m *mockRefreshTokenRepository) RevokeAllForUser(_ context.Context, userID int64) error {
+	now := time.Now()
+	for _, token := range m.tokens {
+		if token.UserID == userID && !token.IsRevoked() {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// mockRoleRepository is a mock implementation of domain.RoleRepository
+type mockRoleRepository struct {
+	roles map[domain.Role]*domain.RoleDefinition
+	users map[domain.Role]int
+}
+
+// newMockRoleRepository creates a new mock role repository with the built-in roles
+func newMockRoleRepository() *mockRoleRepository {
+	return &mockRoleRepository{
+		roles: map[domain.Role]*domain.RoleDefinition{
+			domain.RoleUser:  {Name: domain.RoleUser, Permissions: []domain.Permission{}},
+			domain.RoleAdmin: {Name: domain.RoleAdmin, Permissions: []domain.Permission{}},
+		},
+		users: make(map[domain.Role]int),
+	}
+}
+
+// FindAll gets all roles
+func (m *mockRoleRepository) FindAll(_ context.Context) ([]domain.RoleDefinition, error) {
+	roles := make([]domain.RoleDefinition, 0, len(m.roles))
+	for _, role := range m.roles {
+		roles = append(roles, *role)
+	}
+	return roles, nil
+}
+
+// FindByName gets a role by name
+func (m *mockRoleRepository) FindByName(_ context.Context, name domain.Role) (*domain.RoleDefinition, error) {
+	role, ok := m.roles[name]
+	if !ok {
+		return nil, &domain.NotFoundError{
+			Entity: "Role",
+			ID:     name,
+		}
+	}
+	copied := *role
+	return &copied, nil
+}
+
+// Create creates a role
+func (m *mockRoleRepository) Create(_ context.Context, role *domain.RoleDefinition) error {
+	if _, ok := m.roles[role.Name]; ok {
+		return &domain.ConflictError{
+			Entity: "Role",
+			Field:  "name",
+			Value:  role.Name,
+		}
+	}
+	m.roles[role.Name] = role
+	return nil
+}
+
+// Update updates a role
+func (m *mockRoleRepository) Update(_ context.Context, role *domain.RoleDefinition) error {
+	if _, ok := m.roles[role.Name]; !ok {
+		return &domain.NotFoundError{
+			Entity: "Role",
+			ID:     role.Name,
+		}
+	}
+	m.roles[role.Name] = role
+	return nil
+}
+
+// Delete deletes a role
+func (m *mockRoleRepository) Delete(_ context.Context, name domain.Role) error {
+	if _, ok := m.roles[name]; !ok {
+		return &domain.NotFoundError{
+			Entity: "Role",
+			ID:     name,
+		}
+	}
+	delete(m.roles, name)
+	return nil
+}
+
+// GetPermissions gets the permissions of a role
+func (m *mockRoleRepository) GetPermissions(_ context.Context, name domain.Role) ([]domain.Permission, error) {
+	role, ok := m.roles[name]
+	if !ok {
+		return []domain.Permission{}, nil
+	}
+	return role.Permissions, nil
+}
+
+// CountUsers counts the users holding a role
+func (m *mockRoleRepository) CountUsers(_ context.Context, name domain.Role) (int, error) {
+	return m.users[name], nil
+}
+
 // TestUserUseCase_GetByID tests the GetByID method
 func TestUserUseCase_GetByID(t *testing.T) {
 	// Create a mock repository
@@ -153,7 +341,7 @@ func TestUserUseCase_GetByID(t *testing.T) {
 	jwtService := &mockJWTService{}
 
 	// Create a user use case
-	useCase := NewUserUseCase(repo, jwtService, logger)
+	useCase := NewUserUseCase(repo, newMockRefreshTokenRepository(), newMockRoleRepository(), jwtService, logger)
 
 	// Create a test user
 	user := &domain.User{
@@ -224,7 +412,7 @@ func TestUserUseCase_Create(t *testing.T) {
 	jwtService := &mockJWTService{}
 
 	// Create a user use case
-	useCase := NewUserUseCase(repo, jwtService, logger)
+	useCase := NewUserUseCase(repo, newMockRefreshTokenRepository(), newMockRoleRepository(), jwtService, logger)
 
 	// Create a test user
 	user := &domain.User{
@@ -273,3 +461,80 @@ func TestUserUseCase_Create(t *testing.T) {
 		t.Errorf("Expected a ConflictError, got %T", err)
 	}
 }
+
+// TestUserUseCase_Refresh tests refresh token rotation and reuse detection
+func TestUserUseCase_Refresh(t *testing.T) {
+	repo := newMockUserRepository()
+	tokenRepo := newMockRefreshTokenRepository()
+	useCase := NewUserUseCase(repo, tokenRepo, newMockRoleRepository(), &mockJWTService{}, &mockLogger{})
+
+	ctx := context.Background()
+	user := &domain.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password",
+	}
+	if err := useCase.Register(ctx, user); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Login starts a new token family
+	tokens, err := useCase.Login(ctx, "test@example.com", "password")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if tokens.RefreshToken == "" {
+		t.Fatal("Expected a refresh token")
+	}
+
+	// Refreshing rotates the token
+	rotated, err := useCase.Refresh(ctx, tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if rotated.RefreshToken == tokens.RefreshToken {
+		t.Error("Expected a new refresh token after rotation")
+	}
+
+	// Reusing the rotated token is rejected and revokes the whole family
+	if _, err := useCase.Refresh(ctx, tokens.RefreshToken); err != domain.ErrUnauthorized {
+		t.Errorf("Expected ErrUnauthorized on reuse, got %v", err)
+	}
+	if _, err := useCase.Refresh(ctx, rotated.RefreshToken); err != domain.ErrUnauthorized {
+		t.Errorf("Expected ErrUnauthorized for revoked family, got %v", err)
+	}
+}
+
+// TestUserUseCase_Logout tests that logout revokes the access and refresh tokens
+func TestUserUseCase_Logout(t *testing.T) {
+	repo := newMockUserRepository()
+	tokenRepo := newMockRefreshTokenRepository()
+	jwtService := &mockJWTService{}
+	useCase := NewUserUseCase(repo, tokenRepo, newMockRoleRepository(), jwtService, &mockLogger{})
+
+	ctx := context.Background()
+	user := &domain.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password",
+	}
+	if err := useCase.Register(ctx, user); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tokens, err := useCase.Login(ctx, "test@example.com", "password")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := useCase.Logout(ctx, tokens.Token, &domain.LogoutRequest{RefreshToken: tokens.RefreshToken}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := useCase.ValidateToken(ctx, tokens.Token); err != domain.ErrUnauthorized {
+		t.Errorf("Expected revoked access token to be rejected, got %v", err)
+	}
+	if _, err := useCase.Refresh(ctx, tokens.RefreshToken); err != domain.ErrUnauthorized {
+		t.Errorf("Expected revoked refresh token to be rejected, got %v", err)
+	}
+}
@@ -1,6 +1,10 @@
 package auth
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"time"
 
@@ -12,51 +16,82 @@ import (
 
 // JWTService implements domain.JWTService
 type jwtService struct {
-	secretKey string
-	logger    logger.Logger
+	keys            *KeySet
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+	revokedRepo     domain.RevokedTokenRepository
+	logger          logger.Logger
 }
 
 // NewJWTService creates a new JWT service
-func NewJWTService(secretKey string, logger logger.Logger) domain.JWTService {
+func NewJWTService(keys *KeySet, accessTokenTTL, refreshTokenTTL time.Duration, revokedRepo domain.RevokedTokenRepository, logger logger.Logger) domain.JWTService {
 	return &jwtService{
-		secretKey: secretKey,
-		logger:    logger,
+		keys:            keys,
+		accessTokenTTL:  accessTokenTTL,
+		refreshTokenTTL: refreshTokenTTL,
+		revokedRepo:     revokedRepo,
+		logger:          logger,
 	}
 }
 
-// GenerateToken generates a new JWT token
-func (s *jwtService) GenerateToken(userID int64, username string, role domain.Role) (string, error) {
+// GenerateToken generates a new short-lived JWT access token
+func (s *jwtService) GenerateToken(userID int64, username string, role domain.Role) (string, *domain.JWTClaims, error) {
+	jti, err := randomHex(16)
+	if err != nil {
+		s.logger.Error("Failed to generate token ID", zap.Error(err))
+		return "", nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.accessTokenTTL)
+
 	// Create the claims
 	claims := jwt.MapClaims{
+		"jti":      jti,
 		"user_id":  userID,
 		"username": username,
 		"role":     role,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(), // Token expires in 24 hours
+		"iat":      now.Unix(),
+		"exp":      expiresAt.Unix(),
 	}
 
-	// Create the token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	// Create the token with the active key
+	key := s.keys.Active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
 
 	// Sign the token
-	tokenString, err := token.SignedString([]byte(s.secretKey))
+	tokenString, err := token.SignedString(key.Private)
 	if err != nil {
 		s.logger.Error("Failed to sign token", zap.Error(err))
-		return "", err
+		return "", nil, err
 	}
 
-	return tokenString, nil
+	return tokenString, &domain.JWTClaims{
+		ID:        jti,
+		UserID:    userID,
+		Username:  username,
+		Role:      role,
+		ExpiresAt: time.Unix(expiresAt.Unix(), 0),
+	}, nil
 }
 
-// ValidateToken validates a JWT token
-func (s *jwtService) ValidateToken(tokenString string) (*domain.JWTClaims, error) {
-	// Parse the token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Validate the signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.secretKey), nil
-	})
+// GenerateRefreshToken generates a new opaque refresh token and its expiry
+func (s *jwtService) GenerateRefreshToken() (string, time.Time, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		s.logger.Error("Failed to generate refresh token", zap.Error(err))
+		return "", time.Time{}, err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), time.Now().Add(s.refreshTokenTTL), nil
+}
+
+// ValidateToken validates a JWT token and rejects revoked token IDs
+func (s *jwtService) ValidateToken(ctx context.Context, tokenString string) (*domain.JWTClaims, error) {
+	// Parse the token; the key set validates the kid and signing method
+	token, err := jwt.Parse(tokenString, s.keys.Lookup)
 
 	if err != nil {
 		s.logger.Error("Failed to parse token", zap.Error(err))
@@ -75,6 +110,11 @@ func (s *jwtService) ValidateToken(tokenString string) (*domain.JWTClaims, error
 	}
 
 	// Extract the claims
+	jti, ok := claims["jti"].(string)
+	if !ok || jti == "" {
+		return nil, fmt.Errorf("invalid jti claim")
+	}
+
 	userID, ok := claims["user_id"].(float64)
 	if !ok {
 		return nil, fmt.Errorf("invalid user_id claim")
@@ -90,12 +130,47 @@ func (s *jwtService) ValidateToken(tokenString string) (*domain.JWTClaims, error
 		return nil, fmt.Errorf("invalid role claim")
 	}
 
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return nil, fmt.Errorf("invalid exp claim")
+	}
+
+	// Reject tokens that have been revoked server-side
+	revoked, err := s.revokedRepo.IsRevoked(ctx, jti)
+	if err != nil {
+		s.logger.Error("Failed to check token revocation", zap.String("jti", jti), zap.Error(err))
+		return nil, err
+	}
+	if revoked {
+		return nil, fmt.Errorf("token has been revoked")
+	}
+
 	// Create the JWT claims
 	jwtClaims := &domain.JWTClaims{
-		UserID:   int64(userID),
-		Username: username,
-		Role:     domain.Role(role),
+		ID:        jti,
+		UserID:    int64(userID),
+		Username:  username,
+		Role:      domain.Role(role),
+		ExpiresAt: exp.Time,
 	}
 
 	return jwtClaims, nil
 }
+
+// RevokeToken revokes an access token until it would have expired anyway
+func (s *jwtService) RevokeToken(ctx context.Context, claims *domain.JWTClaims) error {
+	if err := s.revokedRepo.Revoke(ctx, claims.ID, claims.ExpiresAt); err != nil {
+		s.logger.Error("Failed to revoke token", zap.String("jti", claims.ID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// randomHex returns n random bytes encoded as hex
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/milad-ahmd/go-clean-arch/pkg/config"
+)
+
+// SigningKey is a key that can sign and/or verify tokens
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	Private   interface{}
+	Public    interface{}
+	RetiredAt time.Time
+}
+
+// KeySet holds the active signing key and every key still accepted for
+// verification. Retired keys keep verifying tokens for a grace window so
+// tokens issued just before a rotation stay valid until they expire.
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+	grace  time.Duration
+	now    func() time.Time
+}
+
+// JWK represents a public JSON Web Key (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// JWKSet represents a JSON Web Key Set
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewHMACKeySet creates a key set that signs with a shared HS256 secret
+func NewHMACKeySet(secret string) *KeySet {
+	key := &SigningKey{
+		Method:  jwt.SigningMethodHS256,
+		Private: []byte(secret),
+		Public:  []byte(secret),
+	}
+	return &KeySet{
+		active: key,
+		keys:   map[string]*SigningKey{"": key},
+		now:    time.Now,
+	}
+}
+
+// NewKeySet creates a key set from asymmetric keys. activeKeyID selects the
+// signing key; every other key is accepted until RetiredAt plus grace, or
+// indefinitely when RetiredAt is zero (pre-published next keys).
+func NewKeySet(keys []*SigningKey, activeKeyID string, grace time.Duration) (*KeySet, error) {
+	set := &KeySet{
+		keys:  make(map[string]*SigningKey, len(keys)),
+		grace: grace,
+		now:   time.Now,
+	}
+
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, fmt.Errorf("signing key without key ID")
+		}
+		if _, exists := set.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate signing key ID %q", key.ID)
+		}
+		set.keys[key.ID] = key
+	}
+
+	active, ok := set.keys[activeKeyID]
+	if !ok {
+		return nil, fmt.Errorf("active signing key %q not found", activeKeyID)
+	}
+	if !active.RetiredAt.IsZero() {
+		return nil, fmt.Errorf("active signing key %q is marked as retired", activeKeyID)
+	}
+	set.active = active
+
+	return set, nil
+}
+
+// LoadKeySet builds the key set described by the auth configuration. Without
+// a signing keys directory the shared JWT secret is used.
+func LoadKeySet(cfg config.AuthConfig) (*KeySet, error) {
+	if cfg.SigningKeysDir == "" {
+		return NewHMACKeySet(cfg.JWTSecret), nil
+	}
+
+	retired, err := parseRetiredKeys(cfg.RetiredKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(cfg.SigningKeysDir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*SigningKey
+	for _, path := range paths {
+		key, err := loadSigningKey(path)
+		if err != nil {
+			return nil, err
+		}
+		key.RetiredAt = retired[key.ID]
+		keys = append(keys, key)
+	}
+
+	return NewKeySet(keys, cfg.ActiveKeyID, cfg.KeyGracePeriod)
+}
+
+// Active returns the key used to sign new tokens
+func (s *KeySet) Active() *SigningKey {
+	return s.active
+}
+
+// Lookup returns the verification key for a token header, rejecting unknown
+// keys, keys past their grace window and algorithm mismatches
+func (s *KeySet) Lookup(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	if !key.RetiredAt.IsZero() && s.now().After(key.RetiredAt.Add(s.grace)) {
+		return nil, fmt.Errorf("signing key %q has been retired", kid)
+	}
+
+	return key.Public, nil
+}
+
+// JWKS returns the public keys that verifiers should currently accept
+func (s *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+
+	ids := make([]string, 0, len(s.keys))
+	for id := range s.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		key := s.keys[id]
+		if !key.RetiredAt.IsZero() && s.now().After(key.RetiredAt.Add(s.grace)) {
+			continue
+		}
+
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "RSA",
+				KeyID:     key.ID,
+				Use:       "sig",
+				Algorithm: key.Method.Alg(),
+				N:         base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "OKP",
+				KeyID:     key.ID,
+				Use:       "sig",
+				Algorithm: key.Method.Alg(),
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+		// Shared HMAC secrets are never published
+	}
+
+	return set
+}
+
+// loadSigningKey reads a PEM private key; the file name without extension is the key ID
+func loadSigningKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+
+	var private interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block type %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	key := &SigningKey{
+		ID:      strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Private: private,
+	}
+
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		key.Method = jwt.SigningMethodRS256
+		key.Public = &k.PublicKey
+	case ed25519.PrivateKey:
+		key.Method = jwt.SigningMethodEdDSA
+		key.Public = k.Public().(ed25519.PublicKey)
+	default:
+		return nil, fmt.Errorf("%s: unsupported key type %T", path, private)
+	}
+
+	return key, nil
+}
+
+// parseRetiredKeys parses "kid=RFC3339,kid=RFC3339" into retirement times
+func parseRetiredKeys(value string) (map[string]time.Time, error) {
+	retired := make(map[string]time.Time)
+	if value == "" {
+		return retired, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid retired key entry %q, expected kid=RFC3339", entry)
+		}
+		retiredAt, err := time.Parse(time.RFC3339, parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid retirement time for key %q: %w", parts[0], err)
+		}
+		retired[parts[0]] = retiredAt
+	}
+
+	return retired, nil
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"go.uber.org/zap/zapcore"
+)
+
+// mockRevokedTokenRepository is a mock implementation of domain.RevokedTokenRepository
+type mockRevokedTokenRepository struct{}
+
+// Revoke does nothing
+func (m *mockRevokedTokenRepository) Revoke(_ context.Context, _ string, _ time.Time) error {
+	return nil
+}
+
+// IsRevoked reports that no token is revoked
+func (m *mockRevokedTokenRepository) IsRevoked(_ context.Context, _ string) (bool, error) {
+	return false, nil
+}
+
+// mockLogger is a mock implementation of logger.Logger
+type mockLogger struct{}
+
+// Debug logs a debug message
+func (m *mockLogger) Debug(_ string, _ ...zapcore.Field) {}
+
+// Info logs an info message
+func (m *mockLogger) Info(_ string, _ ...zapcore.Field) {}
+
+// Warn logs a warning message
+func (m *mockLogger) Warn(_ string, _ ...zapcore.Field) {}
+
+// Error logs an error message
+func (m *mockLogger) Error(_ string, _ ...zapcore.Field) {}
+
+// Fatal logs a fatal message
+func (m *mockLogger) Fatal(_ string, _ ...zapcore.Field) {}
+
+// newEd25519Key creates a signing key for tests
+func newEd25519Key(t *testing.T, id string) *SigningKey {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, Private: private, Public: public}
+}
+
+// TestKeySet_Rotation tests that retired keys verify tokens only during the grace window
+func TestKeySet_Rotation(t *testing.T) {
+	oldKey := newEd25519Key(t, "old")
+	newKey := newEd25519Key(t, "new")
+
+	// Issue a token with the old key while it is still active
+	oldSet, err := NewKeySet([]*SigningKey{oldKey}, "old", time.Hour)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	oldService := NewJWTService(oldSet, time.Hour, time.Hour, &mockRevokedTokenRepository{}, &mockLogger{})
+	token, _, err := oldService.GenerateToken(1, "testuser", domain.RoleUser)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Rotate: the new key signs, the old key is retired
+	oldKey.RetiredAt = time.Now()
+	rotatedSet, err := NewKeySet([]*SigningKey{oldKey, newKey}, "new", time.Hour)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	service := NewJWTService(rotatedSet, time.Hour, time.Hour, &mockRevokedTokenRepository{}, &mockLogger{})
+
+	if _, err := service.ValidateToken(context.Background(), token); err != nil {
+		t.Errorf("Expected token from retired key to verify during grace, got %v", err)
+	}
+	if got := len(rotatedSet.JWKS().Keys); got != 2 {
+		t.Errorf("Expected 2 published keys during grace, got %d", got)
+	}
+
+	// After the grace window the old key is rejected and unpublished
+	rotatedSet.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if _, err := service.ValidateToken(context.Background(), token); err == nil {
+		t.Error("Expected token from expired retired key to be rejected")
+	}
+	if got := len(rotatedSet.JWKS().Keys); got != 1 {
+		t.Errorf("Expected 1 published key after grace, got %d", got)
+	}
+}
+
+// TestKeySet_RejectsAlgorithmMismatch tests that an HS256 token cannot masquerade as an RSA key
+func TestKeySet_RejectsAlgorithmMismatch(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	set, err := NewKeySet([]*SigningKey{{ID: "rsa", Method: jwt.SigningMethodRS256, Private: private, Public: &private.PublicKey}}, "rsa", time.Hour)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"jti": "x", "user_id": 1})
+	forged.Header["kid"] = "rsa"
+	tokenString, err := forged.SignedString([]byte("guess"))
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	if _, err := jwt.Parse(tokenString, set.Lookup); err == nil {
+		t.Error("Expected algorithm mismatch to be rejected")
+	}
+}
@@ -12,10 +12,12 @@ import (
 
 // Config holds all configuration for our application
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Logger   LoggerConfig
-	Auth     AuthConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	Logger      LoggerConfig
+	Auth        AuthConfig
+	Payment     PaymentConfig
+	Idempotency IdempotencyConfig
 }
 
 // ServerConfig holds all server related configuration
@@ -28,12 +30,13 @@ type ServerConfig struct {
 
 // DatabaseConfig holds all database related configuration
 type DatabaseConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
-	SSLMode  string
+	Host        string
+	Port        string
+	User        string
+	Password    string
+	DBName      string
+	SSLMode     string
+	AutoMigrate bool
 }
 
 // LoggerConfig holds all logger related configuration
@@ -43,7 +46,24 @@ type LoggerConfig struct {
 
 // AuthConfig holds all authentication related configuration
 type AuthConfig struct {
-	JWTSecret string
+	JWTSecret       string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	SigningKeysDir  string
+	ActiveKeyID     string
+	RetiredKeys     string
+	KeyGracePeriod  time.Duration
+}
+
+// PaymentConfig holds all payment gateway related configuration
+type PaymentConfig struct {
+	Provider string
+	Timeout  time.Duration
+}
+
+// IdempotencyConfig holds all Idempotency-Key related configuration
+type IdempotencyConfig struct {
+	TTL time.Duration
 }
 
 // LoadConfig loads configuration from .env file and environment variables
@@ -58,18 +78,32 @@ func LoadConfig() *Config {
 			IdleTimeout:  getDurationEnv("SERVER_IDLE_TIMEOUT", 120*time.Second),
 		},
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "5432"),
-			User:     getEnv("DB_USER", "postgres"),
-			Password: getEnv("DB_PASSWORD", "postgres"),
-			DBName:   getEnv("DB_NAME", "clean_arch"),
-			SSLMode:  getEnv("DB_SSL_MODE", "disable"),
+			Host:        getEnv("DB_HOST", "localhost"),
+			Port:        getEnv("DB_PORT", "5432"),
+			User:        getEnv("DB_USER", "postgres"),
+			Password:    getEnv("DB_PASSWORD", "postgres"),
+			DBName:      getEnv("DB_NAME", "clean_arch"),
+			SSLMode:     getEnv("DB_SSL_MODE", "disable"),
+			AutoMigrate: getBoolEnv("DB_AUTO_MIGRATE", true),
 		},
 		Logger: LoggerConfig{
 			Level: getEnv("LOG_LEVEL", "info"),
 		},
 		Auth: AuthConfig{
-			JWTSecret: getEnv("JWT_SECRET", "your-secret-key"),
+			JWTSecret:       getEnv("JWT_SECRET", "your-secret-key"),
+			AccessTokenTTL:  getDurationEnv("JWT_ACCESS_TOKEN_TTL", 15*time.Minute),
+			RefreshTokenTTL: getDurationEnv("JWT_REFRESH_TOKEN_TTL", 7*24*time.Hour),
+			SigningKeysDir:  getEnv("JWT_SIGNING_KEYS_DIR", ""),
+			ActiveKeyID:     getEnv("JWT_ACTIVE_KEY_ID", ""),
+			RetiredKeys:     getEnv("JWT_RETIRED_KEYS", ""),
+			KeyGracePeriod:  getDurationEnv("JWT_KEY_GRACE_PERIOD", 24*time.Hour),
+		},
+		Payment: PaymentConfig{
+			Provider: getEnv("PAYMENT_PROVIDER", "fake"),
+			Timeout:  getDurationEnv("PAYMENT_TIMEOUT", 30*time.Second),
+		},
+		Idempotency: IdempotencyConfig{
+			TTL: getDurationEnv("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
 		},
 	}
 }
@@ -92,6 +126,16 @@ func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
+// Helper function to get a boolean environment variable with a default value
+func getBoolEnv(key string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
 // loadEnvFile loads environment variables from .env file
 func loadEnvFile() {
 	// Try to find .env file in current directory and parent directories
@@ -22,15 +22,46 @@ var (
 	// ErrConflict is returned when there is a conflict
 	ErrConflict = errors.New("conflict")
 
+	// ErrPreconditionFailed is returned when a request precondition, such as
+	// If-Match, does not hold
+	ErrPreconditionFailed = errors.New("precondition failed")
+
 	// ErrInternal is returned when there is an internal server error
 	ErrInternal = errors.New("internal server error")
 )
 
+// Stable, machine-readable error codes returned to API clients. Codes are
+// part of the API contract and must not change once published.
+const (
+	CodeBadRequest         = "bad_request"
+	CodeValidationFailed   = "validation_failed"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeConflict           = "conflict"
+	CodePreconditionFailed = "precondition_failed"
+	CodeInternal           = "internal_error"
+)
+
+// Coder is implemented by errors that carry their own stable error code,
+// such as domain errors for specific business rules
+type Coder interface {
+	ErrorCode() string
+}
+
+// Violator is implemented by errors that describe invalid request fields,
+// keyed by field name
+type Violator interface {
+	Violations() map[string]interface{}
+}
+
 // AppError represents an application error
 type AppError struct {
 	Err        error
 	Message    string
 	StatusCode int
+	Code       string
 }
 
 // Error returns the error message
@@ -46,6 +77,14 @@ func (e *AppError) Unwrap() error {
 	return e.Err
 }
 
+// ErrorCode returns the stable error code
+func (e *AppError) ErrorCode() string {
+	if e.Code != "" {
+		return e.Code
+	}
+	return codeForStatus(e.StatusCode)
+}
+
 // ValidationError represents a validation error
 type ValidationError struct {
 	Message string                 `json:"message"`
@@ -57,6 +96,21 @@ func (e *ValidationError) Error() string {
 	return e.Message
 }
 
+// Is checks if the error is of the given type
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidInput
+}
+
+// ErrorCode returns the stable error code
+func (e *ValidationError) ErrorCode() string {
+	return CodeValidationFailed
+}
+
+// Violations returns the invalid fields and their messages
+func (e *ValidationError) Violations() map[string]interface{} {
+	return e.Errors
+}
+
 // NewValidationError creates a new validation error
 func NewValidationError(message string, errors map[string]interface{}) *ValidationError {
 	return &ValidationError{
@@ -80,6 +134,7 @@ func NewNotFoundError(entity string, id interface{}) *AppError {
 		Err:        ErrNotFound,
 		Message:    fmt.Sprintf("%s with ID %v not found", entity, id),
 		StatusCode: http.StatusNotFound,
+		Code:       CodeNotFound,
 	}
 }
 
@@ -89,6 +144,7 @@ func NewConflictError(entity, field string, value interface{}) *AppError {
 		Err:        ErrConflict,
 		Message:    fmt.Sprintf("%s with %s %v already exists", entity, field, value),
 		StatusCode: http.StatusConflict,
+		Code:       CodeConflict,
 	}
 }
 
@@ -101,6 +157,7 @@ func NewUnauthorizedError(message string) *AppError {
 		Err:        ErrUnauthorized,
 		Message:    message,
 		StatusCode: http.StatusUnauthorized,
+		Code:       CodeUnauthorized,
 	}
 }
 
@@ -113,6 +170,17 @@ func NewForbiddenError(message string) *AppError {
 		Err:        ErrForbidden,
 		Message:    message,
 		StatusCode: http.StatusForbidden,
+		Code:       CodeForbidden,
+	}
+}
+
+// NewPreconditionFailedError creates a new precondition failed error
+func NewPreconditionFailedError(message string) *AppError {
+	return &AppError{
+		Err:        ErrPreconditionFailed,
+		Message:    message,
+		StatusCode: http.StatusPreconditionFailed,
+		Code:       CodePreconditionFailed,
 	}
 }
 
@@ -122,6 +190,7 @@ func NewInternalError(err error) *AppError {
 		Err:        err,
 		Message:    "internal server error",
 		StatusCode: http.StatusInternalServerError,
+		Code:       CodeInternal,
 	}
 }
 
@@ -131,21 +200,18 @@ func NewBadRequestError(message string) *AppError {
 		Err:        ErrInvalidInput,
 		Message:    message,
 		StatusCode: http.StatusBadRequest,
+		Code:       CodeBadRequest,
 	}
 }
 
-// GetStatusCode returns the HTTP status code for an error
+// GetStatusCode returns the HTTP status code for an error. Domain and
+// application errors share the sentinels above, so both map the same way.
 func GetStatusCode(err error) int {
 	var appErr *AppError
 	if errors.As(err, &appErr) {
 		return appErr.StatusCode
 	}
 
-	var validationErr *ValidationError
-	if errors.As(err, &validationErr) {
-		return http.StatusBadRequest
-	}
-
 	switch {
 	case errors.Is(err, ErrNotFound):
 		return http.StatusNotFound
@@ -157,7 +223,40 @@ func GetStatusCode(err error) int {
 		return http.StatusForbidden
 	case errors.Is(err, ErrConflict):
 		return http.StatusConflict
+	case errors.Is(err, ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
 	default:
 		return http.StatusInternalServerError
 	}
 }
+
+// GetCode returns the stable error code for an error
+func GetCode(err error) string {
+	var coder Coder
+	if errors.As(err, &coder) {
+		return coder.ErrorCode()
+	}
+	return codeForStatus(GetStatusCode(err))
+}
+
+// codeForStatus returns the generic error code for an HTTP status code
+func codeForStatus(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusPreconditionFailed:
+		return CodePreconditionFailed
+	default:
+		return CodeInternal
+	}
+}
@@ -2,11 +2,14 @@ package middleware
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
 	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"github.com/milad-ahmd/go-clean-arch/pkg/response"
 	"go.uber.org/zap"
 )
 
@@ -23,13 +26,13 @@ func Auth(userUseCase domain.UserUseCase, logger logger.Logger) Middleware {
 			// Get token from Authorization header
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				http.Error(w, "Authorization header is required", http.StatusUnauthorized)
+				response.Problem(w, r, errors.NewUnauthorizedError("Authorization header is required"))
 				return
 			}
 
 			// Check if the header has the Bearer prefix
 			if !strings.HasPrefix(authHeader, "Bearer ") {
-				http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
+				response.Problem(w, r, errors.NewUnauthorizedError("Invalid authorization header format"))
 				return
 			}
 
@@ -40,7 +43,7 @@ func Auth(userUseCase domain.UserUseCase, logger logger.Logger) Middleware {
 			user, err := userUseCase.ValidateToken(r.Context(), token)
 			if err != nil {
 				logger.Error("Failed to validate token", zap.Error(err))
-				http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+				response.Problem(w, r, errors.NewUnauthorizedError("Invalid or expired token"))
 				return
 			}
 
@@ -51,6 +54,22 @@ func Auth(userUseCase domain.UserUseCase, logger logger.Logger) Middleware {
 	}
 }
 
+// OptionalAuth middleware authenticates requests that carry an Authorization
+// header and lets anonymous requests through without a user in the context
+func OptionalAuth(userUseCase domain.UserUseCase, logger logger.Logger) Middleware {
+	auth := Auth(userUseCase, logger)
+	return func(next http.Handler) http.Handler {
+		authenticated := auth(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			authenticated.ServeHTTP(w, r)
+		})
+	}
+}
+
 // RequireRole middleware for role-based authorization
 func RequireRole(roles ...domain.Role) Middleware {
 	return func(next http.Handler) http.Handler {
@@ -58,7 +77,7 @@ func RequireRole(roles ...domain.Role) Middleware {
 			// Get user from context
 			user, ok := r.Context().Value(UserKey).(*domain.User)
 			if !ok {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				response.Problem(w, r, errors.NewUnauthorizedError("Authentication is required"))
 				return
 			}
 
@@ -72,7 +91,7 @@ func RequireRole(roles ...domain.Role) Middleware {
 			}
 
 			if !hasRole {
-				http.Error(w, "Forbidden", http.StatusForbidden)
+				response.Problem(w, r, errors.NewForbiddenError("Insufficient role"))
 				return
 			}
 
@@ -81,6 +100,40 @@ func RequireRole(roles ...domain.Role) Middleware {
 	}
 }
 
+// RequirePermission middleware for permission-based authorization; the user
+// must hold every listed permission
+func RequirePermission(permissions ...domain.Permission) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Get user from context
+			user, ok := r.Context().Value(UserKey).(*domain.User)
+			if !ok {
+				response.Problem(w, r, errors.NewUnauthorizedError("Authentication is required"))
+				return
+			}
+
+			// Check if user has every required permission
+			for _, permission := range permissions {
+				if !user.HasPermission(permission) {
+					response.Problem(w, r, errors.NewForbiddenError(fmt.Sprintf("Missing permission: %s", permission)))
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// bearerToken returns the token of a request's Bearer Authorization header
+func bearerToken(r *http.Request) (string, bool) {
+	authHeader := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return "", false
+	}
+	return strings.TrimPrefix(authHeader, "Bearer "), true
+}
+
 // GetUserFromContext gets the user from the context
 func GetUserFromContext(ctx context.Context) (*domain.User, bool) {
 	user, ok := ctx.Value(UserKey).(*domain.User)
@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"github.com/milad-ahmd/go-clean-arch/pkg/response"
+	"go.uber.org/zap"
+)
+
+// maxIdempotencyKeyLength bounds client-supplied idempotency keys
+const maxIdempotencyKeyLength = 255
+
+// replayedHeaders are the response headers stored with an idempotent
+// response and replayed with it
+var replayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// Idempotency makes retries of mutating requests safe. The first request
+// an authenticated user sends with an Idempotency-Key header is handled
+// normally and its response stored for ttl; repeats of the same request
+// with the same key get the stored response replayed, marked with the
+// Idempotent-Replayed header. Reusing a key for a different request, or
+// while the first is still in progress, is a 409 Conflict. Server errors
+// are not stored, so the request can be retried with the same key.
+//
+// The middleware runs before the routes' own authentication, so it
+// resolves the user from the bearer token itself; requests without a valid
+// token pass through and are left to the route.
+func Idempotency(store domain.IdempotencyRepository, userUseCase domain.UserUseCase, ttl time.Duration, logger logger.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(domain.IdempotencyKeyHeader)
+			if key == "" || !mutatingMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > maxIdempotencyKeyLength {
+				response.Problem(w, r, errors.NewBadRequestError("Idempotency-Key must be at most 255 characters"))
+				return
+			}
+
+			user, ok := idempotencyUser(r, userUseCase)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				response.Problem(w, r, errors.NewBadRequestError("Invalid request payload"))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			now := time.Now().UTC()
+			record := &domain.IdempotencyRecord{
+				UserID:      user.ID,
+				Key:         key,
+				Fingerprint: requestFingerprint(r, body),
+				CreatedAt:   now,
+				ExpiresAt:   now.Add(ttl),
+			}
+
+			existing, err := store.Reserve(r.Context(), record)
+			if err != nil {
+				response.Problem(w, r, err)
+				return
+			}
+
+			if existing != nil {
+				replay(w, r, existing, record.Fingerprint)
+				return
+			}
+
+			// Free the key unless a response was stored, including when the
+			// handler panics
+			completed := false
+			defer func() {
+				if !completed {
+					if err := store.Release(context.Background(), user.ID, key); err != nil {
+						logger.Warn("Failed to release idempotency key", zap.Int64("userID", user.ID), zap.Error(err))
+					}
+				}
+			}()
+
+			recorder := &recordingWriter{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+
+			if recorder.statusCode == 0 {
+				recorder.statusCode = http.StatusOK
+			}
+			if recorder.statusCode >= http.StatusInternalServerError {
+				return
+			}
+
+			record.StatusCode = recorder.statusCode
+			record.Headers = make(map[string]string)
+			for _, name := range replayedHeaders {
+				if value := recorder.Header().Get(name); value != "" {
+					record.Headers[name] = value
+				}
+			}
+			record.Body = recorder.body.Bytes()
+			if err := store.Complete(context.Background(), record); err != nil {
+				logger.Error("Failed to store idempotent response", zap.Int64("userID", user.ID), zap.Error(err))
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+// replay writes the stored response of a repeated key, or a conflict when
+// the key was used for another request or its response is not stored yet
+func replay(w http.ResponseWriter, r *http.Request, record *domain.IdempotencyRecord, fingerprint string) {
+	if record.Fingerprint != fingerprint {
+		response.Problem(w, r, &errors.AppError{
+			Err:        errors.ErrConflict,
+			Message:    "Idempotency-Key has already been used for a different request",
+			StatusCode: http.StatusConflict,
+			Code:       "idempotency_key_reused",
+		})
+		return
+	}
+
+	if !record.IsComplete() {
+		response.Problem(w, r, &errors.AppError{
+			Err:        errors.ErrConflict,
+			Message:    "A request with this Idempotency-Key is still in progress",
+			StatusCode: http.StatusConflict,
+			Code:       "idempotency_request_in_progress",
+		})
+		return
+	}
+
+	for name, value := range record.Headers {
+		w.Header().Set(name, value)
+	}
+	w.Header().Set(domain.IdempotentReplayedHeader, "true")
+	w.WriteHeader(record.StatusCode)
+	_, _ = w.Write(record.Body)
+}
+
+// idempotencyUser returns the user of a request with a valid bearer token
+func idempotencyUser(r *http.Request, userUseCase domain.UserUseCase) (*domain.User, bool) {
+	token, ok := bearerToken(r)
+	if !ok {
+		return nil, false
+	}
+	user, err := userUseCase.ValidateToken(r.Context(), token)
+	if err != nil {
+		return nil, false
+	}
+	return user, true
+}
+
+// mutatingMethod reports whether requests with the method change state
+func mutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// requestFingerprint identifies a request by its method, path, query and body
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// recordingWriter passes a response through while keeping a copy of it
+type recordingWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+// WriteHeader captures the status code
+func (rw *recordingWriter) WriteHeader(code int) {
+	if rw.statusCode == 0 {
+		rw.statusCode = code
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// Write captures the body
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if rw.statusCode == 0 {
+		rw.statusCode = http.StatusOK
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
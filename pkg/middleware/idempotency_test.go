@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
+	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+)
+
+// memoryIdempotencyStore is an in-memory idempotency key store
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]domain.IdempotencyRecord
+}
+
+// Reserve claims a key unless it is in use and has not expired
+func (s *memoryIdempotencyStore) Reserve(_ context.Context, record *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := fmt.Sprintf("%d/%s", record.UserID, record.Key)
+	if existing, ok := s.records[id]; ok && existing.ExpiresAt.After(time.Now()) {
+		return &existing, nil
+	}
+	s.records[id] = *record
+	return nil, nil
+}
+
+// Complete stores the response of a key
+func (s *memoryIdempotencyStore) Complete(_ context.Context, record *domain.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[fmt.Sprintf("%d/%s", record.UserID, record.Key)] = *record
+	return nil
+}
+
+// Release frees a key without a response
+func (s *memoryIdempotencyStore) Release(_ context.Context, userID int64, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := fmt.Sprintf("%d/%s", userID, key)
+	if record := s.records[id]; !record.IsComplete() {
+		delete(s.records, id)
+	}
+	return nil
+}
+
+// tokenUserUseCase accepts tokens of the form "user-<id>"; only ValidateToken is used
+type tokenUserUseCase struct {
+	domain.UserUseCase
+}
+
+// ValidateToken returns the user named by the token
+func (tokenUserUseCase) ValidateToken(_ context.Context, token string) (*domain.User, error) {
+	var id int64
+	if _, err := fmt.Sscanf(token, "user-%d", &id); err != nil {
+		return nil, errors.NewUnauthorizedError("")
+	}
+	return &domain.User{ID: id}, nil
+}
+
+// newIdempotentHandler returns a handler that creates a numbered resource
+// on every call it gets, or fails with status when status is set
+func newIdempotentHandler(ttl time.Duration, status *int) (http.Handler, *int) {
+	calls := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if *status != 0 {
+			w.WriteHeader(*status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", fmt.Sprintf("/orders/%d", calls))
+		w.Header().Set("ETag", `"1"`)
+		w.Header().Set("X-Request-ID", fmt.Sprintf("request-%d", calls))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id":%d}`, calls)
+	})
+	store := &memoryIdempotencyStore{records: make(map[string]domain.IdempotencyRecord)}
+	return Idempotency(store, tokenUserUseCase{}, ttl, logger.NewLogger("error"))(handler), &calls
+}
+
+// sendIdempotent sends a POST with an idempotency key
+func sendIdempotent(handler http.Handler, token, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(domain.IdempotencyKeyHeader, key)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestIdempotency tests that repeated keys replay the first response
+func TestIdempotency(t *testing.T) {
+	status := 0
+	handler, calls := newIdempotentHandler(time.Hour, &status)
+
+	first := sendIdempotent(handler, "user-1", "key-1", `{"items":[1]}`)
+	if first.Code != http.StatusCreated || first.Body.String() != `{"id":1}` {
+		t.Fatalf("Expected 201 {\"id\":1}, got %d %s", first.Code, first.Body.String())
+	}
+
+	replayed := sendIdempotent(handler, "user-1", "key-1", `{"items":[1]}`)
+	if replayed.Code != http.StatusCreated || replayed.Body.String() != `{"id":1}` {
+		t.Errorf("Expected the first response replayed, got %d %s", replayed.Code, replayed.Body.String())
+	}
+	if replayed.Header().Get(domain.IdempotentReplayedHeader) != "true" || replayed.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected replay headers, got %v", replayed.Header())
+	}
+	if replayed.Header().Get("Location") != "/orders/1" || replayed.Header().Get("ETag") != `"1"` {
+		t.Errorf("Expected the stored Location and ETag, got %v", replayed.Header())
+	}
+	if replayed.Header().Get("X-Request-ID") != "" {
+		t.Errorf("Expected headers outside the whitelist not to be replayed, got %v", replayed.Header())
+	}
+	if *calls != 1 {
+		t.Errorf("Expected the handler to run once, got %d", *calls)
+	}
+
+	// The same key with another body is a conflict
+	if rec := sendIdempotent(handler, "user-1", "key-1", `{"items":[2]}`); rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "idempotency_key_reused") {
+		t.Errorf("Expected 409 idempotency_key_reused, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Keys are scoped to the user
+	if rec := sendIdempotent(handler, "user-2", "key-1", `{"items":[1]}`); rec.Body.String() != `{"id":2}` {
+		t.Errorf("Expected a new resource for another user, got %s", rec.Body.String())
+	}
+
+	// Requests without a valid token are not deduplicated
+	sendIdempotent(handler, "invalid", "key-1", `{"items":[1]}`)
+	sendIdempotent(handler, "invalid", "key-1", `{"items":[1]}`)
+	if *calls != 4 {
+		t.Errorf("Expected 4 handler calls, got %d", *calls)
+	}
+}
+
+// TestIdempotency_ServerError tests that server errors are not stored
+func TestIdempotency_ServerError(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	handler, calls := newIdempotentHandler(time.Hour, &status)
+
+	if rec := sendIdempotent(handler, "user-1", "key-1", `{}`); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503, got %d", rec.Code)
+	}
+
+	status = 0
+	if rec := sendIdempotent(handler, "user-1", "key-1", `{}`); rec.Code != http.StatusCreated {
+		t.Errorf("Expected the retry to run, got %d", rec.Code)
+	}
+	if *calls != 2 {
+		t.Errorf("Expected 2 handler calls, got %d", *calls)
+	}
+}
+
+// TestIdempotency_Expiry tests that expired keys can be reused
+func TestIdempotency_Expiry(t *testing.T) {
+	status := 0
+	handler, calls := newIdempotentHandler(-time.Second, &status)
+
+	sendIdempotent(handler, "user-1", "key-1", `{}`)
+	sendIdempotent(handler, "user-1", "key-1", `{"changed":true}`)
+	if *calls != 2 {
+		t.Errorf("Expected 2 handler calls, got %d", *calls)
+	}
+}
@@ -1,13 +1,22 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
 	"github.com/milad-ahmd/go-clean-arch/pkg/logger"
+	"github.com/milad-ahmd/go-clean-arch/pkg/response"
 	"go.uber.org/zap"
 )
 
+// maxRequestIDLength bounds client-supplied request IDs
+const maxRequestIDLength = 128
+
 // Middleware represents the signature of a middleware function
 type Middleware func(http.Handler) http.Handler
 
@@ -19,6 +28,46 @@ func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
 	return h
 }
 
+// RequestID assigns every request an ID, reusing a well-formed X-Request-ID
+// header sent by the client. The ID is echoed in the response header and
+// set on the request so that later handlers and error responses can use it.
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(response.RequestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+
+			r.Header.Set(response.RequestIDHeader, id)
+			w.Header().Set(response.RequestIDHeader, id)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// validRequestID reports whether a client-supplied request ID is safe to reuse
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
 // Logger logs request/response details
 func Logger(logger logger.Logger) Middleware {
 	return func(next http.Handler) http.Handler {
@@ -37,6 +86,7 @@ func Logger(logger logger.Logger) Middleware {
 			// Log the request details
 			duration := time.Since(start)
 			logger.Info("HTTP Request",
+				zap.String("request_id", r.Header.Get(response.RequestIDHeader)),
 				zap.String("method", r.Method),
 				zap.String("path", r.URL.Path),
 				zap.Int("status", rw.statusCode),
@@ -54,7 +104,8 @@ func CORS() Middleware {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+response.RequestIDHeader+", "+domain.CartTokenHeader+", "+domain.IdempotencyKeyHeader+", If-Match")
+			w.Header().Set("Access-Control-Expose-Headers", response.RequestIDHeader+", "+domain.CartTokenHeader+", "+domain.IdempotentReplayedHeader+", ETag")
 
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(http.StatusOK)
@@ -75,12 +126,9 @@ func Recover(logger logger.Logger) Middleware {
 					logger.Error("Recovered from panic",
 						zap.Any("error", err),
 						zap.String("path", r.URL.Path),
+						zap.String("request_id", r.Header.Get(response.RequestIDHeader)),
 					)
-					w.WriteHeader(http.StatusInternalServerError)
-					_, writeErr := w.Write([]byte("Internal Server Error"))
-					if writeErr != nil {
-						logger.Error("Failed to write error response", zap.Error(writeErr))
-					}
+					response.Problem(w, r, errors.NewInternalError(fmt.Errorf("panic: %v", err)))
 				}
 			}()
 
@@ -0,0 +1,84 @@
+package response
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
+)
+
+// ProblemContentType is the media type of RFC 7807 error responses
+const ProblemContentType = "application/problem+json"
+
+// RequestIDHeader is the header carrying the request ID
+const RequestIDHeader = "X-Request-ID"
+
+// problemTypePrefix is prepended to an error code to form the problem type URI
+const problemTypePrefix = "/problems/"
+
+// ProblemDetails is an RFC 7807 error response. Code is a stable,
+// machine-readable error code; Errors maps invalid fields to messages.
+type ProblemDetails struct {
+	Type      string                 `json:"type"`
+	Title     string                 `json:"title"`
+	Status    int                    `json:"status"`
+	Detail    string                 `json:"detail,omitempty"`
+	Instance  string                 `json:"instance,omitempty"`
+	Code      string                 `json:"code"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Errors    map[string]interface{} `json:"errors,omitempty"`
+}
+
+// NewProblem creates the problem details for an error. Details of internal
+// errors are not exposed to clients.
+func NewProblem(r *http.Request, err error) *ProblemDetails {
+	status := errors.GetStatusCode(err)
+	code := errors.GetCode(err)
+
+	// Prefer the application message over any wrapping context
+	detail := err.Error()
+	var appErr *errors.AppError
+	if stderrors.As(err, &appErr) {
+		detail = appErr.Error()
+	}
+	if status >= http.StatusInternalServerError {
+		detail = "An unexpected error occurred"
+	}
+
+	problem := &ProblemDetails{
+		Type:      problemTypePrefix + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Code:      code,
+		RequestID: r.Header.Get(RequestIDHeader),
+	}
+	if r.URL != nil {
+		problem.Instance = r.URL.Path
+	}
+
+	var violator errors.Violator
+	if stderrors.As(err, &violator) {
+		problem.Errors = violator.Violations()
+	}
+
+	return problem
+}
+
+// Problem sends an RFC 7807 error response for err
+func Problem(w http.ResponseWriter, r *http.Request, err error) {
+	problem := NewProblem(r, err)
+	if id := w.Header().Get(RequestIDHeader); id != "" {
+		problem.RequestID = id
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(problem.Status)
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		// Log the error but can't really recover at this point
+		// as headers have already been sent
+		fmt.Println("Error encoding problem response:", err)
+	}
+}
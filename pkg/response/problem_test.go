@@ -0,0 +1,153 @@
+package response
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
+)
+
+// TestProblem tests that domain and application errors share one response format
+func TestProblem(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{
+			name:   "domain not found",
+			err:    &domain.NotFoundError{Entity: "user", ID: 7},
+			status: http.StatusNotFound,
+			code:   errors.CodeNotFound,
+			detail: "user with ID 7 not found",
+		},
+		{
+			name:   "domain sentinel",
+			err:    fmt.Errorf("failed to get role: %w", domain.ErrNotFound),
+			status: http.StatusNotFound,
+			code:   errors.CodeNotFound,
+		},
+		{
+			name:   "app not found",
+			err:    errors.NewNotFoundError("Product", 3),
+			status: http.StatusNotFound,
+			code:   errors.CodeNotFound,
+			detail: "Product with ID 3 not found",
+		},
+		{
+			name:   "wrapped app error keeps its message",
+			err:    fmt.Errorf("repository: %w", errors.NewBadRequestError("Insufficient stock")),
+			status: http.StatusBadRequest,
+			code:   errors.CodeBadRequest,
+			detail: "Insufficient stock",
+		},
+		{
+			name:   "domain conflict",
+			err:    &domain.ConflictError{Entity: "user", Field: "email", Value: "a@b.c"},
+			status: http.StatusConflict,
+			code:   errors.CodeConflict,
+		},
+		{
+			name:   "status transition",
+			err:    &domain.StatusTransitionError{From: domain.OrderStatusCompleted, To: domain.OrderStatusPending},
+			status: http.StatusConflict,
+			code:   "invalid_status_transition",
+		},
+		{
+			name:   "version conflict",
+			err:    &domain.VersionConflictError{Entity: "Product", ID: 3, Version: 2},
+			status: http.StatusConflict,
+			code:   "version_conflict",
+			detail: "Product 3 has changed since version 2",
+		},
+		{
+			name:   "precondition failed",
+			err:    errors.NewPreconditionFailedError("Product 3 has changed since version 2"),
+			status: http.StatusPreconditionFailed,
+			code:   errors.CodePreconditionFailed,
+		},
+		{
+			name:   "internal details are hidden",
+			err:    stderrors.New("pq: connection refused"),
+			status: http.StatusInternalServerError,
+			code:   errors.CodeInternal,
+			detail: "An unexpected error occurred",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/things/7", nil)
+			w := httptest.NewRecorder()
+			w.Header().Set(RequestIDHeader, "req-1")
+
+			Problem(w, r, tt.err)
+
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != ProblemContentType {
+				t.Errorf("Expected content type %s, got %s", ProblemContentType, ct)
+			}
+
+			var problem ProblemDetails
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Fatalf("Expected valid JSON, got %v", err)
+			}
+			if problem.Status != tt.status || problem.Code != tt.code {
+				t.Errorf("Expected %d %s, got %d %s", tt.status, tt.code, problem.Status, problem.Code)
+			}
+			if problem.Type != "/problems/"+tt.code {
+				t.Errorf("Expected type /problems/%s, got %s", tt.code, problem.Type)
+			}
+			if problem.Instance != "/things/7" {
+				t.Errorf("Expected instance /things/7, got %s", problem.Instance)
+			}
+			if problem.RequestID != "req-1" {
+				t.Errorf("Expected request ID req-1, got %s", problem.RequestID)
+			}
+			if tt.detail != "" && problem.Detail != tt.detail {
+				t.Errorf("Expected detail %q, got %q", tt.detail, problem.Detail)
+			}
+		})
+	}
+}
+
+// TestProblem_Violations tests that field violations are listed
+func TestProblem_Violations(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		field string
+	}{
+		{
+			name:  "app validation error",
+			err:   errors.NewValidationError("Validation failed", map[string]interface{}{"items[0].quantity": "must be at least 1"}),
+			field: "items[0].quantity",
+		},
+		{
+			name:  "domain validation error",
+			err:   &domain.ValidationError{Field: "permissions", Message: "contains an unknown permission"},
+			field: "permissions",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problem := NewProblem(httptest.NewRequest(http.MethodPost, "/", nil), tt.err)
+			if problem.Status != http.StatusBadRequest || problem.Code != errors.CodeValidationFailed {
+				t.Errorf("Expected 400 %s, got %d %s", errors.CodeValidationFailed, problem.Status, problem.Code)
+			}
+			if _, ok := problem.Errors[tt.field]; !ok {
+				t.Errorf("Expected violation for %s, got %v", tt.field, problem.Errors)
+			}
+		})
+	}
+}
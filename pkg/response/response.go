@@ -5,8 +5,6 @@ import (
 	"fmt"
 	"net/http"
 	"time"
-
-	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
 )
 
 // Response is the standard API response structure
@@ -14,25 +12,30 @@ type Response struct {
 	Success    bool        `json:"success"`
 	Message    string      `json:"message,omitempty"`
 	Data       interface{} `json:"data,omitempty"`
-	Errors     interface{} `json:"errors,omitempty"`
 	StatusCode int         `json:"status_code"`
 	Timestamp  time.Time   `json:"timestamp"`
 }
 
-// Meta contains pagination metadata
+// Meta contains pagination metadata. Page and TotalPage are set in offset
+// mode only, Total is omitted when the client skipped counting, and Next
+// and Prev link to the neighbouring pages when they exist.
 type Meta struct {
-	Page      int `json:"page"`
-	PerPage   int `json:"per_page"`
-	TotalPage int `json:"total_page"`
-	Total     int `json:"total"`
+	Page      int    `json:"page,omitempty"`
+	PerPage   int    `json:"per_page"`
+	TotalPage *int   `json:"total_page,omitempty"`
+	Total     *int   `json:"total,omitempty"`
+	Next      string `json:"next,omitempty"`
+	Prev      string `json:"prev,omitempty"`
 }
 
-// PaginatedResponse is a response with pagination
+// PaginatedResponse is a response with pagination. Facets optionally break
+// down the whole list, not just the page in Data.
 type PaginatedResponse struct {
 	Success    bool        `json:"success"`
 	Message    string      `json:"message,omitempty"`
 	Data       interface{} `json:"data,omitempty"`
 	Meta       Meta        `json:"meta"`
+	Facets     interface{} `json:"facets,omitempty"`
 	StatusCode int         `json:"status_code"`
 	Timestamp  time.Time   `json:"timestamp"`
 }
@@ -48,46 +51,33 @@ func NewResponse(success bool, message string, data interface{}, statusCode int)
 	}
 }
 
-// NewErrorResponse creates a new error response
-func NewErrorResponse(message string, err error, statusCode int) *Response {
-	var errorData interface{}
-
-	// Handle different error types
-	switch e := err.(type) {
-	case *errors.ValidationError:
-		errorData = e.Errors
-	case *errors.AppError:
-		errorData = e.Error()
-	default:
-		errorData = err.Error()
+// NewMeta creates pagination metadata. page is 0 for cursor pagination and
+// total is nil when the rows were not counted.
+func NewMeta(page, perPage int, total *int, next, prev string) Meta {
+	meta := Meta{
+		Page:    page,
+		PerPage: perPage,
+		Total:   total,
+		Next:    next,
+		Prev:    prev,
 	}
-
-	return &Response{
-		Success:    false,
-		Message:    message,
-		Errors:     errorData,
-		StatusCode: statusCode,
-		Timestamp:  time.Now(),
+	if total != nil && page > 0 {
+		totalPage := *total / perPage
+		if *total%perPage > 0 {
+			totalPage++
+		}
+		meta.TotalPage = &totalPage
 	}
+	return meta
 }
 
 // NewPaginatedResponse creates a new paginated response
-func NewPaginatedResponse(message string, data interface{}, page, perPage, total int, statusCode int) *PaginatedResponse {
-	totalPage := total / perPage
-	if total%perPage > 0 {
-		totalPage++
-	}
-
+func NewPaginatedResponse(message string, data interface{}, meta Meta, statusCode int) *PaginatedResponse {
 	return &PaginatedResponse{
-		Success: true,
-		Message: message,
-		Data:    data,
-		Meta: Meta{
-			Page:      page,
-			PerPage:   perPage,
-			TotalPage: totalPage,
-			Total:     total,
-		},
+		Success:    true,
+		Message:    message,
+		Data:       data,
+		Meta:       meta,
 		StatusCode: statusCode,
 		Timestamp:  time.Now(),
 	}
@@ -111,14 +101,8 @@ func Success(w http.ResponseWriter, message string, data interface{}, statusCode
 	JSON(w, statusCode, resp)
 }
 
-// Error sends an error response
-func Error(w http.ResponseWriter, message string, err error, statusCode int) {
-	resp := NewErrorResponse(message, err, statusCode)
-	JSON(w, statusCode, resp)
-}
-
 // Paginated sends a paginated response
-func Paginated(w http.ResponseWriter, message string, data interface{}, page, perPage, total int, statusCode int) {
-	resp := NewPaginatedResponse(message, data, page, perPage, total, statusCode)
+func Paginated(w http.ResponseWriter, message string, data interface{}, meta Meta, statusCode int) {
+	resp := NewPaginatedResponse(message, data, meta, statusCode)
 	JSON(w, statusCode, resp)
 }
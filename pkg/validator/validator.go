@@ -0,0 +1,287 @@
+// Package validator evaluates `validate` struct tags.
+//
+// Rules are separated by commas and applied left to right:
+//
+//	required      the value must not be the zero value (nil for pointers, slices and maps)
+//	omitempty     skip the remaining rules when the value is the zero value
+//	min=n, max=n  length for strings (in characters), slices and maps; value for numbers
+//	len=n         exact length or value
+//	gt, gte, lt, lte=n  comparisons with the same length/value semantics as min and max
+//	oneof=a b c   the value must equal one of the space separated options
+//	email         a bare email address such as "jane@example.com"
+//	url           an absolute URL with a scheme and host
+//	alphanum      ASCII letters and digits only
+//	dive          apply the remaining rules to every element of a slice, array or map
+//
+// Nested structs and pointers to structs are validated recursively. Fields
+// are reported by their JSON names, e.g. "items[0].quantity".
+package validator
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
+)
+
+// tagName is the struct tag holding the validation rules
+const tagName = "validate"
+
+// Validate checks v, a struct or pointer to a struct, against its validate
+// tags. It returns a *errors.ValidationError mapping each invalid field to a
+// message, or nil if every rule passes. It panics on unknown rules, which
+// are programming errors.
+func Validate(v interface{}) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return errors.NewValidationError("Validation failed", map[string]interface{}{"body": "is required"})
+		}
+		value = value.Elem()
+	}
+
+	violations := make(map[string]interface{})
+	validateStruct(value, "", violations)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return errors.NewValidationError("Validation failed", violations)
+}
+
+// validateStruct validates every exported field of a struct value
+func validateStruct(value reflect.Value, prefix string, violations map[string]interface{}) {
+	structType := value.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, ok := fieldName(field)
+		if !ok {
+			// Fields hidden from JSON are still validated under their Go name
+			name = field.Name
+		}
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			// Promote embedded struct fields to the parent's namespace
+			name = ""
+		}
+
+		path := joinPath(prefix, name)
+		rules := splitRules(field.Tag.Get(tagName))
+		validateValue(value.Field(i), path, rules, violations)
+	}
+}
+
+// validateValue applies rules to a value and recurses into nested structs and dived elements
+func validateValue(value reflect.Value, path string, rules []string, violations map[string]interface{}) {
+	for i, rule := range rules {
+		name, param := splitRule(rule)
+		switch name {
+		case "omitempty":
+			if isEmpty(value) {
+				return
+			}
+		case "dive":
+			diveInto(value, path, rules[i+1:], violations)
+			return
+		default:
+			if message, ok := check(value, name, param); !ok {
+				violations[displayPath(path)] = message
+				return
+			}
+		}
+	}
+
+	// Validate nested structs once the field itself is valid
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	if value.Kind() == reflect.Struct && value.Type() != reflect.TypeOf(time.Time{}) {
+		validateStruct(value, path, violations)
+	}
+}
+
+// diveInto applies rules to every element of a slice, array or map
+func diveInto(value reflect.Value, path string, rules []string, violations map[string]interface{}) {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			validateValue(value.Index(i), fmt.Sprintf("%s[%d]", path, i), rules, violations)
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			validateValue(value.MapIndex(key), fmt.Sprintf("%s[%v]", path, key.Interface()), rules, violations)
+		}
+	default:
+		panic(fmt.Sprintf("validator: dive on non-collection field %s", path))
+	}
+}
+
+// check evaluates a single rule and returns the failure message
+func check(value reflect.Value, rule, param string) (string, bool) {
+	if rule == "required" {
+		return "is required", !isEmpty(value)
+	}
+
+	// Remaining rules apply to the value a pointer refers to
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return "", true
+		}
+		value = value.Elem()
+	}
+
+	switch rule {
+	case "min":
+		return compare(value, param, "at least", func(a, b float64) bool { return a >= b })
+	case "max":
+		return compare(value, param, "at most", func(a, b float64) bool { return a <= b })
+	case "len":
+		return compare(value, param, "exactly", func(a, b float64) bool { return a == b })
+	case "gt":
+		return compare(value, param, "greater than", func(a, b float64) bool { return a > b })
+	case "gte":
+		return compare(value, param, "at least", func(a, b float64) bool { return a >= b })
+	case "lt":
+		return compare(value, param, "less than", func(a, b float64) bool { return a < b })
+	case "lte":
+		return compare(value, param, "at most", func(a, b float64) bool { return a <= b })
+	case "oneof":
+		options := strings.Fields(param)
+		actual := fmt.Sprint(value.Interface())
+		for _, option := range options {
+			if actual == option {
+				return "", true
+			}
+		}
+		return "must be one of: " + strings.Join(options, ", "), false
+	case "email":
+		address, err := mail.ParseAddress(value.String())
+		return "must be a valid email address", err == nil && address.Address == value.String()
+	case "url":
+		parsed, err := url.ParseRequestURI(value.String())
+		return "must be a valid URL", err == nil && parsed.Scheme != "" && parsed.Host != ""
+	case "alphanum":
+		return "must contain only letters and digits", isAlphanumeric(value.String())
+	default:
+		panic(fmt.Sprintf("validator: unknown rule %q", rule))
+	}
+}
+
+// compare checks the size of a value against a numeric parameter. Strings,
+// slices and maps are measured by length, numbers by value.
+func compare(value reflect.Value, param, relation string, ok func(actual, limit float64) bool) (string, bool) {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validator: invalid parameter %q", param))
+	}
+
+	var actual float64
+	unit := ""
+	switch value.Kind() {
+	case reflect.String:
+		actual = float64(utf8.RuneCountInString(value.String()))
+		unit = " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		actual = float64(value.Len())
+		unit = " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		actual = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = value.Float()
+	default:
+		panic(fmt.Sprintf("validator: cannot compare %s", value.Kind()))
+	}
+
+	return fmt.Sprintf("must be %s %s%s", relation, param, unit), ok(actual, limit)
+}
+
+// isEmpty reports whether a value is nil or its type's zero value
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map, reflect.Chan, reflect.Func:
+		return value.IsNil()
+	case reflect.Invalid:
+		return true
+	default:
+		return value.IsZero()
+	}
+}
+
+// isAlphanumeric reports whether s consists only of ASCII letters and digits
+func isAlphanumeric(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldName returns the JSON name of a struct field, and false if the field is not encoded
+func fieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name, true
+	}
+	return field.Name, true
+}
+
+// splitRules splits a validate tag into its rules
+func splitRules(tag string) []string {
+	if tag == "" {
+		return nil
+	}
+	return strings.Split(tag, ",")
+}
+
+// splitRule splits a rule such as "min=3" into its name and parameter
+func splitRule(rule string) (string, string) {
+	if i := strings.IndexByte(rule, '='); i >= 0 {
+		return rule[:i], rule[i+1:]
+	}
+	return rule, ""
+}
+
+// joinPath joins a parent path and a field name with a dot
+func joinPath(prefix, name string) string {
+	switch {
+	case prefix == "":
+		return name
+	case name == "":
+		return prefix
+	default:
+		return prefix + "." + name
+	}
+}
+
+// displayPath names the request body itself when a rule applies to the top-level value
+func displayPath(path string) string {
+	if path == "" {
+		return "body"
+	}
+	return path
+}
@@ -0,0 +1,150 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/milad-ahmd/go-clean-arch/internal/domain"
+	"github.com/milad-ahmd/go-clean-arch/pkg/errors"
+)
+
+type testAddress struct {
+	City string `json:"city" validate:"required"`
+	Zip  string `json:"zip"`
+}
+
+type testRequest struct {
+	Name     string            `json:"name" validate:"required,min=3,max=10"`
+	Email    string            `json:"email" validate:"required,email"`
+	Website  string            `json:"website" validate:"omitempty,url"`
+	Code     string            `json:"code" validate:"omitempty,alphanum"`
+	Status   string            `json:"status" validate:"oneof=active inactive"`
+	Count    int               `json:"count" validate:"gte=0,lte=5"`
+	Tags     []string          `json:"tags" validate:"omitempty,max=2,dive,min=2"`
+	Address  testAddress       `json:"address" validate:"required"`
+	Previous *testAddress      `json:"previous" validate:"omitempty"`
+	Items    []testAddress     `json:"items" validate:"required,dive"`
+	Labels   map[string]string `json:"labels" validate:"dive,alphanum"`
+	Internal int64             `json:"-" validate:"required"`
+}
+
+// validRequest returns a request that passes every rule
+func validRequest() testRequest {
+	return testRequest{
+		Name:     "widget",
+		Email:    "jane@example.com",
+		Status:   "active",
+		Count:    3,
+		Address:  testAddress{City: "Berlin", Zip: "10115"},
+		Items:    []testAddress{{City: "Paris"}},
+		Internal: 1,
+	}
+}
+
+// TestValidate tests the supported rules and the reported field names
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(r *testRequest)
+		field  string
+	}{
+		{name: "valid", modify: func(r *testRequest) {}},
+		{name: "required", modify: func(r *testRequest) { r.Name = "" }, field: "name"},
+		{name: "min", modify: func(r *testRequest) { r.Name = "ab" }, field: "name"},
+		{name: "max counts characters", modify: func(r *testRequest) { r.Name = "ääääääääääää" }, field: "name"},
+		{name: "email", modify: func(r *testRequest) { r.Email = "Jane <jane@example.com>" }, field: "email"},
+		{name: "url", modify: func(r *testRequest) { r.Website = "example.com/page" }, field: "website"},
+		{name: "omitempty url", modify: func(r *testRequest) { r.Website = "" }},
+		{name: "alphanum", modify: func(r *testRequest) { r.Code = "abc-123" }, field: "code"},
+		{name: "oneof", modify: func(r *testRequest) { r.Status = "deleted" }, field: "status"},
+		{name: "lte", modify: func(r *testRequest) { r.Count = 6 }, field: "count"},
+		{name: "gte", modify: func(r *testRequest) { r.Count = -1 }, field: "count"},
+		{name: "slice max", modify: func(r *testRequest) { r.Tags = []string{"aa", "bb", "cc"} }, field: "tags"},
+		{name: "dive", modify: func(r *testRequest) { r.Tags = []string{"aa", "b"} }, field: "tags[1]"},
+		{name: "nested struct", modify: func(r *testRequest) { r.Address.City = "" }, field: "address.city"},
+		{name: "nested pointer", modify: func(r *testRequest) { r.Previous = &testAddress{} }, field: "previous.city"},
+		{name: "required slice", modify: func(r *testRequest) { r.Items = nil }, field: "items"},
+		{name: "dive struct", modify: func(r *testRequest) { r.Items = append(r.Items, testAddress{}) }, field: "items[1].city"},
+		{name: "dive map", modify: func(r *testRequest) { r.Labels = map[string]string{"env": "pro-d"} }, field: "labels[env]"},
+		{name: "field hidden from json", modify: func(r *testRequest) { r.Internal = 0 }, field: "Internal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := validRequest()
+			tt.modify(&req)
+
+			err := Validate(&req)
+			if tt.field == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			validationErr, ok := err.(*errors.ValidationError)
+			if !ok {
+				t.Fatalf("Expected *errors.ValidationError, got %v", err)
+			}
+			if len(validationErr.Errors) != 1 {
+				t.Errorf("Expected 1 violation, got %v", validationErr.Errors)
+			}
+			if _, ok := validationErr.Errors[tt.field]; !ok {
+				t.Errorf("Expected violation for %s, got %v", tt.field, validationErr.Errors)
+			}
+		})
+	}
+}
+
+// TestValidate_DomainDTOs tests that every request DTO uses only supported rules
+func TestValidate_DomainDTOs(t *testing.T) {
+	dtos := []interface{}{
+		&domain.LoginRequest{},
+		&domain.RegisterRequest{},
+		&domain.RefreshRequest{},
+		&domain.CategoryCreateDTO{},
+		&domain.CategoryUpdateDTO{},
+		&domain.ProductCreateDTO{Images: []string{"https://example.com/a.png"}},
+		&domain.ProductUpdateDTO{Price: &domain.Money{}, Images: []string{""}},
+		&domain.OrderCreateDTO{Items: []domain.OrderItemCreateDTO{{}}},
+		&domain.OrderUpdateDTO{ShippingInfo: domain.ShippingInfoDTO{Address: "x"}},
+		&domain.OrderStatusUpdateDTO{},
+		&domain.OrderItemCancelDTO{},
+		&domain.RoleCreateDTO{Permissions: []domain.Permission{""}},
+		&domain.RoleUpdateDTO{Permissions: []domain.Permission{""}},
+		&domain.RoleAssignDTO{},
+	}
+
+	for _, dto := range dtos {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("Validate(%T) panicked: %v", dto, r)
+				}
+			}()
+			_ = Validate(dto)
+		}()
+	}
+}
+
+// TestValidate_ProductCreateDTO tests validation of a real request DTO
+func TestValidate_ProductCreateDTO(t *testing.T) {
+	dto := &domain.ProductCreateDTO{
+		Name:       "Desk lamp",
+		Price:      domain.NewMoney(1999, domain.CurrencyUSD),
+		SKU:        "LAMP-01",
+		CategoryID: 1,
+		Images:     []string{"https://example.com/lamp.png", "not a url"},
+	}
+
+	err := Validate(dto)
+	validationErr, ok := err.(*errors.ValidationError)
+	if !ok {
+		t.Fatalf("Expected *errors.ValidationError, got %v", err)
+	}
+	if _, ok := validationErr.Errors["images[1]"]; !ok {
+		t.Errorf("Expected violation for images[1], got %v", validationErr.Errors)
+	}
+	if _, ok := validationErr.Errors["stock"]; ok {
+		t.Errorf("Expected zero stock to be valid, got %v", validationErr.Errors)
+	}
+}